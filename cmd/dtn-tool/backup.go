@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+// runExport is the entry point of the "export" subcommand.
+func runExport(args []string) {
+	if len(args) != 2 {
+		printUsage()
+	}
+
+	f, err := openOutput(args[1])
+	if err != nil {
+		printFatal(err, "Opening archive failed")
+	}
+	defer f.Close()
+
+	if err := withStore(args[0], func(bst *store.BundleStore) error {
+		_, err := bst.Export(f)
+		return err
+	}); err != nil {
+		printFatal(err, "Exporting store failed")
+	}
+}
+
+// runImport is the entry point of the "import" subcommand.
+func runImport(args []string) {
+	if len(args) != 2 {
+		printUsage()
+	}
+
+	f, err := openInput(args[1])
+	if err != nil {
+		printFatal(err, "Opening archive failed")
+	}
+	defer f.Close()
+
+	if err := withStore(args[0], func(bst *store.BundleStore) error {
+		_, err := bst.Import(f)
+		return err
+	}); err != nil {
+		printFatal(err, "Importing store failed")
+	}
+}
+
+// withStore opens the store at the given path for the duration of the function.
+//
+// The store's node ID is irrelevant for exporting and importing, as the archived metadata is kept as it is.
+func withStore(path string, f func(bst *store.BundleStore) error) (err error) {
+	if err = store.InitialiseStore(bpv7.DtnNone(), path); err != nil {
+		return fmt.Errorf("opening store %s failed: %w", path, err)
+	}
+	bst := store.GetStoreSingleton()
+	defer func() {
+		if closeErr := bst.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return f(bst)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// runCreate is the entry point of the "create" subcommand.
+func runCreate(args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	source := flags.String("source", "", "source node ID, e.g., dtn://src/")
+	destination := flags.String("destination", "", "destination endpoint ID, e.g., dtn://dst/app")
+	lifetime := flags.String("lifetime", "24h", "lifetime of the bundle")
+	payload := flags.String("payload", "", "payload as a string")
+	payloadFile := flags.String("payload-file", "", "file to read the payload from, \"-\" for stdin, instead of -payload")
+	output := flags.String("out", "-", "file to write the bundle to or \"-\" for stdout")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		printUsage()
+	}
+
+	data := []byte(*payload)
+	if *payloadFile != "" {
+		var err error
+		if data, err = readPayload(*payloadFile, os.Stdin); err != nil {
+			printFatal(err, "Reading payload failed")
+		}
+	}
+
+	b, err := createBundle(*source, *destination, *lifetime, data)
+	if err != nil {
+		printFatal(err, "Creating bundle failed")
+	}
+
+	f, err := openOutput(*output)
+	if err != nil {
+		printFatal(err, "Opening output failed")
+	}
+	if err := b.WriteBundle(f); err != nil {
+		printFatal(err, "Writing bundle failed")
+	}
+	if err := f.Close(); err != nil {
+		printFatal(err, "Closing output failed")
+	}
+}
+
+// createBundle builds a new bundle, created now, through bpv7.BuildFromMap.
+func createBundle(source, destination, lifetime string, payload []byte) (bpv7.Bundle, error) {
+	if source == "" || destination == "" {
+		return bpv7.Bundle{}, errors.New("both source and destination are required")
+	}
+
+	b, err := bpv7.BuildFromMap(map[string]interface{}{
+		"source":                 source,
+		"destination":            destination,
+		"creation_timestamp_now": true,
+		"lifetime":               lifetime,
+		"payload_block":          payload,
+	})
+	if err != nil {
+		return bpv7.Bundle{}, fmt.Errorf("building bundle failed: %w", err)
+	}
+	return b, nil
+}
+
+// readPayload reads the whole payload from a file or from stdin for "-".
+//
+// The payload is read until EOF, without any line buffering, so that binary and multi-line payloads are kept intact.
+func readPayload(filename string, stdin io.Reader) ([]byte, error) {
+	if filename == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(filename)
+}
+
+// openOutput creates a file for writing or returns stdout for "-".
+func openOutput(filename string) (io.WriteCloser, error) {
+	if filename == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(filename)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestCreateBundle(t *testing.T) {
+	payload := []byte("hello world")
+	b, err := createBundle("dtn://src/", "dtn://dst/app", "30m", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+	if err := b.WriteBundle(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := bpv7.ParseBundle(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if src := parsed.PrimaryBlock.SourceNode; src != bpv7.MustNewEndpointID("dtn://src/") {
+		t.Fatalf("Source is %v", src)
+	}
+	if dst := parsed.PrimaryBlock.Destination; dst != bpv7.MustNewEndpointID("dtn://dst/app") {
+		t.Fatalf("Destination is %v", dst)
+	}
+	if lifetime := parsed.PrimaryBlock.Lifetime; lifetime != uint64((30 * time.Minute).Milliseconds()) {
+		t.Fatalf("Lifetime is %d", lifetime)
+	}
+
+	payloadBlock, err := parsed.PayloadBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := payloadBlock.Value.(*bpv7.PayloadBlock).Data(); !bytes.Equal(data, payload) {
+		t.Fatalf("Payload is %x instead of %x", data, payload)
+	}
+}
+
+func TestCreateBundleInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		lifetime    string
+	}{
+		{"no source", "", "dtn://dst/", "24h"},
+		{"no destination", "dtn://src/", "", "24h"},
+		{"invalid source", "nope", "dtn://dst/", "24h"},
+		{"invalid lifetime", "dtn://src/", "dtn://dst/", "soon"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := createBundle(test.source, test.destination, test.lifetime, nil); err == nil {
+				t.Fatal("Invalid bundle was created")
+			}
+		})
+	}
+}
+
+func TestCreateBundlePayloadStdin(t *testing.T) {
+	payload := []byte("first line\nsecond line\r\n\x00\xff\n\nlast line without newline")
+
+	data, err := readPayload("-", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := createBundle("dtn://src/", "dtn://dst/", "24h", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := payloadBlock.Value.(*bpv7.PayloadBlock).Data(); !bytes.Equal(data, payload) {
+		t.Fatalf("Payload is %q instead of %q", data, payload)
+	}
+}
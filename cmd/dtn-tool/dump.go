@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// runDump is the entry point of the "dump" subcommand.
+func runDump(args []string) {
+	if len(args) != 1 {
+		printUsage()
+	}
+
+	f, err := openInput(args[0])
+	if err != nil {
+		printFatal(err, "Opening input failed")
+	}
+	defer f.Close()
+
+	if err := dumpBundle(f, os.Stdout); err != nil {
+		printFatal(err, "Dumping bundle failed")
+	}
+}
+
+// dumpBundle parses a CBOR encoded bundle and writes an annotated dump of each block.
+//
+// Each block's CRC is validated and reported, continuing with the next block if it is invalid. Truncated or otherwise
+// invalid input results in an error, naming the failed block.
+func dumpBundle(r io.Reader, w io.Writer) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading input failed: %w", err)
+	} else if len(data) == 0 {
+		return errors.New("input is empty")
+	}
+
+	b, blocks, err := parseBundleBlocks(data)
+	if err != nil {
+		return fmt.Errorf("input of %d bytes is no valid bundle: %w", len(data), err)
+	}
+
+	var out strings.Builder
+
+	_, _ = fmt.Fprintf(&out, "Bundle %v, %d bytes\n\n", b.ID(), len(data))
+
+	pb := b.PrimaryBlock
+	_, _ = fmt.Fprintf(&out, "Primary Block\n")
+	_, _ = fmt.Fprintf(&out, "  Version:            %d\n", pb.Version)
+	_, _ = fmt.Fprintf(&out, "  Control Flags:      %#x [%v]\n", uint64(pb.BundleControlFlags), pb.BundleControlFlags)
+	_, _ = fmt.Fprintf(&out, "  CRC:                %s\n", dumpCRC(pb.CRCType, pb.CRC, blocks[0].crcErr))
+	_, _ = fmt.Fprintf(&out, "  Destination:        %v\n", pb.Destination)
+	_, _ = fmt.Fprintf(&out, "  Source Node:        %v\n", pb.SourceNode)
+	_, _ = fmt.Fprintf(&out, "  Report-to:          %v\n", pb.ReportTo)
+	_, _ = fmt.Fprintf(&out, "  Creation Timestamp: %v\n", pb.CreationTimestamp)
+	_, _ = fmt.Fprintf(&out, "  Lifetime:           %d ms\n", pb.Lifetime)
+	if pb.HasFragmentation() {
+		_, _ = fmt.Fprintf(&out, "  Fragment Offset:    %d\n", pb.FragmentOffset)
+		_, _ = fmt.Fprintf(&out, "  Total Data Length:  %d\n", pb.TotalDataLength)
+	}
+	dumpHex(&out, blocks[0].raw)
+
+	for i := range b.CanonicalBlocks {
+		cb := b.CanonicalBlocks[i]
+
+		_, _ = fmt.Fprintf(&out, "\nCanonical Block %d: %s (type code %d)\n",
+			cb.BlockNumber, cb.Value.BlockTypeName(), cb.Value.BlockTypeCode())
+		_, _ = fmt.Fprintf(&out, "  Control Flags:      %#x [%v]\n", uint64(cb.BlockControlFlags), cb.BlockControlFlags)
+		_, _ = fmt.Fprintf(&out, "  CRC:                %s\n", dumpCRC(cb.CRCType, cb.CRC, blocks[i+1].crcErr))
+		if payload, ok := cb.Value.(*bpv7.PayloadBlock); ok {
+			_, _ = fmt.Fprintf(&out, "  Data:               %d bytes\n", len(payload.Data()))
+		} else {
+			_, _ = fmt.Fprintf(&out, "  Data:               %v\n", cb.Value)
+		}
+		dumpHex(&out, blocks[i+1].raw)
+	}
+
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// parsedBlock is a block's CBOR representation as received and the result of its CRC validation.
+type parsedBlock struct {
+	raw []byte
+	// crcErr of an invalid CRC value; nil if the CRC is valid or absent
+	crcErr *bpv7.CRCError
+}
+
+// parseBundleBlocks parses a CBOR encoded bundle like bpv7.ParseBundle, but continues after blocks with an invalid
+// CRC. The parsedBlocks start with the primary block, followed by the canonical blocks.
+func parseBundleBlocks(data []byte) (b bpv7.Bundle, blocks []parsedBlock, err error) {
+	r := bytes.NewReader(data)
+
+	// parseBlock unmarshals the next block, recording its CRC error and passing on all other errors
+	parseBlock := func(block cboring.CborMarshaler) error {
+		start := len(data) - r.Len()
+		err := cboring.Unmarshal(block, r)
+
+		var crcErr *bpv7.CRCError
+		if err != nil && !errors.As(err, &crcErr) {
+			return err
+		}
+		blocks = append(blocks, parsedBlock{raw: data[start : len(data)-r.Len()], crcErr: crcErr})
+		return nil
+	}
+
+	if err = cboring.ReadExpect(cboring.IndefiniteArray, r); err != nil {
+		return
+	}
+
+	if err = parseBlock(&b.PrimaryBlock); err != nil {
+		err = fmt.Errorf("PrimaryBlock failed: %w", err)
+		return
+	}
+
+	for {
+		cb := bpv7.CanonicalBlock{}
+		if cbErr := parseBlock(&cb); cbErr == cboring.FlagBreakCode {
+			break
+		} else if cbErr != nil {
+			err = fmt.Errorf("CanonicalBlock failed: %w", cbErr)
+			return
+		}
+		b.CanonicalBlocks = append(b.CanonicalBlocks, cb)
+	}
+
+	err = b.CheckValid()
+	return
+}
+
+// dumpCRC describes a block's CRC and the result of its validation.
+func dumpCRC(crcType bpv7.CRCType, crc []byte, crcErr *bpv7.CRCError) string {
+	if crcType == bpv7.CRCNo {
+		return "none"
+	} else if crcErr != nil {
+		return fmt.Sprintf("CRC-%v %x (invalid, expected %x)", crcType, crc, crcErr.Expected)
+	}
+	return fmt.Sprintf("CRC-%v %x (valid)", crcType, crc)
+}
+
+// dumpHex writes an indented hex dump of a block's CBOR representation.
+func dumpHex(w io.Writer, raw []byte) {
+	for _, line := range strings.SplitAfter(hex.Dump(raw), "\n") {
+		if line != "" {
+			_, _ = fmt.Fprintf(w, "  %s", line)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func createTestBundle(t *testing.T) []byte {
+	b, err := bpv7.Builder().
+		CRC(bpv7.CRC32).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("30m").
+		HopCountBlock(64).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+	if err := b.WriteBundle(&buff); err != nil {
+		t.Fatal(err)
+	}
+	return buff.Bytes()
+}
+
+func TestDumpBundle(t *testing.T) {
+	data := createTestBundle(t)
+
+	var out bytes.Buffer
+	if err := dumpBundle(bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := out.String()
+	for _, expected := range []string{
+		"Primary Block",
+		"dtn://dst/",
+		"Payload Block (type code 1)",
+		"Hop Count Block (type code 10)",
+		"Data:               11 bytes",
+		"CRC-32",
+		"(valid)",
+		"hello worl",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Fatalf("Dump does not contain %q:\n%s", expected, dump)
+		}
+	}
+}
+
+func TestDumpBundleInvalidCRC(t *testing.T) {
+	data := createTestBundle(t)
+	b, err := bpv7.ParseBundle(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the primary block's CRC, following the bundle's indefinite array start
+	var primary bytes.Buffer
+	if err := cboring.Marshal(&b.PrimaryBlock, &primary); err != nil {
+		t.Fatal(err)
+	}
+	corrupted := bytes.Clone(data)
+	corrupted[primary.Len()] ^= 0xff
+
+	var out bytes.Buffer
+	if err := dumpBundle(bytes.NewReader(corrupted), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The primary block is reported as invalid, while the following blocks are still dumped and validated
+	dump := out.String()
+	primaryDump, canonicalDump, found := strings.Cut(dump, "Canonical Block")
+	if !found {
+		t.Fatalf("Dump stops after the primary block:\n%s", dump)
+	}
+	if !strings.Contains(primaryDump, "(invalid, expected") {
+		t.Fatalf("Primary block's invalid CRC is not reported:\n%s", dump)
+	}
+	for _, expected := range []string{"Payload Block (type code 1)", "Hop Count Block (type code 10)", "(valid)"} {
+		if !strings.Contains(canonicalDump, expected) {
+			t.Fatalf("Dump does not contain %q:\n%s", expected, dump)
+		}
+	}
+	if strings.Contains(canonicalDump, "invalid") {
+		t.Fatalf("Canonical blocks are reported as invalid:\n%s", dump)
+	}
+}
+
+func TestDumpBundleInvalid(t *testing.T) {
+	data := createTestBundle(t)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"garbage", []byte("hello world")},
+		{"truncated", data[:len(data)/2]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := dumpBundle(bytes.NewReader(test.data), &out); err == nil {
+				t.Fatalf("Invalid input was dumped:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// nodeIDKeyBytes is the number of the public key's leading bytes forming a suggested node ID.
+const nodeIDKeyBytes = 8
+
+// keypair is a node's ed25519 identity, hex encoded for its configuration.
+type keypair struct {
+	PrivateKey string
+	PublicKey  string
+	NodeID     bpv7.EndpointID
+}
+
+// runKeygen is the entry point of the "keygen" subcommand.
+func runKeygen(args []string) {
+	if len(args) != 0 {
+		printUsage()
+	}
+
+	kp, err := generateKeypair(rand.Reader)
+	if err != nil {
+		printFatal(err, "Generating keypair failed")
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "signature_private = %q\n", kp.PrivateKey)
+	_, _ = fmt.Fprintf(os.Stdout, "public_key = %q\n", kp.PublicKey)
+	_, _ = fmt.Fprintf(os.Stdout, "node_id = %q\n", kp.NodeID.String())
+}
+
+// generateKeypair creates a new ed25519 keypair from the given randomness and suggests a node ID derived from it.
+func generateKeypair(random io.Reader) (keypair, error) {
+	pub, priv, err := ed25519.GenerateKey(random)
+	if err != nil {
+		return keypair{}, err
+	}
+
+	nodeID, err := bpv7.NewEndpointID(fmt.Sprintf("dtn://%x/", []byte(pub[:nodeIDKeyBytes])))
+	if err != nil {
+		return keypair{}, fmt.Errorf("deriving node ID failed: %w", err)
+	}
+
+	return keypair{
+		PrivateKey: hex.EncodeToString(priv),
+		PublicKey:  hex.EncodeToString(pub),
+		NodeID:     nodeID,
+	}, nil
+}
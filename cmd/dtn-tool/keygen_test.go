@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenerateKeypair(t *testing.T) {
+	kp, err := generateKeypair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privBytes, err := hex.DecodeString(kp.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	} else if l := len(privBytes); l != ed25519.PrivateKeySize {
+		t.Fatalf("Private key's length is %d instead of %d", l, ed25519.PrivateKeySize)
+	}
+	priv := ed25519.PrivateKey(privBytes)
+
+	pub, err := hex.DecodeString(kp.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	} else if !priv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(pub)) {
+		t.Fatalf("Public key %s does not belong to the private key", kp.PublicKey)
+	}
+
+	msg := []byte("hello world")
+	if !ed25519.Verify(pub, msg, ed25519.Sign(priv, msg)) {
+		t.Fatal("Signature of the generated key cannot be verified")
+	}
+
+	if nodeID := kp.NodeID.String(); nodeID != "dtn://"+kp.PublicKey[:2*nodeIDKeyBytes]+"/" {
+		t.Fatalf("Node ID %s is not derived from the public key", nodeID)
+	}
+}
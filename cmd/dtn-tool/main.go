@@ -0,0 +1,81 @@
+// dtn-tool is a collection of utilities to work with bundles without a running dtnd.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// printUsage of dtn-tool and exit with an error code afterwards.
+func printUsage() {
+	_, _ = fmt.Fprintf(os.Stderr, "Usage of %s:\n\n", os.Args[0])
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s create -source EID -destination EID [-lifetime DURATION]\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "    [-payload STRING|-payload-file -|FILENAME] [-out -|FILENAME]\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  Creates a new bundle and writes it CBOR encoded to a file or to stdout.\n\n")
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s dump -|FILENAME\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "  Prints an annotated hex dump of each block of a CBOR encoded bundle.\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  The bundle is read from a file or from stdin for \"-\".\n\n")
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s export STORE -|ARCHIVE\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "  Writes all bundles of a store and their metadata to an archive file or to stdout.\n\n")
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s import STORE -|ARCHIVE\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "  Restores the bundles of an archive, read from a file or from stdin, into a store.\n\n")
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s keygen\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "  Generates an ed25519 keypair and prints its hex encoded private and public key as well\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  as a node ID derived from the public key.\n\n")
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s replay -address ADDRESS [-cla MTCP|QUICL] [-rate BUNDLES_PER_SECOND]\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "    [-dir DIRECTORY | -count N -size BYTES -source EID -destination EID]\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  Injects the bundles of a directory or random bundles into a node for load testing and\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  reports the throughput and error rate.\n\n")
+
+	os.Exit(1)
+}
+
+// printFatal prints an error and exits with an error code afterwards.
+func printFatal(err error, msg string) {
+	_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	os.Exit(1)
+}
+
+// openInput opens a file for reading or returns stdin for "-".
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(filename)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+	}
+
+	switch os.Args[1] {
+	case "create":
+		runCreate(os.Args[2:])
+
+	case "dump":
+		runDump(os.Args[2:])
+
+	case "export":
+		runExport(os.Args[2:])
+
+	case "import":
+		runImport(os.Args[2:])
+
+	case "keygen":
+		runKeygen(os.Args[2:])
+
+	case "replay":
+		runReplay(os.Args[2:])
+
+	default:
+		printUsage()
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	_ "github.com/dtn7/dtn7-go/pkg/cla/mtcp"
+	_ "github.com/dtn7/dtn7-go/pkg/cla/quicl"
+)
+
+// runReplay is the entry point of the "replay" subcommand.
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	claTypeStr := flags.String("cla", "MTCP", "CLA to inject the bundles over, MTCP or QUICL")
+	address := flags.String("address", "", "address of the node's listener, e.g., localhost:35037")
+	dir := flags.String("dir", "", "directory of CBOR encoded bundles to replay instead of random bundles")
+	count := flags.Int("count", 100, "number of random bundles")
+	size := flags.Int("size", 1024, "payload size of random bundles in bytes")
+	source := flags.String("source", "dtn://dtn-tool/", "source node ID of random bundles")
+	destination := flags.String("destination", "dtn://sink/", "destination endpoint ID of random bundles")
+	rate := flags.Float64("rate", 0, "bundles sent per second, unlimited if zero")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 0 || *address == "" {
+		printUsage()
+	}
+
+	claType, err := cla.TypeFromString(*claTypeStr)
+	if err != nil {
+		printFatal(err, "Parsing CLA type failed")
+	}
+
+	var bundles []bpv7.Bundle
+	if *dir != "" {
+		bundles, err = loadBundles(*dir)
+	} else {
+		bundles, err = randomBundles(rand.Reader, *count, *size, *source, *destination)
+	}
+	if err != nil {
+		printFatal(err, "Preparing bundles failed")
+	}
+
+	// The CLA clients report their connection state to the Manager, which is not needed otherwise
+	err = cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		printFatal(err, "Initialising CLA manager failed")
+	}
+
+	sender, err := dialSender(claType, *address)
+	if err != nil {
+		printFatal(err, "Connecting to node failed")
+	}
+	defer func() { _ = sender.Close() }()
+
+	result := replayBundles(sender, bundles, *rate)
+	fmt.Println(result)
+}
+
+// loadBundles parses each file of the directory as a CBOR encoded bundle, ordered by the files' names.
+func loadBundles(dir string) ([]bpv7.Bundle, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var bundles []bpv7.Bundle
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		b, err := loadBundle(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, b)
+	}
+
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("%s contains no bundles", dir)
+	}
+	return bundles, nil
+}
+
+// loadBundle parses a single file as a CBOR encoded bundle.
+func loadBundle(filename string) (bpv7.Bundle, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return bpv7.Bundle{}, err
+	}
+	defer f.Close()
+
+	b, err := bpv7.ParseBundle(f)
+	if err != nil {
+		return bpv7.Bundle{}, fmt.Errorf("parsing %s failed: %w", filename, err)
+	}
+	return b, nil
+}
+
+// randomBundles creates bundles with payloads of random bytes, created now with distinct sequence numbers.
+func randomBundles(random io.Reader, count, size int, source, destination string) ([]bpv7.Bundle, error) {
+	if count <= 0 || size < 0 {
+		return nil, errors.New("count must be positive and size must not be negative")
+	}
+
+	bundles := make([]bpv7.Bundle, 0, count)
+	for i := 0; i < count; i++ {
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(random, payload); err != nil {
+			return nil, err
+		}
+
+		b, err := bpv7.Builder().
+			Source(source).
+			Destination(destination).
+			CreationTimestampNow().
+			Lifetime("24h").
+			PayloadBlock(payload).
+			Build()
+		if err != nil {
+			return nil, fmt.Errorf("building bundle failed: %w", err)
+		}
+		// Bundles created within the same millisecond are distinguished by their sequence number
+		b.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(b.PrimaryBlock.CreationTimestamp.DtnTime(), uint64(i))
+		bundles = append(bundles, b)
+	}
+	return bundles, nil
+}
+
+// dialSender connects to a node's listener through the CLA's client. The CLA Manager must be initialised.
+func dialSender(claType cla.CLAType, address string) (cla.ConvergenceSender, error) {
+	peer, err := cla.NewPeer(claType, address, bpv7.MustNewEndpointID("dtn://dtn-tool/"), bpv7.DtnNone(),
+		func(*bpv7.Bundle) {})
+	if err != nil {
+		return nil, err
+	}
+
+	sender, ok := peer.(cla.ConvergenceSender)
+	if !ok {
+		return nil, fmt.Errorf("%v CLA cannot send bundles", claType)
+	}
+	if err := sender.Activate(); err != nil {
+		return nil, err
+	}
+	return sender, nil
+}
+
+// replayResult summarises the bundles sent by replayBundles.
+type replayResult struct {
+	Sent     int
+	Failed   int
+	Bytes    int
+	Duration time.Duration
+}
+
+func (result replayResult) String() string {
+	seconds := result.Duration.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+
+	total := result.Sent + result.Failed
+	errorRate := 0.0
+	if total > 0 {
+		errorRate = 100 * float64(result.Failed) / float64(total)
+	}
+
+	return fmt.Sprintf("sent %d of %d bundles in %v: %.1f bundles/s, %.1f KiB/s of payload, %.1f%% errors",
+		result.Sent, total, result.Duration.Round(time.Millisecond),
+		float64(result.Sent)/seconds, float64(result.Bytes)/1024/seconds, errorRate)
+}
+
+// replayBundles sends all bundles one after another, pacing them to the rate of bundles per second if positive.
+func replayBundles(sender cla.ConvergenceSender, bundles []bpv7.Bundle, rate float64) (result replayResult) {
+	var ticker *time.Ticker
+	if rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+	}
+
+	start := time.Now()
+	for i, b := range bundles {
+		if ticker != nil && i > 0 {
+			<-ticker.C
+		}
+
+		if err := sender.Send(b); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Sending bundle %v failed: %v\n", b.ID(), err)
+			result.Failed++
+			continue
+		}
+
+		result.Sent++
+		if payload, err := b.PayloadBlock(); err == nil {
+			result.Bytes += len(payload.Value.(*bpv7.PayloadBlock).Data())
+		}
+	}
+	result.Duration = time.Since(start)
+	return
+}
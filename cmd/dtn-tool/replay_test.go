@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+// startSink starts an MTCP listener on a free local port, counting all received bundles.
+func startSink(t *testing.T) (address string, received *atomic.Int64) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address = ln.Addr().String()
+	_ = ln.Close()
+
+	received = new(atomic.Int64)
+	listener, err := cla.NewListener(
+		cla.ListenerConfig{Type: cla.MTCP, Address: address, EndpointId: bpv7.MustNewEndpointID("dtn://sink/")},
+		func(*bpv7.Bundle) { received.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := listener.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	return address, received
+}
+
+func TestReplay(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	var initErr *util.AlreadyInitialised
+	if err != nil && !errors.As(err, &initErr) {
+		t.Fatal(err)
+	}
+
+	const count = 20
+	bundles, err := randomBundles(bytes.NewReader(make([]byte, count*64)), count, 64, "dtn://src/", "dtn://sink/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundles[0].ID() == bundles[1].ID() {
+		t.Fatal("Random bundles share the same ID")
+	}
+
+	// Replay the random bundles from a directory as well
+	dir := t.TempDir()
+	for i, b := range bundles {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%03d.cbor", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.WriteBundle(f); err != nil {
+			t.Fatal(err)
+		}
+		_ = f.Close()
+	}
+	loaded, err := loadBundles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != count || loaded[count-1].ID() != bundles[count-1].ID() {
+		t.Fatalf("Loaded %d bundles instead of the %d written ones", len(loaded), count)
+	}
+
+	address, received := startSink(t)
+	sender, err := dialSender(cla.MTCP, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sender.Close() }()
+
+	result := replayBundles(sender, append(bundles, loaded...), 1000)
+	if result.Sent != 2*count || result.Failed != 0 || result.Bytes != 2*count*64 {
+		t.Fatalf("Unexpected result: %v", result)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); received.Load() != 2*count; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sink received %d bundles instead of %d", received.Load(), 2*count)
+		}
+	}
+}
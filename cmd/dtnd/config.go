@@ -1,17 +1,25 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/dtn7/dtn7-go/pkg/application_agent"
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/quicl"
 	"github.com/dtn7/dtn7-go/pkg/discovery"
+	"github.com/dtn7/dtn7-go/pkg/processing"
 	"github.com/dtn7/dtn7-go/pkg/routing"
 )
 
@@ -31,61 +39,264 @@ func (e *ConfigError) Error() string {
 func (e *ConfigError) Unwrap() error { return e.cause }
 
 type config struct {
-	NodeID    bpv7.EndpointID
-	LogLevel  log.Level
-	Store     storeConfig
-	Routing   routingConfig
-	Listener  []cla.ListenerConfig
-	Agents    agentsConfig
-	Discovery []discovery.Announcement
-	Cron      cronConfig
+	NodeID   bpv7.EndpointID
+	LogLevel log.Level
+	// LogModules overrides LogLevel for single modules, e.g., "cla/quicl"
+	LogModules map[string]log.Level
+	// LogPayloadSize of bundles in log messages; their payloads' contents are never logged
+	LogPayloadSize bool
+	// ClockSkewTolerance between the clocks of bundles' creators and this node
+	ClockSkewTolerance time.Duration
+	// QUICLReceiveStreamLimit of concurrently handled incoming streams per QUICL connection
+	QUICLReceiveStreamLimit int64
+	Store                   storeConfig
+	Routing                 routingConfig
+	Listener                []cla.ListenerConfig
+	Agents                  agentsConfig
+	Discovery               discoveryConfig
+	Cron                    cronConfig
 }
 
 type tomlConfig struct {
-	NodeID   string `toml:"node_id"`
-	LogLevel string `toml:"log_level"`
-	Store    storeConfig
-	Routing  tomlRoutingConfig
-	Listener []listenerTomlConfig
-	Agents   agentsConfig
-	Cron     cronTomlConfig
+	NodeID             string `toml:"node_id"`
+	LogLevel           string `toml:"log_level"`
+	LogModules         map[string]string
+	LogPayloadSize     bool   `toml:"log_payload_size"`
+	ClockSkewTolerance string `toml:"clock_skew_tolerance"`
+	QUICLStreamLimit   int64  `toml:"quicl_receive_stream_limit"`
+	Store              tomlStoreConfig
+	Routing            tomlRoutingConfig
+	Listener           []listenerTomlConfig
+	Agents             tomlAgentsConfig
+	Discovery          discoveryTomlConfig
+	Cron               cronTomlConfig
+}
+
+type tomlStoreConfig struct {
+	Path        string
+	Compress    bool
+	TempDir     string `toml:"temp_dir"`
+	GracePeriod string `toml:"grace_period"`
 }
 
 type storeConfig struct {
 	Path string
+	// Compress newly stored bundles on disk
+	Compress bool
+	// TempDir to write bundles to before moving them into the store; the store's "tmp" subdirectory if empty
+	TempDir string
+	// GracePeriod after their expiry until bundles are deleted
+	GracePeriod time.Duration
 }
 
 type tomlRoutingConfig struct {
-	Algorithm string
+	Algorithm   string
+	Deny        []tomlForwardingRuleConfig
+	Accept      tomlAcceptConfig
+	Egress      []tomlEgressConfig
+	MaxCopies   int    `toml:"max_copies"`
+	SendTimeout string `toml:"send_timeout"`
+	SendQueue   int    `toml:"send_queue"`
+	// MaxForwardingAttempts after which an undelivered bundle is dropped
+	MaxForwardingAttempts int      `toml:"max_forwarding_attempts"`
+	ReportGiveUp          bool     `toml:"report_give_up"`
+	DispatchBackoff       string   `toml:"dispatch_backoff"`
+	MaxDispatchBackoff    string   `toml:"max_dispatch_backoff"`
+	HandoffPeers          []string `toml:"handoff_peers"`
+	MinCRCType            string   `toml:"min_crc_type"`
+}
+
+// tomlEgressConfig restricts the bundles sent over a CLA type or to a peer node, see processing.SetEgressPolicy and
+// processing.SetPeerEgressPolicy. Exactly one of CLA and Peer must be set.
+type tomlEgressConfig struct {
+	CLA     string
+	Peer    string
+	MaxSize int `toml:"max_size"`
+}
+
+// tomlAcceptConfig describes a processing.IngressPolicy by regular expressions.
+type tomlAcceptConfig struct {
+	Source         []string
+	Destination    []string
+	ReportDeletion bool `toml:"report_deletion"`
+}
+
+// tomlForwardingRuleConfig describes a routing.ForwardingRule, denying to forward matching bundles.
+type tomlForwardingRuleConfig struct {
+	Source      string
+	Destination string
+	CLA         []string
 }
 
 type routingConfig struct {
 	Algorithm routing.AlgorithmEnum
+	Filter    *routing.ForwardingFilter
+	// Accept restricts the received bundles; all are accepted if nil
+	Accept *processing.IngressPolicy
+	// Egress predicates restricting the bundles sent over CLAs of a type
+	Egress map[cla.CLAType][]processing.EgressPredicate
+	// PeerEgress predicates restricting the bundles sent to peer nodes
+	PeerEgress []peerEgressConfig
+	// MaxCopies of a bundle per dispatch for the recently_active algorithm; unlimited if zero
+	MaxCopies int
+	// SendTimeout after which a stalled transmission to a peer is abandoned, and the CLA considers its peer gone;
+	// unlimited if zero
+	SendTimeout time.Duration
+	// SendQueue capacity of each sender, sending administrative records first, see cla.Manager.SetSendQueue; no queue
+	// if zero
+	SendQueue int
+	// MaxForwardingAttempts of a bundle before it is dropped; unlimited if zero
+	MaxForwardingAttempts int
+	// ReportGiveUp sends deletion status reports for bundles dropped after their last forwarding attempt
+	ReportGiveUp bool
+	// DispatchBackoff after a bundle's forwarding attempt, doubled for each further attempt up to MaxDispatchBackoff,
+	// see processing.SetDispatchBackoff; retried in each dispatch cycle if zero
+	DispatchBackoff    time.Duration
+	MaxDispatchBackoff time.Duration
+	// HandoffPeers acknowledging the bundles forwarded to them, see processing.SetHandoffPeers
+	HandoffPeers []bpv7.EndpointID
+	// MinCRCType of the blocks of forwarded bundles, see processing.SetMinimumCRCType
+	MinCRCType bpv7.CRCType
+}
+
+// peerEgressConfig restricts the bundles sent to a peer node, see processing.SetPeerEgressPolicy.
+type peerEgressConfig struct {
+	Peer       bpv7.EndpointID
+	Predicates []processing.EgressPredicate
+}
+
+// appendPeerEgress adds a predicate to the peer node's egress policy, merging multiple entries for the same node.
+func appendPeerEgress(policies []peerEgressConfig, peer bpv7.EndpointID,
+	predicate processing.EgressPredicate) []peerEgressConfig {
+	for i := range policies {
+		if policies[i].Peer.SameNode(peer) {
+			policies[i].Predicates = append(policies[i].Predicates, predicate)
+			return policies
+		}
+	}
+	return append(policies, peerEgressConfig{Peer: peer, Predicates: []processing.EgressPredicate{predicate}})
 }
 
 type listenerTomlConfig struct {
-	Type    string
+	Type string
+	// Address to bind the listener to
 	Address string
+	// Addresses to bind additional listeners of the same type to, e.g., on multi-homed hosts
+	Addresses []string
+	// AdvertisedPort is announced by the peer discovery instead of Address' port, e.g., for port-mapping
+	AdvertisedPort uint `toml:"advertised_port"`
+	// MaxConnections limits the concurrent connections of an MTCP listener; unlimited if zero
+	MaxConnections int `toml:"max_connections"`
+	// ReadTimeout disconnects peers of an MTCP listener being silent for longer; disabled if unset
+	ReadTimeout string `toml:"read_timeout"`
 }
 
+type discoveryConfig struct {
+	Announcements []discovery.Announcement
+	// Mode selects whether this node is announced, discovered peers are dialed, or both.
+	Mode discovery.Mode
+	// Dial restricts the CLA types of discovered peers to be connected to; all are allowed if empty.
+	Dial []cla.CLAType
+	// Prefer ranks the CLA types of peers announcing multiple ones, most preferred first.
+	Prefer []cla.CLAType
+	// PeerTimeout after which a silent peer is considered gone; disabled if zero.
+	PeerTimeout time.Duration
+	// Timing of this node's announcements.
+	Timing discovery.AnnouncementTiming
+	// Workers handling received announcements concurrently; discovery.DefaultHandlerWorkers if zero.
+	Workers int
+}
+
+type discoveryTomlConfig struct {
+	Mode        string
+	Dial        []string
+	Prefer      []string
+	PeerTimeout string `toml:"peer_timeout"`
+	Interval    string
+	MinInterval string `toml:"min_interval"`
+	Jitter      string
+	Workers     int
+}
+
+// defaultAnnouncementInterval is used if the discovery's interval is not configured.
+const defaultAnnouncementInterval = 2 * time.Second
+
 // agentsConfig describes the ApplicationAgents/Agent-configuration block.
 type agentsConfig struct {
-	REST agentsRESTConfig
+	// MaxLifetime of bundles sent by agents, longer lifetimes are clamped; unlimited if zero
+	MaxLifetime time.Duration
+	// DefaultSource of anonymous bundles sent by agents; the node ID unless configured
+	DefaultSource bpv7.EndpointID
+	// SigningKey signs bundles sent by agents; disabled if nil
+	SigningKey ed25519.PrivateKey
+	// Ping endpoint answering bundles with an echo; disabled if zero-valued
+	Ping       bpv7.EndpointID
+	REST       agentsRESTConfig
+	DeadLetter deadLetterConfig
+	// Registration restricts the endpoints agents' clients may register for
+	Registration *application_agent.RegistrationPolicy
+}
+
+type tomlAgentsConfig struct {
+	MaxLifetime      string `toml:"max_lifetime"`
+	DefaultSource    string `toml:"default_source"`
+	SignaturePrivate string `toml:"signature_private"`
+	Ping             string
+	REST             agentsRESTConfig
+	DeadLetter       tomlDeadLetterConfig
+	Registration     tomlRegistrationConfig
+}
+
+// tomlRegistrationConfig describes an application_agent.RegistrationPolicy by regular expressions.
+type tomlRegistrationConfig struct {
+	Allow []string
+	Deny  []string
 }
 
 // agentsWebserverConfig describes the nested "Webserver" configuration for agents.
 type agentsRESTConfig struct {
 	Address string
+	// Token to be required as a bearer token by all requests; no authorization if empty
+	Token string
+	// Admin enables the administration of peers, see application_agent.RestAgent.ServePeers; requires a Token
+	Admin bool
+}
+
+// deadLetterConfig describes the mailbox for expired bundles, see application_agent.DeadLetterMailbox.
+type deadLetterConfig struct {
+	Enabled bool
+	// Collect expired bundles of these nodes in addition to this node's own bundles
+	Collect []bpv7.EndpointID
+	// Capacity is the maximum number of bundles retained; the oldest are dropped first
+	Capacity int
+}
+
+type tomlDeadLetterConfig struct {
+	Enabled  bool
+	Collect  []string
+	Capacity int
 }
 
+// defaultDeadLetterCapacity is used as the dead-letter mailbox's capacity if none is configured.
+const defaultDeadLetterCapacity = 100
+
 type cronConfig struct {
 	Dispatch time.Duration
+	// GarbageCollection period after which expired bundles are deleted
+	GarbageCollection time.Duration
+	// Scrub period for verifying the stored bundles' integrity; disabled if zero
+	Scrub time.Duration
 }
 
 type cronTomlConfig struct {
-	Dispatch string
+	Dispatch          string
+	GarbageCollection string `toml:"garbage_collection"`
+	Scrub             string
 }
 
+// defaultGarbageCollection is used as the garbage collection period if none is configured.
+const defaultGarbageCollection = time.Minute
+
 func parseListenPort(endpoint string) (port int, err error) {
 	var portStr string
 	_, portStr, err = net.SplitHostPort(endpoint)
@@ -96,6 +307,18 @@ func parseListenPort(endpoint string) (port int, err error) {
 	return
 }
 
+// parseListenIP returns the IP address an endpoint is bound to, or an empty string for all addresses or a hostname.
+func parseListenIP(endpoint string) string {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return host
+}
+
 func parse(filename string) (config, error) {
 	var tomlConf tomlConfig
 	if _, err := toml.DecodeFile(filename, &tomlConf); err != nil {
@@ -120,15 +343,193 @@ func parse(filename string) (config, error) {
 	}
 	conf.LogLevel = logLevel
 
-	// Store configuration needs no parsing
-	conf.Store = tomlConf.Store
+	conf.LogModules = make(map[string]log.Level, len(tomlConf.LogModules))
+	for module, levelStr := range tomlConf.LogModules {
+		moduleLevel, err := log.ParseLevel(levelStr)
+		if err != nil {
+			return config{}, NewConfigError(fmt.Sprintf("Error parsing log level of module %s", module), err)
+		}
+		conf.LogModules[module] = moduleLevel
+	}
+	conf.LogPayloadSize = tomlConf.LogPayloadSize
+
+	conf.ClockSkewTolerance = bpv7.DefaultClockSkewTolerance
+	if tomlConf.ClockSkewTolerance != "" {
+		tolerance, err := time.ParseDuration(tomlConf.ClockSkewTolerance)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing clock skew tolerance", err)
+		} else if tolerance < 0 {
+			return config{}, NewConfigError("Error parsing clock skew tolerance",
+				fmt.Errorf("%v is negative", tolerance))
+		}
+		conf.ClockSkewTolerance = tolerance
+	}
+
+	conf.QUICLReceiveStreamLimit = quicl.DefaultReceiveStreamLimit
+	if tomlConf.QUICLStreamLimit < 0 {
+		return config{}, NewConfigError("Error parsing QUICL receive stream limit",
+			fmt.Errorf("%d is negative", tomlConf.QUICLStreamLimit))
+	} else if tomlConf.QUICLStreamLimit > 0 {
+		conf.QUICLReceiveStreamLimit = tomlConf.QUICLStreamLimit
+	}
+
+	conf.Store = storeConfig{
+		Path:     tomlConf.Store.Path,
+		Compress: tomlConf.Store.Compress,
+		TempDir:  tomlConf.Store.TempDir,
+	}
+	if tomlConf.Store.GracePeriod != "" {
+		grace, err := time.ParseDuration(tomlConf.Store.GracePeriod)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing store grace period", err)
+		} else if grace < 0 {
+			return config{}, NewConfigError("Error parsing store grace period", fmt.Errorf("%v is negative", grace))
+		}
+		conf.Store.GracePeriod = grace
+	}
 
 	// Parse routing configuration
 	algorithm, err := routing.AlgorithmEnumFromString(tomlConf.Routing.Algorithm)
 	if err != nil {
 		return config{}, NewConfigError("Error parsing routing Algorithm", err)
 	}
-	conf.Routing = routingConfig{Algorithm: algorithm}
+	conf.Routing = routingConfig{
+		Algorithm:   algorithm,
+		SendTimeout: processing.DefaultSendTimeout,
+	}
+
+	if tomlConf.Routing.MaxCopies < 0 {
+		return config{}, NewConfigError("Error parsing routing max copies",
+			fmt.Errorf("%d is negative", tomlConf.Routing.MaxCopies))
+	}
+	conf.Routing.MaxCopies = tomlConf.Routing.MaxCopies
+
+	if tomlConf.Routing.SendQueue < 0 {
+		return config{}, NewConfigError("Error parsing routing send queue",
+			fmt.Errorf("%d is negative", tomlConf.Routing.SendQueue))
+	}
+	conf.Routing.SendQueue = tomlConf.Routing.SendQueue
+
+	if tomlConf.Routing.MaxForwardingAttempts < 0 {
+		return config{}, NewConfigError("Error parsing routing max forwarding attempts",
+			fmt.Errorf("%d is negative", tomlConf.Routing.MaxForwardingAttempts))
+	}
+	conf.Routing.MaxForwardingAttempts = tomlConf.Routing.MaxForwardingAttempts
+	conf.Routing.ReportGiveUp = tomlConf.Routing.ReportGiveUp
+
+	if tomlConf.Routing.DispatchBackoff != "" {
+		backoff, err := time.ParseDuration(tomlConf.Routing.DispatchBackoff)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing dispatch backoff", err)
+		} else if backoff < 0 {
+			return config{}, NewConfigError("Error parsing routing dispatch backoff",
+				fmt.Errorf("%v is negative", backoff))
+		}
+		conf.Routing.DispatchBackoff = backoff
+	}
+	if tomlConf.Routing.MaxDispatchBackoff != "" {
+		maxBackoff, err := time.ParseDuration(tomlConf.Routing.MaxDispatchBackoff)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing max dispatch backoff", err)
+		} else if maxBackoff < 0 {
+			return config{}, NewConfigError("Error parsing routing max dispatch backoff",
+				fmt.Errorf("%v is negative", maxBackoff))
+		}
+		conf.Routing.MaxDispatchBackoff = maxBackoff
+	}
+
+	for _, peerStr := range tomlConf.Routing.HandoffPeers {
+		peer, err := bpv7.NewEndpointID(peerStr)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing handoff peer", err)
+		}
+		conf.Routing.HandoffPeers = append(conf.Routing.HandoffPeers, peer)
+	}
+
+	switch strings.ToLower(tomlConf.Routing.MinCRCType) {
+	case "", "none":
+		conf.Routing.MinCRCType = bpv7.CRCNo
+	case "crc16":
+		conf.Routing.MinCRCType = bpv7.CRC16
+	case "crc32":
+		conf.Routing.MinCRCType = bpv7.CRC32
+	default:
+		return config{}, NewConfigError("Error parsing routing min CRC type",
+			fmt.Errorf("%s is neither none, crc16, nor crc32", tomlConf.Routing.MinCRCType))
+	}
+
+	if tomlConf.Routing.SendTimeout != "" {
+		sendTimeout, err := time.ParseDuration(tomlConf.Routing.SendTimeout)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing send timeout", err)
+		} else if sendTimeout < 0 {
+			return config{}, NewConfigError("Error parsing routing send timeout",
+				fmt.Errorf("%v is negative", sendTimeout))
+		}
+		conf.Routing.SendTimeout = sendTimeout
+	}
+
+	if len(tomlConf.Routing.Deny) > 0 {
+		rules := make([]routing.ForwardingRule, 0, len(tomlConf.Routing.Deny))
+		for _, deny := range tomlConf.Routing.Deny {
+			rule := routing.ForwardingRule{Source: deny.Source, Destination: deny.Destination}
+			for _, claTypeStr := range deny.CLA {
+				claType, err := cla.TypeFromString(claTypeStr)
+				if err != nil {
+					return config{}, NewConfigError("Error parsing routing Deny CLA type", err)
+				}
+				rule.CLATypes = append(rule.CLATypes, claType)
+			}
+			rules = append(rules, rule)
+		}
+
+		filter, err := routing.NewForwardingFilter(rules)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing Deny rules", err)
+		}
+		conf.Routing.Filter = filter
+	}
+
+	if accept := tomlConf.Routing.Accept; len(accept.Source) > 0 || len(accept.Destination) > 0 {
+		policy, err := processing.NewIngressPolicy(accept.Source, accept.Destination, accept.ReportDeletion)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing Accept policy", err)
+		}
+		conf.Routing.Accept = policy
+	}
+
+	for _, egress := range tomlConf.Routing.Egress {
+		if (egress.CLA == "") == (egress.Peer == "") {
+			return config{}, NewConfigError("Error parsing routing Egress",
+				errors.New("exactly one of cla and peer must be set"))
+		}
+		if egress.MaxSize <= 0 {
+			return config{}, NewConfigError("Error parsing routing Egress max size",
+				fmt.Errorf("%d is not positive", egress.MaxSize))
+		}
+		predicate := processing.MaxBundleSize(egress.MaxSize)
+
+		if egress.Peer != "" {
+			peer, err := bpv7.NewEndpointID(egress.Peer)
+			if err != nil {
+				return config{}, NewConfigError("Error parsing routing Egress peer", err)
+			} else if peer == bpv7.DtnNone() {
+				return config{}, NewConfigError("Error parsing routing Egress peer",
+					fmt.Errorf("%v is no peer node", peer))
+			}
+			conf.Routing.PeerEgress = appendPeerEgress(conf.Routing.PeerEgress, peer, predicate)
+			continue
+		}
+
+		claType, err := cla.TypeFromString(egress.CLA)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing routing Egress CLA type", err)
+		}
+		if conf.Routing.Egress == nil {
+			conf.Routing.Egress = make(map[cla.CLAType][]processing.EgressPredicate)
+		}
+		conf.Routing.Egress[claType] = append(conf.Routing.Egress[claType], predicate)
+	}
 
 	// Parse listener configuration
 	for _, listener := range tomlConf.Listener {
@@ -136,17 +537,196 @@ func parse(filename string) (config, error) {
 		if err != nil {
 			return config{}, NewConfigError("Error parsing Listener Type", err)
 		}
-		conf.Listener = append(conf.Listener, cla.ListenerConfig{Type: claType, Address: listener.Address, EndpointId: nodeID})
 
-		port, err := parseListenPort(listener.Address)
+		addresses := listener.Addresses
+		if listener.Address != "" {
+			addresses = append([]string{listener.Address}, addresses...)
+		}
+		if len(addresses) == 0 {
+			return config{}, NewConfigError("Error parsing Listener addresses",
+				fmt.Errorf("%v listener has no address", claType))
+		}
+		if listener.MaxConnections < 0 {
+			return config{}, NewConfigError("Error parsing Listener max connections",
+				fmt.Errorf("%d is negative", listener.MaxConnections))
+		} else if listener.MaxConnections > 0 && claType != cla.MTCP {
+			return config{}, NewConfigError("Error parsing Listener max connections",
+				fmt.Errorf("%v listeners do not support a connection limit", claType))
+		}
+		var readTimeout time.Duration
+		if listener.ReadTimeout != "" {
+			if readTimeout, err = time.ParseDuration(listener.ReadTimeout); err != nil {
+				return config{}, NewConfigError("Error parsing Listener read timeout", err)
+			} else if readTimeout < 0 {
+				return config{}, NewConfigError("Error parsing Listener read timeout",
+					fmt.Errorf("%v is negative", readTimeout))
+			} else if claType != cla.MTCP {
+				return config{}, NewConfigError("Error parsing Listener read timeout",
+					fmt.Errorf("%v listeners do not support a read timeout", claType))
+			}
+		}
+
+		for _, address := range addresses {
+			conf.Listener = append(conf.Listener, cla.ListenerConfig{
+				Type:           claType,
+				Address:        address,
+				EndpointId:     nodeID,
+				MaxConnections: listener.MaxConnections,
+				ReadTimeout:    readTimeout,
+			})
+
+			// Loopback listeners are only reachable from within this process and cannot be announced
+			if claType == cla.Loopback {
+				continue
+			}
+
+			announcement := discovery.Announcement{Type: claType, Port: listener.AdvertisedPort, Endpoint: nodeID}
+			if announcement.Port == 0 {
+				listenPort, err := parseListenPort(address)
+				if err != nil {
+					return config{}, NewConfigError("Error parsing listener port", err)
+				}
+				announcement.Port = uint(listenPort)
+
+				// A listener bound to one interface's address is announced with it, so peers reaching this node via
+				// another interface do not dial it there. Peers learn other listeners' address from the origin.
+				announcement.Address = parseListenIP(address)
+			} else if announcement.Port > 65535 {
+				return config{}, NewConfigError("Error parsing advertised port",
+					fmt.Errorf("%d is not a valid port", announcement.Port))
+			}
+
+			if !slices.Contains(conf.Discovery.Announcements, announcement) {
+				conf.Discovery.Announcements = append(conf.Discovery.Announcements, announcement)
+			}
+		}
+	}
+
+	// Parse discovery configuration
+	if tomlConf.Discovery.Mode != "" {
+		mode, err := discovery.ModeFromString(tomlConf.Discovery.Mode)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Discovery mode", err)
+		}
+		conf.Discovery.Mode = mode
+	}
+	for _, dialType := range tomlConf.Discovery.Dial {
+		claType, err := cla.TypeFromString(dialType)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Discovery dial type", err)
+		}
+		conf.Discovery.Dial = append(conf.Discovery.Dial, claType)
+	}
+	for _, preferredType := range tomlConf.Discovery.Prefer {
+		claType, err := cla.TypeFromString(preferredType)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Discovery preferred type", err)
+		}
+		conf.Discovery.Prefer = append(conf.Discovery.Prefer, claType)
+	}
+	if tomlConf.Discovery.PeerTimeout != "" {
+		peerTimeout, err := time.ParseDuration(tomlConf.Discovery.PeerTimeout)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Discovery peer timeout", err)
+		}
+		conf.Discovery.PeerTimeout = peerTimeout
+	}
+	if tomlConf.Discovery.Workers < 0 {
+		return config{}, NewConfigError("Error parsing Discovery workers",
+			fmt.Errorf("%d is negative", tomlConf.Discovery.Workers))
+	}
+	conf.Discovery.Workers = tomlConf.Discovery.Workers
+	conf.Discovery.Timing.Interval = defaultAnnouncementInterval
+	for _, timing := range []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"interval", tomlConf.Discovery.Interval, &conf.Discovery.Timing.Interval},
+		{"min interval", tomlConf.Discovery.MinInterval, &conf.Discovery.Timing.MinInterval},
+		{"jitter", tomlConf.Discovery.Jitter, &conf.Discovery.Timing.Jitter},
+	} {
+		if timing.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(timing.value)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Discovery "+timing.name, err)
+		} else if duration < 0 {
+			return config{}, NewConfigError("Error parsing Discovery "+timing.name,
+				fmt.Errorf("%v is negative", duration))
+		}
+		*timing.field = duration
+	}
+
+	// Parse agents config
+	conf.Agents.REST = tomlConf.Agents.REST
+	if conf.Agents.REST.Admin && conf.Agents.REST.Token == "" {
+		return config{}, NewConfigError("Error parsing Agents REST admin", errors.New("admin requires a token"))
+	}
+
+	conf.Agents.DefaultSource = nodeID
+	if tomlConf.Agents.DefaultSource != "" {
+		defaultSource, err := bpv7.NewEndpointID(tomlConf.Agents.DefaultSource)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Agents default source", err)
+		}
+		conf.Agents.DefaultSource = defaultSource
+	}
+
+	if tomlConf.Agents.SignaturePrivate != "" {
+		key, err := hex.DecodeString(tomlConf.Agents.SignaturePrivate)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing Agents signature private key", err)
+		} else if len(key) != ed25519.PrivateKeySize {
+			return config{}, NewConfigError("Error parsing Agents signature private key",
+				fmt.Errorf("key has %d bytes instead of %d", len(key), ed25519.PrivateKeySize))
+		}
+		conf.Agents.SigningKey = key
+	}
+
+	if tomlConf.Agents.Ping != "" {
+		ping, err := bpv7.NewEndpointID(tomlConf.Agents.Ping)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing ping endpoint", err)
+		}
+		conf.Agents.Ping = ping
+	}
+
+	registration := tomlConf.Agents.Registration
+	conf.Agents.Registration, err = application_agent.NewRegistrationPolicy(nodeID, registration.Allow, registration.Deny)
+	if err != nil {
+		return config{}, NewConfigError("Error parsing agents' registration policy", err)
+	}
+
+	if tomlConf.Agents.MaxLifetime != "" {
+		maxLifetime, err := time.ParseDuration(tomlConf.Agents.MaxLifetime)
 		if err != nil {
-			return config{}, NewConfigError("Error parsing listener port", err)
+			return config{}, NewConfigError("Error parsing agents' maximum lifetime", err)
+		} else if maxLifetime < 0 {
+			return config{}, NewConfigError("Error parsing agents' maximum lifetime",
+				fmt.Errorf("%v is negative", maxLifetime))
 		}
-		conf.Discovery = append(conf.Discovery, discovery.Announcement{Type: claType, Port: uint(port), Endpoint: nodeID})
+		conf.Agents.MaxLifetime = maxLifetime
 	}
 
-	// Agents config needs no parsing
-	conf.Agents = tomlConf.Agents
+	conf.Agents.DeadLetter = deadLetterConfig{
+		Enabled:  tomlConf.Agents.DeadLetter.Enabled,
+		Capacity: defaultDeadLetterCapacity,
+	}
+	if capacity := tomlConf.Agents.DeadLetter.Capacity; capacity < 0 {
+		return config{}, NewConfigError("Error parsing dead-letter capacity",
+			fmt.Errorf("%d is not a valid capacity", capacity))
+	} else if capacity > 0 {
+		conf.Agents.DeadLetter.Capacity = capacity
+	}
+	for _, collectStr := range tomlConf.Agents.DeadLetter.Collect {
+		collect, err := bpv7.NewEndpointID(collectStr)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing dead-letter collected node", err)
+		}
+		conf.Agents.DeadLetter.Collect = append(conf.Agents.DeadLetter.Collect, collect)
+	}
 
 	// Parse cron config
 	dispatchTime, err := time.ParseDuration(tomlConf.Cron.Dispatch)
@@ -155,5 +735,22 @@ func parse(filename string) (config, error) {
 	}
 	conf.Cron.Dispatch = dispatchTime
 
+	conf.Cron.GarbageCollection = defaultGarbageCollection
+	if tomlConf.Cron.GarbageCollection != "" {
+		gcTime, err := time.ParseDuration(tomlConf.Cron.GarbageCollection)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing garbage collection period", err)
+		}
+		conf.Cron.GarbageCollection = gcTime
+	}
+
+	if tomlConf.Cron.Scrub != "" {
+		scrubTime, err := time.ParseDuration(tomlConf.Cron.Scrub)
+		if err != nil {
+			return config{}, NewConfigError("Error parsing scrub period", err)
+		}
+		conf.Cron.Scrub = scrubTime
+	}
+
 	return conf, nil
 }
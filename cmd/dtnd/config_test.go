@@ -0,0 +1,1096 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/quicl"
+	"github.com/dtn7/dtn7-go/pkg/discovery"
+	"github.com/dtn7/dtn7-go/pkg/processing"
+	"github.com/dtn7/dtn7-go/pkg/routing"
+)
+
+func parseTestConfig(t *testing.T, content string) (config, error) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return parse(filename)
+}
+
+const testConfigHeader = `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+
+[Cron]
+dispatch = "10s"
+`
+
+func TestParseAdvertisedPort(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[[Listener]]
+type = "QUICL"
+address = ":35037"
+advertised_port = 45037
+
+[[Listener]]
+type = "MTCP"
+address = "127.0.0.1:35038"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(conf.Listener); l != 2 {
+		t.Fatalf("Expected two listeners, got %d", l)
+	}
+	if l := len(conf.Discovery.Announcements); l != 2 {
+		t.Fatalf("Expected two announcements, got %d", l)
+	}
+
+	tests := []struct {
+		claType       cla.CLAType
+		bindAddress   string
+		announcedPort uint
+	}{
+		{cla.QUICL, ":35037", 45037},
+		{cla.MTCP, "127.0.0.1:35038", 35038},
+	}
+
+	for i, test := range tests {
+		if listener := conf.Listener[i]; listener.Type != test.claType || listener.Address != test.bindAddress {
+			t.Fatalf("Listener %d is %v, expected %v on %s", i, listener, test.claType, test.bindAddress)
+		}
+		if announcement := conf.Discovery.Announcements[i]; announcement.Type != test.claType || announcement.Port != test.announcedPort {
+			t.Fatalf("Announcement %d is %v, expected %v on port %d", i, announcement, test.claType, test.announcedPort)
+		}
+	}
+}
+
+func TestParseAdvertisedPortInvalid(t *testing.T) {
+	_, err := parseTestConfig(t, testConfigHeader+`
+[[Listener]]
+type = "QUICL"
+address = ":35037"
+advertised_port = 70000
+`)
+	if err == nil {
+		t.Fatal("Invalid advertised port was accepted")
+	}
+}
+
+func TestAdvertisedPortListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+	conf, err := parseTestConfig(t, testConfigHeader+fmt.Sprintf(`
+[[Listener]]
+type = "MTCP"
+address = "%s"
+advertised_port = 45037
+`, address))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Peers receive the advertised port as the discovery sends it, without the listener's own address
+	data, err := discovery.MarshalAnnouncements(conf.Discovery.Announcements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	announcements, err := discovery.UnmarshalAnnouncements(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []discovery.Announcement{{Type: cla.MTCP, Port: 45037, Endpoint: conf.NodeID}}
+	if !reflect.DeepEqual(announcements, expected) {
+		t.Fatalf("Expected announcements %v, got %v", expected, announcements)
+	}
+
+	connected := make(chan bpv7.EndpointID, 1)
+	err = cla.InitialiseCLAManager(
+		func(*bpv7.Bundle) {},
+		func(eid bpv7.EndpointID) { connected <- eid },
+		func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	listener, err := cla.NewListener(conf.Listener[0], func(*bpv7.Bundle) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cla.GetManagerSingleton().RegisterListener(listener); err != nil {
+		t.Fatal(err)
+	}
+
+	// The listener is still bound to its local port, e.g., behind a port-mapping
+	peer, err := cla.NewPeer(cla.MTCP, address, bpv7.DtnNone(), conf.NodeID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.Activate(); err != nil {
+		t.Fatalf("Listener is not bound to %s: %v", address, err)
+	}
+	defer peer.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("Peer did not connect to %s", address)
+	}
+}
+
+func TestParseDiscovery(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[Discovery]
+mode = "announce"
+dial = ["QUICL", "mtcp"]
+prefer = ["mtcp"]
+peer_timeout = "1m30s"
+interval = "10s"
+jitter = "3s"
+workers = 8
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dial := conf.Discovery.Dial; len(dial) != 2 || dial[0] != cla.QUICL || dial[1] != cla.MTCP {
+		t.Fatalf("Unexpected dial types %v", dial)
+	}
+	if prefer := conf.Discovery.Prefer; len(prefer) != 1 || prefer[0] != cla.MTCP {
+		t.Fatalf("Unexpected preferred types %v", prefer)
+	}
+	if peerTimeout := conf.Discovery.PeerTimeout; peerTimeout != 90*time.Second {
+		t.Fatalf("Unexpected peer timeout %v", peerTimeout)
+	}
+	if mode := conf.Discovery.Mode; mode != discovery.ModeAnnounce {
+		t.Fatalf("Unexpected mode %v", mode)
+	}
+	if workers := conf.Discovery.Workers; workers != 8 {
+		t.Fatalf("Unexpected workers %d", workers)
+	}
+	expected := discovery.AnnouncementTiming{Interval: 10 * time.Second, Jitter: 3 * time.Second}
+	if timing := conf.Discovery.Timing; timing != expected {
+		t.Fatalf("Expected announcement timing %v, got %v", expected, timing)
+	}
+
+	for _, invalid := range []string{`interval = "soon"`, `jitter = "-1s"`, `workers = -1`, `mode = "shout"`} {
+		if _, err := parseTestConfig(t, testConfigHeader+"[Discovery]\n"+invalid+"\n"); err == nil {
+			t.Fatalf("Invalid setting %s was accepted", invalid)
+		}
+	}
+
+	if _, err := parseTestConfig(t, testConfigHeader+`
+[Discovery]
+dial = ["carrier pigeon"]
+`); err == nil {
+		t.Fatal("Invalid dial type was accepted")
+	}
+}
+
+func TestParseRoutingDeny(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[[Routing.Deny]]
+destination = "ipn:9\\..*"
+cla = ["QUICL"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.Filter == nil {
+		t.Fatal("No forwarding filter was created")
+	}
+
+	invalid := []string{`
+[[Routing.Deny]]
+destination = "ipn:9.[*"
+`, `
+[[Routing.Deny]]
+cla = ["carrier pigeon"]
+`, `
+[[Routing.Deny]]
+`}
+	for _, deny := range invalid {
+		if _, err := parseTestConfig(t, testConfigHeader+deny); err == nil {
+			t.Fatalf("Invalid deny rule was accepted: %s", deny)
+		}
+	}
+}
+
+func TestParseRoutingAccept(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.Accept != nil {
+		t.Fatal("An ingress policy was created without any pattern")
+	}
+
+	conf, err = parseTestConfig(t, testConfigHeader+`
+[Routing.Accept]
+source = ["dtn://partner/.*"]
+destination = ["dtn://test/.*"]
+report_deletion = true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.Accept == nil {
+		t.Fatal("No ingress policy was created")
+	}
+
+	if _, err := parseTestConfig(t, testConfigHeader+`
+[Routing.Accept]
+source = ["dtn://partner/.[*"]
+`); err == nil {
+		t.Fatal("Invalid accept pattern was accepted")
+	}
+}
+
+func TestParseRoutingEgress(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[[Routing.Egress]]
+cla = "MTCP"
+max_size = 65536
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Routing.Egress) != 1 || len(conf.Routing.Egress[cla.MTCP]) != 1 {
+		t.Fatalf("Expected one MTCP egress predicate, got %v", conf.Routing.Egress)
+	}
+
+	conf, err = parseTestConfig(t, testConfigHeader+`
+[[Routing.Egress]]
+peer = "dtn://satellite/"
+max_size = 65536
+
+[[Routing.Egress]]
+peer = "dtn://satellite/dtn7"
+max_size = 1024
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Routing.Egress) != 0 {
+		t.Fatalf("Peer egress policies restrict CLA types: %v", conf.Routing.Egress)
+	}
+	if peers := conf.Routing.PeerEgress; len(peers) != 1 || len(peers[0].Predicates) != 2 ||
+		peers[0].Peer != bpv7.MustNewEndpointID("dtn://satellite/") {
+		t.Fatalf("Expected two egress predicates for dtn://satellite/, got %v", peers)
+	}
+
+	invalid := []string{`
+[[Routing.Egress]]
+cla = "carrier pigeon"
+max_size = 65536
+`, `
+[[Routing.Egress]]
+cla = "MTCP"
+`, `
+[[Routing.Egress]]
+max_size = 65536
+`, `
+[[Routing.Egress]]
+cla = "MTCP"
+peer = "dtn://satellite/"
+max_size = 65536
+`, `
+[[Routing.Egress]]
+peer = "dtn:none"
+max_size = 65536
+`, `
+[[Routing.Egress]]
+peer = "not an endpoint"
+max_size = 65536
+`}
+	for _, egress := range invalid {
+		if _, err := parseTestConfig(t, testConfigHeader+egress); err == nil {
+			t.Fatalf("Invalid egress policy was accepted: %s", egress)
+		}
+	}
+}
+
+func TestParseLogModules(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[LogModules]
+"cla/quicl" = "Trace"
+store = "Warn"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]log.Level{"cla/quicl": log.TraceLevel, "store": log.WarnLevel}
+	if !reflect.DeepEqual(conf.LogModules, expected) {
+		t.Fatalf("Expected log modules %v, got %v", expected, conf.LogModules)
+	}
+
+	_, err = parseTestConfig(t, testConfigHeader+`
+[LogModules]
+store = "loud"
+`)
+	if err == nil {
+		t.Fatal("Invalid module log level was accepted")
+	}
+}
+
+func TestParseLogPayloadSize(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	} else if conf.LogPayloadSize {
+		t.Fatal("Payload size is logged by default")
+	}
+
+	conf, err = parseTestConfig(t, "log_payload_size = true\n"+testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	} else if !conf.LogPayloadSize {
+		t.Fatal("Payload size is not logged")
+	}
+}
+
+func TestParseDeadLetter(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Agents.DeadLetter.Enabled {
+		t.Fatal("Dead-letter mailbox is enabled by default")
+	}
+
+	conf, err = parseTestConfig(t, testConfigHeader+`
+[Agents.DeadLetter]
+enabled = true
+collect = ["dtn://other/"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadLetter := conf.Agents.DeadLetter
+	if !deadLetter.Enabled || deadLetter.Capacity != defaultDeadLetterCapacity {
+		t.Fatalf("Unexpected dead-letter config %v", deadLetter)
+	}
+	if !reflect.DeepEqual(deadLetter.Collect, []bpv7.EndpointID{bpv7.MustNewEndpointID("dtn://other/")}) {
+		t.Fatalf("Unexpected collected nodes %v", deadLetter.Collect)
+	}
+
+	invalid := []string{`
+[Agents.DeadLetter]
+capacity = -1
+`, `
+[Agents.DeadLetter]
+collect = ["no endpoint"]
+`}
+	for _, deadLetter := range invalid {
+		if _, err := parseTestConfig(t, testConfigHeader+deadLetter); err == nil {
+			t.Fatalf("Invalid dead-letter config was accepted: %s", deadLetter)
+		}
+	}
+}
+
+func TestParseAgentsMaxLifetime(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[Agents]
+max_lifetime = "168h"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxLifetime := conf.Agents.MaxLifetime; maxLifetime != 168*time.Hour {
+		t.Fatalf("Unexpected maximum lifetime %v", maxLifetime)
+	}
+
+	for _, maxLifetime := range []string{"forever", "-1h"} {
+		if _, err := parseTestConfig(t, testConfigHeader+"[Agents]\nmax_lifetime = \""+maxLifetime+"\"\n"); err == nil {
+			t.Fatalf("Invalid maximum lifetime %s was accepted", maxLifetime)
+		}
+	}
+}
+
+func TestParseAgentsDefaultSource(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Agents.DefaultSource != conf.NodeID {
+		t.Fatalf("Expected the node ID %v as default source, got %v", conf.NodeID, conf.Agents.DefaultSource)
+	}
+
+	conf, err = parseTestConfig(t, testConfigHeader+`
+[Agents]
+default_source = "dtn://test/anonymous"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := bpv7.MustNewEndpointID("dtn://test/anonymous"); conf.Agents.DefaultSource != expected {
+		t.Fatalf("Expected default source %v, got %v", expected, conf.Agents.DefaultSource)
+	}
+
+	if _, err := parseTestConfig(t, testConfigHeader+"[Agents]\ndefault_source = \"nowhere\"\n"); err == nil {
+		t.Fatal("Invalid default source was accepted")
+	}
+}
+
+func TestParseAgentsRegistration(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[Agents.Registration]
+deny = ["dtn://test/admin"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Agents.Registration.Check(bpv7.MustNewEndpointID("dtn://test/admin")) == nil {
+		t.Fatal("Denied endpoint can be registered")
+	}
+	if err := conf.Agents.Registration.Check(bpv7.MustNewEndpointID("dtn://test/app")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseTestConfig(t, testConfigHeader+`
+[Agents.Registration]
+allow = ["dtn://test/[app"]
+`); err == nil {
+		t.Fatal("Invalid allow pattern was accepted")
+	}
+}
+
+func TestParseAgentsPing(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[Agents]
+ping = "dtn://test/ping"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping := conf.Agents.Ping; ping != bpv7.MustNewEndpointID("dtn://test/ping") {
+		t.Fatalf("Unexpected ping endpoint %v", ping)
+	}
+
+	if _, err := parseTestConfig(t, testConfigHeader+"[Agents]\nping = \"ping\"\n"); err == nil {
+		t.Fatal("Invalid ping endpoint was accepted")
+	}
+}
+
+func TestParseAgentsRESTAdmin(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[Agents.REST]
+address = "localhost:8080"
+token = "secret"
+admin = true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conf.Agents.REST.Admin {
+		t.Fatal("REST admin was not enabled")
+	}
+
+	if _, err := parseTestConfig(t, testConfigHeader+`
+[Agents.REST]
+address = "localhost:8080"
+admin = true
+`); err == nil {
+		t.Fatal("REST admin without a token was accepted")
+	}
+}
+
+func TestParseRoutingRecentlyActive(t *testing.T) {
+	conf, err := parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "recently_active"
+max_copies = 3
+
+[Cron]
+dispatch = "10s"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.Algorithm != routing.RecentlyActive || conf.Routing.MaxCopies != 3 {
+		t.Fatalf("Unexpected routing configuration %+v", conf.Routing)
+	}
+
+	_, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "recently_active"
+max_copies = -1
+
+[Cron]
+dispatch = "10s"
+`)
+	if err == nil {
+		t.Fatal("Negative max copies were accepted")
+	}
+}
+
+func TestParseRoutingSendTimeout(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sendTimeout := conf.Routing.SendTimeout; sendTimeout != processing.DefaultSendTimeout {
+		t.Fatalf("Unexpected default send timeout %v", sendTimeout)
+	}
+
+	conf, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+send_timeout = "5s"
+
+[Cron]
+dispatch = "10s"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sendTimeout := conf.Routing.SendTimeout; sendTimeout != 5*time.Second {
+		t.Fatalf("Unexpected send timeout %v", sendTimeout)
+	}
+}
+
+func TestParseRoutingDispatchBackoff(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.DispatchBackoff != 0 || conf.Routing.MaxDispatchBackoff != 0 {
+		t.Fatalf("Unexpected default dispatch backoff %v up to %v",
+			conf.Routing.DispatchBackoff, conf.Routing.MaxDispatchBackoff)
+	}
+
+	conf, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+dispatch_backoff = "30s"
+max_dispatch_backoff = "30m"
+
+[Cron]
+dispatch = "10s"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.DispatchBackoff != 30*time.Second || conf.Routing.MaxDispatchBackoff != 30*time.Minute {
+		t.Fatalf("Unexpected dispatch backoff %v up to %v",
+			conf.Routing.DispatchBackoff, conf.Routing.MaxDispatchBackoff)
+	}
+
+	_, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+dispatch_backoff = "-30s"
+
+[Cron]
+dispatch = "10s"
+`)
+	if err == nil {
+		t.Fatal("Negative dispatch backoff was accepted")
+	}
+}
+
+func TestParseRoutingSendQueue(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.SendQueue != 0 {
+		t.Fatalf("Unexpected default send queue %d", conf.Routing.SendQueue)
+	}
+
+	conf, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+send_queue = 64
+
+[Cron]
+dispatch = "10s"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.SendQueue != 64 {
+		t.Fatalf("Unexpected send queue %d", conf.Routing.SendQueue)
+	}
+
+	_, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+send_queue = -1
+
+[Cron]
+dispatch = "10s"
+`)
+	if err == nil {
+		t.Fatal("Negative send queue was accepted")
+	}
+}
+
+func TestParseListenerMTCPOptions(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader+`
+[[Listener]]
+type = "MTCP"
+address = ":35038"
+max_connections = 64
+read_timeout = "30s"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(conf.Listener); l != 1 || conf.Listener[0].MaxConnections != 64 || conf.Listener[0].ReadTimeout != 30*time.Second {
+		t.Fatalf("Unexpected listeners %v", conf.Listener)
+	}
+
+	for _, invalid := range []struct{ claType, option string }{
+		{"MTCP", "max_connections = -1"},
+		{"QUICL", "max_connections = 64"},
+		{"MTCP", `read_timeout = "-1s"`},
+		{"MTCP", `read_timeout = "soon"`},
+		{"QUICL", `read_timeout = "30s"`},
+	} {
+		_, err := parseTestConfig(t, testConfigHeader+fmt.Sprintf(`
+[[Listener]]
+type = "%s"
+address = ":35038"
+%s
+`, invalid.claType, invalid.option))
+		if err == nil {
+			t.Fatalf("Invalid option %s of a %s listener was accepted", invalid.option, invalid.claType)
+		}
+	}
+}
+
+func TestParseListenerAddresses(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	addresses := []string{fmt.Sprintf("127.0.0.1:%d", port), fmt.Sprintf("127.0.0.2:%d", port)}
+	conf, err := parseTestConfig(t, testConfigHeader+fmt.Sprintf(`
+[[Listener]]
+type = "MTCP"
+addresses = ["%s", "%s"]
+`, addresses[0], addresses[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(conf.Listener); l != 2 {
+		t.Fatalf("Expected two listeners, got %d", l)
+	}
+	expected := []discovery.Announcement{
+		{Type: cla.MTCP, Port: uint(port), Endpoint: conf.NodeID, Address: "127.0.0.1"},
+		{Type: cla.MTCP, Port: uint(port), Endpoint: conf.NodeID, Address: "127.0.0.2"},
+	}
+	if !reflect.DeepEqual(conf.Discovery.Announcements, expected) {
+		t.Fatalf("Expected announcements %v, got %v", expected, conf.Discovery.Announcements)
+	}
+
+	// The peers' connections report to the manager asynchronously
+	connected := make(chan bpv7.EndpointID, len(addresses))
+	err = cla.InitialiseCLAManager(
+		func(*bpv7.Bundle) {},
+		func(eid bpv7.EndpointID) { connected <- eid },
+		func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shutting down waits for the listeners, whose connections still report to the manager when closed
+	defer cla.GetManagerSingleton().Shutdown()
+
+	received := make(chan *bpv7.Bundle, len(addresses))
+	for i, listenerConf := range conf.Listener {
+		if listenerConf.Address != addresses[i] || listenerConf.EndpointId != conf.NodeID {
+			t.Fatalf("Listener %d is %v, expected %s", i, listenerConf, addresses[i])
+		}
+
+		listener, err := cla.NewListener(listenerConf, func(bundle *bpv7.Bundle) { received <- bundle })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cla.GetManagerSingleton().RegisterListener(listener); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, address := range addresses {
+		peer, err := cla.NewPeer(cla.MTCP, address, bpv7.DtnNone(), conf.NodeID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := peer.Activate(); err != nil {
+			t.Fatal(err)
+		}
+		defer peer.Close()
+
+		select {
+		case <-connected:
+		case <-time.After(time.Second):
+			t.Fatalf("Peer %s did not connect", address)
+		}
+
+		bundle, err := bpv7.Builder().
+			Source("dtn://src/").
+			Destination(conf.NodeID).
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock([]byte(address)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := peer.(cla.ConvergenceSender).Send(bundle); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case bundle := <-received:
+			if payload, _ := bundle.PayloadBlock(); string(payload.Value.(*bpv7.PayloadBlock).Data()) != address {
+				t.Fatalf("Received unexpected bundle %v via %s", bundle.ID(), address)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No bundle was received via %s", address)
+		}
+	}
+}
+
+func TestParseListenerWithoutAddress(t *testing.T) {
+	_, err := parseTestConfig(t, testConfigHeader+`
+[[Listener]]
+type = "MTCP"
+`)
+	if err == nil {
+		t.Fatal("Listener without an address was accepted")
+	}
+}
+
+func TestParseClockSkewTolerance(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tolerance := conf.ClockSkewTolerance; tolerance != bpv7.DefaultClockSkewTolerance {
+		t.Fatalf("Unexpected default clock skew tolerance %v", tolerance)
+	}
+
+	for _, test := range []struct {
+		value     string
+		tolerance time.Duration
+		valid     bool
+	}{
+		{"5s", 5 * time.Second, true},
+		{"0s", 0, true},
+		{"-5s", 0, false},
+		{"soon", 0, false},
+	} {
+		conf, err = parseTestConfig(t, fmt.Sprintf(`clock_skew_tolerance = %q`, test.value)+testConfigHeader)
+		if valid := err == nil; valid != test.valid {
+			t.Fatalf("Parsing %q resulted in %v", test.value, err)
+		} else if valid && conf.ClockSkewTolerance != test.tolerance {
+			t.Fatalf("Parsing %q resulted in %v", test.value, conf.ClockSkewTolerance)
+		}
+	}
+}
+
+func TestParseQUICLReceiveStreamLimit(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit := conf.QUICLReceiveStreamLimit; limit != quicl.DefaultReceiveStreamLimit {
+		t.Fatalf("Unexpected default QUICL receive stream limit %d", limit)
+	}
+
+	for _, test := range []struct {
+		value int
+		limit int64
+		valid bool
+	}{
+		{4, 4, true},
+		{0, quicl.DefaultReceiveStreamLimit, true},
+		{-1, 0, false},
+	} {
+		conf, err = parseTestConfig(t, fmt.Sprintf(`quicl_receive_stream_limit = %d`, test.value)+testConfigHeader)
+		if valid := err == nil; valid != test.valid {
+			t.Fatalf("Parsing %d resulted in %v", test.value, err)
+		} else if valid && conf.QUICLReceiveStreamLimit != test.limit {
+			t.Fatalf("Parsing %d resulted in %d", test.value, conf.QUICLReceiveStreamLimit)
+		}
+	}
+}
+
+func TestParseAgentsSignaturePrivate(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Agents.SigningKey != nil {
+		t.Fatal("Signing key is set by default")
+	}
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err = parseTestConfig(t, testConfigHeader+fmt.Sprintf("[Agents]\nsignature_private = %q\n", hex.EncodeToString(priv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.Equal(conf.Agents.SigningKey) {
+		t.Fatalf("Expected signing key %x, got %x", priv, conf.Agents.SigningKey)
+	}
+
+	for _, key := range []string{"nope", hex.EncodeToString(priv[:ed25519.SeedSize])} {
+		if _, err := parseTestConfig(t, testConfigHeader+fmt.Sprintf("[Agents]\nsignature_private = %q\n", key)); err == nil {
+			t.Fatalf("Invalid signature private key %q was accepted", key)
+		}
+	}
+}
+
+func TestParseRoutingMaxForwardingAttempts(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.MaxForwardingAttempts != 0 || conf.Routing.ReportGiveUp {
+		t.Fatalf("Unexpected default forwarding attempts %d, reporting %t",
+			conf.Routing.MaxForwardingAttempts, conf.Routing.ReportGiveUp)
+	}
+
+	conf, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+max_forwarding_attempts = 10
+report_give_up = true
+
+[Cron]
+dispatch = "10s"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Routing.MaxForwardingAttempts != 10 || !conf.Routing.ReportGiveUp {
+		t.Fatalf("Unexpected forwarding attempts %d, reporting %t",
+			conf.Routing.MaxForwardingAttempts, conf.Routing.ReportGiveUp)
+	}
+
+	_, err = parseTestConfig(t, `
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+max_forwarding_attempts = -1
+
+[Cron]
+dispatch = "10s"
+`)
+	if err == nil {
+		t.Fatal("Negative forwarding attempts were accepted")
+	}
+}
+
+func TestParseStoreGracePeriod(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grace := conf.Store.GracePeriod; grace != 0 {
+		t.Fatalf("Unexpected default grace period %v", grace)
+	}
+
+	for _, test := range []struct {
+		value string
+		grace time.Duration
+		valid bool
+	}{
+		{"10m", 10 * time.Minute, true},
+		{"0s", 0, true},
+		{"-10m", 0, false},
+		{"soon", 0, false},
+	} {
+		conf, err := parseTestConfig(t, fmt.Sprintf(`
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+grace_period = "%s"
+
+[Routing]
+algorithm = "epidemic"
+
+[Cron]
+dispatch = "10s"
+`, test.value))
+		if (err == nil) != test.valid {
+			t.Fatalf("Grace period %q resulted in error %v", test.value, err)
+		}
+		if err == nil && conf.Store.GracePeriod != test.grace {
+			t.Fatalf("Grace period %q was parsed as %v", test.value, conf.Store.GracePeriod)
+		}
+	}
+}
+
+func TestParseRoutingHandoffPeers(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peers := conf.Routing.HandoffPeers; len(peers) != 0 {
+		t.Fatalf("Unexpected default handoff peers %v", peers)
+	}
+
+	for _, test := range []struct {
+		value string
+		peers []bpv7.EndpointID
+		valid bool
+	}{
+		{`["dtn://a/", "ipn:2.1"]`,
+			[]bpv7.EndpointID{bpv7.MustNewEndpointID("dtn://a/"), bpv7.MustNewEndpointID("ipn:2.1")}, true},
+		{`["foo"]`, nil, false},
+	} {
+		conf, err := parseTestConfig(t, fmt.Sprintf(`
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+handoff_peers = %s
+
+[Cron]
+dispatch = "10s"
+`, test.value))
+		if (err == nil) != test.valid {
+			t.Fatalf("Handoff peers %s resulted in error %v", test.value, err)
+		}
+		if err == nil && !reflect.DeepEqual(conf.Routing.HandoffPeers, test.peers) {
+			t.Fatalf("Handoff peers %s were parsed as %v", test.value, conf.Routing.HandoffPeers)
+		}
+	}
+}
+
+func TestParseRoutingMinCRCType(t *testing.T) {
+	conf, err := parseTestConfig(t, testConfigHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crcType := conf.Routing.MinCRCType; crcType != bpv7.CRCNo {
+		t.Fatalf("Unexpected default min CRC type %v", crcType)
+	}
+
+	for _, test := range []struct {
+		value   string
+		crcType bpv7.CRCType
+		valid   bool
+	}{
+		{"none", bpv7.CRCNo, true},
+		{"crc16", bpv7.CRC16, true},
+		{"CRC32", bpv7.CRC32, true},
+		{"crc64", bpv7.CRCNo, false},
+	} {
+		conf, err := parseTestConfig(t, fmt.Sprintf(`
+node_id = "dtn://test/"
+log_level = "Debug"
+
+[Store]
+path = "/tmp/dtn_store"
+
+[Routing]
+algorithm = "epidemic"
+min_crc_type = "%s"
+
+[Cron]
+dispatch = "10s"
+`, test.value))
+		if (err == nil) != test.valid {
+			t.Fatalf("Min CRC type %s resulted in error %v", test.value, err)
+		}
+		if err == nil && conf.Routing.MinCRCType != test.crcType {
+			t.Fatalf("Min CRC type %s was parsed as %v", test.value, conf.Routing.MinCRCType)
+		}
+	}
+}
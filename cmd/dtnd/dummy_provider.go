@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
+)
+
+// dummyProvider creates a dummy_cla.DummyListener for listeners of the Dummy type, which only act as placeholders.
+//
+// Unlike the other CLAs, the dummy CLA has no package registering its provider on initialisation. The dummy_cla
+// package cannot import the cla package, whose tests use it.
+type dummyProvider struct{}
+
+func (dummyProvider) Type() cla.CLAType {
+	return cla.Dummy
+}
+
+func (dummyProvider) NewListener(config cla.ListenerConfig, _ func(*bpv7.Bundle)) (cla.ConvergenceListener, error) {
+	return dummy_cla.NewDummyListener(config.Address), nil
+}
+
+func (dummyProvider) NewPeer(address string, _ bpv7.EndpointID, _ bpv7.EndpointID, _ func(*bpv7.Bundle)) (cla.Convergence, error) {
+	return nil, fmt.Errorf("the dummy CLA cannot connect to peer %s", address)
+}
@@ -10,15 +10,17 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dtn7/dtn7-go/pkg/application_agent"
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/cla"
-	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
-	"github.com/dtn7/dtn7-go/pkg/cla/mtcp"
+	_ "github.com/dtn7/dtn7-go/pkg/cla/loopback"
+	_ "github.com/dtn7/dtn7-go/pkg/cla/mtcp"
 	"github.com/dtn7/dtn7-go/pkg/cla/quicl"
 	"github.com/dtn7/dtn7-go/pkg/discovery"
 	"github.com/dtn7/dtn7-go/pkg/id_keeper"
 	"github.com/dtn7/dtn7-go/pkg/processing"
 	"github.com/dtn7/dtn7-go/pkg/routing"
 	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
 func main() {
@@ -31,13 +33,29 @@ func main() {
 		log.WithField("error", err).Fatal("Config error")
 	}
 
-	log.SetLevel(conf.LogLevel)
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: "2006-01-02T15:04:05.000",
 	})
+	util.SetModuleLevels(log.StandardLogger(), conf.LogLevel, conf.LogModules)
+	bpv7.SetLogPayloadSize(conf.LogPayloadSize)
 
+	bpv7.SetClockSkewTolerance(conf.ClockSkewTolerance)
+	quicl.SetReceiveStreamLimit(conf.QUICLReceiveStreamLimit)
 	processing.SetOwnNodeID(conf.NodeID)
+	processing.SetSendTimeout(conf.Routing.SendTimeout)
+	processing.SetMaxForwardingAttempts(conf.Routing.MaxForwardingAttempts, conf.Routing.ReportGiveUp)
+	processing.SetDispatchBackoff(conf.Routing.DispatchBackoff, conf.Routing.MaxDispatchBackoff)
+	processing.SetHandoffPeers(conf.Routing.HandoffPeers...)
+	processing.SetMinimumCRCType(conf.Routing.MinCRCType)
+	processing.SetIngressPolicy(conf.Routing.Accept)
+	for claType, predicates := range conf.Routing.Egress {
+		processing.SetEgressPolicy(claType, predicates...)
+	}
+	for _, egress := range conf.Routing.PeerEgress {
+		processing.SetPeerEgressPolicy(egress.Peer, egress.Predicates...)
+	}
+	cla.SetSendIdleTimeout(conf.Routing.SendTimeout)
 
 	// Setup Store
 	err = store.InitialiseStore(conf.NodeID, conf.Store.Path)
@@ -45,6 +63,13 @@ func main() {
 		log.WithField("error", err).Fatal("Error initialising store")
 	}
 	defer store.GetStoreSingleton().Close()
+	store.GetStoreSingleton().SetCompression(conf.Store.Compress)
+	store.GetStoreSingleton().SetGracePeriod(conf.Store.GracePeriod)
+	if conf.Store.TempDir != "" {
+		if err := store.GetStoreSingleton().SetTempDirectory(conf.Store.TempDir); err != nil {
+			log.WithField("error", err).Fatal("Error setting the store's temp directory")
+		}
+	}
 
 	// Setup IdKeeper
 	err = id_keeper.InitializeIdKeeper()
@@ -53,7 +78,8 @@ func main() {
 	}
 
 	// Setup routing
-	err = routing.InitialiseAlgorithm(conf.Routing.Algorithm)
+	routing.SetMaxCopies(conf.Routing.MaxCopies)
+	err = routing.InitialiseAlgorithm(conf.Routing.Algorithm, conf.Routing.Filter)
 	if err != nil {
 		log.WithField("error", err).Fatal("Error initialising routing algorithm")
 	}
@@ -64,20 +90,26 @@ func main() {
 		log.WithField("error", err).Fatal("Error initialising CLAs")
 	}
 	defer cla.GetManagerSingleton().Shutdown()
+	cla.GetManagerSingleton().SetReceiveFromCallback(processing.ReceiveBundleFrom)
+	cla.GetManagerSingleton().SetSendQueue(cla.AdministrativeRecordsFirst, conf.Routing.SendQueue)
+
+	// The dummy CLA has no package registering its provider, unlike the imported CLAs
+	if err := cla.RegisterProvider(dummyProvider{}); err != nil {
+		log.WithField("error", err).Fatal("Error registering the dummy CLA")
+	}
 
 	for _, lstConf := range conf.Listener {
-		var listener cla.ConvergenceListener
-		switch lstConf.Type {
-		case cla.Dummy:
-			listener = dummy_cla.NewDummyListener(lstConf.Address)
-		case cla.MTCP:
-			srv := mtcp.NewMTCPServer(lstConf.Address, lstConf.EndpointId, cla.GetManagerSingleton().NotifyReceive)
-			listener = srv
-			cla.GetManagerSingleton().Register(srv)
-		case cla.QUICL:
-			listener = quicl.NewQUICListener(lstConf.Address, lstConf.EndpointId, cla.GetManagerSingleton().NotifyReceive)
-		default:
-			log.WithField("Type", lstConf.Type).Fatal("Not valid convergence listener type")
+		listener, err := cla.NewListener(lstConf, cla.GetManagerSingleton().NotifyReceive)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"type":  lstConf.Type,
+			}).Fatal("Not valid convergence listener type")
+		}
+
+		// Some listeners, e.g., MTCP's server, are receiving CLAs themselves
+		if conv, ok := listener.(cla.Convergence); ok {
+			cla.GetManagerSingleton().Register(conv)
 		}
 
 		err = cla.GetManagerSingleton().RegisterListener(listener)
@@ -90,13 +122,16 @@ func main() {
 	}
 
 	// Setup neighbour discovery
-	err = discovery.InitialiseManager(conf.NodeID, conf.Discovery, 2*time.Second, true, false, cla.GetManagerSingleton().NotifyReceive)
+	err = discovery.InitialiseManager(conf.NodeID, conf.Discovery.Announcements, conf.Discovery.Timing, true, false,
+		conf.Discovery.Mode, conf.Discovery.Dial, conf.Discovery.PeerTimeout, conf.Discovery.Workers,
+		cla.GetManagerSingleton().NotifyReceive)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Fatal("Error starting discovery manager")
 	}
 	defer discovery.GetManagerSingleton().Close()
+	discovery.GetManagerSingleton().SetDialPreference(conf.Discovery.Prefer)
 
 	s, err := gocron.NewScheduler()
 	if err != nil {
@@ -113,6 +148,30 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("Error initializing dispatching cronjob")
 	}
+	_, err = s.NewJob(
+		gocron.DurationJob(
+			conf.Cron.GarbageCollection,
+		),
+		gocron.NewTask(
+			processing.GarbageCollect,
+		),
+	)
+	if err != nil {
+		log.WithError(err).Fatal("Error initializing garbage collection cronjob")
+	}
+	if conf.Cron.Scrub > 0 {
+		_, err = s.NewJob(
+			gocron.DurationJob(
+				conf.Cron.Scrub,
+			),
+			gocron.NewTask(
+				processing.Scrub,
+			),
+		)
+		if err != nil {
+			log.WithError(err).Fatal("Error initializing scrub cronjob")
+		}
+	}
 	s.Start()
 	defer s.Shutdown()
 
@@ -122,16 +181,43 @@ func main() {
 		log.WithField("error", err).Fatal("Error initialising Application Agent Manager")
 	}
 	defer application_agent.GetManagerSingleton().Shutdown()
+	application_agent.GetManagerSingleton().SetMaxLifetime(conf.Agents.MaxLifetime)
+	application_agent.GetManagerSingleton().SetDefaultSource(conf.Agents.DefaultSource)
+	if conf.Agents.SigningKey != nil {
+		// Signature Blocks are not known by default; register them to verify signatures of received bundles as well
+		if err := bpv7.GetExtensionBlockManager().Register(&bpv7.SignatureBlock{}); err != nil {
+			log.WithField("error", err).Fatal("Error registering Signature Block")
+		}
+		application_agent.GetManagerSingleton().SetSigningKey(conf.Agents.SigningKey)
+	}
 
 	// TODO: make this asynchronous
 	r := mux.NewRouter()
 	restRouter := r.PathPrefix("/rest").Subrouter()
-	restAgent := application_agent.NewRestAgent(restRouter)
+	restAgent := application_agent.NewRestAgent(restRouter, conf.Agents.REST.Token)
+	restAgent.SetRegistrationPolicy(conf.Agents.Registration)
 	err = application_agent.GetManagerSingleton().RegisterAgent(restAgent)
 	if err != nil {
 		log.WithError(err).Fatal("Error registering REST application agent")
 	}
 
+	if conf.Agents.Ping != (bpv7.EndpointID{}) {
+		err = application_agent.GetManagerSingleton().RegisterAgent(application_agent.NewPingAgent(conf.Agents.Ping))
+		if err != nil {
+			log.WithError(err).Fatal("Error registering ping application agent")
+		}
+	}
+
+	if deadLetter := conf.Agents.DeadLetter; deadLetter.Enabled {
+		mailbox := application_agent.NewDeadLetterMailbox(conf.NodeID, deadLetter.Collect, deadLetter.Capacity)
+		processing.SetDeadLetterMailbox(mailbox)
+		restAgent.ServeDeadLetters(mailbox)
+	}
+
+	if conf.Agents.REST.Admin {
+		restAgent.ServePeers(conf.NodeID)
+	}
+
 	httpServer := &http.Server{
 		Addr:              conf.Agents.REST.Address,
 		Handler:           r,
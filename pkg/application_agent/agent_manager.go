@@ -1,39 +1,104 @@
 package application_agent
 
 import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/id_keeper"
 	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
+// Bounds of the queue between the agents' Send calls and the send callback.
+const (
+	// SendQueueSize is the number of bundles waiting for a send worker before Send fails
+	SendQueueSize = 64
+	// SendWorkers is the number of bundles passed to the send callback concurrently
+	SendWorkers = 4
+)
+
+// SendQueueFull is returned by Manager.Send if too many bundles are pending. The bundle should be sent again later.
+type SendQueueFull int
+
+func (err *SendQueueFull) Error() string {
+	return fmt.Sprintf("queue of %d bundles to be sent is full, try again later", int(*err))
+}
+
+func NewSendQueueFullError(size int) *SendQueueFull {
+	err := SendQueueFull(size)
+	return &err
+}
+
 type Manager struct {
 	stateMutex   sync.RWMutex
 	agents       []ApplicationAgent
 	sendCallback func(bundle *bpv7.Bundle)
+
+	// sendQueue holds the bundles passed to Send until a send worker hands them to the sendCallback
+	sendQueue chan *bpv7.Bundle
+	// sendMutex guards sendQueue against being closed while Send enqueues a bundle
+	sendMutex   sync.RWMutex
+	sendClosed  bool
+	sendWorkers sync.WaitGroup
+
+	// maxLifetime of bundles sent by agents; longer lifetimes are clamped, unlimited if zero
+	maxLifetime time.Duration
+	// defaultSource replaces the dtn:none source of bundles sent by agents; disabled if zero-valued
+	defaultSource bpv7.EndpointID
+	// signingKey signs the bundles sent by agents with a Signature Block; disabled if nil
+	signingKey ed25519.PrivateKey
 }
 
 var managerSingleton *Manager
 
 func InitialiseApplicationAgentManager(sendCallback func(bundle *bpv7.Bundle)) error {
-	manager := Manager{
+	if managerSingleton != nil {
+		return util.NewAlreadyInitialisedError("Application Agent Manager")
+	}
+
+	managerSingleton = newManager(sendCallback, SendQueueSize, SendWorkers)
+	return nil
+}
+
+// newManager creates a Manager and starts its send workers.
+func newManager(sendCallback func(bundle *bpv7.Bundle), queueSize, workers int) *Manager {
+	manager := &Manager{
 		agents:       make([]ApplicationAgent, 0, 10),
 		sendCallback: sendCallback,
+		sendQueue:    make(chan *bpv7.Bundle, queueSize),
 	}
-	managerSingleton = &manager
-	return nil
+
+	manager.sendWorkers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go manager.handleSend()
+	}
+
+	return manager
+}
+
+// LookupManagerSingleton returns the manager singleton-instance or a util.NotInitialised-error.
+func LookupManagerSingleton() (*Manager, error) {
+	if managerSingleton == nil {
+		return nil, util.NewNotInitialisedError("Application Agent Manager")
+	}
+	return managerSingleton, nil
 }
 
 // GetManagerSingleton returns the manager singleton-instance.
-// Attempting to call this function before store initialisation will cause the program to panic.
+// Attempting to call this function before store initialisation will cause the program to panic; see
+// LookupManagerSingleton.
 func GetManagerSingleton() *Manager {
-	if managerSingleton == nil {
+	manager, err := LookupManagerSingleton()
+	if err != nil {
 		log.Fatalf("Attempting to access an uninitialised agent manager. This must never happen!")
 	}
-	return managerSingleton
+	return manager
 }
 
 // GetEndpoints returns a slice of all registered Endpoints on this node
@@ -86,38 +151,200 @@ func (manager *Manager) UnregisterEndpoint(removeAgent ApplicationAgent) error {
 	return nil
 }
 
-func (manager *Manager) Delivery(bundleDescriptor *store.BundleDescriptor) {
+// Delivery hands a bundle over to all registered agents. The returned bool indicates whether an agent responsible for
+// the bundle's destination accepted it.
+func (manager *Manager) Delivery(bundleDescriptor *store.BundleDescriptor) (delivered bool) {
 	manager.stateMutex.RLock()
 	defer manager.stateMutex.RUnlock()
 
 	for _, agent := range manager.agents {
 		err := agent.Deliver(bundleDescriptor)
-		if err != nil {
+		if errors.Is(err, store.ErrBundleNotFound) {
+			// The bundle was deleted in the meantime, e.g., as it expired
+			log.WithFields(log.Fields{
+				"bundle": bundleDescriptor.ID,
+				"agent":  agent,
+				"error":  err,
+			}).Info("Bundle to be delivered is no longer stored")
+		} else if err != nil {
 			log.WithFields(log.Fields{
 				"bundle": bundleDescriptor.ID,
 				"agent":  agent,
 				"error":  err,
 			}).Error("Error delivering bundle")
+		} else if bagContainsEndpoint(agent.Endpoints(), []bpv7.EndpointID{bundleDescriptor.Destination}) {
+			delivered = true
 		}
 	}
+	return
 }
 
+// Shutdown all agents and stop the send workers after they have passed on the queued bundles.
 func (manager *Manager) Shutdown() {
 	manager.stateMutex.RLock()
-	defer manager.stateMutex.RUnlock()
-
 	for _, agent := range manager.agents {
 		agent.Shutdown()
 	}
-
 	manager.agents = make([]ApplicationAgent, 0)
+	manager.stateMutex.RUnlock()
+
+	manager.sendMutex.Lock()
+	if !manager.sendClosed {
+		manager.sendClosed = true
+		close(manager.sendQueue)
+	}
+	manager.sendMutex.Unlock()
+	manager.sendWorkers.Wait()
 
 	managerSingleton = nil
 }
 
-func (manager *Manager) Send(bndl *bpv7.Bundle) {
+// SetMaxLifetime limits the lifetime of bundles sent by agents. A zero duration disables the limit.
+func (manager *Manager) SetMaxLifetime(maxLifetime time.Duration) {
+	manager.stateMutex.Lock()
+	defer manager.stateMutex.Unlock()
+
+	manager.maxLifetime = maxLifetime
+}
+
+// SetDefaultSource for bundles sent by agents without a source, i.e., with a dtn:none source or built without one.
+// A zero-valued EndpointID disables the default source, keeping such bundles anonymous.
+func (manager *Manager) SetDefaultSource(source bpv7.EndpointID) {
+	manager.stateMutex.Lock()
+	defer manager.stateMutex.Unlock()
+
+	manager.defaultSource = source
+}
+
+// DefaultSource returns the source for bundles sent without one, or a zero-valued EndpointID if there is none.
+func (manager *Manager) DefaultSource() bpv7.EndpointID {
+	manager.stateMutex.RLock()
+	defer manager.stateMutex.RUnlock()
+
+	return manager.defaultSource
+}
+
+// SetSigningKey for bundles sent by agents, which get a bpv7.SignatureBlock attached. A nil key disables signing.
+//
+// Only bundles created locally and passed to Send are signed; bundles received from other nodes are left untouched.
+func (manager *Manager) SetSigningKey(priv ed25519.PrivateKey) {
+	manager.stateMutex.Lock()
+	defer manager.stateMutex.Unlock()
+
+	manager.signingKey = priv
+}
+
+// sign attaches a Signature Block to the bundle, if a signing key is set. Signature Blocks already attached, e.g., by
+// a client, are replaced, as only this node's signature can be vouched for.
+//
+// As the signature covers the Primary Block, the bundle must not be altered afterwards.
+func (manager *Manager) sign(bndl *bpv7.Bundle) error {
+	manager.stateMutex.RLock()
+	priv := manager.signingKey
+	manager.stateMutex.RUnlock()
+
+	if priv == nil {
+		return nil
+	}
+
+	// Blocks are referenced within the bundle, whose slice of blocks is altered by each removal
+	existing, _ := bndl.ExtensionBlocks(bpv7.ExtBlockTypeSignatureBlock)
+	blockNumbers := make([]uint64, len(existing))
+	for i, cb := range existing {
+		blockNumbers[i] = cb.BlockNumber
+	}
+	for _, blockNumber := range blockNumbers {
+		log.WithField("bundle", bndl.ID().String()).Info("Replacing the Signature Block of a bundle to be sent")
+		bndl.RemoveExtensionBlockByBlockNumber(blockNumber)
+	}
+
+	sb, err := bpv7.NewSignatureBlock(*bndl, priv)
+	if err != nil {
+		return fmt.Errorf("signing bundle %v failed: %w", bndl.ID(), err)
+	}
+	return bndl.AddExtensionBlock(bpv7.NewCanonicalBlock(0, bpv7.ReplicateBlock|bpv7.DeleteBundle, sb))
+}
+
+// applyDefaultSource replaces an anonymous bundle's dtn:none source, and its report-to field if also dtn:none.
+func (manager *Manager) applyDefaultSource(bndl *bpv7.Bundle) {
+	source := manager.DefaultSource()
+	if source == (bpv7.EndpointID{}) || bndl.PrimaryBlock.SourceNode != bpv7.DtnNone() {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle": bndl.ID().String(),
+		"source": source,
+	}).Debug("Applying default source to anonymous bundle sent by an application agent")
+	bndl.PrimaryBlock.SourceNode = source
+	if bndl.PrimaryBlock.ReportTo == bpv7.DtnNone() {
+		bndl.PrimaryBlock.ReportTo = source
+	}
+}
+
+// clampLifetime reduces the bundle's lifetime to the configured maximum.
+func (manager *Manager) clampLifetime(bndl *bpv7.Bundle) {
+	manager.stateMutex.RLock()
+	maxLifetime := uint64(manager.maxLifetime.Milliseconds())
+	manager.stateMutex.RUnlock()
+
+	if maxLifetime == 0 || bndl.PrimaryBlock.Lifetime <= maxLifetime {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":       bndl.ID().String(),
+		"lifetime":     time.Duration(bndl.PrimaryBlock.Lifetime) * time.Millisecond,
+		"max_lifetime": time.Duration(maxLifetime) * time.Millisecond,
+	}).Info("Clamping lifetime of bundle sent by an application agent")
+	bndl.PrimaryBlock.Lifetime = maxLifetime
+}
+
+// Send a bundle created by an agent. The bundle is queued and passed to the send callback in the background.
+//
+// Anonymous bundles get the default source, if one is set; see SetDefaultSource.
+//
+// If the queue is full, a SendQueueFull-error is returned and the agent should ask its client to try again later.
+func (manager *Manager) Send(bndl *bpv7.Bundle) error {
+	manager.clampLifetime(bndl)
+	manager.applyDefaultSource(bndl)
+
 	idKeeper := id_keeper.GetIdKeeperSingleton()
 	idKeeper.Update(bndl)
-	log.WithFields(log.Fields{"bundle": bndl.ID().String()}).Debug("Application agent sent bundle")
-	manager.sendCallback(bndl)
+
+	if err := manager.sign(bndl); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bndl.ID().String(),
+			"error":  err,
+		}).Warn("Rejecting bundle sent by an application agent, signing failed")
+		return err
+	}
+
+	manager.sendMutex.RLock()
+	defer manager.sendMutex.RUnlock()
+
+	if manager.sendClosed {
+		return util.NewNotInitialisedError("Application Agent Manager")
+	}
+
+	select {
+	case manager.sendQueue <- bndl:
+		log.WithFields(log.Fields{"bundle": bndl.ID().String()}).Debug("Application agent sent bundle")
+		return nil
+	default:
+		log.WithFields(log.Fields{
+			"bundle":     bndl.ID().String(),
+			"queue_size": cap(manager.sendQueue),
+		}).Warn("Rejecting bundle sent by an application agent, send queue is full")
+		return NewSendQueueFullError(cap(manager.sendQueue))
+	}
+}
+
+// handleSend passes queued bundles to the send callback until the queue is closed.
+func (manager *Manager) handleSend() {
+	defer manager.sendWorkers.Done()
+
+	for bndl := range manager.sendQueue {
+		manager.sendCallback(bndl)
+	}
 }
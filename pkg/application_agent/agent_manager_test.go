@@ -0,0 +1,317 @@
+package application_agent
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/id_keeper"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+func TestSendLifetimeClamp(t *testing.T) {
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := id_keeper.InitializeIdKeeper(); err != nil && !errors.As(err, &alreadyInitialised) {
+		t.Fatal(err)
+	}
+
+	sent := make(chan *bpv7.Bundle, 1)
+	if err := InitialiseApplicationAgentManager(func(bundle *bpv7.Bundle) { sent <- bundle }); err != nil {
+		t.Fatal(err)
+	}
+	defer GetManagerSingleton().Shutdown()
+	GetManagerSingleton().SetMaxLifetime(24 * time.Hour)
+
+	tests := []struct {
+		lifetime string
+		expected time.Duration
+	}{
+		{"1h", time.Hour},
+		{"24h", 24 * time.Hour},
+		{"8760h", 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		bundle, err := bpv7.Builder().
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime(test.lifetime).
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := GetManagerSingleton().Send(&bundle); err != nil {
+			t.Fatal(err)
+		}
+
+		if lifetime := time.Duration((<-sent).PrimaryBlock.Lifetime) * time.Millisecond; lifetime != test.expected {
+			t.Fatalf("Lifetime %s was sent as %v instead of %v", test.lifetime, lifetime, test.expected)
+		}
+	}
+}
+
+func TestSendDefaultSource(t *testing.T) {
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := id_keeper.InitializeIdKeeper(); err != nil && !errors.As(err, &alreadyInitialised) {
+		t.Fatal(err)
+	}
+
+	sent := make(chan *bpv7.Bundle, 1)
+	if err := InitialiseApplicationAgentManager(func(bundle *bpv7.Bundle) { sent <- bundle }); err != nil {
+		t.Fatal(err)
+	}
+	defer GetManagerSingleton().Shutdown()
+
+	defaultSource := bpv7.MustNewEndpointID("dtn://node/anonymous")
+	tests := []struct {
+		name          string
+		source        string
+		defaultSource bpv7.EndpointID
+		expected      bpv7.EndpointID
+	}{
+		{"explicit source", "dtn://app/", defaultSource, bpv7.MustNewEndpointID("dtn://app/")},
+		{"default source", "dtn:none", defaultSource, defaultSource},
+		{"no default source", "dtn:none", bpv7.EndpointID{}, bpv7.DtnNone()},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			GetManagerSingleton().SetDefaultSource(test.defaultSource)
+
+			bundle, err := bpv7.Builder().
+				Source(test.source).
+				Destination("dtn://dst/").
+				BundleCtrlFlags(bpv7.MustNotFragmented).
+				CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+				Lifetime("1h").
+				PayloadBlock([]byte("hello world")).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := GetManagerSingleton().Send(&bundle); err != nil {
+				t.Fatal(err)
+			}
+
+			pb := (<-sent).PrimaryBlock
+			if pb.SourceNode != test.expected || pb.ReportTo != test.expected {
+				t.Fatalf("Expected source and report-to %v, got %v and %v", test.expected, pb.SourceNode, pb.ReportTo)
+			}
+		})
+	}
+}
+
+func TestSendSigned(t *testing.T) {
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := id_keeper.InitializeIdKeeper(); err != nil && !errors.As(err, &alreadyInitialised) {
+		t.Fatal(err)
+	}
+
+	sent := make(chan *bpv7.Bundle, 1)
+	if err := InitialiseApplicationAgentManager(func(bundle *bpv7.Bundle) { sent <- bundle }); err != nil {
+		t.Fatal(err)
+	}
+	defer GetManagerSingleton().Shutdown()
+
+	// By default, the SignatureBlock is not registered in the singleton ExtensionManager
+	if err := bpv7.GetExtensionBlockManager().Register(&bpv7.SignatureBlock{}); err != nil {
+		t.Fatal(err)
+	}
+	defer bpv7.GetExtensionBlockManager().Unregister(&bpv7.SignatureBlock{})
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, key := range []ed25519.PrivateKey{priv, nil} {
+		GetManagerSingleton().SetSigningKey(key)
+
+		bundle, err := bpv7.Builder().
+			Source("dtn://app/").
+			Destination("dtn://dst/").
+			CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := GetManagerSingleton().Send(&bundle); err != nil {
+			t.Fatal(err)
+		}
+
+		// Serialise the sent bundle, as it would be transmitted, to verify the signature on the receiving side
+		var buff bytes.Buffer
+		if err := (<-sent).WriteBundle(&buff); err != nil {
+			t.Fatal(err)
+		}
+		received, err := bpv7.ParseBundle(&buff)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cb, err := received.ExtensionBlock(bpv7.ExtBlockTypeSignatureBlock)
+		if key == nil {
+			if err == nil {
+				t.Fatal("Bundle was signed without a signing key")
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("Bundle was not signed: %v", err)
+		}
+
+		sb := cb.Value.(*bpv7.SignatureBlock)
+		if !pub.Equal(ed25519.PublicKey(sb.PublicKey)) {
+			t.Fatalf("Signature Block's public key %x differs from %x", sb.PublicKey, pub)
+		} else if !sb.Verify(received) {
+			t.Fatal("Signature Block cannot be verified")
+		}
+	}
+
+	// A client's own Signature Block is replaced by the node's signature
+	GetManagerSingleton().SetSigningKey(priv)
+	_, forgedPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := bpv7.Builder().
+		Source("dtn://app/").
+		Destination("dtn://dst/").
+		CreationTimestampTime(time.Now().Add(time.Minute)).
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := bpv7.NewSignatureBlock(bundle, forgedPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.AddExtensionBlock(bpv7.NewCanonicalBlock(0, bpv7.ReplicateBlock, forged)); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetManagerSingleton().Send(&bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	signed := <-sent
+	if cb, err := signed.ExtensionBlock(bpv7.ExtBlockTypeSignatureBlock); err != nil {
+		t.Fatalf("Bundle has not exactly one Signature Block: %v", err)
+	} else if sb := cb.Value.(*bpv7.SignatureBlock); !pub.Equal(ed25519.PublicKey(sb.PublicKey)) {
+		t.Fatalf("Client's Signature Block with the public key %x was kept", sb.PublicKey)
+	}
+}
+
+func TestInitialiseTwice(t *testing.T) {
+	if err := InitialiseApplicationAgentManager(func(*bpv7.Bundle) {}); err != nil {
+		t.Fatal(err)
+	}
+	manager := GetManagerSingleton()
+	defer manager.Shutdown()
+
+	endpoint := bpv7.MustNewEndpointID("dtn://node/ping")
+	if err := manager.RegisterAgent(NewPingAgent(endpoint)); err != nil {
+		t.Fatal(err)
+	}
+
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := InitialiseApplicationAgentManager(func(*bpv7.Bundle) {}); !errors.As(err, &alreadyInitialised) {
+		t.Fatalf("Expected AlreadyInitialised error, got %v", err)
+	}
+
+	if GetManagerSingleton() != manager {
+		t.Fatal("Manager was replaced by the second initialisation")
+	}
+	if endpoints := manager.GetEndpoints(); len(endpoints) != 1 || endpoints[0] != endpoint {
+		t.Fatalf("Expected registered endpoint %v, got %v", endpoint, endpoints)
+	}
+}
+
+func TestSendQueue(t *testing.T) {
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := id_keeper.InitializeIdKeeper(); err != nil && !errors.As(err, &alreadyInitialised) {
+		t.Fatal(err)
+	}
+
+	const (
+		queueSize = 3
+		workers   = 2
+	)
+
+	var (
+		mutex             sync.Mutex
+		active, maxActive int
+	)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	manager := newManager(func(*bpv7.Bundle) {
+		mutex.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mutex.Unlock()
+
+		started <- struct{}{}
+		<-release
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+	}, queueSize, workers)
+	defer func(m *Manager) { managerSingleton = m }(managerSingleton)
+
+	send := func(i int) error {
+		bundle, err := bpv7.Builder().
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return manager.Send(&bundle)
+	}
+
+	// Block all workers, then fill the queue
+	for i := 0; i < workers; i++ {
+		if err := send(i); err != nil {
+			t.Fatal(err)
+		}
+		<-started
+	}
+	for i := workers; i < workers+queueSize; i++ {
+		if err := send(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var queueFull *SendQueueFull
+	if err := send(workers + queueSize); !errors.As(err, &queueFull) {
+		t.Fatalf("Expected SendQueueFull error, got %v", err)
+	}
+
+	go func() {
+		for range started {
+		}
+	}()
+	close(release)
+	manager.Shutdown()
+	close(started)
+
+	if maxActive != workers {
+		t.Fatalf("Expected %d concurrent sends, got %d", workers, maxActive)
+	}
+	if err := send(0); err == nil {
+		t.Fatal("Sending after shutdown succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package application_agent
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// DeadLetterMailbox keeps bundles which expired without being delivered, allowing applications to inspect failures.
+//
+// Only bundles created by this node or by one of the collected nodes are accepted. The mailbox holds at most capacity
+// bundles; if it is full, the oldest bundle is dropped for a new one.
+//
+// The mailbox is volatile: it is only held in memory, and its bundles are lost when the process exits. Applications
+// should fetch dead bundles regularly.
+type DeadLetterMailbox struct {
+	mutex    sync.Mutex
+	nodes    []bpv7.EndpointID
+	capacity int
+	// bundles in the order of their arrival, oldest first
+	bundles []bpv7.Bundle
+}
+
+// NewDeadLetterMailbox creates a DeadLetterMailbox for bundles of this node and those of the collected nodes.
+func NewDeadLetterMailbox(nodeID bpv7.EndpointID, collect []bpv7.EndpointID, capacity int) *DeadLetterMailbox {
+	return &DeadLetterMailbox{
+		nodes:    append([]bpv7.EndpointID{nodeID}, collect...),
+		capacity: capacity,
+	}
+}
+
+// accepts checks if the bundle's source node is one of the mailbox's nodes.
+func (m *DeadLetterMailbox) accepts(bundle bpv7.Bundle) bool {
+	for _, node := range m.nodes {
+		if bundle.PrimaryBlock.SourceNode.SameNode(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add a dead bundle to the mailbox, if it is accepted. The returned bool indicates if the bundle was added.
+func (m *DeadLetterMailbox) Add(bundle bpv7.Bundle) bool {
+	if m.capacity <= 0 || !m.accepts(bundle) {
+		return false
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, dead := range m.bundles {
+		if dead.ID() == bundle.ID() {
+			return true
+		}
+	}
+
+	if len(m.bundles) >= m.capacity {
+		log.WithFields(log.Fields{
+			"bundle":   m.bundles[0].ID().String(),
+			"capacity": m.capacity,
+		}).Info("Dead-letter mailbox is full, dropping its oldest bundle")
+		m.bundles = m.bundles[1:]
+	}
+	m.bundles = append(m.bundles, bundle)
+
+	log.WithField("bundle", bundle.ID().String()).Debug("Added bundle to dead-letter mailbox")
+	return true
+}
+
+// Fetch the dead bundles sent from the given source's node, oldest first.
+//
+// At most limit bundles are returned, unless limit is zero. If remove is set, the returned bundles are removed.
+func (m *DeadLetterMailbox) Fetch(source bpv7.EndpointID, limit int, remove bool) []bpv7.Bundle {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var (
+		fetched   = make([]bpv7.Bundle, 0)
+		remaining = make([]bpv7.Bundle, 0, len(m.bundles))
+	)
+	for _, bundle := range m.bundles {
+		if bundle.PrimaryBlock.SourceNode.SameNode(source) && (limit == 0 || len(fetched) < limit) {
+			fetched = append(fetched, bundle)
+			if remove {
+				continue
+			}
+		}
+		remaining = append(remaining, bundle)
+	}
+	m.bundles = remaining
+
+	return fetched
+}
@@ -0,0 +1,65 @@
+package application_agent
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestDeadLetterMailbox(t *testing.T) {
+	const capacity = 3
+
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	collected := bpv7.MustNewEndpointID("dtn://collected/")
+	mailbox := NewDeadLetterMailbox(nodeID, []bpv7.EndpointID{collected}, capacity)
+
+	newBundle := func(source string, seq uint64) bpv7.Bundle {
+		b, err := bpv7.Builder().
+			Source(source).
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("24h").
+			PayloadBlock([]byte(fmt.Sprintf("bundle %d", seq))).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(b.PrimaryBlock.CreationTimestamp.DtnTime(), seq)
+		return b
+	}
+
+	if mailbox.Add(newBundle("dtn://other/", 0)) {
+		t.Fatal("Bundle of a foreign node was accepted")
+	}
+	if !mailbox.Add(newBundle("dtn://collected/app", 0)) {
+		t.Fatal("Bundle of a collected node was rejected")
+	}
+
+	var own []bpv7.Bundle
+	for i := uint64(0); i < capacity; i++ {
+		b := newBundle("dtn://node/app", i)
+		if !mailbox.Add(b) {
+			t.Fatal("Bundle of this node was rejected")
+		}
+		own = append(own, b)
+	}
+
+	// The collected node's bundle was the oldest and got dropped
+	if dead := mailbox.Fetch(bpv7.MustNewEndpointID("dtn://collected/app"), 0, false); len(dead) != 0 {
+		t.Fatalf("Full mailbox kept its oldest bundle %v", dead)
+	}
+
+	source := bpv7.MustNewEndpointID("dtn://node/app")
+	if dead := mailbox.Fetch(source, 2, true); len(dead) != 2 || dead[0].ID() != own[0].ID() || dead[1].ID() != own[1].ID() {
+		t.Fatalf("Fetched %v instead of the two oldest bundles", dead)
+	}
+	// Bundles are fetched by their source node, e.g., by another endpoint of this node
+	if dead := mailbox.Fetch(bpv7.MustNewEndpointID("dtn://node/other-app"), 0, true); len(dead) != 1 ||
+		dead[0].ID() != own[2].ID() {
+		t.Fatalf("Fetched %v instead of the remaining bundle", dead)
+	}
+	if dead := mailbox.Fetch(source, 0, true); len(dead) != 0 {
+		t.Fatalf("Fetched %v from an empty mailbox", dead)
+	}
+}
@@ -0,0 +1,83 @@
+package application_agent
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+// PingAgent answers each bundle addressed to its endpoint with an echo bundle to the bundle's source.
+//
+// The echo carries the received payload and the received bundle's lifetime. Bundles from dtn:none, administrative
+// records, and the PingAgent's own bundles are not answered. Pings from another node's ping endpoint are answered like
+// all others; as its echoes are answered in turn, such pings should only be sent if the other node runs no PingAgent
+// at this endpoint.
+type PingAgent struct {
+	endpoint bpv7.EndpointID
+}
+
+// NewPingAgent creates a PingAgent answering on the given endpoint. It must be registered at the Manager.
+func NewPingAgent(endpoint bpv7.EndpointID) *PingAgent {
+	return &PingAgent{endpoint: endpoint}
+}
+
+func (pa *PingAgent) Endpoints() []bpv7.EndpointID {
+	return []bpv7.EndpointID{pa.endpoint}
+}
+
+// Deliver sends an echo bundle for each ping addressed to this PingAgent.
+func (pa *PingAgent) Deliver(bundleDescriptor *store.BundleDescriptor) error {
+	if bundleDescriptor.Destination != pa.endpoint {
+		return nil
+	}
+
+	bndl, err := bundleDescriptor.Load()
+	if err != nil {
+		return err
+	}
+
+	source := bndl.PrimaryBlock.SourceNode
+	if source == bpv7.DtnNone() || source == pa.endpoint || bndl.IsAdministrativeRecord() {
+		log.WithField("bundle", bundleDescriptor.ID.String()).Debug("Ping agent ignores unanswerable bundle")
+		return nil
+	}
+
+	payload, err := bndl.PayloadBlock()
+	if err != nil {
+		return err
+	}
+
+	echo, err := bpv7.Builder().
+		Source(pa.endpoint).
+		Destination(source).
+		CreationTimestampNow().
+		Lifetime(bndl.PrimaryBlock.Lifetime).
+		PayloadBlock(payload.Value.(*bpv7.PayloadBlock).Data()).
+		Build()
+	if err != nil {
+		return fmt.Errorf("creating echo bundle failed: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"bundle": bundleDescriptor.ID.String(),
+		"echo":   echo.ID().String(),
+	}).Info("Ping agent answers with an echo bundle")
+
+	// Deliver is called while the Manager holds its state lock, which Send acquires again
+	manager := GetManagerSingleton()
+	go func() {
+		if err := manager.Send(&echo); err != nil {
+			log.WithFields(log.Fields{
+				"echo":  echo.ID().String(),
+				"error": err,
+			}).Warn("Ping agent failed to send echo bundle")
+		}
+	}()
+
+	return nil
+}
+
+func (pa *PingAgent) Shutdown() {}
@@ -0,0 +1,86 @@
+package application_agent
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/id_keeper"
+	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+func TestPingAgent(t *testing.T) {
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := id_keeper.InitializeIdKeeper(); err != nil && !errors.As(err, &alreadyInitialised) {
+		t.Fatal(err)
+	}
+
+	sent := make(chan *bpv7.Bundle, 4)
+	if err := InitialiseApplicationAgentManager(func(bundle *bpv7.Bundle) { sent <- bundle }); err != nil {
+		t.Fatal(err)
+	}
+
+	pingEndpoint := bpv7.MustNewEndpointID("dtn://node/ping")
+	if err := GetManagerSingleton().RegisterAgent(NewPingAgent(pingEndpoint)); err != nil {
+		t.Fatal(err)
+	}
+
+	deliver := func(source, destination string) bpv7.Bundle {
+		bundle, err := bpv7.Builder().
+			Source(source).
+			Destination(destination).
+			BundleCtrlFlags(bpv7.MustNotFragmented).
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("ping")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		GetManagerSingleton().Delivery(&store.BundleDescriptor{
+			ID:          bundle.ID(),
+			Source:      bundle.PrimaryBlock.SourceNode,
+			Destination: bundle.PrimaryBlock.Destination,
+			Bundle:      &bundle,
+		})
+		return bundle
+	}
+
+	// Pings are answered, including those from another node's ping endpoint
+	for _, source := range []string{"dtn://sender/app", "dtn://other-node/ping"} {
+		ping := deliver(source, "dtn://node/ping")
+
+		var echo *bpv7.Bundle
+		select {
+		case echo = <-sent:
+		case <-time.After(time.Second):
+			t.Fatalf("Ping from %s was not answered", source)
+		}
+		if echo.PrimaryBlock.SourceNode != pingEndpoint || echo.PrimaryBlock.Destination != ping.PrimaryBlock.SourceNode {
+			t.Fatalf("Echo was sent from %v to %v", echo.PrimaryBlock.SourceNode, echo.PrimaryBlock.Destination)
+		}
+		if payload, err := echo.PayloadBlock(); err != nil {
+			t.Fatal(err)
+		} else if data := payload.Value.(*bpv7.PayloadBlock).Data(); !bytes.Equal(data, []byte("ping")) {
+			t.Fatalf("Echo carries payload %q", data)
+		}
+	}
+
+	// Neither bundles for other endpoints nor the agent's own bundles are answered
+	deliver("dtn://sender/app", "dtn://node/other")
+	deliver("dtn://node/ping", "dtn://node/ping")
+	deliver("dtn:none", "dtn://node/ping")
+
+	select {
+	case bundle := <-sent:
+		t.Fatalf("Unexpected bundle from %v to %v was sent",
+			bundle.PrimaryBlock.SourceNode, bundle.PrimaryBlock.Destination)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	GetManagerSingleton().Shutdown()
+}
@@ -0,0 +1,85 @@
+package application_agent
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// RegistrationDeniedError is returned if an endpoint must not be registered by an application.
+type RegistrationDeniedError struct {
+	eid    bpv7.EndpointID
+	reason string
+}
+
+func NewRegistrationDeniedError(eid bpv7.EndpointID, reason string) *RegistrationDeniedError {
+	return &RegistrationDeniedError{eid: eid, reason: reason}
+}
+
+func (err *RegistrationDeniedError) Error() string {
+	return fmt.Sprintf("registration of endpoint %v denied: %s", err.eid, err.reason)
+}
+
+// RegistrationPolicy decides which endpoints may be registered by an ApplicationAgent's clients.
+//
+// The node's own ID and dtn:none are reserved and can never be registered. Other endpoints are checked against
+// regular expressions, each matching the whole endpoint ID. A denied endpoint is rejected, even if it is also allowed.
+// If allow patterns exist, an endpoint must match at least one of them.
+type RegistrationPolicy struct {
+	nodeID bpv7.EndpointID
+	allow  []*regexp.Regexp
+	deny   []*regexp.Regexp
+}
+
+// NewRegistrationPolicy compiles the allow and deny patterns into a RegistrationPolicy for the node.
+func NewRegistrationPolicy(nodeID bpv7.EndpointID, allow, deny []string) (*RegistrationPolicy, error) {
+	policy := &RegistrationPolicy{nodeID: nodeID}
+
+	var err error
+	if policy.allow, err = compileEndpointPatterns(allow); err != nil {
+		return nil, fmt.Errorf("invalid allow pattern: %w", err)
+	}
+	if policy.deny, err = compileEndpointPatterns(deny); err != nil {
+		return nil, fmt.Errorf("invalid deny pattern: %w", err)
+	}
+
+	return policy, nil
+}
+
+// compileEndpointPatterns compiles regular expressions to match whole endpoint IDs.
+func compileEndpointPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Check if the endpoint may be registered. Otherwise, a RegistrationDeniedError is returned.
+func (policy *RegistrationPolicy) Check(eid bpv7.EndpointID) error {
+	if eid == bpv7.DtnNone() || eid == policy.nodeID {
+		return NewRegistrationDeniedError(eid, "endpoint is reserved")
+	}
+
+	eidStr := eid.String()
+	for _, re := range policy.deny {
+		if re.MatchString(eidStr) {
+			return NewRegistrationDeniedError(eid, "endpoint is denied")
+		}
+	}
+
+	if len(policy.allow) == 0 {
+		return nil
+	}
+	for _, re := range policy.allow {
+		if re.MatchString(eidStr) {
+			return nil
+		}
+	}
+	return NewRegistrationDeniedError(eid, "endpoint is not allowed")
+}
@@ -0,0 +1,87 @@
+package application_agent
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestRegistrationPolicy(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	policy, err := NewRegistrationPolicy(nodeID, []string{"dtn://node/.+", "ipn:23\\..*"}, []string{"dtn://node/admin.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		eid     string
+		allowed bool
+	}{
+		{"dtn://node/app", true},
+		{"ipn:23.42", true},
+		{"dtn://node/", false},
+		{"dtn:none", false},
+		{"dtn://node/admin", false},
+		{"dtn://node/administration/app", false},
+		{"dtn://other/app", false},
+		{"ipn:230.1", false},
+	}
+
+	for _, test := range tests {
+		err := policy.Check(bpv7.MustNewEndpointID(test.eid))
+		if allowed := err == nil; allowed != test.allowed {
+			t.Fatalf("Registration of %s resulted in %v", test.eid, err)
+		}
+
+		var deniedErr *RegistrationDeniedError
+		if err != nil && !errors.As(err, &deniedErr) {
+			t.Fatalf("Registration of %s failed with %T instead of a RegistrationDeniedError", test.eid, err)
+		}
+	}
+
+	if _, err := NewRegistrationPolicy(nodeID, nil, []string{"dtn://["}); err == nil {
+		t.Fatal("Invalid deny pattern was accepted")
+	}
+}
+
+func TestRestAgentRegistrationPolicy(t *testing.T) {
+	policy, err := NewRegistrationPolicy(bpv7.MustNewEndpointID("dtn://node/"), nil, []string{"dtn://node/admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	ra := NewRestAgent(router, "")
+	ra.SetRegistrationPolicy(policy)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tests := []struct {
+		eid    string
+		status int
+	}{
+		{"dtn://node/app", http.StatusOK},
+		{"dtn://node/admin", http.StatusForbidden},
+		{"dtn://node/", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Post(server.URL+"/register", "application/json",
+			bytes.NewBufferString(`{"endpoint_id":"`+test.eid+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Fatalf("Registration of %s resulted in %d, expected %d", test.eid, resp.StatusCode, test.status)
+		}
+	}
+}
@@ -7,15 +7,19 @@ package application_agent
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
 	"github.com/dtn7/dtn7-go/pkg/store"
 )
 
@@ -26,7 +30,12 @@ import (
 // a client should unregister itself.
 //
 // This is all done by HTTP POSTing JSON objects. Their structure is described in `rest_agent_messages.go` by the types
-// with the `Rest` prefix in their names.
+// with the `Rest` prefix in their names. Failed requests are answered with a matching HTTP status code, e.g.,
+// 400 Bad Request for invalid input or 404 Not Found for an unknown UUID, and the error field set.
+//
+// If the RestAgent was created with a token, each request must carry it as a bearer token in the Authorization
+// header, e.g., `Authorization: Bearer secret`. Otherwise, the request is rejected with HTTP 401 Unauthorized.
+// Registrations for endpoints denied by the RegistrationPolicy, if set, are rejected with HTTP 403 Forbidden.
 //
 // A possible conversation follows as an example.
 //
@@ -51,8 +60,15 @@ import (
 //	//          {"blockNumber":1,"blockTypeCode":1,"blockControlFlags":null,"data":"S2hlbGxvIHdvcmxk"}
 //	//        ]
 //	//      }
-//	//    ]}
-//	// <- {"error":"","bundles":[]}
+//	//    ], "bundle_ids":["dtn://sender/-640103526000-0"]}
+//	// <- {"error":"","bundles":[],"bundle_ids":[]}
+//
+//	//    For at-least-once delivery, bundles might be peeked and acknowledged afterwards, POST to /fetch and /ack.
+//	//    Optionally, the amount of returned bundles can be limited.
+//	// -> {"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f","peek":true,"limit":10}
+//	// <- {"error":"","bundles":[...],"bundle_ids":["dtn://sender/-640103526000-0"]}
+//	// -> {"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f","bundle_ids":["dtn://sender/-640103526000-0"]}
+//	// <- {"error":""}
 //
 //	// 3. Create and dispatch a new bundle, POST to /build
 //	// -> {
@@ -67,33 +83,95 @@ import (
 //	//    }
 //	// <- {"error":""}
 //
+//	//    If too many bundles are pending, the request fails with 503 Service Unavailable and should be retried.
+//
 //	// 4. Unregister the client, POST to /unregister
 //	// -> {"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f"}
 //	// <- {"error":""}
+//
+// Independent of any registration, the store's statistics and the bytes sent and received by the CLAs, including
+// their framing, can be retrieved for monitoring.
+//
+//	// GET /stats
+//	// <- {"error":"","stats":{"bundles":3,"bytes":312,
+//	//      "by_destination":{"dtn://foo/bar":2,"dtn://dst/":1},"by_source":{"dtn://sender/":3}},
+//	//    "traffic":{"total":{"sent":1024,"received":2048},
+//	//      "clas":{"mtcp://:35038":{"sent":0,"received":2048},"10.0.0.2:35037":{"sent":1024,"received":0}}}}
+//
+// If enabled by ServePeers, peers can be connected at runtime. The request only succeeds after the CLA has connected;
+// an unreachable peer is answered with 502 Bad Gateway. A removed peer is disconnected and not connected again, e.g.,
+// by the discovery, until it is added anew.
+//
+//	// POST /peers/add
+//	// -> {"address":"mtcp://192.0.2.1:35038","endpoint_id":"dtn://peer/"}
+//	// <- {"error":"","address":"192.0.2.1:35038"}
+//
+//	// POST /peers/remove
+//	// -> {"endpoint_id":"dtn://peer/"}
+//	// <- {"error":"","addresses":["192.0.2.1:35038"]}
 type RestAgent struct {
 	router *mux.Router
+	token  string
 
 	// map UUIDs to EIDs and received bundles
 	clients      sync.Map // uuid[string] -> bpv7.EndpointID
 	mailboxes    map[string]map[bpv7.BundleID]bpv7.Bundle
 	mailboxMutex sync.Mutex
+
+	deadLetters *DeadLetterMailbox
+	// nodeID of this node, used for peers connected through ServePeers
+	nodeID bpv7.EndpointID
+	// policy restricting the registrable endpoints, unrestricted if nil
+	policy *RegistrationPolicy
 }
 
 // NewRestAgent creates a new RESTful Application Agent.
-func NewRestAgent(router *mux.Router) (ra *RestAgent) {
+//
+// If token is not empty, all requests must be authorized by this bearer token.
+func NewRestAgent(router *mux.Router, token string) (ra *RestAgent) {
 	ra = &RestAgent{
 		router:    router,
+		token:     token,
 		mailboxes: make(map[string]map[bpv7.BundleID]bpv7.Bundle),
 	}
 
+	if token != "" {
+		ra.router.Use(ra.authenticate)
+	}
+
 	ra.router.HandleFunc("/register", ra.handleRegister).Methods(http.MethodPost)
 	ra.router.HandleFunc("/unregister", ra.handleUnregister).Methods(http.MethodPost)
 	ra.router.HandleFunc("/fetch", ra.handleFetch).Methods(http.MethodPost)
+	ra.router.HandleFunc("/ack", ra.handleAck).Methods(http.MethodPost)
 	ra.router.HandleFunc("/build", ra.handleBuild).Methods(http.MethodPost)
+	ra.router.HandleFunc("/stats", ra.handleStats).Methods(http.MethodGet)
 
 	return ra
 }
 
+// SetRegistrationPolicy restricts the endpoints clients may register for.
+func (ra *RestAgent) SetRegistrationPolicy(policy *RegistrationPolicy) {
+	ra.policy = policy
+}
+
+// ServeDeadLetters makes the DeadLetterMailbox available through /dead_letters.
+//
+// A client POSTs a RestFetchRequest and receives the expired bundles sent from its endpoint as a RestFetchResponse.
+func (ra *RestAgent) ServeDeadLetters(mailbox *DeadLetterMailbox) {
+	ra.deadLetters = mailbox
+	ra.router.HandleFunc("/dead_letters", ra.handleDeadLetters).Methods(http.MethodPost)
+}
+
+// ServePeers makes the administration of this node's peers available through /peers/add and /peers/remove.
+//
+// A client POSTs a RestAddPeerRequest to connect to a new peer as this node, or a RestRemovePeerRequest to disconnect
+// a peer, without changing this node's configuration.
+func (ra *RestAgent) ServePeers(nodeID bpv7.EndpointID) {
+	ra.nodeID = nodeID
+	ra.router.HandleFunc("/peers/add", ra.handleAddPeer).Methods(http.MethodPost)
+	ra.router.HandleFunc("/peers/remove", ra.handleRemovePeer).Methods(http.MethodPost)
+}
+
 // Deliver checks incoming BundleMessages and puts them inbox.
 func (ra *RestAgent) Deliver(bundleDescriptor *store.BundleDescriptor) error {
 	var uuids []string
@@ -104,11 +182,12 @@ func (ra *RestAgent) Deliver(bundleDescriptor *store.BundleDescriptor) error {
 		return false // multiple clients might be registered for some endpoint
 	})
 
-	ra.mailboxMutex.Lock()
 	bndl, err := bundleDescriptor.Load()
 	if err != nil {
 		return err
 	}
+
+	ra.mailboxMutex.Lock()
 	for _, uuid := range uuids {
 		mailbox, exists := ra.mailboxes[uuid]
 		if !exists {
@@ -139,29 +218,76 @@ func (ra *RestAgent) Deliver(bundleDescriptor *store.BundleDescriptor) error {
 	return nil
 }
 
-// randomUuid to be used for authentication. UUID not compliant with RFC 4122.
+// authenticate is a middleware rejecting all requests without the configured bearer token.
+func (ra *RestAgent) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(ra.token)) != 1 {
+			log.WithFields(log.Fields{
+				"remote": r.RemoteAddr,
+				"path":   r.URL.Path,
+			}).Warn("Rejecting unauthorized REST request")
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkRegistration checks the endpoint against the RegistrationPolicy, if one is set.
+func (ra *RestAgent) checkRegistration(eid bpv7.EndpointID) error {
+	if ra.policy == nil {
+		return nil
+	}
+	return ra.policy.Check(eid)
+}
+
+// randomUuid to be used as a client's handle, a random (version 4) UUID as specified in RFC 4122.
 func (_ *RestAgent) randomUuid() (uuid string, err error) {
 	uuidBytes := make([]byte, 16)
 	if _, err = rand.Read(uuidBytes); err == nil {
+		// Version 4 in the high nibble of time_hi_and_version, variant 10x in clock_seq_hi_and_reserved
+		uuidBytes[6] = (uuidBytes[6] & 0x0f) | 0x40
+		uuidBytes[8] = (uuidBytes[8] & 0x3f) | 0x80
+
 		uuid = fmt.Sprintf("%x-%x-%x-%x-%x",
 			uuidBytes[0:4], uuidBytes[4:6], uuidBytes[6:8], uuidBytes[8:10], uuidBytes[10:16])
 	}
 	return
 }
 
+// writeResponse writes a JSON response with the given HTTP status code.
+func (_ *RestAgent) writeResponse(w http.ResponseWriter, status int, response interface{}, name string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.WithError(err).Warnf("Failed to write REST %s response", name)
+	}
+}
+
 // handleRegister processes /register POST requests.
 func (ra *RestAgent) handleRegister(w http.ResponseWriter, r *http.Request) {
 	var (
 		registerRequest  RestRegisterRequest
 		registerResponse RestRegisterResponse
+		status           = http.StatusOK
 	)
 
 	if jsonErr := json.NewDecoder(r.Body).Decode(&registerRequest); jsonErr != nil {
 		registerResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
 	} else if eid, eidErr := bpv7.NewEndpointID(registerRequest.EndpointId); eidErr != nil {
 		registerResponse.Error = eidErr.Error()
+		status = http.StatusBadRequest
+	} else if policyErr := ra.checkRegistration(eid); policyErr != nil {
+		registerResponse.Error = policyErr.Error()
+		status = http.StatusForbidden
 	} else if uuid, uuidErr := ra.randomUuid(); uuidErr != nil {
 		registerResponse.Error = uuidErr.Error()
+		status = http.StatusInternalServerError
 	} else {
 		ra.clients.Store(uuid, eid)
 		registerResponse.UUID = uuid
@@ -172,10 +298,7 @@ func (ra *RestAgent) handleRegister(w http.ResponseWriter, r *http.Request) {
 		"response": registerResponse,
 	}).Info("Processing REST registration")
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(registerResponse); err != nil {
-		log.WithError(err).Warn("Failed to write REST registration response")
-	}
+	ra.writeResponse(w, status, registerResponse, "registration")
 }
 
 // handleUnregister processes /unregister POST requests.
@@ -183,23 +306,26 @@ func (ra *RestAgent) handleUnregister(w http.ResponseWriter, r *http.Request) {
 	var (
 		unregisterRequest  RestUnregisterRequest
 		unregisterResponse RestUnregisterResponse
+		status             = http.StatusOK
 	)
 
 	if jsonErr := json.NewDecoder(r.Body).Decode(&unregisterRequest); jsonErr != nil {
 		log.WithError(jsonErr).Warn("Failed to parse REST unregistration request")
+		unregisterResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
+	} else if _, ok := ra.clients.LoadAndDelete(unregisterRequest.UUID); !ok {
+		log.WithField("uuid", unregisterRequest.UUID).Debug("REST client cannot unregister unknown UUID")
+		unregisterResponse.Error = "Invalid UUID"
+		status = http.StatusNotFound
 	} else {
 		log.WithField("uuid", unregisterRequest.UUID).Info("Unregister REST client")
-		ra.clients.Delete(unregisterRequest.UUID)
 
 		ra.mailboxMutex.Lock()
 		delete(ra.mailboxes, unregisterRequest.UUID)
 		ra.mailboxMutex.Unlock()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(unregisterResponse); err != nil {
-		log.WithError(err).Warn("Failed to write REST unregistration response")
-	}
+	ra.writeResponse(w, status, unregisterResponse, "unregistration")
 }
 
 // handleFetch returns the bundles from some client's inbox, called by /fetch.
@@ -207,33 +333,162 @@ func (ra *RestAgent) handleFetch(w http.ResponseWriter, r *http.Request) {
 	var (
 		fetchRequest  RestFetchRequest
 		fetchResponse RestFetchResponse
+		status        = http.StatusOK
 	)
 
 	if jsonErr := json.NewDecoder(r.Body).Decode(&fetchRequest); jsonErr != nil {
 		log.WithError(jsonErr).Warn("Failed to parse REST fetch request")
 		fetchResponse.Error = jsonErr.Error()
-	} else if mailbox, ok := ra.mailboxes[fetchRequest.UUID]; ok {
-		log.WithField("uuid", fetchRequest.UUID).Info("REST client fetches bundles")
-
+		status = http.StatusBadRequest
+	} else if _, ok := ra.clients.Load(fetchRequest.UUID); !ok {
+		log.WithField("uuid", fetchRequest.UUID).Debug("REST client cannot fetch for unknown UUID")
+		fetchResponse.Error = "Invalid UUID"
+		status = http.StatusNotFound
+	} else if fetchRequest.Limit < 0 {
+		fetchResponse.Error = "Negative limit"
+		status = http.StatusBadRequest
+	} else {
 		ra.mailboxMutex.Lock()
+		mailbox := ra.mailboxes[fetchRequest.UUID]
+
 		bundles := make([]bpv7.Bundle, 0, len(mailbox))
 		for _, bundle := range mailbox {
 			bundles = append(bundles, bundle)
 		}
+		sortBundles(bundles)
+		if fetchRequest.Limit > 0 && len(bundles) > fetchRequest.Limit {
+			bundles = bundles[:fetchRequest.Limit]
+		}
+
+		if !fetchRequest.Peek {
+			for _, bundle := range bundles {
+				delete(mailbox, bundle.ID())
+			}
+			if len(mailbox) == 0 {
+				delete(ra.mailboxes, fetchRequest.UUID)
+			}
+		}
+		ra.mailboxMutex.Unlock()
+
+		if len(bundles) > 0 {
+			log.WithFields(log.Fields{
+				"uuid":    fetchRequest.UUID,
+				"bundles": len(bundles),
+				"peek":    fetchRequest.Peek,
+			}).Info("REST client fetches bundles")
+		} else {
+			log.WithField("uuid", fetchRequest.UUID).Debug("REST client has no new bundles to fetch")
+		}
 
 		fetchResponse.Bundles = bundles
+		fetchResponse.BundleIDs = make([]string, len(bundles))
+		for i, bundle := range bundles {
+			fetchResponse.BundleIDs[i] = bundle.ID().String()
+		}
+	}
+
+	ra.writeResponse(w, status, fetchResponse, "fetch")
+}
+
+// handleAck removes acknowledged bundles from some client's inbox, called by /ack.
+//
+// Bundles are matched by the exact ID string returned by /fetch. Acknowledging bundles not in the inbox, e.g.,
+// acknowledged twice, is no error, but their IDs are reported back.
+func (ra *RestAgent) handleAck(w http.ResponseWriter, r *http.Request) {
+	var (
+		ackRequest  RestAckRequest
+		ackResponse RestAckResponse
+		status      = http.StatusOK
+	)
+
+	if jsonErr := json.NewDecoder(r.Body).Decode(&ackRequest); jsonErr != nil {
+		log.WithError(jsonErr).Warn("Failed to parse REST ack request")
+		ackResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
+	} else if _, ok := ra.clients.Load(ackRequest.UUID); !ok {
+		log.WithField("uuid", ackRequest.UUID).Debug("REST client cannot acknowledge for unknown UUID")
+		ackResponse.Error = "Invalid UUID"
+		status = http.StatusNotFound
+	}
 
-		delete(ra.mailboxes, fetchRequest.UUID)
+	if status == http.StatusOK {
+		log.WithFields(log.Fields{
+			"uuid":    ackRequest.UUID,
+			"bundles": ackRequest.BundleIDs,
+		}).Info("REST client acknowledges bundles")
+
+		acked := make(map[string]bool, len(ackRequest.BundleIDs))
+		for _, bundleIDStr := range ackRequest.BundleIDs {
+			acked[bundleIDStr] = false
+		}
+
+		ra.mailboxMutex.Lock()
+		if mailbox, ok := ra.mailboxes[ackRequest.UUID]; ok {
+			for bundleID := range mailbox {
+				bundleIDStr := bundleID.String()
+				if _, ok := acked[bundleIDStr]; ok {
+					delete(mailbox, bundleID)
+					acked[bundleIDStr] = true
+				}
+			}
+			if len(mailbox) == 0 {
+				delete(ra.mailboxes, ackRequest.UUID)
+			}
+		}
 		ra.mailboxMutex.Unlock()
-	} else if !ok {
-		log.WithField("uuid", fetchRequest.UUID).Debug("REST client has no new bundles to fetch")
-		fetchResponse.Bundles = make([]bpv7.Bundle, 0)
+
+		for _, bundleIDStr := range ackRequest.BundleIDs {
+			if !acked[bundleIDStr] {
+				ackResponse.UnknownBundleIDs = append(ackResponse.UnknownBundleIDs, bundleIDStr)
+			}
+		}
+		if len(ackResponse.UnknownBundleIDs) > 0 {
+			log.WithFields(log.Fields{
+				"uuid":    ackRequest.UUID,
+				"bundles": ackResponse.UnknownBundleIDs,
+			}).Debug("REST client acknowledges bundles not in its inbox")
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(fetchResponse); err != nil {
-		log.WithError(err).Warn("Failed to write REST fetch response")
+	ra.writeResponse(w, status, ackResponse, "ack")
+}
+
+// handleDeadLetters returns the expired bundles sent from the node of some client's endpoint, called by /dead_letters.
+func (ra *RestAgent) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var (
+		fetchRequest  RestFetchRequest
+		fetchResponse RestFetchResponse
+		status        = http.StatusOK
+	)
+
+	if jsonErr := json.NewDecoder(r.Body).Decode(&fetchRequest); jsonErr != nil {
+		log.WithError(jsonErr).Warn("Failed to parse REST dead-letter request")
+		fetchResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
+	} else if eid, ok := ra.clients.Load(fetchRequest.UUID); !ok {
+		log.WithField("uuid", fetchRequest.UUID).Debug("REST client cannot fetch dead letters for unknown UUID")
+		fetchResponse.Error = "Invalid UUID"
+		status = http.StatusNotFound
+	} else if fetchRequest.Limit < 0 {
+		fetchResponse.Error = "Negative limit"
+		status = http.StatusBadRequest
+	} else {
+		bundles := ra.deadLetters.Fetch(eid.(bpv7.EndpointID), fetchRequest.Limit, !fetchRequest.Peek)
+
+		log.WithFields(log.Fields{
+			"uuid":    fetchRequest.UUID,
+			"bundles": len(bundles),
+			"peek":    fetchRequest.Peek,
+		}).Debug("REST client fetches dead letters")
+
+		fetchResponse.Bundles = bundles
+		fetchResponse.BundleIDs = make([]string, len(bundles))
+		for i, bundle := range bundles {
+			fetchResponse.BundleIDs[i] = bundle.ID().String()
+		}
 	}
+
+	ra.writeResponse(w, status, fetchResponse, "dead-letter")
 }
 
 // handleBuild creates and dispatches a new bundle, called by /build.
@@ -241,17 +496,21 @@ func (ra *RestAgent) handleBuild(w http.ResponseWriter, r *http.Request) {
 	var (
 		buildRequest  RestBuildRequest
 		buildResponse RestBuildResponse
+		status        = http.StatusOK
 	)
 
 	if jsonErr := json.NewDecoder(r.Body).Decode(&buildRequest); jsonErr != nil {
 		log.WithError(jsonErr).Warn("Failed to parse REST build request")
 		buildResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
 	} else if eid, ok := ra.clients.Load(buildRequest.UUID); !ok {
 		log.WithField("uuid", buildRequest.UUID).Debug("REST client cannot build for unknown UUID")
 		buildResponse.Error = "Invalid UUID"
-	} else if b, bErr := bpv7.BuildFromMap(buildRequest.Args); bErr != nil {
+		status = http.StatusNotFound
+	} else if b, bErr := bpv7.BuildFromMap(withDefaultSource(buildRequest.Args)); bErr != nil {
 		log.WithError(bErr).WithField("uuid", buildRequest.UUID).Warn("REST client failed to build a bundle")
 		buildResponse.Error = bErr.Error()
+		status = http.StatusBadRequest
 	} else if pb := b.PrimaryBlock; pb.SourceNode != eid && pb.ReportTo != eid {
 		msg := "REST client's endpoint is neither the source nor the report_to field"
 		log.WithFields(log.Fields{
@@ -260,18 +519,197 @@ func (ra *RestAgent) handleBuild(w http.ResponseWriter, r *http.Request) {
 			"bundle":   b.ID().String(),
 		}).Warn(msg)
 		buildResponse.Error = msg
+		status = http.StatusForbidden
+	} else if sErr := GetManagerSingleton().Send(&b); sErr != nil {
+		log.WithError(sErr).WithFields(log.Fields{
+			"uuid":   buildRequest.UUID,
+			"bundle": b.ID().String(),
+		}).Warn("REST client's bundle could not be sent")
+		buildResponse.Error = sErr.Error()
+		status = http.StatusServiceUnavailable
 	} else {
 		log.WithFields(log.Fields{
 			"uuid":   buildRequest.UUID,
 			"bundle": b.ID().String(),
 		}).Info("REST client sent bundle")
-		GetManagerSingleton().Send(&b)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(buildResponse); err != nil {
-		log.WithError(err).Warn("Failed to write REST build response")
+	ra.writeResponse(w, status, buildResponse, "build")
+}
+
+// handleAddPeer connects to a new peer, called by /peers/add.
+func (ra *RestAgent) handleAddPeer(w http.ResponseWriter, r *http.Request) {
+	var (
+		addRequest  RestAddPeerRequest
+		addResponse RestAddPeerResponse
+		status      = http.StatusOK
+	)
+
+	var (
+		claType cla.CLAType
+		address string
+		err     error
+		peerID  = bpv7.DtnNone()
+	)
+	if jsonErr := json.NewDecoder(r.Body).Decode(&addRequest); jsonErr != nil {
+		log.WithError(jsonErr).Warn("Failed to parse REST add peer request")
+		addResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
+	} else if claType, address, err = cla.ParseAddress(addRequest.Address); err != nil {
+		addResponse.Error = err.Error()
+		status = http.StatusBadRequest
+	} else if addRequest.EndpointId != "" {
+		if peerID, err = bpv7.NewEndpointID(addRequest.EndpointId); err != nil {
+			addResponse.Error = err.Error()
+			status = http.StatusBadRequest
+		}
+	}
+
+	if status == http.StatusOK {
+		addResponse.Address, err = connectPeer(claType, address, ra.nodeID, peerID)
+		if err != nil {
+			log.WithError(err).WithField("address", addRequest.Address).Warn("REST client failed to add a peer")
+			addResponse.Error = err.Error()
+			status = http.StatusBadGateway
+		} else {
+			log.WithFields(log.Fields{
+				"address": addRequest.Address,
+				"peer":    peerID,
+			}).Info("REST client added a peer")
+		}
+	}
+
+	ra.writeResponse(w, status, addResponse, "add peer")
+}
+
+// connectPeer creates a ConvergenceSender for the peer and registers it, returning its address once connected.
+func connectPeer(claType cla.CLAType, address string, nodeID, peerID bpv7.EndpointID) (string, error) {
+	manager, err := cla.LookupManagerSingleton()
+	if err != nil {
+		return "", err
+	}
+
+	conv, err := cla.NewPeer(claType, address, nodeID, peerID, manager.NotifyReceive)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := conv.(cla.ConvergenceSender); !ok {
+		return "", fmt.Errorf("%v CLA cannot send bundles", claType)
 	}
+
+	// a peer added anew might have been removed before
+	manager.AllowPeer(address)
+	if peerID != bpv7.DtnNone() {
+		manager.AllowPeer(peerID.String())
+	}
+
+	if err := manager.RegisterSync(conv); err != nil {
+		return "", err
+	}
+	return conv.Address(), nil
+}
+
+// handleRemovePeer disconnects a peer, called by /peers/remove.
+func (ra *RestAgent) handleRemovePeer(w http.ResponseWriter, r *http.Request) {
+	var (
+		removeRequest  RestRemovePeerRequest
+		removeResponse = RestRemovePeerResponse{Addresses: []string{}}
+		status         = http.StatusOK
+		peer           string
+	)
+
+	if jsonErr := json.NewDecoder(r.Body).Decode(&removeRequest); jsonErr != nil {
+		log.WithError(jsonErr).Warn("Failed to parse REST remove peer request")
+		removeResponse.Error = jsonErr.Error()
+		status = http.StatusBadRequest
+	} else if (removeRequest.Address == "") == (removeRequest.EndpointId == "") {
+		removeResponse.Error = "either an address or an endpoint_id is required"
+		status = http.StatusBadRequest
+	} else if removeRequest.Address != "" {
+		if _, address, err := cla.ParseAddress(removeRequest.Address); err != nil {
+			removeResponse.Error = err.Error()
+			status = http.StatusBadRequest
+		} else {
+			peer = address
+		}
+	} else if peerID, err := bpv7.NewEndpointID(removeRequest.EndpointId); err != nil {
+		removeResponse.Error = err.Error()
+		status = http.StatusBadRequest
+	} else if peerID == bpv7.DtnNone() {
+		removeResponse.Error = "dtn:none does not identify a peer"
+		status = http.StatusBadRequest
+	} else {
+		peer = peerID.String()
+	}
+
+	if status == http.StatusOK {
+		if manager, err := cla.LookupManagerSingleton(); err != nil {
+			removeResponse.Error = err.Error()
+			status = http.StatusInternalServerError
+		} else {
+			for _, sender := range manager.DisconnectPeer(peer) {
+				removeResponse.Addresses = append(removeResponse.Addresses, sender.Address())
+			}
+
+			log.WithFields(log.Fields{
+				"peer":         peer,
+				"disconnected": removeResponse.Addresses,
+			}).Info("REST client removed a peer")
+		}
+	}
+
+	ra.writeResponse(w, status, removeResponse, "remove peer")
+}
+
+// withDefaultSource sets the Manager's default source for build arguments without a source.
+func withDefaultSource(args map[string]interface{}) map[string]interface{} {
+	manager, err := LookupManagerSingleton()
+	if _, ok := args["source"]; ok || err != nil {
+		return args
+	}
+	if source := manager.DefaultSource(); source != (bpv7.EndpointID{}) {
+		if args == nil {
+			args = make(map[string]interface{})
+		}
+		args["source"] = source
+	}
+	return args
+}
+
+// handleStats returns the store's statistics and the CLAs' traffic, called by /stats.
+func (ra *RestAgent) handleStats(w http.ResponseWriter, _ *http.Request) {
+	var (
+		statsResponse RestStatsResponse
+		status        = http.StatusOK
+	)
+
+	if stats, err := store.GetStoreSingleton().Stats(); err != nil {
+		log.WithError(err).Warn("Failed to aggregate store statistics")
+		statsResponse.Error = err.Error()
+		status = http.StatusInternalServerError
+	} else {
+		statsResponse.Stats = stats
+	}
+
+	statsResponse.Traffic.Total = cla.TotalTraffic()
+	if manager, err := cla.LookupManagerSingleton(); err == nil {
+		statsResponse.Traffic.CLAs = manager.Traffic()
+	}
+
+	ra.writeResponse(w, status, statsResponse, "stats")
+}
+
+// sortBundles by their creation timestamp, oldest first, to return an inbox in a stable order.
+func sortBundles(bundles []bpv7.Bundle) {
+	sort.Slice(bundles, func(i, j int) bool {
+		ti, tj := bundles[i].PrimaryBlock.CreationTimestamp, bundles[j].PrimaryBlock.CreationTimestamp
+		if ti.DtnTime() != tj.DtnTime() {
+			return ti.DtnTime() < tj.DtnTime()
+		} else if ti.SequenceNumber() != tj.SequenceNumber() {
+			return ti.SequenceNumber() < tj.SequenceNumber()
+		}
+		return bundles[i].ID().String() < bundles[j].ID().String()
+	})
 }
 
 func (ra *RestAgent) Endpoints() (eids []bpv7.EndpointID) {
@@ -4,7 +4,11 @@
 
 package application_agent
 
-import "github.com/dtn7/dtn7-go/pkg/bpv7"
+import (
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
 
 // RestRegisterRequest describes a JSON to be POSTed to /register.
 type RestRegisterRequest struct {
@@ -28,14 +32,37 @@ type RestUnregisterResponse struct {
 }
 
 // RestFetchRequest describes a JSON to be POSTed to /fetch.
+//
+// At most Limit bundles are returned, unless Limit is zero. Peeked bundles are kept in the inbox until acknowledged
+// through /ack, while otherwise fetched bundles are removed.
 type RestFetchRequest struct {
-	UUID string `json:"uuid"`
+	UUID  string `json:"uuid"`
+	Limit int    `json:"limit,omitempty"`
+	Peek  bool   `json:"peek,omitempty"`
 }
 
 // RestFetchResponse describes a JSON response for /fetch.
+//
+// BundleIDs holds the string representation of each bundle's ID, in the same order as Bundles.
 type RestFetchResponse struct {
-	Error   string        `json:"error"`
-	Bundles []bpv7.Bundle `json:"bundles"`
+	Error     string        `json:"error"`
+	Bundles   []bpv7.Bundle `json:"bundles"`
+	BundleIDs []string      `json:"bundle_ids"`
+}
+
+// RestAckRequest describes a JSON to be POSTed to /ack, removing the acknowledged bundles from the inbox.
+type RestAckRequest struct {
+	UUID      string   `json:"uuid"`
+	BundleIDs []string `json:"bundle_ids"`
+}
+
+// RestAckResponse describes a JSON response for /ack.
+//
+// UnknownBundleIDs lists the acknowledged IDs which matched no bundle in the inbox, e.g., as they were acknowledged
+// before.
+type RestAckResponse struct {
+	Error            string   `json:"error"`
+	UnknownBundleIDs []string `json:"unknown_bundle_ids"`
 }
 
 // RestBuildRequest describes a JSON to be POSTed to /build.
@@ -48,3 +75,52 @@ type RestBuildRequest struct {
 type RestBuildResponse struct {
 	Error string `json:"error"`
 }
+
+// RestStatsResponse describes a JSON response for /stats.
+type RestStatsResponse struct {
+	Error   string           `json:"error"`
+	Stats   store.Stats      `json:"stats"`
+	Traffic RestTrafficStats `json:"traffic"`
+}
+
+// RestTrafficStats describes the bytes sent and received by the CLAs, as part of a RestStatsResponse.
+type RestTrafficStats struct {
+	// Total of all CLAs since dtnd was started, including those already disconnected
+	Total cla.Traffic `json:"total"`
+	// CLAs currently registered, identified by their addresses
+	CLAs map[string]cla.Traffic `json:"clas"`
+}
+
+// RestAddPeerRequest describes a JSON to be POSTed to /peers/add.
+//
+// Address combines the CLA type and its address, e.g., "mtcp://192.0.2.1:35038", see cla.ParseAddress. EndpointId
+// is the peer's node ID, if known in advance; dtn:none otherwise.
+type RestAddPeerRequest struct {
+	Address    string `json:"address"`
+	EndpointId string `json:"endpoint_id,omitempty"`
+}
+
+// RestAddPeerResponse describes a JSON response for /peers/add.
+//
+// Address identifies the connected CLA, as listed in the CLA traffic of /stats.
+type RestAddPeerResponse struct {
+	Error   string `json:"error"`
+	Address string `json:"address"`
+}
+
+// RestRemovePeerRequest describes a JSON to be POSTed to /peers/remove.
+//
+// The peer is identified either by its EndpointId or by its Address, combining the CLA type and its address like
+// RestAddPeerRequest.
+type RestRemovePeerRequest struct {
+	Address    string `json:"address,omitempty"`
+	EndpointId string `json:"endpoint_id,omitempty"`
+}
+
+// RestRemovePeerResponse describes a JSON response for /peers/remove.
+//
+// Addresses lists the disconnected CLAs, which might be empty if the peer was not connected.
+type RestRemovePeerResponse struct {
+	Error     string   `json:"error"`
+	Addresses []string `json:"addresses"`
+}
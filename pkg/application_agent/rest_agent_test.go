@@ -0,0 +1,483 @@
+package application_agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/mtcp"
+)
+
+func TestRestAgentAuthentication(t *testing.T) {
+	router := mux.NewRouter()
+	_ = NewRestAgent(router.PathPrefix("/rest").Subrouter(), "secret")
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	requests := []struct {
+		path string
+		body string
+	}{
+		{"/rest/register", `{"endpoint_id":"dtn://foo/bar"}`},
+		{"/rest/fetch", `{"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f"}`},
+		{"/rest/build", `{"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f","arguments":{}}`},
+	}
+
+	authorizations := []struct {
+		header     string
+		authorized bool
+	}{
+		{"", false},
+		{"Bearer", false},
+		{"Bearer wrong", false},
+		{"Basic secret", false},
+		{"Bearer secret", true},
+	}
+
+	for _, request := range requests {
+		for _, authorization := range authorizations {
+			req, err := http.NewRequest(http.MethodPost, server.URL+request.path, bytes.NewBufferString(request.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if authorization.header != "" {
+				req.Header.Set("Authorization", authorization.header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			// Authorized requests might still fail, e.g., for the unknown UUID
+			if unauthorized := resp.StatusCode == http.StatusUnauthorized; unauthorized == authorization.authorized {
+				t.Fatalf("%s with authorization %q resulted in %d",
+					request.path, authorization.header, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestRestAgentWithoutToken(t *testing.T) {
+	router := mux.NewRouter()
+	_ = NewRestAgent(router, "")
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/register", "application/json",
+		bytes.NewBufferString(`{"endpoint_id":"dtn://foo/bar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Request without a configured token resulted in %d", resp.StatusCode)
+	}
+}
+
+func TestRestAgentRandomUuid(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	ra := &RestAgent{}
+	uuids := make(map[string]bool)
+
+	for i := 0; i < 10000; i++ {
+		uuid, err := ra.randomUuid()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !uuidV4.MatchString(uuid) {
+			t.Fatalf("%s is no valid version 4 UUID", uuid)
+		}
+		if uuids[uuid] {
+			t.Fatalf("%s was generated twice", uuid)
+		}
+		uuids[uuid] = true
+	}
+}
+
+func TestRestAgentStatusCodes(t *testing.T) {
+	router := mux.NewRouter()
+	ra := NewRestAgent(router, "")
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(path, body string) (status int, response map[string]interface{}) {
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("%s returned no JSON body: %v", path, err)
+		}
+		return resp.StatusCode, response
+	}
+
+	status, response := post("/register", `{"endpoint_id":"dtn://foo/bar"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Registration failed with %d: %v", status, response)
+	}
+	uuid := response["uuid"].(string)
+
+	unknownUuid, err := ra.randomUuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/register", `{"endpoint_id":`, http.StatusBadRequest},
+		{"/register", `{"endpoint_id":"foo"}`, http.StatusBadRequest},
+
+		{"/fetch", `{"uuid":`, http.StatusBadRequest},
+		{"/fetch", `{"uuid":"` + unknownUuid + `"}`, http.StatusNotFound},
+		{"/fetch", `{"uuid":"` + uuid + `"}`, http.StatusOK},
+
+		{"/build", `{"uuid":`, http.StatusBadRequest},
+		{"/build", `{"uuid":"` + unknownUuid + `","arguments":{}}`, http.StatusNotFound},
+		{"/build", `{"uuid":"` + uuid + `","arguments":{"nope":1}}`, http.StatusBadRequest},
+		{"/build", `{"uuid":"` + uuid + `","arguments":{"destination":"dtn://dst/","source":"dtn://other/",` +
+			`"creation_timestamp_now":1,"lifetime":"24h","payload_block":"hello world"}}`, http.StatusForbidden},
+
+		{"/unregister", `{"uuid":`, http.StatusBadRequest},
+		{"/unregister", `{"uuid":"` + unknownUuid + `"}`, http.StatusNotFound},
+		{"/unregister", `{"uuid":"` + uuid + `"}`, http.StatusOK},
+		{"/unregister", `{"uuid":"` + uuid + `"}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		status, response := post(test.path, test.body)
+		if status != test.status {
+			t.Fatalf("%s with %s resulted in %d, expected %d: %v", test.path, test.body, status, test.status, response)
+		}
+		if errMsg, _ := response["error"].(string); (status == http.StatusOK) != (errMsg == "") {
+			t.Fatalf("%s with %s resulted in %d with error %q", test.path, test.body, status, errMsg)
+		}
+	}
+}
+
+func TestRestAgentFetchPeekAck(t *testing.T) {
+	router := mux.NewRouter()
+	ra := NewRestAgent(router, "")
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(path string, request, response interface{}) {
+		body, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s resulted in %d", path, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var registerResponse RestRegisterResponse
+	post("/register", RestRegisterRequest{EndpointId: "dtn://foo/bar"}, &registerResponse)
+	uuid := registerResponse.UUID
+
+	const bundleCount = 5
+	mailbox := make(map[bpv7.BundleID]bpv7.Bundle)
+	var expectedIDs []string
+	for i := 0; i < bundleCount; i++ {
+		b, err := bpv7.Builder().
+			Source("dtn://sender/app-1-2").
+			Destination("dtn://foo/bar").
+			CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+			Lifetime("24h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mailbox[b.ID()] = b
+		expectedIDs = append(expectedIDs, b.ID().String())
+	}
+	ra.mailboxes[uuid] = mailbox
+
+	fetch := func(request RestFetchRequest) []string {
+		request.UUID = uuid
+
+		// Bundles cannot be unmarshalled from JSON
+		var fetchResponse struct {
+			Bundles   []json.RawMessage `json:"bundles"`
+			BundleIDs []string          `json:"bundle_ids"`
+		}
+		post("/fetch", request, &fetchResponse)
+
+		if len(fetchResponse.Bundles) != len(fetchResponse.BundleIDs) {
+			t.Fatalf("Received %d bundles, but %d IDs", len(fetchResponse.Bundles), len(fetchResponse.BundleIDs))
+		}
+		return fetchResponse.BundleIDs
+	}
+
+	// Peeking does not remove bundles
+	for i := 0; i < 2; i++ {
+		if ids := fetch(RestFetchRequest{Peek: true}); !reflect.DeepEqual(ids, expectedIDs) {
+			t.Fatalf("Peeked %v, expected %v", ids, expectedIDs)
+		}
+	}
+
+	// Limiting returns the oldest bundles
+	if ids := fetch(RestFetchRequest{Peek: true, Limit: 2}); !reflect.DeepEqual(ids, expectedIDs[:2]) {
+		t.Fatalf("Peeked %v, expected %v", ids, expectedIDs[:2])
+	}
+
+	// Acknowledged bundles are removed, acknowledging them again is no error but reports them as unknown
+	for i := 0; i < 2; i++ {
+		var ackResponse RestAckResponse
+		post("/ack", RestAckRequest{UUID: uuid, BundleIDs: expectedIDs[:2]}, &ackResponse)
+		if ackResponse.Error != "" {
+			t.Fatal(ackResponse.Error)
+		}
+
+		var expectedUnknown []string
+		if i > 0 {
+			expectedUnknown = expectedIDs[:2]
+		}
+		if !reflect.DeepEqual(ackResponse.UnknownBundleIDs, expectedUnknown) {
+			t.Fatalf("Acknowledging reported %v as unknown, expected %v", ackResponse.UnknownBundleIDs, expectedUnknown)
+		}
+	}
+	if ids := fetch(RestFetchRequest{Peek: true}); !reflect.DeepEqual(ids, expectedIDs[2:]) {
+		t.Fatalf("Peeked %v after acknowledging, expected %v", ids, expectedIDs[2:])
+	}
+
+	// Fetching without peeking removes only the returned bundles
+	if ids := fetch(RestFetchRequest{Limit: 2}); !reflect.DeepEqual(ids, expectedIDs[2:4]) {
+		t.Fatalf("Fetched %v, expected %v", ids, expectedIDs[2:4])
+	}
+	if ids := fetch(RestFetchRequest{}); !reflect.DeepEqual(ids, expectedIDs[4:]) {
+		t.Fatalf("Fetched %v, expected %v", ids, expectedIDs[4:])
+	}
+	if ids := fetch(RestFetchRequest{}); len(ids) != 0 {
+		t.Fatalf("Fetched %v from an empty inbox", ids)
+	}
+}
+
+// freeAddress returns a local TCP address which is currently not listened on.
+func freeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// startPeer starts an MTCP server for dtn://peer/, passing the IDs of received bundles to the channel.
+func startPeer(t *testing.T, received chan<- bpv7.BundleID) (address string) {
+	address = freeAddress(t)
+	peer := mtcp.NewMTCPServer(address, bpv7.MustNewEndpointID("dtn://peer/"), func(bndl *bpv7.Bundle) {
+		received <- bndl.ID()
+	})
+	if err := peer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = peer.Close() })
+	return
+}
+
+// startPeersAgent starts a RestAgent serving the peers of dtn://node/.
+//
+// The CLA Manager is initialised once and kept for all tests, as MTCP clients still access it after being closed.
+// Only the senders are closed after each test.
+func startPeersAgent(t *testing.T) (post func(path string, request, response interface{}) int) {
+	if _, err := cla.LookupManagerSingleton(); err != nil {
+		err = cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, sender := range cla.GetManagerSingleton().GetSenders() {
+			_ = sender.Close()
+		}
+	})
+
+	router := mux.NewRouter()
+	ra := NewRestAgent(router, "")
+	ra.ServePeers(bpv7.MustNewEndpointID("dtn://node/"))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return func(path string, request, response interface{}) int {
+		body, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+}
+
+func TestRestAgentAddPeer(t *testing.T) {
+	received := make(chan bpv7.BundleID, 1)
+	peerAddress := startPeer(t, received)
+	post := startPeersAgent(t)
+
+	addPeer := func(request RestAddPeerRequest) (status int, response RestAddPeerResponse) {
+		status = post("/peers/add", request, &response)
+		return
+	}
+
+	failures := []struct {
+		request RestAddPeerRequest
+		status  int
+	}{
+		{RestAddPeerRequest{Address: peerAddress}, http.StatusBadRequest},
+		{RestAddPeerRequest{Address: "foo://" + peerAddress}, http.StatusBadRequest},
+		{RestAddPeerRequest{Address: "mtcp://" + peerAddress, EndpointId: "foo"}, http.StatusBadRequest},
+		{RestAddPeerRequest{Address: "mtcp://" + freeAddress(t)}, http.StatusBadGateway},
+	}
+	for _, failure := range failures {
+		if status, response := addPeer(failure.request); status != failure.status || response.Error == "" {
+			t.Fatalf("Adding %v resulted in %d, expected %d: %v", failure.request, status, failure.status, response)
+		}
+	}
+	if senders := cla.GetManagerSingleton().GetSenders(); len(senders) != 0 {
+		t.Fatalf("Failed requests registered %v", senders)
+	}
+
+	status, response := addPeer(RestAddPeerRequest{Address: "mtcp://" + peerAddress, EndpointId: "dtn://peer/"})
+	if status != http.StatusOK || response.Error != "" {
+		t.Fatalf("Adding peer resulted in %d: %v", status, response)
+	}
+
+	senders := cla.GetManagerSingleton().GetSenders()
+	if len(senders) != 1 || senders[0].Address() != response.Address {
+		t.Fatalf("Senders %v do not contain the added peer %s", senders, response.Address)
+	}
+	if peerID := senders[0].GetPeerEndpointID(); peerID != bpv7.MustNewEndpointID("dtn://peer/") {
+		t.Fatalf("Added peer has the endpoint ID %v", peerID)
+	}
+
+	bndl, err := bpv7.Builder().
+		Source("dtn://node/").
+		Destination("dtn://peer/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := senders[0].Send(bndl); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case id := <-received:
+		if id != bndl.ID() {
+			t.Fatalf("Peer received %v instead of %v", id, bndl.ID())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Peer received no bundle")
+	}
+}
+
+func TestRestAgentRemovePeer(t *testing.T) {
+	received := make(chan bpv7.BundleID, 1)
+	peerAddress := startPeer(t, received)
+	post := startPeersAgent(t)
+
+	var addResponse RestAddPeerResponse
+	addRequest := RestAddPeerRequest{Address: "mtcp://" + peerAddress, EndpointId: "dtn://peer/"}
+	if status := post("/peers/add", addRequest, &addResponse); status != http.StatusOK {
+		t.Fatalf("Adding peer resulted in %d: %v", status, addResponse)
+	}
+
+	removePeer := func(request RestRemovePeerRequest) (status int, response RestRemovePeerResponse) {
+		status = post("/peers/remove", request, &response)
+		return
+	}
+
+	failures := []RestRemovePeerRequest{
+		{},
+		{Address: "mtcp://" + peerAddress, EndpointId: "dtn://peer/"},
+		{Address: peerAddress},
+		{EndpointId: "foo"},
+		{EndpointId: "dtn:none"},
+	}
+	for _, failure := range failures {
+		if status, response := removePeer(failure); status != http.StatusBadRequest || response.Error == "" {
+			t.Fatalf("Removing %v resulted in %d: %v", failure, status, response)
+		}
+	}
+
+	status, response := removePeer(RestRemovePeerRequest{EndpointId: "dtn://peer/"})
+	if status != http.StatusOK || !reflect.DeepEqual(response.Addresses, []string{addResponse.Address}) {
+		t.Fatalf("Removing peer resulted in %d: %v", status, response)
+	}
+	if senders := cla.GetManagerSingleton().GetSenders(); len(senders) != 0 {
+		t.Fatalf("Senders %v still contain the removed peer", senders)
+	}
+
+	// The removed peer is not registered again when dialed, e.g., by the discovery
+	redial, err := cla.NewPeer(cla.MTCP, peerAddress, bpv7.MustNewEndpointID("dtn://node/"),
+		bpv7.MustNewEndpointID("dtn://peer/"), cla.GetManagerSingleton().NotifyReceive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cla.GetManagerSingleton().RegisterSync(redial); err == nil {
+		t.Fatal("Removed peer was registered again")
+	}
+	if senders := cla.GetManagerSingleton().GetSenders(); len(senders) != 0 {
+		t.Fatalf("Senders %v contain the removed peer", senders)
+	}
+
+	// Removing a peer which is not connected is no error
+	if status, response := removePeer(RestRemovePeerRequest{Address: "mtcp://" + peerAddress}); status != http.StatusOK ||
+		len(response.Addresses) != 0 {
+		t.Fatalf("Removing a disconnected peer resulted in %d: %v", status, response)
+	}
+
+	// Adding the peer again lifts the block
+	if status := post("/peers/add", addRequest, &addResponse); status != http.StatusOK {
+		t.Fatalf("Adding peer again resulted in %d: %v", status, addResponse)
+	}
+	if senders := cla.GetManagerSingleton().GetSenders(); len(senders) != 1 {
+		t.Fatalf("Senders %v do not contain the added peer", senders)
+	}
+}
@@ -18,6 +18,9 @@ import (
 const (
 	// AdminRecordTypeStatusReport is the administrative record type code for a status report.
 	AdminRecordTypeStatusReport uint64 = 1
+
+	// AdminRecordTypeHandoffAck is the custom administrative record type code for a HandoffAck.
+	AdminRecordTypeHandoffAck uint64 = 192
 )
 
 // AdministrativeRecord describes an administrative record, e.g., a status report.
@@ -125,6 +128,7 @@ func GetAdministrativeRecordManager() *AdministrativeRecordManager {
 		administrativeRecordManager = NewAdministrativeRecordManager()
 
 		_ = administrativeRecordManager.Register(&StatusReport{})
+		_ = administrativeRecordManager.Register(&HandoffAck{})
 	}
 
 	return administrativeRecordManager
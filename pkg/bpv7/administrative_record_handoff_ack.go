@@ -0,0 +1,57 @@
+package bpv7
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dtn7/cboring"
+)
+
+// HandoffAck is a custom administrative record, acknowledging that a node took over a bundle forwarded to it.
+//
+// Cooperating nodes exchange it for an acknowledged handoff, similar to custody transfer. The forwarding node may
+// delete its copy of the referenced bundle after receiving the acknowledgement.
+type HandoffAck struct {
+	RefBundle BundleID
+}
+
+// NewHandoffAck creates a HandoffAck for the given bundle.
+func NewHandoffAck(bndl Bundle) *HandoffAck {
+	return &HandoffAck{RefBundle: bndl.ID()}
+}
+
+func (ha *HandoffAck) MarshalCbor(w io.Writer) error {
+	if err := cboring.WriteArrayLength(ha.RefBundle.Len(), w); err != nil {
+		return err
+	}
+
+	if err := cboring.Marshal(&ha.RefBundle, w); err != nil {
+		return fmt.Errorf("marshalling BundleID failed: %v", err)
+	}
+	return nil
+}
+
+func (ha *HandoffAck) UnmarshalCbor(r io.Reader) error {
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if n == 2 {
+		ha.RefBundle.IsFragment = false
+	} else if n == 4 {
+		ha.RefBundle.IsFragment = true
+	} else {
+		return fmt.Errorf("expected array of length 2 or 4, got %d", n)
+	}
+
+	if err := cboring.Unmarshal(&ha.RefBundle, r); err != nil {
+		return fmt.Errorf("unmarshalling BundleID failed: %v", err)
+	}
+	return nil
+}
+
+func (ha *HandoffAck) RecordTypeCode() uint64 {
+	return AdminRecordTypeHandoffAck
+}
+
+func (ha HandoffAck) String() string {
+	return fmt.Sprintf("HandoffAck(%v)", ha.RefBundle)
+}
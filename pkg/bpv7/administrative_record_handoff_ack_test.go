@@ -0,0 +1,40 @@
+package bpv7
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestHandoffAckCbor(t *testing.T) {
+	bndl, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dest/").
+		CreationTimestampNow().
+		Lifetime("60s").
+		PayloadBlock([]byte("hello world!")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fragmentID := bndl.ID()
+	fragmentID.IsFragment = true
+	fragmentID.FragmentOffset = 4
+	fragmentID.TotalDataLength = 12
+
+	for _, ack := range []*HandoffAck{NewHandoffAck(bndl), {RefBundle: fragmentID}} {
+		buff := new(bytes.Buffer)
+		if err := GetAdministrativeRecordManager().WriteAdministrativeRecord(ack, buff); err != nil {
+			t.Fatal(err)
+		}
+
+		ar, err := GetAdministrativeRecordManager().ReadAdministrativeRecord(buff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ar, ack) {
+			t.Fatalf("CBOR result differs:\n%v\n%v", ack, ar)
+		}
+	}
+}
@@ -97,6 +97,17 @@ func (b *Bundle) ExtensionBlock(blockType uint64) (*CanonicalBlock, error) {
 	}
 }
 
+// UnknownBlocks returns all this Bundle's canonical blocks of types unknown to this node, i.e., not registered at the
+// ExtensionBlockManager. Such blocks are represented by a GenericExtensionBlock.
+func (b *Bundle) UnknownBlocks() (cbs []*CanonicalBlock) {
+	for i := 0; i < len(b.CanonicalBlocks); i++ {
+		if _, unknown := b.CanonicalBlocks[i].Value.(*GenericExtensionBlock); unknown {
+			cbs = append(cbs, &b.CanonicalBlocks[i])
+		}
+	}
+	return
+}
+
 // HasExtensionBlock checks if a CanonicalBlock / ExtensionBlock for some block type number is present.
 func (b *Bundle) HasExtensionBlock(blockType uint64) bool {
 	_, err := b.ExtensionBlocks(blockType)
@@ -187,6 +198,30 @@ func (b *Bundle) SetCRCType(crcType CRCType) {
 	})
 }
 
+// UpgradeCRCType sets the given CRCType for each block with a weaker CRC, i.e., no CRC or a CRC16 for a CRC32. Their
+// CRC values are calculated when this Bundle is serialised.
+//
+// A SignatureBlock covers the primary and payload block, including their CRCs. Thus, these blocks are left unaltered
+// in a signed Bundle to keep its signature valid. The number of upgraded blocks is returned.
+func (b *Bundle) UpgradeCRCType(minimum CRCType) (upgraded int) {
+	signed := b.HasExtensionBlock(ExtBlockTypeSignatureBlock)
+
+	b.forEachBlock(func(blck block) {
+		if blck.GetCRCType() >= minimum {
+			return
+		}
+		if signed {
+			if cb, isCanonical := blck.(*CanonicalBlock); !isCanonical || cb.TypeCode() == ExtBlockTypePayloadBlock {
+				return
+			}
+		}
+
+		blck.SetCRCType(minimum)
+		upgraded++
+	})
+	return
+}
+
 // ID returns a BundleID representing this Bundle.
 func (b Bundle) ID() BundleID {
 	return BundleID{
@@ -203,19 +238,59 @@ func (b Bundle) String() string {
 	return b.ID().String()
 }
 
+// IsAgeOnly returns if this Bundle's lifetime can only be evaluated by its Bundle Age Block. This is the case for
+// bundles created by a node without an accurate clock, which sets the creation timestamp's time to zero.
+func (b Bundle) IsAgeOnly() bool {
+	return b.PrimaryBlock.CreationTimestamp.IsZeroTime() && b.HasExtensionBlock(ExtBlockTypeBundleAgeBlock)
+}
+
 // IsLifetimeExceeded of this Bundle by checking an optional Bundle Age Block and the PrimaryBlock's Lifetime.
+//
+// If a Bundle Age Block is present, the lifetime is exceeded as soon as the bundle's age is greater than its
+// lifetime. If the creation timestamp's time is set, the lifetime is also exceeded when the current time is past
+// the creation time plus the lifetime and the ClockSkewTolerance. An age-only bundle is evaluated without consulting
+// the wall clock.
 func (b Bundle) IsLifetimeExceeded() bool {
-	if b.PrimaryBlock.CreationTimestamp.IsZeroTime() {
-		if bab, err := b.ExtensionBlock(ExtBlockTypeBundleAgeBlock); err != nil {
+	if bab, err := b.ExtensionBlock(ExtBlockTypeBundleAgeBlock); err == nil {
+		if bab.Value.(*BundleAgeBlock).Age() > b.PrimaryBlock.Lifetime {
 			return true
-		} else {
-			return bab.Value.(*BundleAgeBlock).Age() > b.PrimaryBlock.Lifetime
 		}
+	} else if b.PrimaryBlock.CreationTimestamp.IsZeroTime() {
+		return true
+	}
+
+	if b.PrimaryBlock.CreationTimestamp.IsZeroTime() {
+		return false
 	}
 
 	maxTimestamp := b.PrimaryBlock.CreationTimestamp.DtnTime().Time().Add(
-		time.Duration(b.PrimaryBlock.Lifetime) * time.Millisecond)
-	return time.Now().After(maxTimestamp)
+		time.Duration(b.PrimaryBlock.Lifetime)*time.Millisecond + clockSkewTolerance)
+	return now().After(maxTimestamp)
+}
+
+// IsCreatedInFuture checks if the bundle's creation time lies further in the future than the ClockSkewTolerance.
+//
+// Unlike an exceeded lifetime, this is not checked by CheckValid, as a bundle's validity should not depend on the
+// clock of the node checking it. A receiving node might drop such bundles nevertheless.
+func (b Bundle) IsCreatedInFuture() bool {
+	if b.PrimaryBlock.CreationTimestamp.IsZeroTime() {
+		return false
+	}
+
+	return b.PrimaryBlock.CreationTimestamp.DtnTime().Time().After(now().Add(clockSkewTolerance))
+}
+
+// IncrementBundleAge adds an offset in milliseconds to this Bundle's Bundle Age Block and returns the new age.
+//
+// This should be called before forwarding a bundle, passing the time the bundle resided at this node. An error is
+// returned if there is no Bundle Age Block.
+func (b *Bundle) IncrementBundleAge(offset uint64) (uint64, error) {
+	bab, err := b.ExtensionBlock(ExtBlockTypeBundleAgeBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	return bab.Value.(*BundleAgeBlock).Increment(offset), nil
 }
 
 // CheckValid returns an array of errors for incorrect data.
@@ -6,9 +6,13 @@ package bpv7
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"time"
+
+	"github.com/dtn7/cboring"
 )
 
 // BundleBuilder is a simple framework to create bundles by method chaining.
@@ -29,6 +33,7 @@ type BundleBuilder struct {
 	canonicals       []CanonicalBlock
 	canonicalCounter uint64
 	crcType          CRCType
+	ageOnly          bool
 }
 
 // Builder creates a new BundleBuilder.
@@ -75,6 +80,25 @@ func (bldr *BundleBuilder) Build() (bndl Bundle, err error) {
 		return
 	}
 
+	// Age-only bundles have a zero creation time and require a Bundle Age Block
+	if bldr.ageOnly {
+		bldr.primary.CreationTimestamp = NewCreationTimestamp(DtnTimeEpoch, 0)
+
+		hasAgeBlock := false
+		for _, cb := range bldr.canonicals {
+			if cb.TypeCode() == ExtBlockTypeBundleAgeBlock {
+				hasAgeBlock = true
+				break
+			}
+		}
+		if !hasAgeBlock {
+			if bldr.BundleAgeBlock(0); bldr.err != nil {
+				err = bldr.err
+				return
+			}
+		}
+	}
+
 	bndl, err = NewBundle(bldr.primary, bldr.canonicals)
 	if err == nil {
 		bndl.SetCRCType(bldr.crcType)
@@ -142,6 +166,42 @@ func bldrParseLifetime(duration interface{}) (ms uint64, err error) {
 	return
 }
 
+// LifetimeError is returned by BuildFromMap for a lifetime which is invalid, zero, or already expired.
+type LifetimeError struct {
+	reason string
+}
+
+// NewLifetimeError creates a LifetimeError for the given reason.
+func NewLifetimeError(reason string) *LifetimeError {
+	return &LifetimeError{reason: reason}
+}
+
+func (err *LifetimeError) Error() string {
+	return fmt.Sprintf("invalid lifetime: %s", err.reason)
+}
+
+// checkLifetime returns a LifetimeError if the bundle to be built has a zero lifetime or would be born expired.
+func (bldr *BundleBuilder) checkLifetime() error {
+	if bldr.primary.Lifetime == 0 {
+		return NewLifetimeError("lifetime is zero")
+	}
+
+	probe := Bundle{PrimaryBlock: bldr.primary, CanonicalBlocks: bldr.canonicals}
+	if bldr.ageOnly {
+		probe.PrimaryBlock.CreationTimestamp = NewCreationTimestamp(DtnTimeEpoch, 0)
+	}
+
+	// Without a Bundle Age Block, age-only bundles start with a zero age and zero creation times are invalid anyway
+	if !probe.HasExtensionBlock(ExtBlockTypeBundleAgeBlock) && probe.PrimaryBlock.CreationTimestamp.IsZeroTime() {
+		return nil
+	}
+
+	if probe.IsLifetimeExceeded() {
+		return NewLifetimeError("bundle is already expired at its creation")
+	}
+	return nil
+}
+
 // PrimaryBlock related methods
 
 // Destination sets the bundle's destination, stored in its primary block.
@@ -216,6 +276,17 @@ func (bldr *BundleBuilder) CreationTimestampTime(t time.Time) *BundleBuilder {
 	return bldr.creationTimestamp(DtnTimeFromTime(t))
 }
 
+// AgeOnly creates a bundle whose lifetime is only tracked by a Bundle Age Block, as intended for nodes without an
+// accurate clock. The creation timestamp's time will be set to zero and, if none was added, a Bundle Age Block
+// with an age of zero will be added on Build.
+func (bldr *BundleBuilder) AgeOnly() *BundleBuilder {
+	if bldr.err == nil {
+		bldr.ageOnly = true
+	}
+
+	return bldr
+}
+
 // Lifetime sets the bundle's lifetime, stored in its primary block. Possible
 // values are an uint/int, representing the lifetime in milliseconds, a format
 // string (compare time.ParseDuration) for the duration or a time.Duration.
@@ -379,6 +450,28 @@ func (bldr *BundleBuilder) HopCountBlock(args ...interface{}) *BundleBuilder {
 	return bldr.Canonical(NewHopCountBlock(uint8(limit)), flags)
 }
 
+// RouteRecordBlock adds a route record block to this bundle. The parameters are:
+//
+//	Limit[, BlockControlFlags]
+//
+//	where Limit is the maximum number of recorded nodes and
+//	BlockControlFlags are _optional_ block processing control flags
+func (bldr *BundleBuilder) RouteRecordBlock(args ...interface{}) *BundleBuilder {
+	if bldr.err != nil {
+		return bldr
+	}
+
+	limit, chk := args[0].(int)
+	if !chk || limit < 0 {
+		bldr.err = fmt.Errorf("RouteRecordBlock received wrong parameter type")
+		return bldr
+	}
+
+	flags := bldr.canonicalParseFlags(args) | ReplicateBlock
+
+	return bldr.Canonical(NewRouteRecordBlock(uint64(limit)), flags)
+}
+
 // PayloadBlock adds a payload block to this bundle. The parameters are:
 //
 //	Data[, BlockControlFlags]
@@ -425,6 +518,41 @@ func (bldr *BundleBuilder) PreviousNodeBlock(args ...interface{}) *BundleBuilder
 	return bldr.Canonical(NewPreviousNodeBlock(eid), flags)
 }
 
+// ExtensionBlockData adds an extension block of a type registered at the ExtensionBlockManager, which is unmarshalled
+// from its block-type-specific data. This allows adding blocks without knowing their Go type, e.g., from a map passed
+// to BuildFromMap. Unregistered block types, the payload block, and invalid data result in an error.
+func (bldr *BundleBuilder) ExtensionBlockData(typeCode uint64, data []byte, flags BlockControlFlags) *BundleBuilder {
+	if bldr.err != nil {
+		return bldr
+	}
+
+	ebm := GetExtensionBlockManager()
+	if typeCode == ExtBlockTypePayloadBlock {
+		bldr.err = fmt.Errorf("payload block cannot be added as an extension block")
+		return bldr
+	} else if !ebm.IsKnown(typeCode) {
+		bldr.err = fmt.Errorf("block type code %d is not registered", typeCode)
+		return bldr
+	}
+
+	buff := new(bytes.Buffer)
+	if err := cboring.WriteByteString(data, buff); err != nil {
+		bldr.err = err
+		return bldr
+	}
+	block, err := ebm.ReadBlock(typeCode, buff)
+	if err != nil {
+		bldr.err = fmt.Errorf("parsing data of block type code %d failed: %w", typeCode, err)
+		return bldr
+	}
+	if err := block.CheckValid(); err != nil {
+		bldr.err = fmt.Errorf("block of type code %d is invalid: %w", typeCode, err)
+		return bldr
+	}
+
+	return bldr.Canonical(block, flags)
+}
+
 // AdministrativeRecord configures an AdministrativeRecord as the Payload. Furthermore, the AdministrativeRecordPayload
 // BundleControlFlags is set.
 func (bldr *BundleBuilder) AdministrativeRecord(ar AdministrativeRecord) *BundleBuilder {
@@ -491,9 +619,105 @@ func (bldr *BundleBuilder) StatusReport(args ...interface{}) *BundleBuilder {
 	return bldr.AdministrativeRecord(NewStatusReport(bundle, statusItem, reason, t))
 }
 
+// bldrParseUint returns an unsigned integer for a given integer or an integral float64, as decoded from JSON.
+func bldrParseUint(value interface{}) (n uint64, err error) {
+	switch value := value.(type) {
+	case uint64:
+		n = value
+	case int:
+		if value < 0 {
+			err = fmt.Errorf("%d is negative", value)
+		} else {
+			n = uint64(value)
+		}
+	case float64:
+		// float64(math.MaxUint64) is rounded up to 2^64, which is already out of range
+		if value < 0 || value != math.Trunc(value) || value >= math.MaxUint64 {
+			err = fmt.Errorf("%f is no unsigned integer", value)
+		} else {
+			n = uint64(value)
+		}
+	default:
+		err = fmt.Errorf("%T is an unsupported type to parse an unsigned integer from", value)
+	}
+	return
+}
+
+// bldrNodeManagedBlocks are the block types set by the nodes processing a bundle, by their routing algorithms, or by
+// the signing application agent manager. BuildFromMap's "extension_blocks" must not contain them, as a client could
+// otherwise forge them.
+var bldrNodeManagedBlocks = map[uint64]bool{
+	ExtBlockTypePreviousNodeBlock: true,
+	ExtBlockTypeBundleAgeBlock:    true,
+	ExtBlockTypeHopCountBlock:     true,
+	ExtBlockTypeBinarySprayBlock:  true,
+	ExtBlockTypeDTLSRBlock:        true,
+	ExtBlockTypeProphetBlock:      true,
+	ExtBlockTypeSignatureBlock:    true,
+	ExtBlockTypeRouteRecordBlock:  true,
+}
+
+// bldrExtensionBlocks adds the extension blocks of BuildFromMap's "extension_blocks" list through ExtensionBlockData.
+//
+// Each entry is a map of the block's "type" code, its optional block control "flags", and its block-type-specific
+// "data", either as a byte slice or as a base64 encoded string, as []byte is represented in JSON. Block types managed
+// by the nodes, see bldrNodeManagedBlocks, are rejected; a hop limit or an initial bundle age can be set through the
+// dedicated "hop_count_block" and "bundle_age_block" arguments.
+func bldrExtensionBlocks(bldr *BundleBuilder, args interface{}) error {
+	var entries []map[string]interface{}
+	switch args := args.(type) {
+	case []map[string]interface{}:
+		entries = args
+	case []interface{}:
+		for _, arg := range args {
+			entry, ok := arg.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("extension block entry %T is no map", arg)
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return fmt.Errorf("extension_blocks needs a list, not %T", args)
+	}
+
+	for i, entry := range entries {
+		typeCode, err := bldrParseUint(entry["type"])
+		if err != nil {
+			return fmt.Errorf("extension block %d has an invalid type: %w", i, err)
+		} else if bldrNodeManagedBlocks[typeCode] {
+			return fmt.Errorf("extension block %d has the node-managed type code %d", i, typeCode)
+		}
+
+		var flags uint64
+		if flagsArg, ok := entry["flags"]; ok {
+			if flags, err = bldrParseUint(flagsArg); err != nil {
+				return fmt.Errorf("extension block %d has invalid flags: %w", i, err)
+			}
+		}
+
+		var data []byte
+		switch dataArg := entry["data"].(type) {
+		case []byte:
+			data = dataArg
+		case string:
+			if data, err = base64.StdEncoding.DecodeString(dataArg); err != nil {
+				return fmt.Errorf("extension block %d has invalid data: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("extension block %d needs data as bytes or a base64 string, not %T", i, dataArg)
+		}
+
+		if err := bldr.ExtensionBlockData(typeCode, data, BlockControlFlags(flags)).Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // BuildFromMap creates a Bundle from a map which "calls" the BundleBuilder's methods.
 //
 // This function does not use reflection or other dark magic. So it is safe to be called by unchecked data.
+// An invalid, zero, or already expired lifetime results in a LifetimeError.
 //
 //	args := map[string]interface{}{
 //	  "destination":            "dtn://dst/",
@@ -501,6 +725,9 @@ func (bldr *BundleBuilder) StatusReport(args ...interface{}) *BundleBuilder {
 //	  "creation_timestamp_now": true,
 //	  "lifetime":               "24h",
 //	  "payload_block":          "hello world",
+//	  "extension_blocks": []interface{}{
+//	    map[string]interface{}{"type": 10, "flags": 0, "data": []byte{0x82, 0x18, 0x40, 0x00}},
+//	  },
 //	}
 //	b, err := BuildFromMap(args)
 func BuildFromMap(m map[string]interface{}) (bndl Bundle, err error) {
@@ -536,9 +763,17 @@ func BuildFromMap(m map[string]interface{}) (bndl Bundle, err error) {
 				err = fmt.Errorf("creation_timestamp_time needs a time.Time, not %T", args)
 			}
 
+		// func (bldr *BundleBuilder) AgeOnly() *BundleBuilder
+		case "age_only":
+			bldr.AgeOnly()
+
 		// func (bldr *BundleBuilder) Lifetime(duration interface{}) *BundleBuilder
 		case "lifetime":
-			bldr.Lifetime(args)
+			if ms, lifetimeErr := bldrParseLifetime(args); lifetimeErr != nil {
+				err = NewLifetimeError(lifetimeErr.Error())
+			} else {
+				bldr.Lifetime(ms)
+			}
 
 		// func (bldr *BundleBuilder) BundleCtrlFlags(bcf BundleControlFlags) *BundleBuilder
 		case "bundle_ctrl_flags":
@@ -557,6 +792,14 @@ func BuildFromMap(m map[string]interface{}) (bndl Bundle, err error) {
 		case "hop_count_block":
 			bldr.HopCountBlock(args)
 
+		// func (bldr *BundleBuilder) RouteRecordBlock(args ...interface{}) *BundleBuilder
+		case "route_record_block":
+			if fArgs, ok := args.(float64); ok {
+				bldr.RouteRecordBlock(int(fArgs))
+			} else {
+				bldr.RouteRecordBlock(args)
+			}
+
 		// func (bldr *BundleBuilder) PayloadBlock(args ...interface{}) *BundleBuilder
 		case "payload_block":
 			if sArgs, ok := args.(string); ok {
@@ -569,6 +812,10 @@ func BuildFromMap(m map[string]interface{}) (bndl Bundle, err error) {
 		case "previous_node_block":
 			bldr.PreviousNodeBlock(args)
 
+		// func (bldr *BundleBuilder) ExtensionBlockData(typeCode uint64, data []byte, flags BlockControlFlags) *BundleBuilder
+		case "extension_blocks":
+			err = bldrExtensionBlocks(bldr, args)
+
 		default:
 			err = fmt.Errorf("method %s is either not implemented or not existing", method)
 		}
@@ -581,5 +828,9 @@ func BuildFromMap(m map[string]interface{}) (bndl Bundle, err error) {
 		}
 	}
 
+	if err = bldr.checkLifetime(); err != nil {
+		return
+	}
+
 	return bldr.Build()
 }
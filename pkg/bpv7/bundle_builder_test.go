@@ -6,7 +6,11 @@ package bpv7
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -289,3 +293,164 @@ func TestBuildFromMapJSON(t *testing.T) {
 		t.Fatalf("%v != %v", expectedBndl, bndl)
 	}
 }
+
+func TestBuildFromMapExtensionBlocks(t *testing.T) {
+	// Only third party blocks may be added, as the known block types are managed by the nodes
+	ebm := GetExtensionBlockManager()
+	if err := ebm.RegisterConstructor(250, func() ExtensionBlock { return &counterBlock{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer ebm.UnregisterTypeCode(250)
+
+	counterData, err := (&counterBlock{counter: 64}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonArgs map[string]interface{}
+	jsonData := []byte(`{
+		"destination":              "dtn://dst/",
+		"source":                   "dtn://src/",
+		"creation_timestamp_now":   1,
+		"lifetime":                 "24h",
+		"payload_block":            "hello world",
+		"extension_blocks":         [{"type": 250, "flags": 1, "data": "` +
+		base64.StdEncoding.EncodeToString(counterData) + `"}]
+	}`)
+	if err := json.Unmarshal(jsonData, &jsonArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	mapArgs := map[string]interface{}{
+		"destination":            "dtn://dst/",
+		"source":                 "dtn://src/",
+		"creation_timestamp_now": true,
+		"lifetime":               "24h",
+		"payload_block":          "hello world",
+		"extension_blocks": []map[string]interface{}{
+			{"type": uint64(250), "flags": uint64(ReplicateBlock), "data": counterData},
+		},
+	}
+
+	for name, args := range map[string]map[string]interface{}{"json": jsonArgs, "map": mapArgs} {
+		bndl, err := BuildFromMap(args)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		buff := new(bytes.Buffer)
+		if err := bndl.MarshalCbor(buff); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		parsed, err := ParseBundle(buff)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(bndl, parsed) {
+			t.Fatalf("%s: %v != %v", name, bndl, parsed)
+		}
+		block, err := parsed.ExtensionBlock(250)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if block.BlockControlFlags != ReplicateBlock {
+			t.Fatalf("%s: block control flags %v != %v", name, block.BlockControlFlags, ReplicateBlock)
+		}
+		if counter := block.Value.(*counterBlock).counter; counter != 64 {
+			t.Fatalf("%s: counter %d != 64", name, counter)
+		}
+	}
+}
+
+func TestBuildFromMapExtensionBlocksInvalid(t *testing.T) {
+	ebm := GetExtensionBlockManager()
+	if err := ebm.RegisterConstructor(250, func() ExtensionBlock { return &counterBlock{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer ebm.UnregisterTypeCode(250)
+
+	tests := []struct {
+		name    string
+		entries interface{}
+	}{
+		{"no list", "nope"},
+		{"no map", []interface{}{23}},
+		{"unknown type", []interface{}{map[string]interface{}{"type": 251.0, "data": ""}}},
+		{"payload type", []interface{}{map[string]interface{}{"type": 1.0, "data": ""}}},
+		{"negative type", []interface{}{map[string]interface{}{"type": -250.0, "data": ""}}},
+		{"fractional type", []interface{}{map[string]interface{}{"type": 250.5, "data": ""}}},
+		{"missing type", []interface{}{map[string]interface{}{"data": ""}}},
+		{"missing data", []interface{}{map[string]interface{}{"type": 250.0}}},
+		{"invalid base64", []interface{}{map[string]interface{}{"type": 250.0, "data": "!"}}},
+		{"invalid data", []interface{}{map[string]interface{}{"type": 250.0, "data": []byte{0xff}}}},
+		{"out of range type", []interface{}{map[string]interface{}{"type": float64(math.MaxUint64), "data": ""}}},
+	}
+	for typeCode := range bldrNodeManagedBlocks {
+		tests = append(tests, struct {
+			name    string
+			entries interface{}
+		}{
+			fmt.Sprintf("node-managed type %d", typeCode),
+			[]interface{}{map[string]interface{}{"type": float64(typeCode), "data": ""}},
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildFromMap(map[string]interface{}{
+				"destination":              "dtn://dst/",
+				"source":                   "dtn://src/",
+				"creation_timestamp_epoch": true,
+				"lifetime":                 "24h",
+				"payload_block":            "hello world",
+				"extension_blocks":         tt.entries,
+			})
+			if err == nil {
+				t.Fatal("BuildFromMap accepted invalid extension blocks")
+			}
+		})
+	}
+}
+
+func TestBuildFromMapLifetime(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"creation_timestamp_now": true, "lifetime": "1h"}, false},
+		{"valid age", map[string]interface{}{"age_only": true, "bundle_age_block": 1000, "lifetime": "1h"}, false},
+		{"missing", map[string]interface{}{"creation_timestamp_now": true}, true},
+		{"zero int", map[string]interface{}{"creation_timestamp_now": true, "lifetime": 0}, true},
+		{"zero string", map[string]interface{}{"creation_timestamp_now": true, "lifetime": "0s"}, true},
+		{"negative", map[string]interface{}{"creation_timestamp_now": true, "lifetime": -1000}, true},
+		{"unparsable", map[string]interface{}{"creation_timestamp_now": true, "lifetime": "forever"}, true},
+		{"born expired", map[string]interface{}{
+			"creation_timestamp_time": time.Now().Add(-48 * time.Hour),
+			"lifetime":                "24h",
+		}, true},
+		{"aged out", map[string]interface{}{"age_only": true, "bundle_age_block": 7200000, "lifetime": "1h"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]interface{}{
+				"destination":   "dtn://dst/",
+				"source":        "dtn://src/",
+				"payload_block": "hello world",
+			}
+			for k, v := range tt.args {
+				args[k] = v
+			}
+
+			_, err := BuildFromMap(args)
+			var lifetimeErr *LifetimeError
+			if isLifetimeErr := errors.As(err, &lifetimeErr); isLifetimeErr != tt.wantErr {
+				t.Fatalf("BuildFromMap() error = %v, want LifetimeError %t", err, tt.wantErr)
+			} else if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package bpv7
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/dtn7/cboring"
@@ -29,17 +30,69 @@ type BundleID struct {
 	TotalDataLength uint64
 }
 
+// String returns a textual representation of this BundleID, which can be parsed again by NewBundleID.
+//
+// The representation is "SOURCE-TIME-SEQUENCE" for a non-fragmented and "SOURCE-TIME-SEQUENCE:OFFSET:LENGTH" for a
+// fragmented bundle. The fragment's fields are separated by colons to distinguish them from a source node whose
+// EndpointID might also end with dash-separated numbers. This representation is also used as the store's key.
 func (bid BundleID) String() string {
 	var bldr strings.Builder
 
 	_, _ = fmt.Fprintf(&bldr, "%v-%d-%d", bid.SourceNode, bid.Timestamp[0], bid.Timestamp[1])
 	if bid.IsFragment {
-		_, _ = fmt.Fprintf(&bldr, "-%d-%d", bid.FragmentOffset, bid.TotalDataLength)
+		_, _ = fmt.Fprintf(&bldr, ":%d:%d", bid.FragmentOffset, bid.TotalDataLength)
 	}
 
 	return bldr.String()
 }
 
+// NewBundleID parses a BundleID from its textual representation, as returned by BundleID.String.
+func NewBundleID(s string) (bid BundleID, err error) {
+	// The source node's EndpointID might contain both dashes and colons. Thus, the string is parsed from its end.
+	seqSep := strings.LastIndex(s, "-")
+	if seqSep < 0 {
+		err = fmt.Errorf("BundleID %q misses its creation timestamp", s)
+		return
+	}
+
+	var tail []uint64
+	for _, field := range strings.Split(s[seqSep+1:], ":") {
+		if n, nErr := strconv.ParseUint(field, 10, 64); nErr != nil {
+			err = fmt.Errorf("BundleID %q has an invalid numeric field %q: %v", s, field, nErr)
+			return
+		} else {
+			tail = append(tail, n)
+		}
+	}
+
+	switch len(tail) {
+	case 1:
+	case 3:
+		bid.IsFragment = true
+		bid.FragmentOffset = tail[1]
+		bid.TotalDataLength = tail[2]
+	default:
+		err = fmt.Errorf("BundleID %q has %d instead of one or three colon-separated trailing fields", s, len(tail))
+		return
+	}
+
+	timeSep := strings.LastIndex(s[:seqSep], "-")
+	if timeSep < 0 {
+		err = fmt.Errorf("BundleID %q misses its creation time", s)
+		return
+	}
+
+	creationTime, timeErr := strconv.ParseUint(s[timeSep+1:seqSep], 10, 64)
+	if timeErr != nil {
+		err = fmt.Errorf("BundleID %q has an invalid creation time: %v", s, timeErr)
+		return
+	}
+	bid.Timestamp = NewCreationTimestamp(DtnTime(creationTime), tail[0])
+
+	bid.SourceNode, err = NewEndpointID(s[:timeSep])
+	return
+}
+
 // Len returns the amount of fields, dependent on the fragmentation.
 func (bid BundleID) Len() uint64 {
 	if bid.IsFragment {
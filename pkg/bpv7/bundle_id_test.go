@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/dtn7/cboring"
+	"pgregory.net/rapid"
 )
 
 func TestBundleIDCbor(t *testing.T) {
@@ -99,3 +100,126 @@ func TestBundleIDScrub(t *testing.T) {
 		}
 	}
 }
+
+func TestBundleIDStringAmbiguousSource(t *testing.T) {
+	tests := []BundleID{
+		{
+			SourceNode: MustNewEndpointID("dtn://foo/bar-1-2"),
+			Timestamp:  NewCreationTimestamp(3, 4),
+			IsFragment: false,
+		},
+		{
+			SourceNode:      MustNewEndpointID("dtn://foo/bar"),
+			Timestamp:       NewCreationTimestamp(1, 2),
+			IsFragment:      true,
+			FragmentOffset:  3,
+			TotalDataLength: 4,
+		},
+		{
+			SourceNode:      MustNewEndpointID("dtn://foo/b:1:2-3-4"),
+			Timestamp:       NewCreationTimestamp(5, 6),
+			IsFragment:      true,
+			FragmentOffset:  7,
+			TotalDataLength: 8,
+		},
+		{
+			SourceNode: MustNewEndpointID("ipn:23.42"),
+			Timestamp:  NewCreationTimestamp(0, 0),
+			IsFragment: false,
+		},
+	}
+
+	for _, test := range tests {
+		if bid, err := NewBundleID(test.String()); err != nil {
+			t.Fatalf("Parsing %q failed: %v", test.String(), err)
+		} else if !reflect.DeepEqual(bid, test) {
+			t.Fatalf("Parsed BundleID %v differs from %v", bid, test)
+		}
+	}
+}
+
+func TestBundleIDStringFormat(t *testing.T) {
+	tests := []struct {
+		bid BundleID
+		str string
+	}{
+		{
+			BundleID{SourceNode: MustNewEndpointID("dtn://foo/"), Timestamp: NewCreationTimestamp(23, 42)},
+			"dtn://foo/-23-42",
+		},
+		{
+			BundleID{
+				SourceNode:      MustNewEndpointID("dtn://foo/"),
+				Timestamp:       NewCreationTimestamp(23, 42),
+				IsFragment:      true,
+				FragmentOffset:  5,
+				TotalDataLength: 100,
+			},
+			"dtn://foo/-23-42:5:100",
+		},
+		{
+			BundleID{SourceNode: MustNewEndpointID("ipn:1.2"), Timestamp: NewCreationTimestamp(0, 1)},
+			"ipn:1.2-0-1",
+		},
+	}
+
+	for _, test := range tests {
+		if str := test.bid.String(); str != test.str {
+			t.Fatalf("BundleID's representation %q differs from the store's key format %q", str, test.str)
+		}
+		if bid, err := NewBundleID(test.str); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(bid, test.bid) {
+			t.Fatalf("Parsed BundleID %v differs from %v", bid, test.bid)
+		}
+	}
+}
+
+func TestBundleIDStringRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		bndl := GenerateBundle(t, 0)
+		bndl.PrimaryBlock.CreationTimestamp[1] = rapid.Uint64().Draw(t, "sequence number")
+
+		bndls := []Bundle{bndl}
+		if rapid.Bool().Draw(t, "fragment") {
+			payload, _ := bndl.PayloadBlock()
+			payloadLen := len(payload.Value.(*PayloadBlock).Data())
+			mtu := rapid.IntRange(256, 256+payloadLen).Draw(t, "mtu")
+
+			if frags, err := bndl.Fragment(mtu); err != nil {
+				t.Fatal(err)
+			} else {
+				bndls = frags
+			}
+		}
+
+		for _, b := range bndls {
+			bid := b.ID()
+			parsed, err := NewBundleID(bid.String())
+			if err != nil {
+				t.Fatalf("Parsing %q failed: %v", bid.String(), err)
+			}
+			if !reflect.DeepEqual(parsed, bid) {
+				t.Fatalf("Parsed BundleID %v differs from %v", parsed, bid)
+			}
+		}
+	})
+}
+
+func TestNewBundleIDInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"dtn://foo/",
+		"dtn://foo/-23",
+		"dtn://foo/-23-x",
+		"dtn://foo/-23-0:1",
+		"dtn://foo/-23-0:1:2:3",
+		"foo-23-0",
+	}
+
+	for _, test := range tests {
+		if bid, err := NewBundleID(test); err == nil {
+			t.Fatalf("Parsing %q did not fail, but resulted in %v", test, bid)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package bpv7
+
+import "sync/atomic"
+
+// logPayloadSize controls whether PayloadLogFields reports the payload's size
+var logPayloadSize atomic.Bool
+
+// SetLogPayloadSize controls whether the size of a Bundle's payload is logged, see PayloadLogFields. A payload's
+// contents are never logged.
+func SetLogPayloadSize(enabled bool) {
+	logPayloadSize.Store(enabled)
+}
+
+// PayloadLogFields returns the metadata of this Bundle's payload to be added to log entries about it, usable as
+// logrus.Fields. Only the payload's size is reported, if enabled by SetLogPayloadSize; otherwise, nothing is.
+//
+// Log entries must identify bundles by their ID instead of the Bundle itself, as its JSON representation includes the
+// payload, which would be logged by a JSON formatter.
+func (b Bundle) PayloadLogFields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	if !logPayloadSize.Load() {
+		return fields
+	}
+
+	if payloadBlock, err := b.PayloadBlock(); err == nil {
+		if payload, ok := payloadBlock.Value.(*PayloadBlock); ok {
+			fields["payload size"] = len(payload.Data())
+		}
+	}
+	return fields
+}
@@ -7,10 +7,12 @@ package bpv7
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"fmt"
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dtn7/cboring"
 )
@@ -333,3 +335,259 @@ func BenchmarkBundleDeserializationCboring(b *testing.B) {
 		}
 	}
 }
+
+func TestBundleAgeOnly(t *testing.T) {
+	bndl, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		AgeOnly().
+		Lifetime(1000).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bndl.PrimaryBlock.CreationTimestamp.IsZeroTime() {
+		t.Fatalf("Age-only bundle has a creation time: %v", bndl.PrimaryBlock.CreationTimestamp)
+	}
+	if !bndl.IsAgeOnly() {
+		t.Fatal("Bundle is not age-only")
+	}
+	if err := bndl.CheckValid(); err != nil {
+		t.Fatalf("Age-only bundle is invalid: %v", err)
+	}
+
+	// Forward the bundle along several hops, each one adding its residence time.
+	hops := []struct {
+		residence uint64
+		age       uint64
+		exceeded  bool
+	}{
+		{400, 400, false},
+		{600, 1000, false},
+		{1, 1001, true},
+	}
+
+	for i, hop := range hops {
+		buff := new(bytes.Buffer)
+		if err := bndl.MarshalCbor(buff); err != nil {
+			t.Fatal(err)
+		}
+
+		var received Bundle
+		if err := cboring.Unmarshal(&received, buff); err != nil {
+			t.Fatalf("Hop %d failed to parse bundle: %v", i, err)
+		}
+
+		if age, err := received.IncrementBundleAge(hop.residence); err != nil {
+			t.Fatal(err)
+		} else if age != hop.age {
+			t.Fatalf("Hop %d resulted in age %d instead of %d", i, age, hop.age)
+		}
+
+		if exceeded := received.IsLifetimeExceeded(); exceeded != hop.exceeded {
+			t.Fatalf("Hop %d: lifetime exceeded is %t, expected %t", i, exceeded, hop.exceeded)
+		}
+
+		bndl = received
+	}
+
+	if err := bndl.CheckValid(); err == nil {
+		t.Fatal("Bundle with an exceeded lifetime is valid")
+	}
+}
+
+func TestBundleLifetimeAgeBlockWithTimestamp(t *testing.T) {
+	bndl, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		BundleAgeBlock("2h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err == nil {
+		t.Fatalf("Bundle older than its lifetime was created: %v", bndl)
+	}
+
+	bndl, err = Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampEpoch().
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err == nil {
+		t.Fatalf("Bundle without a creation time and Bundle Age Block was created: %v", bndl)
+	}
+}
+
+func TestBundleClockSkewTolerance(t *testing.T) {
+	defer SetClockSkewTolerance(DefaultClockSkewTolerance)
+	SetClockSkewTolerance(10 * time.Second)
+
+	tests := []struct {
+		name     string
+		creation time.Time
+		lifetime uint64
+		future   bool
+		exceeded bool
+	}{
+		{"future within tolerance", time.Now().Add(5 * time.Second), 60 * 60 * 1000, false, false},
+		{"future beyond tolerance", time.Now().Add(30 * time.Second), 60 * 60 * 1000, true, false},
+		{"expired within tolerance", time.Now().Add(-65 * time.Second), 60 * 1000, false, false},
+		{"expired beyond tolerance", time.Now().Add(-90 * time.Second), 60 * 1000, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bndl := Bundle{
+				PrimaryBlock: NewPrimaryBlock(0,
+					MustNewEndpointID("dtn://dst/"), MustNewEndpointID("dtn://src/"),
+					NewCreationTimestamp(DtnTimeFromTime(test.creation), 0), test.lifetime),
+				CanonicalBlocks: []CanonicalBlock{NewCanonicalBlock(1, 0, NewPayloadBlock([]byte("hello world")))},
+			}
+
+			if future := bndl.IsCreatedInFuture(); future != test.future {
+				t.Fatalf("Created in future is %t, expected %t", future, test.future)
+			}
+			if exceeded := bndl.IsLifetimeExceeded(); exceeded != test.exceeded {
+				t.Fatalf("Lifetime exceeded is %t, expected %t", exceeded, test.exceeded)
+			}
+		})
+	}
+}
+
+func TestBundleLifetimeFakeClock(t *testing.T) {
+	defer func() { now = time.Now }()
+	defer SetClockSkewTolerance(DefaultClockSkewTolerance)
+	SetClockSkewTolerance(0)
+
+	creation := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	bndl := Bundle{
+		PrimaryBlock: NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dst/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(DtnTimeFromTime(creation), 0), 60*1000),
+		CanonicalBlocks: []CanonicalBlock{NewCanonicalBlock(1, 0, NewPayloadBlock([]byte("hello world")))},
+	}
+
+	tests := []struct {
+		offset   time.Duration
+		future   bool
+		exceeded bool
+	}{
+		{-time.Second, true, false},
+		{0, false, false},
+		{time.Minute, false, false},
+		{time.Minute + time.Millisecond, false, true},
+	}
+
+	for _, test := range tests {
+		now = func() time.Time { return creation.Add(test.offset) }
+
+		if future := bndl.IsCreatedInFuture(); future != test.future {
+			t.Fatalf("Created in future is %t at %v, expected %t", future, test.offset, test.future)
+		}
+		if exceeded := bndl.IsLifetimeExceeded(); exceeded != test.exceeded {
+			t.Fatalf("Lifetime exceeded is %t at %v, expected %t", exceeded, test.offset, test.exceeded)
+		}
+	}
+}
+
+func TestBundleUpgradeCRCType(t *testing.T) {
+	newBundle := func() Bundle {
+		b, err := Builder().
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("10m").
+			HopCountBlock(64).
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The builder enforces a CRC for the primary block, while received bundles might lack it
+		b.SetCRCType(CRCNo)
+		b.PrimaryBlock.CRCType = CRCNo
+		b.PrimaryBlock.CRC = nil
+		return b
+	}
+
+	roundTrip := func(b Bundle) Bundle {
+		buff := new(bytes.Buffer)
+		if err := b.MarshalCbor(buff); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseBundle(buff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		b := newBundle()
+		if upgraded := b.UpgradeCRCType(CRC32); upgraded != 1+len(b.CanonicalBlocks) {
+			t.Fatalf("Upgraded %d blocks", upgraded)
+		}
+
+		b.forEachBlock(func(blck block) {
+			if blck.GetCRCType() != CRC32 {
+				t.Fatalf("Block has CRC type %v", blck.GetCRCType())
+			}
+		})
+		parsed := roundTrip(b)
+		parsed.forEachBlock(func(blck block) {
+			if blck.GetCRCType() != CRC32 {
+				t.Fatalf("Parsed block has CRC type %v", blck.GetCRCType())
+			}
+		})
+
+		// Stronger CRCs are kept
+		if upgraded := b.UpgradeCRCType(CRC16); upgraded != 0 {
+			t.Fatalf("Upgraded %d blocks with a stronger CRC", upgraded)
+		}
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		_, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := newBundle()
+		sb, err := NewSignatureBlock(b, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.AddExtensionBlock(NewCanonicalBlock(0, ReplicateBlock|DeleteBundle, sb)); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the hop count and the signature block are upgraded
+		if upgraded := b.UpgradeCRCType(CRC32); upgraded != 2 {
+			t.Fatalf("Upgraded %d blocks", upgraded)
+		}
+
+		parsed := roundTrip(b)
+		if !sb.Verify(parsed) {
+			t.Fatal("Signature is invalid after upgrading the CRCs")
+		}
+		if parsed.PrimaryBlock.CRCType != CRCNo {
+			t.Fatalf("Signed primary block has CRC type %v", parsed.PrimaryBlock.CRCType)
+		}
+		for _, cb := range parsed.CanonicalBlocks {
+			expected := CRC32
+			if cb.TypeCode() == ExtBlockTypePayloadBlock {
+				expected = CRCNo
+			}
+			if cb.CRCType != expected {
+				t.Fatalf("Block of type %d has CRC type %v, expected %v", cb.TypeCode(), cb.CRCType, expected)
+			}
+		}
+	})
+}
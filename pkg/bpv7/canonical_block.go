@@ -154,7 +154,8 @@ func (cb *CanonicalBlock) UnmarshalCbor(r io.Reader) error {
 		} else if crcVal, err := cboring.ReadByteString(r); err != nil {
 			return err
 		} else if !bytes.Equal(crcCalc, crcVal) {
-			return fmt.Errorf("invalid CRC value: %x instead of expected %x", crcVal, crcCalc)
+			cb.CRC = crcVal
+			return &CRCError{Value: crcVal, Expected: crcCalc}
 		} else {
 			cb.CRC = crcVal
 		}
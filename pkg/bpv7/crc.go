@@ -42,6 +42,17 @@ func (c CRCType) String() string {
 	}
 }
 
+// CRCError is returned when unmarshalling a block whose CRC value does not match its content. The block has been read
+// completely nonetheless, including its received CRC value, so that the next block can be read.
+type CRCError struct {
+	Value    []byte
+	Expected []byte
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("invalid CRC value: %x instead of expected %x", e.Value, e.Expected)
+}
+
 var (
 	crc16table = crc16.MakeTable(crc16.CCITT)
 	crc32table = crc32.MakeTable(crc32.Castagnoli)
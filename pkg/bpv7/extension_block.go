@@ -48,6 +48,9 @@ const (
 
 	// ExtBlockTypeSignatureBlock is the custom block type code for a SignatureBlock, bpv7/extension_block_signature.go
 	ExtBlockTypeSignatureBlock uint64 = 195
+
+	// ExtBlockTypeRouteRecordBlock is the custom block type code for a RouteRecordBlock, bpv7/extension_block_route_record.go
+	ExtBlockTypeRouteRecordBlock uint64 = 196
 )
 
 // ExtensionBlock describes the block-type specific data of any Canonical Block.
@@ -71,13 +74,20 @@ type ExtensionBlock interface {
 	BlockTypeName() string
 }
 
+// ExtensionBlockConstructor creates a new, empty ExtensionBlock of a specific type, into which a received block's data
+// is unmarshalled.
+type ExtensionBlockConstructor func() ExtensionBlock
+
 // ExtensionBlockManager keeps a book on various types of ExtensionBlocks that
 // can be changed at runtime. Thus, new ExtensionBlocks can be created based on
 // their block type code.
 //
+// Third parties can add their own block types by registering them, either by an exemplary instance or by an
+// ExtensionBlockConstructor. Blocks of unregistered types are represented as GenericExtensionBlocks.
+//
 // A singleton ExtensionBlockManager can be fetched by GetExtensionBlockManager.
 type ExtensionBlockManager struct {
-	data  map[uint64]reflect.Type
+	data  map[uint64]ExtensionBlockConstructor
 	mutex sync.Mutex
 }
 
@@ -85,37 +95,58 @@ type ExtensionBlockManager struct {
 // singleton ExtensionBlockManager one can use GetExtensionBlockManager.
 func NewExtensionBlockManager() *ExtensionBlockManager {
 	return &ExtensionBlockManager{
-		data: make(map[uint64]reflect.Type),
+		data: make(map[uint64]ExtensionBlockConstructor),
 	}
 }
 
 // Register a new ExtensionBlock type through an exemplary instance.
+//
+// Blocks of this type are created as new, zero-valued instances of the exemplary instance's type.
 func (ebm *ExtensionBlockManager) Register(eb ExtensionBlock) error {
-	ebm.mutex.Lock()
-	defer ebm.mutex.Unlock()
-
-	extCode := eb.BlockTypeCode()
 	extType := reflect.TypeOf(eb).Elem()
+	return ebm.RegisterConstructor(eb.BlockTypeCode(), func() ExtensionBlock {
+		return reflect.New(extType).Interface().(ExtensionBlock)
+	})
+}
 
-	if extType == reflect.TypeOf((*GenericExtensionBlock)(nil)).Elem() {
+// RegisterConstructor registers a new ExtensionBlock type for the block type code, whose blocks are created by the
+// constructor. The created blocks must report this block type code.
+func (ebm *ExtensionBlockManager) RegisterConstructor(typeCode uint64, constructor ExtensionBlockConstructor) error {
+	if constructor == nil {
+		return fmt.Errorf("no constructor for block type code %d", typeCode)
+	}
+
+	sample := constructor()
+	if _, isGeneric := sample.(*GenericExtensionBlock); isGeneric {
 		return fmt.Errorf("not allowed to register a GenericExtensionBlock")
+	} else if sampleCode := sample.BlockTypeCode(); sampleCode != typeCode {
+		return fmt.Errorf("constructor for block type code %d creates %s blocks of type code %d",
+			typeCode, sample.BlockTypeName(), sampleCode)
 	}
 
-	if otherType, exists := ebm.data[extCode]; exists {
+	ebm.mutex.Lock()
+	defer ebm.mutex.Unlock()
+
+	if otherConstructor, exists := ebm.data[typeCode]; exists {
 		return fmt.Errorf("block type code %d is already registered for %s",
-			extCode, otherType.Name())
+			typeCode, otherConstructor().BlockTypeName())
 	}
 
-	ebm.data[extCode] = extType
+	ebm.data[typeCode] = constructor
 	return nil
 }
 
 // Unregister an ExtensionBlock type through an exemplary instance.
 func (ebm *ExtensionBlockManager) Unregister(eb ExtensionBlock) {
+	ebm.UnregisterTypeCode(eb.BlockTypeCode())
+}
+
+// UnregisterTypeCode removes the ExtensionBlock type registered for this block type code.
+func (ebm *ExtensionBlockManager) UnregisterTypeCode(typeCode uint64) {
 	ebm.mutex.Lock()
 	defer ebm.mutex.Unlock()
 
-	delete(ebm.data, eb.BlockTypeCode())
+	delete(ebm.data, typeCode)
 }
 
 // IsKnown returns true if the ExtensionBlock for this block type code is known.
@@ -129,8 +160,12 @@ func (ebm *ExtensionBlockManager) IsKnown(typeCode uint64) bool {
 
 // createBlock returns either a specific ExtensionBlock or, if type code is not registered, an GenericExtensionBlock.
 func (ebm *ExtensionBlockManager) createBlock(typeCode uint64) ExtensionBlock {
-	if extType, exists := ebm.data[typeCode]; exists {
-		return reflect.New(extType).Interface().(ExtensionBlock)
+	ebm.mutex.Lock()
+	constructor, exists := ebm.data[typeCode]
+	ebm.mutex.Unlock()
+
+	if exists {
+		return constructor()
 	} else {
 		return &GenericExtensionBlock{typeCode: typeCode}
 	}
@@ -206,6 +241,7 @@ func GetExtensionBlockManager() *ExtensionBlockManager {
 		_ = extensionBlockManager.Register(NewPreviousNodeBlock(DtnNone()))
 		_ = extensionBlockManager.Register(NewBundleAgeBlock(0))
 		_ = extensionBlockManager.Register(NewHopCountBlock(0))
+		_ = extensionBlockManager.Register(NewRouteRecordBlock(0))
 	}
 
 	return extensionBlockManager
@@ -5,6 +5,9 @@
 package bpv7
 
 // GenericExtensionBlock is a dummy ExtensionBlock to cover for unknown or unregistered ExtensionBlocks.
+//
+// Its block-type-specific data is kept as received and serialised unaltered. Thus, bundles with unknown blocks can be
+// forwarded intact, as required by RFC 9171. The block processing control flags are part of the CanonicalBlock.
 type GenericExtensionBlock struct {
 	data     []byte
 	typeCode uint64
@@ -18,6 +21,11 @@ func NewGenericExtensionBlock(data []byte, typeCode uint64) *GenericExtensionBlo
 	}
 }
 
+// Data returns the block-type-specific data of this block, which is unknown to this node.
+func (geb *GenericExtensionBlock) Data() []byte {
+	return geb.data
+}
+
 // MarshalBinary writes a binary representation of this block.
 func (geb *GenericExtensionBlock) MarshalBinary() ([]byte, error) {
 	return geb.data, nil
@@ -0,0 +1,141 @@
+package bpv7
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dtn7/cboring"
+)
+
+// RouteRecordBlock records the nodes which forwarded a bundle, allowing its destination to inspect the path taken.
+//
+// At most Limit nodes are recorded to bound the block's size. Further nodes are not recorded.
+type RouteRecordBlock struct {
+	Limit uint64
+	Route []EndpointID
+}
+
+// BlockTypeCode must return a constant integer, indicating the block type code.
+func (rrb *RouteRecordBlock) BlockTypeCode() uint64 {
+	return ExtBlockTypeRouteRecordBlock
+}
+
+// BlockTypeName must return a constant string, this block's name.
+func (rrb *RouteRecordBlock) BlockTypeName() string {
+	return "Route Record Block"
+}
+
+// NewRouteRecordBlock creates a new, empty RouteRecordBlock recording up to limit nodes.
+func NewRouteRecordBlock(limit uint64) *RouteRecordBlock {
+	return &RouteRecordBlock{
+		Limit: limit,
+		Route: []EndpointID{},
+	}
+}
+
+// IsFull returns true if no more nodes can be recorded.
+func (rrb *RouteRecordBlock) IsFull() bool {
+	return uint64(len(rrb.Route)) >= rrb.Limit
+}
+
+// Record appends a node to the route and returns if it was recorded.
+//
+// A node is not recorded if the route is full or if it is already the route's last node, e.g., when forwarding the
+// same bundle again.
+func (rrb *RouteRecordBlock) Record(node EndpointID) bool {
+	if rrb.IsFull() || (len(rrb.Route) > 0 && rrb.Route[len(rrb.Route)-1] == node) {
+		return false
+	}
+
+	rrb.Route = append(rrb.Route, node)
+	return true
+}
+
+// MarshalCbor writes a CBOR representation of this Route Record Block.
+func (rrb *RouteRecordBlock) MarshalCbor(w io.Writer) error {
+	if err := cboring.WriteArrayLength(2, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteUInt(rrb.Limit, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteArrayLength(uint64(len(rrb.Route)), w); err != nil {
+		return err
+	}
+	for i := range rrb.Route {
+		if err := cboring.Marshal(&rrb.Route[i], w); err != nil {
+			return fmt.Errorf("EndpointID failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalCbor reads a CBOR representation of a Route Record Block.
+func (rrb *RouteRecordBlock) UnmarshalCbor(r io.Reader) error {
+	if l, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if l != 2 {
+		return fmt.Errorf("expected array with length 2, got %d", l)
+	}
+
+	if limit, err := cboring.ReadUInt(r); err != nil {
+		return err
+	} else {
+		rrb.Limit = limit
+	}
+
+	l, err := cboring.ReadArrayLength(r)
+	if err != nil {
+		return err
+	} else if l > rrb.Limit {
+		return fmt.Errorf("route of %d nodes exceeds its limit of %d", l, rrb.Limit)
+	}
+
+	rrb.Route = make([]EndpointID, l)
+	for i := range rrb.Route {
+		if err := cboring.Unmarshal(&rrb.Route[i], r); err != nil {
+			return fmt.Errorf("EndpointID failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON writes a JSON representation of this Route Record Block.
+func (rrb *RouteRecordBlock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Limit uint64       `json:"limit"`
+		Route []EndpointID `json:"route"`
+	}{rrb.Limit, rrb.Route})
+}
+
+// CheckValid returns an array of errors for incorrect data.
+func (rrb *RouteRecordBlock) CheckValid() error {
+	if uint64(len(rrb.Route)) > rrb.Limit {
+		return fmt.Errorf("RouteRecordBlock's route of %d nodes exceeds its limit of %d", len(rrb.Route), rrb.Limit)
+	}
+
+	for _, node := range rrb.Route {
+		if err := node.CheckValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckContextValid that there is at most one Route Record Block.
+func (rrb *RouteRecordBlock) CheckContextValid(b *Bundle) error {
+	cb, err := b.ExtensionBlock(ExtBlockTypeRouteRecordBlock)
+
+	if err != nil {
+		return err
+	} else if cb.Value != rrb {
+		return fmt.Errorf("RouteRecordBlock's pointer differs, %p != %p", cb.Value, rrb)
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package bpv7
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRouteRecordBlockRecord(t *testing.T) {
+	rrb := NewRouteRecordBlock(2)
+
+	nodes := []EndpointID{MustNewEndpointID("dtn://a/"), MustNewEndpointID("dtn://b/"), MustNewEndpointID("dtn://c/")}
+	if !rrb.Record(nodes[0]) {
+		t.Fatal("First node was not recorded")
+	}
+	if rrb.Record(nodes[0]) {
+		t.Fatal("Same node was recorded twice in a row")
+	}
+	if !rrb.Record(nodes[1]) {
+		t.Fatal("Second node was not recorded")
+	}
+	if rrb.Record(nodes[2]) || !rrb.IsFull() {
+		t.Fatal("Node was recorded beyond the limit")
+	}
+	if len(rrb.Route) != 2 || rrb.Route[0] != nodes[0] || rrb.Route[1] != nodes[1] {
+		t.Fatalf("Unexpected route %v", rrb.Route)
+	}
+}
+
+func TestRouteRecordBlockExceedsLimit(t *testing.T) {
+	rrb := &RouteRecordBlock{Limit: 1, Route: []EndpointID{MustNewEndpointID("dtn://a/"), MustNewEndpointID("dtn://b/")}}
+	if err := rrb.CheckValid(); err == nil {
+		t.Fatal("Route beyond its limit is valid")
+	}
+
+	var buff bytes.Buffer
+	if err := rrb.MarshalCbor(&buff); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(RouteRecordBlock).UnmarshalCbor(&buff); err == nil {
+		t.Fatal("Route beyond its limit was unmarshalled")
+	}
+}
@@ -6,6 +6,8 @@ package bpv7
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
 	"reflect"
 	"testing"
 )
@@ -53,6 +55,7 @@ func TestExtensionBlockManagerRWBlock(t *testing.T) {
 		{NewBundleAgeBlock(23), []byte{0x41, 0x17}, ExtBlockTypeBundleAgeBlock},
 		{NewHopCountBlock(16), []byte{0x43, 0x82, 0x10, 0x00}, ExtBlockTypeHopCountBlock},
 		{NewPreviousNodeBlock(MustNewEndpointID("dtn://23/")), []byte{0x48, 0x82, 0x01, 0x65, 0x2F, 0x2F, 0x32, 0x33, 0x2F}, ExtBlockTypePreviousNodeBlock},
+		{&RouteRecordBlock{Limit: 2, Route: []EndpointID{MustNewEndpointID("dtn://23/")}}, []byte{0x4B, 0x82, 0x02, 0x81, 0x82, 0x01, 0x65, 0x2F, 0x2F, 0x32, 0x33, 0x2F}, ExtBlockTypeRouteRecordBlock},
 
 		// Binary; also wrapped, of course
 		{NewGenericExtensionBlock([]byte{0xFF}, 192), []byte{0x41, 0xFF}, 192},
@@ -86,3 +89,121 @@ func TestExtensionBlockManagerGenericRegister(t *testing.T) {
 		t.Fatalf("Registering a GenericExtensionBlock did not erred")
 	}
 }
+
+// counterBlock is a third party's ExtensionBlock, unknown to this package.
+type counterBlock struct {
+	counter uint32
+}
+
+func (cb *counterBlock) MarshalBinary() ([]byte, error) {
+	return binary.BigEndian.AppendUint32(nil, cb.counter), nil
+}
+
+func (cb *counterBlock) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("expected 4 bytes, got %d", len(data))
+	}
+	cb.counter = binary.BigEndian.Uint32(data)
+	return nil
+}
+
+func (cb *counterBlock) CheckValid() error               { return nil }
+func (cb *counterBlock) CheckContextValid(*Bundle) error { return nil }
+func (cb *counterBlock) BlockTypeCode() uint64           { return 250 }
+func (cb *counterBlock) BlockTypeName() string           { return "Counter Block" }
+
+func TestExtensionBlockManagerRegisterConstructor(t *testing.T) {
+	var ebm = NewExtensionBlockManager()
+	constructor := func() ExtensionBlock { return &counterBlock{} }
+
+	if err := ebm.RegisterConstructor(250, nil); err == nil {
+		t.Fatal("Registering no constructor did not err")
+	}
+	if err := ebm.RegisterConstructor(251, constructor); err == nil {
+		t.Fatal("Registering a constructor for another block type code did not err")
+	}
+	genericConstructor := func() ExtensionBlock { return NewGenericExtensionBlock(nil, 250) }
+	if err := ebm.RegisterConstructor(250, genericConstructor); err == nil {
+		t.Fatal("Registering a GenericExtensionBlock constructor did not err")
+	}
+
+	if err := ebm.RegisterConstructor(250, constructor); err != nil {
+		t.Fatal(err)
+	}
+	if err := ebm.RegisterConstructor(250, constructor); err == nil {
+		t.Fatal("Registering a block type code twice did not err")
+	}
+	if !ebm.IsKnown(250) {
+		t.Fatal("Registered block type code is unknown")
+	}
+
+	ebm.UnregisterTypeCode(250)
+	if ebm.IsKnown(250) {
+		t.Fatal("Unregistered block type code is known")
+	}
+}
+
+func TestExtensionBlockManagerCustomBlockBundle(t *testing.T) {
+	b, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		Canonical(&counterBlock{counter: 23}).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buff := new(bytes.Buffer)
+	if err := b.MarshalCbor(buff); err != nil {
+		t.Fatal(err)
+	}
+	data := buff.Bytes()
+
+	// Registered type code: parsed as the custom block
+	ebm := GetExtensionBlockManager()
+	if err := ebm.RegisterConstructor(250, func() ExtensionBlock { return &counterBlock{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer ebm.UnregisterTypeCode(250)
+
+	parsed, err := ParseBundle(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b, parsed) {
+		t.Fatalf("Bundles differ: %v %v", b, parsed)
+	}
+	if cb, err := parsed.ExtensionBlock(250); err != nil {
+		t.Fatal(err)
+	} else if counter := cb.Value.(*counterBlock).counter; counter != 23 {
+		t.Fatalf("Counter is %d, not 23", counter)
+	}
+
+	// Unregistered type code: falls back to a GenericExtensionBlock, which is serialised unaltered
+	ebm.UnregisterTypeCode(250)
+
+	parsed, err = ParseBundle(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := parsed.ExtensionBlock(250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geb, ok := cb.Value.(*GenericExtensionBlock); !ok {
+		t.Fatalf("Unregistered block was parsed as %T", cb.Value)
+	} else if !bytes.Equal(geb.Data(), []byte{0, 0, 0, 23}) {
+		t.Fatalf("Generic block's data is %x", geb.Data())
+	}
+
+	buff.Reset()
+	if err := parsed.MarshalCbor(buff); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buff.Bytes(), data) {
+		t.Fatalf("Serialised bundles differ: %x != %x", data, buff.Bytes())
+	}
+}
@@ -232,7 +232,8 @@ func (pb *PrimaryBlock) UnmarshalCbor(r io.Reader) error {
 		} else if crcVal, err := cboring.ReadByteString(r); err != nil {
 			return err
 		} else if !bytes.Equal(crcCalc, crcVal) {
-			return fmt.Errorf("invalid CRC value: %x instead of expected %x", crcVal, crcCalc)
+			pb.CRC = crcVal
+			return &CRCError{Value: crcVal, Expected: crcCalc}
 		} else {
 			pb.CRC = crcVal
 		}
@@ -7,6 +7,7 @@ package bpv7
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -92,6 +93,31 @@ func TestPrimaryBlockCbor(t *testing.T) {
 	}
 }
 
+func TestPrimaryBlockInvalidCRC(t *testing.T) {
+	pb1 := setupPrimaryBlock()
+	pb1.CRCType = CRC32
+
+	buff := new(bytes.Buffer)
+	if err := cboring.Marshal(&pb1, buff); err != nil {
+		t.Fatal(err)
+	}
+	data := buff.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	var pb2 PrimaryBlock
+	err := cboring.Unmarshal(&pb2, bytes.NewReader(data))
+	var crcErr *CRCError
+	if !errors.As(err, &crcErr) {
+		t.Fatalf("Invalid CRC resulted in %v", err)
+	}
+	if !bytes.Equal(pb2.CRC, data[len(data)-4:]) || bytes.Equal(crcErr.Expected, crcErr.Value) {
+		t.Fatalf("Received CRC %x is not reported as invalid against %x", pb2.CRC, crcErr.Expected)
+	}
+	if pb2.Destination != pb1.Destination || pb2.Lifetime != pb1.Lifetime {
+		t.Fatalf("Block with an invalid CRC was not read completely: %v", pb2)
+	}
+}
+
 func TestPrimaryBlockJson(t *testing.T) {
 	tests := []struct {
 		pb        PrimaryBlock
@@ -54,6 +54,28 @@ func DtnTimeNow() DtnTime {
 	return DtnTimeFromTime(time.Now())
 }
 
+// now returns the current time for the lifetime checks. Tests may replace it to control the clock deterministically.
+var now = time.Now
+
+// DefaultClockSkewTolerance is the default offset tolerated between the clocks of a bundle's creator and this node.
+const DefaultClockSkewTolerance = time.Minute
+
+// clockSkewTolerance is the offset tolerated between the clocks of a bundle's creator and this node
+var clockSkewTolerance = DefaultClockSkewTolerance
+
+// SetClockSkewTolerance sets the offset tolerated between the clocks of a bundle's creator and this node.
+//
+// A bundle's creation time may lie up to this tolerance in the future without rendering the bundle invalid, and its
+// lifetime is considered exceeded only after this tolerance has passed as well. A zero tolerance trusts both clocks.
+func SetClockSkewTolerance(tolerance time.Duration) {
+	clockSkewTolerance = tolerance
+}
+
+// ClockSkewTolerance returns the offset tolerated between the clocks of a bundle's creator and this node.
+func ClockSkewTolerance() time.Duration {
+	return clockSkewTolerance
+}
+
 // CreationTimestamp is a tuple of a DtnTime and a sequence number (to differ
 // bundles with the same DtnTime (seconds) from the same endpoint). It is
 // specified in section 4.1.7.
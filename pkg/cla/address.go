@@ -0,0 +1,41 @@
+package cla
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseAddress splits a combined address of the form "<type>://<address>", e.g., "quicl://192.0.2.1:35037", into its
+// CLAType and the address to be passed to the CLA. This is the scheme of, e.g., the MTCPServer's Address.
+//
+// The type is case-insensitive, as for TypeFromString. Addresses of network CLAs must consist of a host and a port.
+func ParseAddress(address string) (claType CLAType, claAddress string, err error) {
+	scheme, claAddress, ok := strings.Cut(address, "://")
+	if !ok {
+		return 0, "", fmt.Errorf("address %q lacks a <type>:// prefix", address)
+	}
+
+	claType, err = TypeFromString(scheme)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if claAddress == "" {
+		return 0, "", fmt.Errorf("address %q lacks the %v address", address, claType)
+	} else if claType == Loopback {
+		return claType, claAddress, nil
+	}
+
+	host, port, err := net.SplitHostPort(claAddress)
+	if err != nil {
+		return 0, "", fmt.Errorf("address %q is invalid: %w", address, err)
+	} else if host == "" {
+		return 0, "", fmt.Errorf("address %q lacks a host", address)
+	} else if portNo, err := strconv.ParseUint(port, 10, 16); err != nil || portNo == 0 {
+		return 0, "", fmt.Errorf("address %q has an invalid port %q", address, port)
+	}
+
+	return claType, claAddress, nil
+}
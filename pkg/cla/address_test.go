@@ -0,0 +1,39 @@
+package cla
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address    string
+		claType    CLAType
+		claAddress string
+		valid      bool
+	}{
+		{"mtcp://192.0.2.1:35038", MTCP, "192.0.2.1:35038", true},
+		{"QUICL://node.example:35037", QUICL, "node.example:35037", true},
+		{"quicl://[2001:db8::1]:35037", QUICL, "[2001:db8::1]:35037", true},
+		{"quicl://[fe80::1%eth0]:35037", QUICL, "[fe80::1%eth0]:35037", true},
+		{"loopback://node-b", Loopback, "node-b", true},
+		{"192.0.2.1:35038", 0, "", false},
+		{"carrier-pigeon://192.0.2.1:35038", 0, "", false},
+		{"mtcp://", 0, "", false},
+		{"mtcp://192.0.2.1", 0, "", false},
+		{"mtcp://:35038", 0, "", false},
+		{"mtcp://192.0.2.1:0", 0, "", false},
+		{"mtcp://192.0.2.1:65536", 0, "", false},
+		{"mtcp://192.0.2.1:port", 0, "", false},
+		{"quicl://2001:db8::1:35037", 0, "", false},
+	}
+
+	for _, test := range tests {
+		claType, claAddress, err := ParseAddress(test.address)
+		if test.valid && err != nil {
+			t.Fatalf("Parsing %q failed: %v", test.address, err)
+		} else if !test.valid && err == nil {
+			t.Fatalf("Invalid address %q was parsed as %v and %q", test.address, claType, claAddress)
+		} else if test.valid && (claType != test.claType || claAddress != test.claAddress) {
+			t.Fatalf("Parsed %q as %v and %q, expected %v and %q",
+				test.address, claType, claAddress, test.claType, test.claAddress)
+		}
+	}
+}
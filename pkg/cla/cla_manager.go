@@ -1,6 +1,8 @@
 package cla
 
 import (
+	"fmt"
+	"io"
 	"sync"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
@@ -23,9 +25,14 @@ type Manager struct {
 	disconnectMutex sync.Mutex
 	pendingRemoval  map[string]bool
 
+	// blockedPeers are the endpoint IDs and addresses of peers disconnected by DisconnectPeer
+	blockedPeers map[string]bool
+
 	// receiveCallback will be called for every received bundle
 	// This is necessary since we can't directly import either the store or processing module without creating an import loop
 	receiveCallback func(bundle *bpv7.Bundle)
+	// receiveFromCallback is called instead of receiveCallback for bundles whose sending peer is known, if set
+	receiveFromCallback func(bundle *bpv7.Bundle, from bpv7.EndpointID)
 
 	// connectCallback is called whenever a new peer connects.
 	// This is necessary since we can't import the routing-module without creating an import loop
@@ -34,6 +41,11 @@ type Manager struct {
 	// disconnectCallback is called whenever a new peer disconnects.
 	// This is necessary since we can't import the routing-module without creating an import loop
 	disconnectCallback func(eid bpv7.EndpointID)
+
+	// sendPriority and sendQueueCapacity configure the QueuedSender wrapping each registered sender, if the capacity
+	// is positive
+	sendPriority      PriorityFunc
+	sendQueueCapacity int
 }
 
 // managerSingleton is the singleton object which should always be used for manager access
@@ -48,7 +60,14 @@ func InitialiseCLAManager(receiveCallback func(bundle *bpv7.Bundle), connectCall
 		return util.NewAlreadyInitialisedError("CLA Manager")
 	}
 
-	manager := Manager{
+	managerSingleton = NewManager(receiveCallback, connectCallback, disconnectCallback)
+	return nil
+}
+
+// NewManager creates a Manager independent of the singleton, e.g., for multiple nodes within one process.
+// Only CLAs implementing ManagerAware report to such a Manager, all others report to the singleton.
+func NewManager(receiveCallback func(bundle *bpv7.Bundle), connectCallback func(eid bpv7.EndpointID), disconnectCallback func(eid bpv7.EndpointID)) *Manager {
+	return &Manager{
 		receivers:          make([]ConvergenceReceiver, 0, 10),
 		senders:            make([]ConvergenceSender, 0, 10),
 		pendingStart:       make([]Convergence, 0, 10),
@@ -57,18 +76,27 @@ func InitialiseCLAManager(receiveCallback func(bundle *bpv7.Bundle), connectCall
 		connectCallback:    connectCallback,
 		disconnectCallback: disconnectCallback,
 		pendingRemoval:     make(map[string]bool),
+		blockedPeers:       make(map[string]bool),
 	}
-	managerSingleton = &manager
-	return nil
+}
+
+// LookupManagerSingleton returns the manager singleton-instance or a util.NotInitialised-error.
+func LookupManagerSingleton() (*Manager, error) {
+	if managerSingleton == nil {
+		return nil, util.NewNotInitialisedError("CLA Manager")
+	}
+	return managerSingleton, nil
 }
 
 // GetManagerSingleton returns the manager singleton-instance.
-// Attempting to call this function before manager initialisation will cause the program to panic.
+// Attempting to call this function before manager initialisation will cause the program to panic; see
+// LookupManagerSingleton.
 func GetManagerSingleton() *Manager {
-	if managerSingleton == nil {
+	manager, err := LookupManagerSingleton()
+	if err != nil {
 		log.Fatalf("Attempting to access an uninitialised CLA manager. This must never happen!")
 	}
-	return managerSingleton
+	return manager
 }
 
 // GetSenders returns the list of currently active sender-type CLAs
@@ -95,32 +123,40 @@ func (manager *Manager) GetListeners() []ConvergenceListener {
 	return manager.listeners
 }
 
-// TODO: Method to create CLA from parameters
-
 // Register is the exported method to register a new CLA.
 // All it does is spawn the actual registration in a goroutine and return immediately
 // This is done to avoid deadlocks where another process may indefinitely wait for the CLA's
 // Start-method to return
 // This method is thread-safe
 func (manager *Manager) Register(cla Convergence) {
-	go manager.registerAsync(cla)
+	go func() { _ = manager.RegisterSync(cla) }()
 }
 
-// registerAsync performs the actual CLA registration
+// RegisterSync performs the actual CLA registration and blocks until it is finished.
 // It will call the CLA's Start-method, wait for it to return and if no error was produced,
 // the CLA will be added to the manager's sender/receiver lists.
-func (manager *Manager) registerAsync(cla Convergence) {
+// Registering an already registered CLA is a no-op, while a CLA currently being started by another registration
+// results in an error. Outside of tests, Register should be preferred.
+// This method is thread-safe
+func (manager *Manager) RegisterSync(cla Convergence) error {
 	log.WithField("cla", cla.Address()).Info("Registering new CLA")
 	manager.stateMutex.RLock()
 	log.WithField("cla", cla.Address()).Debug("Acquired read lock")
 
+	if manager.isBlocked(cla) {
+		log.WithField("cla", cla.Address()).Info("Refusing CLA of a disconnected peer")
+		manager.stateMutex.RUnlock()
+		log.WithField("cla", cla.Address()).Debug("Released read lock")
+		return fmt.Errorf("peer of CLA %v was disconnected", cla.Address())
+	}
+
 	// check if this CLA is present in the manager's pendingStart-list
 	for _, pending := range manager.pendingStart {
 		if cla.Address() == pending.Address() {
 			log.WithField("cla", cla.Address()).Debug("CLA already being started")
 			manager.stateMutex.RUnlock()
 			log.WithField("cla", cla.Address()).Debug("Released read lock")
-			return
+			return fmt.Errorf("CLA %v is already being started", cla.Address())
 		}
 	}
 
@@ -132,7 +168,7 @@ func (manager *Manager) registerAsync(cla Convergence) {
 				log.WithField("cla", cla.Address()).Debug("CLA already registered as receiver")
 				manager.stateMutex.RUnlock()
 				log.WithField("cla", cla.Address()).Debug("Released read lock")
-				return
+				return nil
 			}
 		}
 	}
@@ -145,7 +181,7 @@ func (manager *Manager) registerAsync(cla Convergence) {
 				log.WithField("cla", cla.Address()).Debug("CLA already registered as sender")
 				manager.stateMutex.RUnlock()
 				log.WithField("cla", cla.Address()).Debug("Released read lock")
-				return
+				return nil
 			}
 		}
 	}
@@ -157,9 +193,20 @@ func (manager *Manager) registerAsync(cla Convergence) {
 	// add CLA to pendingStart, so that no-one else will try to start it while we're still working
 	manager.pendingStart = append(manager.pendingStart, cla)
 	log.WithField("cla", cla.Address()).Debug("Added cla to pending")
+	peerAware := manager.receiveFromCallback != nil
+	sendPriority, sendQueueCapacity := manager.sendPriority, manager.sendQueueCapacity
 	manager.stateMutex.Unlock()
 	log.WithField("cla", cla.Address()).Debug("Released state lock")
 
+	if aware, ok := cla.(ManagerAware); ok {
+		aware.SetManager(manager)
+	}
+
+	// only redirect received bundles if someone is interested in their peers, keeping the CLA's own callback otherwise
+	if receiver, ok := cla.(PeerAwareReceiver); ok && peerAware {
+		receiver.SetReceiveFromCallback(manager.NotifyReceiveFrom)
+	}
+
 	err := cla.Activate()
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -185,6 +232,13 @@ func (manager *Manager) registerAsync(cla Convergence) {
 	manager.pendingStart = pending
 	log.WithField("cla", cla).Debug("CLA removed from pending")
 
+	// the peer might have been disconnected while this CLA was started
+	if err == nil && manager.isBlocked(cla) {
+		log.WithField("cla", cla.Address()).Info("Refusing CLA of a disconnected peer")
+		go cla.Close()
+		err = fmt.Errorf("peer of CLA %v was disconnected", cla.Address())
+	}
+
 	if err == nil {
 		// add the CLA to the corresponding lists
 		// Note that a single object can be both a sender and receiver
@@ -193,19 +247,65 @@ func (manager *Manager) registerAsync(cla Convergence) {
 			log.WithField("cla", cla).Debug("CLA added to receivers")
 		}
 		if sender, ok := cla.(ConvergenceSender); ok {
+			if sendQueueCapacity > 0 {
+				queued := NewQueuedSender(sender, sendPriority, sendQueueCapacity)
+				queued.start()
+				sender = queued
+			}
 			manager.senders = append(manager.senders, sender)
 			log.WithField("cla", cla).Debug("CLA added to senders")
 		}
 	}
+	return err
 }
 
 // NotifyReceive is to be called by CLAs when they have received (and successfully unmarshalled) a bundle.
 // This method spawns a new goroutine to handle the bundle asynchronously
 func (manager *Manager) NotifyReceive(bundle *bpv7.Bundle) {
-	log.WithField("bundle", bundle.ID().String()).Debug("Received bundle")
+	log.WithField(util.CorrelationField, bundle.ID().String()).
+		WithFields(bundle.PayloadLogFields()).
+		Debug("Received bundle")
 	go manager.receiveCallback(bundle)
 }
 
+// NotifyReceiveFrom is the counterpart of NotifyReceive for CLAs knowing the peer a bundle was received from,
+// see PeerAwareReceiver.
+func (manager *Manager) NotifyReceiveFrom(bundle *bpv7.Bundle, from bpv7.EndpointID) {
+	manager.stateMutex.RLock()
+	receiveFromCallback := manager.receiveFromCallback
+	manager.stateMutex.RUnlock()
+
+	if receiveFromCallback == nil {
+		manager.NotifyReceive(bundle)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		util.CorrelationField: bundle.ID().String(),
+		"peer":                from,
+	}).WithFields(bundle.PayloadLogFields()).Debug("Received bundle")
+	go receiveFromCallback(bundle, from)
+}
+
+// SetReceiveFromCallback sets the callback for bundles received by a PeerAwareReceiver, which is passed the bundle's
+// sending peer. It only applies to CLAs registered afterwards; without it, CLAs use their own receive callback.
+func (manager *Manager) SetReceiveFromCallback(receiveFromCallback func(bundle *bpv7.Bundle, from bpv7.EndpointID)) {
+	manager.stateMutex.Lock()
+	defer manager.stateMutex.Unlock()
+
+	manager.receiveFromCallback = receiveFromCallback
+}
+
+// SetSendQueue wraps each sender in a QueuedSender, buffering at most capacity bundles which are sent by descending
+// priority. A non-positive capacity disables the queue. It only applies to CLAs registered afterwards.
+func (manager *Manager) SetSendQueue(priority PriorityFunc, capacity int) {
+	manager.stateMutex.Lock()
+	defer manager.stateMutex.Unlock()
+
+	manager.sendPriority = priority
+	manager.sendQueueCapacity = capacity
+}
+
 // NotifyConnect is to be called by a CLA if it has successfully stared AND is a sender AND is aware of its neighbours EndpointID
 // THis information is passed on to the routing algorithm asynchronously
 func (manager *Manager) NotifyConnect(peerID bpv7.EndpointID) {
@@ -216,6 +316,9 @@ func (manager *Manager) NotifyConnect(peerID bpv7.EndpointID) {
 // Will remove the CLA from either or both of the manager's lists.
 // This method is thread-safe.
 func (manager *Manager) NotifyDisconnect(cla Convergence) {
+	if queued, ok := cla.(*QueuedSender); ok {
+		cla = queued.ConvergenceSender
+	}
 	log.WithField("cla", cla).Info("CLA disappeared")
 
 	manager.disconnectMutex.Lock()
@@ -256,6 +359,9 @@ func (manager *Manager) NotifyDisconnect(cla Convergence) {
 		for _, registeredSender := range manager.senders {
 			if sender.Address() != registeredSender.Address() {
 				newSenders = append(newSenders, registeredSender)
+			} else if queued, ok := registeredSender.(*QueuedSender); ok {
+				// the wrapped sender is already gone, its queued bundles cannot be sent anymore
+				queued.drop()
 			}
 		}
 		log.WithFields(log.Fields{
@@ -270,7 +376,72 @@ func (manager *Manager) NotifyDisconnect(cla Convergence) {
 	delete(manager.pendingRemoval, cla.Address())
 }
 
+// DisconnectPeer closes and removes all senders to a peer, identified either by its endpoint ID or by its CLA's
+// address. Afterwards, no CLA of this peer is registered, e.g., when it is dialed again by the discovery, until the peer
+// is allowed again by AllowPeer. The closed senders are returned.
+// This method is thread-safe.
+func (manager *Manager) DisconnectPeer(peer string) []ConvergenceSender {
+	manager.stateMutex.Lock()
+	manager.blockedPeers[peer] = true
+
+	var disconnected []ConvergenceSender
+	for _, sender := range manager.senders {
+		if sender.Address() == peer || sender.GetPeerEndpointID().String() == peer {
+			disconnected = append(disconnected, sender)
+		}
+	}
+	manager.stateMutex.Unlock()
+
+	for _, sender := range disconnected {
+		log.WithFields(log.Fields{
+			"cla":  sender.Address(),
+			"peer": peer,
+		}).Info("Disconnecting CLA")
+
+		_ = sender.Close()
+		// not every CLA notifies the manager of its own closing
+		if manager.hasSender(sender) {
+			manager.NotifyDisconnect(sender)
+		}
+	}
+	return disconnected
+}
+
+// AllowPeer lifts the block of a peer disconnected by DisconnectPeer.
+// This method is thread-safe.
+func (manager *Manager) AllowPeer(peer string) {
+	manager.stateMutex.Lock()
+	defer manager.stateMutex.Unlock()
+	delete(manager.blockedPeers, peer)
+}
+
+// isBlocked checks if a CLA belongs to a peer disconnected by DisconnectPeer. The stateMutex must be held.
+func (manager *Manager) isBlocked(cla Convergence) bool {
+	if manager.blockedPeers[cla.Address()] {
+		return true
+	}
+	sender, ok := cla.(ConvergenceSender)
+	return ok && manager.blockedPeers[sender.GetPeerEndpointID().String()]
+}
+
+// hasSender checks if a sender is still registered.
+func (manager *Manager) hasSender(sender ConvergenceSender) bool {
+	manager.stateMutex.RLock()
+	defer manager.stateMutex.RUnlock()
+
+	for _, registeredSender := range manager.senders {
+		if sender.Address() == registeredSender.Address() {
+			return true
+		}
+	}
+	return false
+}
+
 func (manager *Manager) RegisterListener(listener ConvergenceListener) error {
+	if aware, ok := listener.(ManagerAware); ok {
+		aware.SetManager(manager)
+	}
+
 	err := listener.Start()
 	if err != nil {
 		return err
@@ -281,24 +452,38 @@ func (manager *Manager) RegisterListener(listener ConvergenceListener) error {
 	return nil
 }
 
+// Shutdown closes all CLAs concurrently and waits for them before the Manager singleton is reset, as closing CLAs, e.g.,
+// a listener's connections, still report to the Manager.
 func (manager *Manager) Shutdown() {
 	manager.stateMutex.Lock()
-	defer manager.stateMutex.Unlock()
-
+	closers := make([]io.Closer, 0, len(manager.receivers)+len(manager.senders)+len(manager.listeners))
 	for _, receiver := range manager.receivers {
-		go receiver.Close()
+		closers = append(closers, receiver)
 	}
 	manager.receivers = make([]ConvergenceReceiver, 0)
 
 	for _, sender := range manager.senders {
-		go sender.Close()
+		closers = append(closers, sender)
 	}
 	manager.senders = make([]ConvergenceSender, 0)
 
 	for _, listener := range manager.listeners {
-		go listener.Close()
+		closers = append(closers, listener)
 	}
 	manager.listeners = make([]ConvergenceListener, 0)
+	manager.stateMutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(closers))
+	for _, closer := range closers {
+		go func(closer io.Closer) {
+			defer wg.Done()
+			_ = closer.Close()
+		}(closer)
+	}
+	wg.Wait()
 
-	managerSingleton = nil
+	if managerSingleton == manager {
+		managerSingleton = nil
+	}
 }
@@ -1,16 +1,25 @@
 package cla
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
 	log "github.com/sirupsen/logrus"
+	"go.uber.org/goleak"
 	"pgregory.net/rapid"
 )
 
+// DummyCLA is used as a stand-in for real CLAs and must satisfy their interfaces
+var (
+	_ ConvergenceSender = (*dummy_cla.DummyCLA)(nil)
+	_ PeerAwareReceiver = (*dummy_cla.DummyCLA)(nil)
+)
+
 func setup(t *rapid.T) {
 	receive := func(bundle *bpv7.Bundle) {}
 	connect := func(eid bpv7.EndpointID) {}
@@ -62,6 +71,8 @@ func TestRegisterListener(t *testing.T) {
 }
 
 func TestRegisterSender(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
 	rapid.Check(t, func(t *rapid.T) {
 		setup(t)
 		defer teardown()
@@ -77,11 +88,11 @@ func TestRegisterSender(t *testing.T) {
 			eid := bpv7.MustNewEndpointID(rapid.StringMatching(bpv7.DtnEndpointRegexpNotNone).Draw(t, fmt.Sprintf("CLA %v", i)))
 			cla, _ := dummy_cla.NewDummyCLAPair(eid, eid, noop)
 			clas[i] = cla
-			GetManagerSingleton().Register(cla)
+			if err := GetManagerSingleton().RegisterSync(cla); err != nil {
+				t.Fatal(err)
+			}
 		}
 
-		time.Sleep(time.Millisecond * 10)
-
 		for _, cla := range clas {
 			senders := GetManagerSingleton().GetSenders()
 			present := false
@@ -100,3 +111,77 @@ func TestRegisterSender(t *testing.T) {
 		}
 	})
 }
+
+func TestRegisterSyncTwice(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		setup(t)
+		defer teardown()
+
+		eid := bpv7.MustNewEndpointID(rapid.StringMatching(bpv7.DtnEndpointRegexpNotNone).Draw(t, "CLA"))
+		cla, _ := dummy_cla.NewDummyCLAPair(eid, eid, func(bpv7.Bundle) (interface{}, error) { return nil, nil })
+
+		for i := 0; i < 2; i++ {
+			if err := GetManagerSingleton().RegisterSync(cla); err != nil {
+				t.Fatal(err)
+			}
+			if senders := GetManagerSingleton().GetSenders(); len(senders) != 1 || senders[0].Address() != cla.Address() {
+				t.Fatalf("Expected only CLA %v after registration %d, got %v", cla.Address(), i, senders)
+			}
+		}
+	})
+}
+
+func TestNotifyReceiveLogsNoPayload(t *testing.T) {
+	payload := []byte("confidential payload of a bundle")
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.StandardLogger()
+	oldOut, oldFormatter, oldLevel := logger.Out, logger.Formatter, logger.GetLevel()
+	defer func() {
+		logger.SetOutput(oldOut)
+		logger.SetFormatter(oldFormatter)
+		logger.SetLevel(oldLevel)
+		bpv7.SetLogPayloadSize(false)
+	}()
+	logger.SetLevel(log.DebugLevel)
+
+	received := make(chan struct{}, 2)
+	manager := &Manager{receiveCallback: func(*bpv7.Bundle) { received <- struct{}{} }}
+	manager.receiveFromCallback = func(*bpv7.Bundle, bpv7.EndpointID) { received <- struct{}{} }
+
+	formatters := map[string]log.Formatter{"text": &log.TextFormatter{}, "json": &log.JSONFormatter{}}
+	for name, formatter := range formatters {
+		for _, logSize := range []bool{false, true} {
+			var buf bytes.Buffer
+			logger.SetOutput(&buf)
+			logger.SetFormatter(formatter)
+			bpv7.SetLogPayloadSize(logSize)
+
+			manager.NotifyReceive(&bundle)
+			manager.NotifyReceiveFrom(&bundle, bpv7.MustNewEndpointID("dtn://peer/"))
+			<-received
+			<-received
+
+			output := buf.String()
+			if !strings.Contains(output, bundle.ID().String()) {
+				t.Fatalf("%s log lacks the bundle's ID: %s", name, output)
+			}
+			if strings.Contains(output, string(payload)) ||
+				strings.Contains(output, base64.StdEncoding.EncodeToString(payload)) {
+				t.Fatalf("%s log contains the payload: %s", name, output)
+			}
+			if logged := strings.Contains(output, "payload size"); logged != logSize {
+				t.Fatalf("%s log contains payload size: %t, expected %t: %s", name, logged, logSize, output)
+			}
+		}
+	}
+}
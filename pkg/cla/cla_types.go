@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 )
@@ -31,6 +32,9 @@ const (
 	// Dummy CLA used for testing
 	Dummy CLAType = 8080
 
+	// Loopback CLA connecting nodes within the same process
+	Loopback CLAType = 8081
+
 	unknownClaTypeString string = "unknown CLA type"
 )
 
@@ -43,6 +47,8 @@ func TypeFromString(claType string) (CLAType, error) {
 		return MTCP, nil
 	case "quicl":
 		return QUICL, nil
+	case "loopback":
+		return Loopback, nil
 	default:
 		return 0, fmt.Errorf("invalid CLA Type: %v", claType)
 	}
@@ -67,6 +73,9 @@ func (claType CLAType) String() string {
 	case QUICL:
 		return "QUICL"
 
+	case Loopback:
+		return "Loopback"
+
 	default:
 		return unknownClaTypeString
 	}
@@ -87,4 +96,8 @@ type ListenerConfig struct {
 	Type       CLAType
 	Address    string
 	EndpointId bpv7.EndpointID
+	// MaxConnections limits the concurrent connections of an MTCP listener; unlimited if zero
+	MaxConnections int
+	// ReadTimeout disconnects peers of an MTCP listener being silent for longer; disabled if zero
+	ReadTimeout time.Duration
 }
@@ -18,13 +18,16 @@
 // Furthermore, the ConvergenceProvider provides the ability to create new
 // instances of Convergence objects.
 //
-// Those types are generalized by the Convergable interface.
+// Those types are generalized by the Convergence interface.
 //
-// A centralized instance for CLA management offers the Manager, designed to
-// work seamlessly with the types above.
+// A centralized instance for CLA management offers the Manager singleton,
+// designed to work seamlessly with the types above. Received bundles and
+// appearing or disappearing peers are reported to the callbacks passed to
+// InitialiseCLAManager.
 package cla
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
@@ -64,6 +67,28 @@ type Convergence interface {
 	// TODO: String method for address-logging
 }
 
+// TypedConvergence is implemented by Convergences knowing their CLAType.
+//
+// This is not part of the Convergence interface, as, e.g., test CLAs might not have a type.
+type TypedConvergence interface {
+	Convergence
+
+	// Type of this Convergence's convergence layer.
+	Type() CLAType
+}
+
+// TypeOf returns a Convergence's CLAType, if it implements TypedConvergence. A QueuedSender has the type of the
+// sender it wraps.
+func TypeOf(c Convergence) (claType CLAType, ok bool) {
+	if queued, isQueued := c.(*QueuedSender); isQueued {
+		c = queued.ConvergenceSender
+	}
+	if typed, isTyped := c.(TypedConvergence); isTyped {
+		return typed.Type(), true
+	}
+	return 0, false
+}
+
 // ConvergenceReceiver is an interface for types which are able to receive
 // bundles from other nodes.
 type ConvergenceReceiver interface {
@@ -73,6 +98,27 @@ type ConvergenceReceiver interface {
 	GetEndpointID() bpv7.EndpointID
 }
 
+// PeerAwareReceiver is implemented by ConvergenceReceivers knowing the peer each bundle was received from.
+//
+// If the Manager has a receive-from callback, it sets this callback on registration, before activating the receiver.
+// Afterwards, received bundles are to be passed to this callback instead of the receive callback the receiver was
+// created with.
+type PeerAwareReceiver interface {
+	ConvergenceReceiver
+
+	// SetReceiveFromCallback sets the callback for received bundles, together with the peer they came from.
+	SetReceiveFromCallback(receiveFromCallback func(bundle *bpv7.Bundle, from bpv7.EndpointID))
+}
+
+// ManagerAware is implemented by Convergences and ConvergenceListeners reporting to the Manager they are registered at,
+// instead of the Manager singleton, see NewManager.
+//
+// The Manager sets itself on registration, before activating or starting them.
+type ManagerAware interface {
+	// SetManager sets the Manager to report to.
+	SetManager(manager *Manager)
+}
+
 // ConvergenceSender is an interface for types which are able to transmit
 // bundles to another node.
 type ConvergenceSender interface {
@@ -85,3 +131,67 @@ type ConvergenceSender interface {
 	// if it's known. Otherwise, the zero endpoint will be returned.
 	GetPeerEndpointID() bpv7.EndpointID
 }
+
+// BatchSender is implemented by ConvergenceSenders which can send multiple bundles more efficiently than one by one,
+// e.g., by pipelining them.
+type BatchSender interface {
+	ConvergenceSender
+
+	// SendMany sends all bundles to this ConvergenceSender's endpoint. This method should be thread safe.
+	//
+	// If only some bundles could not be sent, a SendManyError must be returned. Any other error indicates that none of
+	// the bundles were sent.
+	SendMany([]bpv7.Bundle) error
+}
+
+// SendMany sends all bundles via the ConvergenceSender. If it is a BatchSender, the bundles are sent as one batch.
+// Otherwise, Send is called for each bundle.
+//
+// If only some bundles could not be sent, a SendManyError is returned. Use BundleError to inspect a single bundle.
+func SendMany(sender ConvergenceSender, bndls []bpv7.Bundle) error {
+	if batchSender, ok := sender.(BatchSender); ok {
+		return batchSender.SendMany(bndls)
+	}
+
+	sendErr := NewSendManyError()
+	for i, bndl := range bndls {
+		if err := sender.Send(bndl); err != nil {
+			sendErr.Add(i, err)
+		}
+	}
+	return sendErr.ErrorOrNil()
+}
+
+// SendManyError reports which bundles of a SendMany call could not be sent, based on their index.
+type SendManyError struct {
+	Failed map[int]error
+}
+
+func NewSendManyError() *SendManyError {
+	return &SendManyError{Failed: make(map[int]error)}
+}
+
+// Add reports that the bundle at the given index could not be sent.
+func (err *SendManyError) Add(index int, cause error) {
+	err.Failed[index] = cause
+}
+
+// ErrorOrNil returns nil if no bundle failed, and this SendManyError otherwise.
+func (err *SendManyError) ErrorOrNil() error {
+	if len(err.Failed) == 0 {
+		return nil
+	}
+	return err
+}
+
+func (err *SendManyError) Error() string {
+	return fmt.Sprintf("sending %d bundle(s) failed", len(err.Failed))
+}
+
+// BundleError returns the error for the bundle at the given index from SendMany's result.
+func BundleError(err error, index int) error {
+	if sendErr, ok := err.(*SendManyError); ok {
+		return sendErr.Failed[index]
+	}
+	return err
+}
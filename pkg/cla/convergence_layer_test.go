@@ -0,0 +1,117 @@
+package cla
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+var errTestSend = errors.New("test send failure")
+
+// testSender records sent bundles, failing for those from dtn://fail/.
+type testSender struct {
+	sent []bpv7.BundleID
+}
+
+func (ts *testSender) Close() error                       { return nil }
+func (ts *testSender) Activate() error                    { return nil }
+func (ts *testSender) Active() bool                       { return true }
+func (ts *testSender) Address() string                    { return "test" }
+func (ts *testSender) GetPeerEndpointID() bpv7.EndpointID { return bpv7.DtnNone() }
+
+func (ts *testSender) Send(bndl bpv7.Bundle) error {
+	if bndl.PrimaryBlock.SourceNode == bpv7.MustNewEndpointID("dtn://fail/") {
+		return errTestSend
+	}
+	ts.sent = append(ts.sent, bndl.ID())
+	return nil
+}
+
+// testBatchSender records its batches.
+type testBatchSender struct {
+	testSender
+	batches int
+}
+
+func (tbs *testBatchSender) SendMany(bndls []bpv7.Bundle) error {
+	tbs.batches++
+	sendErr := NewSendManyError()
+	for i, bndl := range bndls {
+		if err := tbs.Send(bndl); err != nil {
+			sendErr.Add(i, err)
+		}
+	}
+	return sendErr.ErrorOrNil()
+}
+
+func createSendBundles(t *testing.T, sources ...string) (bndls []bpv7.Bundle) {
+	for i, source := range sources {
+		bndl, err := bpv7.Builder().
+			Source(source).
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock([]byte{byte(i)}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bndls = append(bndls, bndl)
+	}
+	return
+}
+
+func TestSendMany(t *testing.T) {
+	bndls := createSendBundles(t, "dtn://a/", "dtn://b/", "dtn://c/")
+
+	single := &testSender{}
+	for _, bndl := range bndls {
+		if err := single.Send(bndl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fallback := &testSender{}
+	if err := SendMany(fallback, bndls); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := &testBatchSender{}
+	if err := SendMany(batch, bndls); err != nil {
+		t.Fatal(err)
+	}
+	if batch.batches != 1 {
+		t.Fatalf("BatchSender was called %d times instead of once", batch.batches)
+	}
+
+	if !reflect.DeepEqual(single.sent, fallback.sent) || !reflect.DeepEqual(single.sent, batch.sent) {
+		t.Fatalf("Sent bundles differ: single %v, fallback %v, batch %v", single.sent, fallback.sent, batch.sent)
+	}
+}
+
+func TestSendManyPartialFailure(t *testing.T) {
+	bndls := createSendBundles(t, "dtn://a/", "dtn://fail/", "dtn://c/", "dtn://fail/")
+
+	for _, sender := range []ConvergenceSender{&testSender{}, &testBatchSender{}} {
+		err := SendMany(sender, bndls)
+
+		var sendErr *SendManyError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("%T: expected a SendManyError, got %v", sender, err)
+		}
+		if len(sendErr.Failed) != 2 {
+			t.Fatalf("%T: expected two failed bundles, got %v", sender, sendErr.Failed)
+		}
+
+		for i := range bndls {
+			bundleErr := BundleError(err, i)
+			if shouldFail := i%2 == 1; shouldFail != (bundleErr != nil) {
+				t.Fatalf("%T: bundle %d reported error %v", sender, i, bundleErr)
+			} else if shouldFail && !errors.Is(bundleErr, errTestSend) {
+				t.Fatalf("%T: bundle %d reported the wrong error %v", sender, i, bundleErr)
+			}
+		}
+	}
+}
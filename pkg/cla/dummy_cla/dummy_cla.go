@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,25 +16,40 @@ import (
 // DummyCLA transfers bundles to another instance of DummyCLA via a channel
 // Only used for testing
 type DummyCLA struct {
-	ownID           bpv7.EndpointID
-	peerID          bpv7.EndpointID
-	transferChannel chan []byte
-	channelActive   atomic.Bool
+	ownID  bpv7.EndpointID
+	peerID bpv7.EndpointID
+	// inbox receives the bundles sent by the peer, outbox is the peer's inbox
+	inbox  <-chan []byte
+	outbox chan<- []byte
+	// closed is shared by both peers and closed by the first one to be closed
+	closed        chan struct{}
+	closeOnce     *sync.Once
+	channelActive atomic.Bool
+
 	receiveCallback func(bundle bpv7.Bundle) (interface{}, error)
+	// receiveFromCallback is called instead of receiveCallback if set, see cla.PeerAwareReceiver
+	receiveFromCallback func(bundle *bpv7.Bundle, from bpv7.EndpointID)
 }
 
 func NewDummyCLAPair(peerAID bpv7.EndpointID, peerBID bpv7.EndpointID, receiveCallback func(bundle bpv7.Bundle) (interface{}, error)) (*DummyCLA, *DummyCLA) {
-	transferChannel := make(chan []byte)
+	toA, toB := make(chan []byte), make(chan []byte)
+	closed, closeOnce := make(chan struct{}), new(sync.Once)
 	peerA := DummyCLA{
 		ownID:           peerAID,
 		peerID:          peerBID,
-		transferChannel: transferChannel,
+		inbox:           toA,
+		outbox:          toB,
+		closed:          closed,
+		closeOnce:       closeOnce,
 		receiveCallback: receiveCallback,
 	}
 	peerB := DummyCLA{
 		ownID:           peerBID,
 		peerID:          peerAID,
-		transferChannel: transferChannel,
+		inbox:           toB,
+		outbox:          toA,
+		closed:          closed,
+		closeOnce:       closeOnce,
 		receiveCallback: receiveCallback,
 	}
 	return &peerA, &peerB
@@ -42,10 +58,8 @@ func NewDummyCLAPair(peerAID bpv7.EndpointID, peerBID bpv7.EndpointID, receiveCa
 func (cla *DummyCLA) Close() error {
 	wait := time.Duration(rand.Intn(10))
 	time.Sleep(time.Millisecond * wait)
-	active := cla.channelActive.Swap(false)
-	if active {
-		close(cla.transferChannel)
-	}
+	cla.channelActive.Store(false)
+	cla.closeOnce.Do(func() { close(cla.closed) })
 	return nil
 }
 
@@ -61,17 +75,20 @@ func (cla *DummyCLA) Active() bool {
 
 func (cla *DummyCLA) handleReceive() {
 	for {
-		bbytes, more := <-cla.transferChannel
-		if !more {
-			//cla.channelActive = false
+		var bbytes []byte
+		select {
+		case <-cla.closed:
 			return
+		case bbytes = <-cla.inbox:
 		}
+
 		serialiser := bytes.NewReader(bbytes)
 		bundle := bpv7.Bundle{}
 		err := cboring.Unmarshal(&bundle, serialiser)
 		if err == nil {
-			_, err = cla.receiveCallback(bundle)
-			if err != nil {
+			if cla.receiveFromCallback != nil {
+				cla.receiveFromCallback(&bundle, cla.peerID)
+			} else if _, err = cla.receiveCallback(bundle); err != nil {
 				log.WithFields(log.Fields{
 					"cla":   cla.Address(),
 					"error": err,
@@ -86,6 +103,11 @@ func (cla *DummyCLA) handleReceive() {
 	}
 }
 
+// SetReceiveFromCallback sets a callback being passed each received bundle together with this CLA's peer.
+func (cla *DummyCLA) SetReceiveFromCallback(receiveFromCallback func(bundle *bpv7.Bundle, from bpv7.EndpointID)) {
+	cla.receiveFromCallback = receiveFromCallback
+}
+
 func (cla *DummyCLA) Address() string {
 	return fmt.Sprintf("dummycla://%v/", cla.ownID)
 }
@@ -110,7 +132,10 @@ func (cla *DummyCLA) Send(bundle bpv7.Bundle) error {
 		return fmt.Errorf("%v shut down", cla.Address())
 	}
 
-	cla.transferChannel <- bbytes
-
-	return nil
+	select {
+	case cla.outbox <- bbytes:
+		return nil
+	case <-cla.closed:
+		return fmt.Errorf("%v shut down", cla.Address())
+	}
 }
@@ -0,0 +1,8 @@
+// Package loopback implements a convergence layer which connects nodes within the same process.
+//
+// Instead of sockets, a Listener registers its address in a process-wide table. An Endpoint dialing this address
+// will be paired with a new Endpoint on the Listener's side, which gets registered at the CLA manager, just like
+// an incoming connection of a real CLA. Bundles are serialised and passed between both Endpoints through channels.
+//
+// The loopback CLA is intended for integration tests and co-located nodes.
+package loopback
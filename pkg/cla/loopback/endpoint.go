@@ -0,0 +1,238 @@
+package loopback
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+// Endpoint is one side of a loopback connection. It implements both the ConvergenceSender and ConvergenceReceiver.
+type Endpoint struct {
+	address string
+	// dialAddress is the Listener's address, only set for the dialing side
+	dialAddress string
+
+	id     bpv7.EndpointID
+	peerID bpv7.EndpointID
+	peer   *Endpoint
+
+	receiveCallback func(*bpv7.Bundle)
+	// receiveFromCallback is called instead of receiveCallback if set, see cla.PeerAwareReceiver
+	receiveFromCallback func(*bpv7.Bundle, bpv7.EndpointID)
+
+	inbox   chan []byte
+	stopSyn chan struct{}
+	active  atomic.Bool
+	closed  atomic.Bool
+
+	// manager to report to, the Manager singleton if unset
+	manager atomic.Pointer[cla.Manager]
+
+	// peerMutex protects peer and peerID
+	peerMutex sync.Mutex
+}
+
+func newEndpoint(address string, id bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) *Endpoint {
+	return &Endpoint{
+		address:         address,
+		id:              id,
+		peerID:          bpv7.DtnNone(),
+		receiveCallback: receiveCallback,
+		inbox:           make(chan []byte),
+		stopSyn:         make(chan struct{}),
+	}
+}
+
+// NewDialerEndpoint creates an Endpoint which connects to the Listener at the given address once activated.
+func NewDialerEndpoint(address string, id bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) *Endpoint {
+	endpoint := newEndpoint(fmt.Sprintf("loopback://%s", address), id, receiveCallback)
+	endpoint.dialAddress = address
+	return endpoint
+}
+
+func (endpoint *Endpoint) String() string {
+	return endpoint.address
+}
+
+/**
+Methods for Convergence interface
+*/
+
+func (endpoint *Endpoint) Activate() error {
+	if endpoint.closed.Load() {
+		return fmt.Errorf("%v was already closed", endpoint)
+	}
+
+	if endpoint.dialAddress != "" {
+		listener, err := lookupListener(endpoint.dialAddress)
+		if err != nil {
+			return err
+		}
+
+		endpoint.peerMutex.Lock()
+		endpoint.peer = listener.accept(endpoint)
+		endpoint.peerID = listener.endpointID
+		endpoint.peerMutex.Unlock()
+	}
+
+	go endpoint.handleReceive()
+	endpoint.active.Store(true)
+
+	peerID := endpoint.GetPeerEndpointID()
+	log.WithFields(log.Fields{
+		"cla":  endpoint,
+		"peer": peerID,
+	}).Debug("Loopback endpoint activated")
+
+	endpoint.getManager().NotifyConnect(peerID)
+	return nil
+}
+
+func (endpoint *Endpoint) Active() bool {
+	return endpoint.active.Load()
+}
+
+// Type of this CLA, cla.Loopback.
+func (_ *Endpoint) Type() cla.CLAType {
+	return cla.Loopback
+}
+
+func (endpoint *Endpoint) Address() string {
+	return endpoint.address
+}
+
+// Close this Endpoint and its peer.
+func (endpoint *Endpoint) Close() error {
+	if endpoint.closed.Swap(true) {
+		return nil
+	}
+
+	endpoint.active.Store(false)
+	close(endpoint.stopSyn)
+	endpoint.getManager().NotifyDisconnect(endpoint)
+
+	endpoint.peerMutex.Lock()
+	peer := endpoint.peer
+	endpoint.peerMutex.Unlock()
+
+	if peer != nil {
+		return peer.Close()
+	}
+	return nil
+}
+
+/**
+Methods for ConvergenceReceiver interface
+*/
+
+func (endpoint *Endpoint) GetEndpointID() bpv7.EndpointID {
+	return endpoint.id
+}
+
+/**
+Methods for ConvergenceSender interface
+*/
+
+func (endpoint *Endpoint) GetPeerEndpointID() bpv7.EndpointID {
+	endpoint.peerMutex.Lock()
+	defer endpoint.peerMutex.Unlock()
+
+	return endpoint.peerID
+}
+
+func (endpoint *Endpoint) Send(bndl bpv7.Bundle) error {
+	log.WithFields(log.Fields{
+		"cla":                 endpoint,
+		util.CorrelationField: bndl.ID().String(),
+	}).WithFields(bndl.PayloadLogFields()).Debug("Sending bundle via loopback")
+
+	if !endpoint.active.Load() {
+		return fmt.Errorf("%v is not active", endpoint)
+	}
+
+	buff := new(bytes.Buffer)
+	if err := cboring.Marshal(&bndl, buff); err != nil {
+		return err
+	}
+
+	endpoint.peerMutex.Lock()
+	peer := endpoint.peer
+	endpoint.peerMutex.Unlock()
+
+	// The peer might never take the bundle, e.g., if it was not activated
+	var timeout <-chan time.Time
+	if idleTimeout := cla.SendIdleTimeout(); idleTimeout > 0 {
+		timer := time.NewTimer(idleTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case peer.inbox <- buff.Bytes():
+		return nil
+	case <-peer.stopSyn:
+		return fmt.Errorf("peer of %v was closed", endpoint)
+	case <-endpoint.stopSyn:
+		return fmt.Errorf("%v was closed", endpoint)
+	case <-timeout:
+		return fmt.Errorf("peer of %v did not receive the bundle within %v", endpoint, cla.SendIdleTimeout())
+	}
+}
+
+/*
+Non-interface methods
+*/
+
+// SetManager sets the Manager this Endpoint reports to, implementing cla.ManagerAware.
+func (endpoint *Endpoint) SetManager(manager *cla.Manager) {
+	endpoint.manager.Store(manager)
+}
+
+// getManager returns the Manager this Endpoint reports to.
+func (endpoint *Endpoint) getManager() *cla.Manager {
+	if manager := endpoint.manager.Load(); manager != nil {
+		return manager
+	}
+	return cla.GetManagerSingleton()
+}
+
+// SetReceiveFromCallback passes received bundles together with the peer's endpoint ID to the given callback.
+func (endpoint *Endpoint) SetReceiveFromCallback(receiveFromCallback func(*bpv7.Bundle, bpv7.EndpointID)) {
+	endpoint.receiveFromCallback = receiveFromCallback
+}
+
+// handleReceive unmarshals the bundles passed by the peer and hands them to the receiveCallback.
+func (endpoint *Endpoint) handleReceive() {
+	for {
+		select {
+		case <-endpoint.stopSyn:
+			return
+
+		case data := <-endpoint.inbox:
+			bndl := new(bpv7.Bundle)
+			if err := cboring.Unmarshal(bndl, bytes.NewBuffer(data)); err != nil {
+				log.WithFields(log.Fields{
+					"cla":   endpoint,
+					"error": err,
+				}).Error("Loopback endpoint failed to read bundle")
+				continue
+			}
+
+			if endpoint.receiveFromCallback != nil {
+				endpoint.receiveFromCallback(bndl, endpoint.GetPeerEndpointID())
+			} else {
+				endpoint.receiveCallback(bndl)
+			}
+		}
+	}
+}
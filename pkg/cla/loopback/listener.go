@@ -0,0 +1,113 @@
+package loopback
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+var (
+	// listeners maps listen addresses to the running Listeners
+	listeners      = make(map[string]*Listener)
+	listenersMutex sync.RWMutex
+)
+
+// lookupListener returns the running Listener for an address.
+func lookupListener(address string) (*Listener, error) {
+	listenersMutex.RLock()
+	defer listenersMutex.RUnlock()
+
+	listener, ok := listeners[address]
+	if !ok {
+		return nil, fmt.Errorf("no loopback listener for address %s", address)
+	}
+	return listener, nil
+}
+
+// Listener waits for Endpoints dialing its address.
+type Listener struct {
+	listenAddress string
+	endpointID    bpv7.EndpointID
+	running       atomic.Bool
+
+	receiveCallback func(*bpv7.Bundle)
+
+	// manager the accepted Endpoints are registered at, the Manager singleton if unset
+	manager atomic.Pointer[cla.Manager]
+}
+
+func NewListener(listenAddress string, endpointID bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) *Listener {
+	return &Listener{
+		listenAddress:   listenAddress,
+		endpointID:      endpointID,
+		receiveCallback: receiveCallback,
+	}
+}
+
+func (listener *Listener) Start() error {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	if _, exists := listeners[listener.listenAddress]; exists {
+		return fmt.Errorf("loopback address %s is already in use", listener.listenAddress)
+	}
+	listeners[listener.listenAddress] = listener
+	listener.running.Store(true)
+
+	log.WithField("address", listener.listenAddress).Info("Started loopback listener")
+	return nil
+}
+
+func (listener *Listener) Close() error {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	if listeners[listener.listenAddress] == listener {
+		delete(listeners, listener.listenAddress)
+	}
+	listener.running.Store(false)
+
+	return nil
+}
+
+func (listener *Listener) Running() bool {
+	return listener.running.Load()
+}
+
+func (listener *Listener) Address() string {
+	return listener.listenAddress
+}
+
+// SetManager sets the Manager the accepted Endpoints are registered at, implementing cla.ManagerAware.
+func (listener *Listener) SetManager(manager *cla.Manager) {
+	listener.manager.Store(manager)
+}
+
+// accept creates the Listener's side of a new connection and registers it at the CLA manager. If the registration
+// fails, the connection is closed, so that the dialer does not send to an inactive Endpoint.
+func (listener *Listener) accept(dialer *Endpoint) *Endpoint {
+	endpoint := newEndpoint(
+		fmt.Sprintf("loopback://%s<-%v", listener.listenAddress, dialer.id),
+		listener.endpointID, listener.receiveCallback)
+	endpoint.peerID = dialer.id
+	endpoint.peer = dialer
+
+	manager := listener.manager.Load()
+	if manager == nil {
+		manager = cla.GetManagerSingleton()
+	}
+	endpoint.SetManager(manager)
+
+	go func() {
+		if err := manager.RegisterSync(endpoint); err != nil {
+			_ = endpoint.Close()
+		}
+	}()
+
+	return endpoint
+}
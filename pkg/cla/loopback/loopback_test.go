@@ -0,0 +1,181 @@
+package loopback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// testNode is a node within the test process, with its own CLA manager.
+type testNode struct {
+	id        bpv7.EndpointID
+	manager   *cla.Manager
+	connected chan bpv7.EndpointID
+	received  chan *bpv7.Bundle
+}
+
+func newTestNode(id string) *testNode {
+	node := &testNode{
+		id:        bpv7.MustNewEndpointID(id),
+		connected: make(chan bpv7.EndpointID, 1),
+		received:  make(chan *bpv7.Bundle, 1),
+	}
+	node.manager = cla.NewManager(
+		func(*bpv7.Bundle) {},
+		func(eid bpv7.EndpointID) { node.connected <- eid },
+		func(bpv7.EndpointID) {})
+	node.manager.SetReceiveFromCallback(func(bundle *bpv7.Bundle, _ bpv7.EndpointID) { node.received <- bundle })
+	return node
+}
+
+// expectConnected waits for the node's manager to report the peer.
+func (node *testNode) expectConnected(t *testing.T, peer bpv7.EndpointID) {
+	select {
+	case eid := <-node.connected:
+		if eid != peer {
+			t.Fatalf("%v connected to %v instead of %v", node.id, eid, peer)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%v did not connect to %v", node.id, peer)
+	}
+}
+
+// expectReceived waits for the node to receive the bundle.
+func (node *testNode) expectReceived(t *testing.T, bndl bpv7.Bundle) {
+	select {
+	case received := <-node.received:
+		if received.ID() != bndl.ID() {
+			t.Fatalf("%v received bundle %v instead of %v", node.id, received.ID(), bndl.ID())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Bundle %v was not received by %v", bndl.ID(), node.id)
+	}
+}
+
+func createBundle(t *testing.T, source, destination bpv7.EndpointID) bpv7.Bundle {
+	bndl, err := bpv7.Builder().
+		Source(source).
+		Destination(destination).
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bndl
+}
+
+// connect node a to node b's listener at the address, returning a's dialing Endpoint.
+func connect(t *testing.T, a, b *testNode, address string) *Endpoint {
+	listener, err := cla.NewListener(
+		cla.ListenerConfig{Type: cla.Loopback, Address: address, EndpointId: b.id}, func(*bpv7.Bundle) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.manager.RegisterListener(listener); err != nil {
+		t.Fatal(err)
+	}
+
+	conv, err := cla.NewPeer(cla.Loopback, address, a.id, bpv7.DtnNone(), func(*bpv7.Bundle) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.manager.RegisterSync(conv); err != nil {
+		t.Fatal(err)
+	}
+	return conv.(*Endpoint)
+}
+
+func TestSendReceive(t *testing.T) {
+	nodeA := newTestNode("dtn://node-a/")
+	defer nodeA.manager.Shutdown()
+	nodeB := newTestNode("dtn://node-b/")
+	defer nodeB.manager.Shutdown()
+
+	dialer := connect(t, nodeA, nodeB, "node-b")
+
+	// Each node's manager is notified about its new peer
+	nodeA.expectConnected(t, nodeB.id)
+	nodeB.expectConnected(t, nodeA.id)
+
+	if peer := dialer.GetPeerEndpointID(); peer != nodeB.id {
+		t.Fatalf("Dialer's peer is %v, not %v", peer, nodeB.id)
+	}
+	if senders := nodeA.manager.GetSenders(); len(senders) != 1 || senders[0].Address() != dialer.Address() {
+		t.Fatalf("Node A has senders %v instead of its dialer", senders)
+	}
+
+	bndlAB := createBundle(t, nodeA.id, nodeB.id)
+	if err := dialer.Send(bndlAB); err != nil {
+		t.Fatal(err)
+	}
+	nodeB.expectReceived(t, bndlAB)
+
+	// The Listener's side is registered as a sender at node B's manager and can answer
+	senders := nodeB.manager.GetSenders()
+	if len(senders) != 1 || senders[0].GetPeerEndpointID() != nodeA.id {
+		t.Fatalf("Node B has senders %v instead of the Listener's endpoint", senders)
+	}
+	listenerSide := senders[0]
+
+	bndlBA := createBundle(t, nodeB.id, nodeA.id)
+	if err := listenerSide.Send(bndlBA); err != nil {
+		t.Fatal(err)
+	}
+	nodeA.expectReceived(t, bndlBA)
+
+	if err := dialer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if listenerSide.Active() {
+		t.Fatal("Closing the dialer did not close its peer")
+	}
+	if err := dialer.Send(bndlAB); err == nil {
+		t.Fatal("Sending over a closed endpoint did not fail")
+	}
+	if senders := nodeB.manager.GetSenders(); len(senders) != 0 {
+		t.Fatalf("Node B still has senders %v", senders)
+	}
+}
+
+func TestSendRefusedPeer(t *testing.T) {
+	nodeA := newTestNode("dtn://node-a/")
+	defer nodeA.manager.Shutdown()
+	nodeB := newTestNode("dtn://node-b/")
+	defer nodeB.manager.Shutdown()
+
+	// Node B refuses the Listener's side of the connection, which closes the dialer as well
+	nodeB.manager.DisconnectPeer(nodeA.id.String())
+	dialer := connect(t, nodeA, nodeB, "node-b-refusing")
+
+	if err := dialer.Send(createBundle(t, nodeA.id, nodeB.id)); err == nil {
+		t.Fatal("Sending to a refused peer did not fail")
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	cla.SetSendIdleTimeout(10 * time.Millisecond)
+	defer cla.SetSendIdleTimeout(cla.DefaultSendIdleTimeout)
+
+	// The peer is never activated and does not receive
+	nodeA := bpv7.MustNewEndpointID("dtn://node-a/")
+	nodeB := bpv7.MustNewEndpointID("dtn://node-b/")
+	dialer := newEndpoint("loopback://inactive", nodeA, func(*bpv7.Bundle) {})
+	dialer.peer = newEndpoint("loopback://inactive<-node-a", nodeB, func(*bpv7.Bundle) {})
+	dialer.active.Store(true)
+
+	errs := make(chan error, 1)
+	go func() { errs <- dialer.Send(createBundle(t, nodeA, nodeB)) }()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Sending to an inactive peer did not fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Sending to an inactive peer blocks")
+	}
+}
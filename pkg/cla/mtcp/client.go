@@ -22,6 +22,7 @@ import (
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
 // MTCPClient is an implementation of a Minimal TCP Convergence-Layer client
@@ -36,6 +37,11 @@ type MTCPClient struct {
 
 	stopSyn chan struct{}
 	stopped atomic.Bool
+
+	// manager to report to, the Manager singleton if unset
+	manager atomic.Pointer[cla.Manager]
+
+	traffic cla.TrafficCounter
 }
 
 // NewMTCPClient creates a new MTCPClient, connected to the given address for
@@ -75,7 +81,7 @@ func (client *MTCPClient) handler() {
 	defer ticker.Stop()
 
 	// Introduce ourselves once
-	cla.GetManagerSingleton().NotifyConnect(client.peer)
+	client.getManager().NotifyConnect(client.peer)
 
 	for {
 		select {
@@ -85,7 +91,7 @@ func (client *MTCPClient) handler() {
 
 		case <-ticker.C:
 			client.mutex.Lock()
-			err := cboring.WriteByteStringLen(0, client.conn)
+			err := cboring.WriteByteStringLen(0, client.traffic.Writer(client.conn))
 			client.mutex.Unlock()
 
 			if err != nil {
@@ -100,7 +106,17 @@ func (client *MTCPClient) handler() {
 	}
 }
 
-func (client *MTCPClient) Send(bndl bpv7.Bundle) (err error) {
+func (client *MTCPClient) Send(bndl bpv7.Bundle) error {
+	return cla.BundleError(client.SendMany([]bpv7.Bundle{bndl}), 0)
+}
+
+// SendMany writes all bundles while holding the connection once and flushes them together.
+//
+// A bundle which cannot be serialised is reported in a SendManyError. A failing connection fails the whole batch and
+// closes this client, as does a transmission stalling for the cla.SendIdleTimeout.
+func (client *MTCPClient) SendMany(bndls []bpv7.Bundle) (err error) {
+	sendErr := cla.NewSendManyError()
+
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("MTCPClient.Send: %v", r)
@@ -110,30 +126,40 @@ func (client *MTCPClient) Send(bndl bpv7.Bundle) (err error) {
 	defer func() {
 		if err != nil {
 			client.Close()
+		} else {
+			err = sendErr.ErrorOrNil()
 		}
 	}()
 
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
 
-	log.WithField("bundle", bndl.ID().String()).Debug("mtcp sending bundle")
+	// A peer not accepting any data within the idle timeout fails the batch, closing this client as a disconnect
+	conn := cla.IdleDeadlineWriter(client.conn, client.conn)
+	defer func() { _ = client.conn.SetWriteDeadline(time.Time{}) }()
 
-	connWriter := bufio.NewWriter(client.conn)
+	connWriter := bufio.NewWriter(client.traffic.Writer(conn))
 
-	buff := new(bytes.Buffer)
-	if cborErr := cboring.Marshal(&bndl, buff); cborErr != nil {
-		err = cborErr
-		return
-	}
+	for i := range bndls {
+		log.WithField(util.CorrelationField, bndls[i].ID().String()).
+			WithFields(bndls[i].PayloadLogFields()).
+			Debug("mtcp sending bundle")
 
-	if bsErr := cboring.WriteByteStringLen(uint64(buff.Len()), connWriter); bsErr != nil {
-		err = bsErr
-		return
-	}
+		buff := new(bytes.Buffer)
+		if cborErr := cboring.Marshal(&bndls[i], buff); cborErr != nil {
+			sendErr.Add(i, cborErr)
+			continue
+		}
 
-	if _, plErr := buff.WriteTo(connWriter); plErr != nil {
-		err = plErr
-		return
+		if bsErr := cboring.WriteByteStringLen(uint64(buff.Len()), connWriter); bsErr != nil {
+			err = bsErr
+			return
+		}
+
+		if _, plErr := buff.WriteTo(connWriter); plErr != nil {
+			err = plErr
+			return
+		}
 	}
 
 	if flushErr := connWriter.Flush(); flushErr != nil {
@@ -142,7 +168,7 @@ func (client *MTCPClient) Send(bndl bpv7.Bundle) (err error) {
 	}
 
 	// Check if the connection is still alive with an empty, unbuffered packet
-	if probeErr := cboring.WriteByteStringLen(0, client.conn); probeErr != nil {
+	if probeErr := cboring.WriteByteStringLen(0, client.traffic.Writer(conn)); probeErr != nil {
 		err = probeErr
 		return
 	}
@@ -156,17 +182,40 @@ func (client *MTCPClient) Close() error {
 		return nil
 	}
 
-	cla.GetManagerSingleton().NotifyDisconnect(client)
+	client.getManager().NotifyDisconnect(client)
 
 	close(client.stopSyn)
 
 	return nil
 }
 
+// SetManager sets the Manager this client reports to, implementing cla.ManagerAware.
+func (client *MTCPClient) SetManager(manager *cla.Manager) {
+	client.manager.Store(manager)
+}
+
+// getManager returns the Manager this client reports to.
+func (client *MTCPClient) getManager() *cla.Manager {
+	if manager := client.manager.Load(); manager != nil {
+		return manager
+	}
+	return cla.GetManagerSingleton()
+}
+
 func (client *MTCPClient) GetPeerEndpointID() bpv7.EndpointID {
 	return client.peer
 }
 
+// Type of this CLA, cla.MTCP.
+func (_ *MTCPClient) Type() cla.CLAType {
+	return cla.MTCP
+}
+
+// Traffic returns the bytes sent by this client, including keepalives.
+func (client *MTCPClient) Traffic() cla.Traffic {
+	return client.traffic.Traffic()
+}
+
 func (client *MTCPClient) Address() string {
 	return client.address
 }
@@ -0,0 +1,29 @@
+package mtcp
+
+import (
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// provider is MTCP's cla.ConvergenceProvider, registered on package initialisation.
+type provider struct{}
+
+func init() {
+	_ = cla.RegisterProvider(provider{})
+}
+
+func (provider) Type() cla.CLAType {
+	return cla.MTCP
+}
+
+func (provider) NewListener(config cla.ListenerConfig, receiveCallback func(*bpv7.Bundle)) (cla.ConvergenceListener, error) {
+	options := ServerOptions{
+		MaxConnections: config.MaxConnections,
+		ReadTimeout:    config.ReadTimeout,
+	}
+	return NewMTCPServerWithOptions(config.Address, config.EndpointId, receiveCallback, options), nil
+}
+
+func (provider) NewPeer(address string, _ bpv7.EndpointID, peerID bpv7.EndpointID, _ func(*bpv7.Bundle)) (cla.Convergence, error) {
+	return NewMTCPClient(address, peerID), nil
+}
@@ -0,0 +1,75 @@
+package mtcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+func TestSendIdleTimeout(t *testing.T) {
+	const deadline = 200 * time.Millisecond
+	defer cla.SetSendIdleTimeout(cla.DefaultSendIdleTimeout)
+	cla.SetSendIdleTimeout(deadline)
+
+	disconnected := make(chan bpv7.EndpointID, 1)
+	err := cla.InitialiseCLAManager(
+		func(*bpv7.Bundle) {},
+		func(bpv7.EndpointID) {},
+		func(eid bpv7.EndpointID) { disconnected <- eid })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	// The black hole accepts connections, but never reads from them
+	blackHole, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = blackHole.Close() }()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := blackHole.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	peer := bpv7.MustNewEndpointID("dtn://black-hole/")
+	client := NewMTCPClient(blackHole.Addr().String(), peer)
+	if err := client.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = (<-accepted).Close() }()
+
+	// The bundle exceeds the sockets' buffers, blocking the write
+	bndl, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination(peer).
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock(make([]byte, 64*1024*1024)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := client.Send(bndl); err == nil {
+		t.Fatal("Sending to a black hole succeeded")
+	} else if duration := time.Since(start); duration > deadline+time.Second {
+		t.Fatalf("Send returned after %v, exceeding the deadline of %v", duration, deadline)
+	}
+
+	select {
+	case eid := <-disconnected:
+		if eid != peer {
+			t.Fatalf("Disconnect was reported for %v instead of %v", eid, peer)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stalled transmission was not reported as a disconnect")
+	}
+}
@@ -6,9 +6,11 @@ package mtcp
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -16,8 +18,35 @@ import (
 	"github.com/dtn7/cboring"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
 )
 
+const (
+	// drainTimeout bounds the time Close waits for connections to finish the
+	// bundle they are currently receiving.
+	drainTimeout = 2 * time.Second
+
+	// drainIdleTimeout is the time an idle connection is granted on Close to
+	// start receiving a bundle which might already be in transit.
+	drainIdleTimeout = 100 * time.Millisecond
+
+	// acceptMinDelay is the delay after a failed Accept, doubled for each
+	// consecutive failure up to acceptMaxDelay.
+	acceptMinDelay = 5 * time.Millisecond
+	acceptMaxDelay = time.Second
+)
+
+// ServerOptions configures an MTCPServer, see NewMTCPServerWithOptions. Its zero value is the default configuration.
+type ServerOptions struct {
+	// MaxConnections limits the concurrent connections. Further connections are closed right after being accepted,
+	// until an existing one is closed. A zero limit allows unlimited connections.
+	MaxConnections int
+	// ReadTimeout is the duration each read of a connection may block. A peer being silent for longer, e.g., having
+	// stalled in the middle of a bundle, is disconnected. As MTCPClients send a keepalive every five seconds, the
+	// timeout should exceed this interval. A zero duration disables the timeout.
+	ReadTimeout time.Duration
+}
+
 // MTCPServer is an implementation of a Minimal TCP Convergence-Layer server
 // which accepts bundles from multiple connections and forwards them to the
 // given channel. This struct implements a ConvergenceReceiver.
@@ -25,22 +54,45 @@ type MTCPServer struct {
 	listenAddress string
 	endpointID    bpv7.EndpointID
 	running       bool
+	listener      *net.TCPListener
 
 	receiveCallback func(*bpv7.Bundle)
 
+	// conns maps each open connection to whether it is currently receiving a bundle.
+	conns     map[net.Conn]bool
+	connMutex sync.Mutex
+	// maxConns limits the concurrent connections; unlimited if zero
+	maxConns int
+	// readTimeout bounds each read of a connection; unlimited if zero
+	readTimeout time.Duration
+	draining    bool
+	handlers    sync.WaitGroup
+
 	stopSyn chan struct{}
 	stopAck chan struct{}
+
+	// traffic of all connections
+	traffic cla.TrafficCounter
 }
 
 // NewMTCPServer creates a new MTCPServer for the given listen address. The
 // permanent flag indicates if this MTCPServer should never be removed from
 // the core.
 func NewMTCPServer(listenAddress string, endpointID bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) *MTCPServer {
+	return NewMTCPServerWithOptions(listenAddress, endpointID, receiveCallback, ServerOptions{})
+}
+
+// NewMTCPServerWithOptions creates a new MTCPServer like NewMTCPServer, configured by the given ServerOptions.
+func NewMTCPServerWithOptions(listenAddress string, endpointID bpv7.EndpointID, receiveCallback func(*bpv7.Bundle),
+	options ServerOptions) *MTCPServer {
 	return &MTCPServer{
 		listenAddress:   listenAddress,
 		endpointID:      endpointID,
 		running:         false,
 		receiveCallback: receiveCallback,
+		conns:           make(map[net.Conn]bool),
+		maxConns:        options.MaxConnections,
+		readTimeout:     options.ReadTimeout,
 		stopSyn:         make(chan struct{}),
 		stopAck:         make(chan struct{}),
 	}
@@ -57,40 +109,93 @@ func (serv *MTCPServer) Start() error {
 		return err
 	}
 
-	go func(ln *net.TCPListener) {
-		for {
+	serv.listener = ln
+	go serv.acceptConnections(ln)
+
+	serv.running = true
+
+	return nil
+}
+
+// acceptConnections handles each new connection until the listener is closed by Close.
+//
+// Persistent Accept errors, e.g., an exhausted number of file descriptors, are retried with an exponential backoff
+// instead of spinning.
+func (serv *MTCPServer) acceptConnections(ln net.Listener) {
+	defer close(serv.stopAck)
+
+	var delay time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
 			select {
 			case <-serv.stopSyn:
 				serv.running = false
-				_ = ln.Close()
-				close(serv.stopAck)
-
 				return
 
 			default:
-				if err := ln.SetDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+				if errors.Is(err, net.ErrClosed) {
 					log.WithFields(log.Fields{
 						"cla":   serv,
 						"error": err,
-					}).Error("MTCPServer failed to set deadline on TCP socket")
+					}).Error("MTCPServer failed to accept a connection")
+
+					serv.running = false
+					return
+				}
 
-					_ = serv.Close()
-				} else if conn, err := ln.Accept(); err == nil {
-					go serv.handleSender(conn)
+				if delay == 0 {
+					delay = acceptMinDelay
+				} else {
+					delay = min(2*delay, acceptMaxDelay)
+				}
+				log.WithFields(log.Fields{
+					"cla":   serv,
+					"error": err,
+					"delay": delay,
+				}).Error("MTCPServer failed to accept a connection, retrying")
+
+				select {
+				case <-serv.stopSyn:
+					serv.running = false
+					return
+				case <-time.After(delay):
 				}
+				continue
 			}
 		}
-	}(ln)
+		delay = 0
 
-	serv.running = true
+		serv.connMutex.Lock()
+		if serv.maxConns > 0 && len(serv.conns) >= serv.maxConns {
+			serv.connMutex.Unlock()
 
-	return nil
+			log.WithFields(log.Fields{
+				"cla":             serv,
+				"conn":            conn.RemoteAddr(),
+				"max connections": serv.maxConns,
+			}).Warn("MTCPServer refuses a connection exceeding its connection limit")
+			_ = conn.Close()
+			continue
+		}
+		serv.conns[conn] = false
+		serv.connMutex.Unlock()
+
+		serv.handlers.Add(1)
+		go serv.handleSender(conn)
+	}
 }
 
 func (serv *MTCPServer) handleSender(conn net.Conn) {
 	defer func() {
 		_ = conn.Close()
 
+		serv.connMutex.Lock()
+		delete(serv.conns, conn)
+		serv.connMutex.Unlock()
+
+		serv.handlers.Done()
+
 		if r := recover(); r != nil {
 			log.WithFields(log.Fields{
 				"cla":   serv,
@@ -105,10 +210,14 @@ func (serv *MTCPServer) handleSender(conn net.Conn) {
 		"conn": conn,
 	}).Debug("MTCP handleServer connection was established")
 
-	connReader := bufio.NewReader(conn)
+	var reader io.Reader = conn
+	if serv.readTimeout > 0 {
+		reader = &timeoutReader{conn: conn, serv: serv}
+	}
+	connReader := bufio.NewReader(serv.traffic.Reader(reader))
 	for {
 		if n, err := cboring.ReadByteStringLen(connReader); err != nil {
-			if err != io.EOF {
+			if err != io.EOF && !serv.isDraining() {
 				log.WithFields(log.Fields{
 					"cla":   serv,
 					"conn":  conn,
@@ -125,6 +234,8 @@ func (serv *MTCPServer) handleSender(conn net.Conn) {
 			continue
 		}
 
+		serv.setReceiving(conn, true)
+
 		bndl := new(bpv7.Bundle)
 		if err := cboring.Unmarshal(bndl, connReader); err != nil {
 			log.WithFields(log.Fields{
@@ -142,13 +253,87 @@ func (serv *MTCPServer) handleSender(conn net.Conn) {
 
 			serv.receiveCallback(bndl)
 		}
+
+		serv.setReceiving(conn, false)
+		if serv.isDraining() {
+			return
+		}
 	}
 }
 
+// timeoutReader reads from a connection, failing each read blocking for longer than the server's read timeout.
+type timeoutReader struct {
+	conn net.Conn
+	serv *MTCPServer
+}
+
+func (tr *timeoutReader) Read(p []byte) (int, error) {
+	// While draining, the deadlines set by Close or setReceiving apply
+	tr.serv.connMutex.Lock()
+	if !tr.serv.draining {
+		_ = tr.conn.SetReadDeadline(time.Now().Add(tr.serv.readTimeout))
+	}
+	tr.serv.connMutex.Unlock()
+
+	return tr.conn.Read(p)
+}
+
+// setReceiving marks if a connection is currently in the middle of receiving a bundle.
+// If the server is already draining, a connection starting to receive a bundle is granted the full drainTimeout.
+func (serv *MTCPServer) setReceiving(conn net.Conn, receiving bool) {
+	serv.connMutex.Lock()
+	defer serv.connMutex.Unlock()
+
+	serv.conns[conn] = receiving
+	if receiving && serv.draining {
+		_ = conn.SetReadDeadline(time.Now().Add(drainTimeout))
+	}
+}
+
+func (serv *MTCPServer) isDraining() bool {
+	serv.connMutex.Lock()
+	defer serv.connMutex.Unlock()
+
+	return serv.draining
+}
+
+// Close stops accepting new connections and drains the existing ones.
+// Connections which are currently receiving a bundle may finish it within the drainTimeout,
+// idle connections are closed after the shorter drainIdleTimeout.
 func (serv *MTCPServer) Close() error {
 	close(serv.stopSyn)
+
+	// A server which was never started has neither a listener nor connections
+	if serv.listener == nil {
+		return nil
+	}
+	_ = serv.listener.Close()
 	<-serv.stopAck
 
+	serv.connMutex.Lock()
+	serv.draining = true
+	now := time.Now()
+	for conn, receiving := range serv.conns {
+		if receiving {
+			_ = conn.SetReadDeadline(now.Add(drainTimeout))
+		} else {
+			_ = conn.SetReadDeadline(now.Add(drainIdleTimeout))
+		}
+	}
+	serv.connMutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		serv.handlers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		log.WithField("cla", serv).Warn("MTCPServer did not drain all connections in time")
+	}
+
 	return nil
 }
 
@@ -156,6 +341,16 @@ func (serv *MTCPServer) GetEndpointID() bpv7.EndpointID {
 	return serv.endpointID
 }
 
+// Type of this CLA, cla.MTCP.
+func (_ *MTCPServer) Type() cla.CLAType {
+	return cla.MTCP
+}
+
+// Traffic returns the bytes received by all of this server's connections.
+func (serv *MTCPServer) Traffic() cla.Traffic {
+	return serv.traffic.Traffic()
+}
+
 func (serv *MTCPServer) Address() string {
 	return fmt.Sprintf("mtcp://%s", serv.listenAddress)
 }
@@ -0,0 +1,79 @@
+package mtcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// failingListener fails each Accept, e.g., as the process ran out of file descriptors.
+type failingListener struct {
+	accepts atomic.Int64
+}
+
+func (fl *failingListener) Accept() (net.Conn, error) {
+	fl.accepts.Add(1)
+	return nil, errors.New("too many open files")
+}
+
+func (fl *failingListener) Close() error   { return nil }
+func (fl *failingListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestServerAcceptBackoff(t *testing.T) {
+	serv := NewMTCPServer("localhost:0", bpv7.MustNewEndpointID("dtn://mtcpcla/"), func(*bpv7.Bundle) {})
+
+	ln := &failingListener{}
+	go serv.acceptConnections(ln)
+
+	// Spinning would retry thousands of times, while the backoff waits 5, 10, 20, 40, 80, 160, ... milliseconds
+	time.Sleep(300 * time.Millisecond)
+	if accepts := ln.accepts.Load(); accepts > 10 {
+		t.Fatalf("Failing Accept was retried %d times within 300ms", accepts)
+	}
+
+	// Stopping the server interrupts the backoff
+	close(serv.stopSyn)
+	select {
+	case <-serv.stopAck:
+	case <-time.After(time.Second):
+		t.Fatal("Accept loop did not stop during its backoff")
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	serv := NewMTCPServer(fmt.Sprintf("localhost:%d", port), bpv7.MustNewEndpointID("dtn://mtcpcla/"), func(*bpv7.Bundle) {})
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	start := time.Now()
+	go func() {
+		_ = serv.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Fatalf("Closing the server took %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server did not close")
+	}
+	if serv.Running() {
+		t.Fatal("Server is still running after Close")
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"go.uber.org/goleak"
 	"pgregory.net/rapid"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
@@ -49,6 +52,8 @@ func teardown() {
 }
 
 func TestSendReceive(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
 	rapid.Check(t, func(t *rapid.T) {
 		setup(t)
 		defer teardown()
@@ -113,3 +118,139 @@ func TestSendReceive(t *testing.T) {
 		}
 	})
 }
+
+func TestCloseDrain(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	rapid.Check(t, func(t *rapid.T) {
+		// The client reports to its own manager, independent of the singleton other tests reset
+		manager := cla.NewManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+		defer manager.Shutdown()
+
+		port := getRandomPort(t)
+		bundle := bpv7.GenerateBundle(t, 0)
+
+		var received atomic.Int32
+		receiveFunc := func(bundle *bpv7.Bundle) {
+			received.Add(1)
+		}
+
+		serv := NewMTCPServer(
+			fmt.Sprintf(":%d", port), bpv7.MustNewEndpointID("dtn://mtcpcla/"), receiveFunc)
+		if err := serv.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		client := NewAnonymousMTCPClient(fmt.Sprintf("localhost:%d", port))
+		client.SetManager(manager)
+		if err := client.Activate(); err != nil {
+			t.Fatal(fmt.Errorf("starting Client failed: %v", err))
+		}
+		defer client.Close()
+
+		if err := client.Send(bundle); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only accepted connections are drained, not those still waiting in the listener's backlog
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+			serv.connMutex.Lock()
+			accepted := len(serv.conns) > 0
+			serv.connMutex.Unlock()
+
+			if accepted {
+				break
+			} else if time.Now().After(deadline) {
+				t.Fatal("Server did not accept the client's connection")
+			}
+		}
+
+		if err := serv.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := received.Load(); n != 1 {
+			t.Fatalf("Expected 1 delivered bundle after Close, got %d", n)
+		}
+
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCloseUnstarted(t *testing.T) {
+	serv := NewMTCPServer("localhost:0", bpv7.MustNewEndpointID("dtn://mtcpcla/"), func(*bpv7.Bundle) {})
+	if err := serv.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendMany(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	rapid.Check(t, func(t *rapid.T) {
+		setup(t)
+		defer teardown()
+
+		port := getRandomPort(t)
+		numberOfBundles := rapid.IntRange(1, 100).Draw(t, "Number of Bundles")
+		numberOfBatched := rapid.IntRange(0, numberOfBundles).Draw(t, "Number of batched Bundles")
+
+		bundles := make([]bpv7.Bundle, numberOfBundles)
+		for i := 0; i < numberOfBundles; i++ {
+			bundles[i] = bpv7.GenerateBundle(t, i)
+		}
+
+		var wgReceive sync.WaitGroup
+		wgReceive.Add(numberOfBundles)
+		var receivedMutex sync.Mutex
+		received := make(map[string]int)
+		receiveFunc := func(bundle *bpv7.Bundle) {
+			receivedMutex.Lock()
+			received[bundle.ID().String()]++
+			receivedMutex.Unlock()
+			wgReceive.Done()
+		}
+
+		serv := NewMTCPServer(
+			fmt.Sprintf(":%d", port), bpv7.MustNewEndpointID("dtn://mtcpcla/"), receiveFunc)
+		if err := serv.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		client := NewAnonymousMTCPClient(fmt.Sprintf("localhost:%d", port))
+		if err := client.Activate(); err != nil {
+			t.Fatal(fmt.Errorf("starting Client failed: %v", err))
+		}
+
+		// Send some bundles one by one and the rest as a batch, which must be received alike
+		for _, bundle := range bundles[numberOfBatched:] {
+			if err := client.Send(bundle); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := client.SendMany(bundles[:numberOfBatched]); err != nil {
+			t.Fatal(err)
+		}
+		wgReceive.Wait()
+
+		// Generated bundles might share an ID, e.g., for the same source within a second
+		expected := make(map[string]int)
+		for _, bundle := range bundles {
+			expected[bundle.ID().String()]++
+		}
+		for id, n := range expected {
+			if received[id] != n {
+				t.Fatalf("Bundle %v was received %d times, expected %d", id, received[id], n)
+			}
+		}
+
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := serv.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
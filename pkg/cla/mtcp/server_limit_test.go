@@ -0,0 +1,132 @@
+package mtcp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// sendRaw writes a bundle to an MTCP connection without using an MTCPClient.
+func sendRaw(t *testing.T, conn net.Conn, source string) {
+	bndl, err := bpv7.Builder().
+		Source(source).
+		Destination("dtn://server/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buff := new(bytes.Buffer)
+	if err := cboring.Marshal(&bndl, buff); err != nil {
+		t.Fatal(err)
+	}
+	if err := cboring.WriteByteStringLen(uint64(buff.Len()), conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(buff.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerMaxConnections(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	_ = ln.Close()
+
+	received := make(chan bpv7.EndpointID, 8)
+	// The limit is passed from the ListenerConfig through MTCP's provider
+	config := cla.ListenerConfig{
+		Type:           cla.MTCP,
+		Address:        address,
+		EndpointId:     bpv7.MustNewEndpointID("dtn://server/"),
+		MaxConnections: 2,
+	}
+	listener, err := cla.NewListener(config, func(bndl *bpv7.Bundle) {
+		received <- bndl.PrimaryBlock.SourceNode
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv := listener.(*MTCPServer)
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	expectReceived := func(source string) {
+		select {
+		case eid := <-received:
+			if eid != bpv7.MustNewEndpointID(source) {
+				t.Fatalf("Received bundle from %v instead of %s", eid, source)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Bundle from %s was not received", source)
+		}
+	}
+
+	var conns []net.Conn
+	for _, source := range []string{"dtn://first/", "dtn://second/"} {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+
+		sendRaw(t, conn, source)
+		expectReceived(source)
+	}
+
+	// The connection exceeding the limit is closed by the server
+	excess, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer excess.Close()
+	_ = excess.SetReadDeadline(time.Now().Add(time.Second))
+	var netErr net.Error
+	if _, err := excess.Read(make([]byte, 1)); errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("Connection exceeding the limit was not closed")
+	} else if err == nil {
+		t.Fatal("Connection exceeding the limit received data")
+	}
+
+	// Existing connections keep working
+	sendRaw(t, conns[0], "dtn://first/")
+	expectReceived("dtn://first/")
+
+	// Closing a connection frees its slot
+	_ = conns[1].Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		serv.connMutex.Lock()
+		l := len(serv.conns)
+		serv.connMutex.Unlock()
+
+		if l < 2 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("Closed connection was not released")
+		}
+	}
+
+	third, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	sendRaw(t, third, "dtn://third/")
+	expectReceived("dtn://third/")
+}
@@ -0,0 +1,88 @@
+package mtcp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+func TestServerReadTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	_ = ln.Close()
+
+	received := make(chan bpv7.EndpointID, 8)
+	// The timeout is passed from the ListenerConfig through MTCP's provider
+	config := cla.ListenerConfig{
+		Type:        cla.MTCP,
+		Address:     address,
+		EndpointId:  bpv7.MustNewEndpointID("dtn://server/"),
+		ReadTimeout: timeout,
+	}
+	listener, err := cla.NewListener(config, func(bndl *bpv7.Bundle) {
+		received <- bndl.PrimaryBlock.SourceNode
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv := listener.(*MTCPServer)
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	// A byte string header announcing a four byte length, followed by only one of these bytes
+	stalled, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	if _, err := stalled.Write([]byte{0x5a, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A peer sending within the timeout stays connected
+	active, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer active.Close()
+	for i := 0; i < 3; i++ {
+		time.Sleep(timeout / 2)
+		sendRaw(t, active, "dtn://active/")
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("Bundle of the active peer was not received")
+		}
+	}
+
+	_ = stalled.SetReadDeadline(time.Now().Add(5 * timeout))
+	var netErr net.Error
+	if _, err := stalled.Read(make([]byte, 1)); errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("Stalled connection was not closed")
+	} else if err == nil {
+		t.Fatal("Stalled connection received data")
+	}
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		serv.connMutex.Lock()
+		l := len(serv.conns)
+		serv.connMutex.Unlock()
+
+		if l == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Expected only the active connection, got %d", l)
+		}
+	}
+}
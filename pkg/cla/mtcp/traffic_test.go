@@ -0,0 +1,84 @@
+package mtcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+func TestTraffic(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	_ = ln.Close()
+
+	received := make(chan struct{}, 8)
+	serv := NewMTCPServer(address, bpv7.MustNewEndpointID("dtn://server/"), func(*bpv7.Bundle) {
+		received <- struct{}{}
+	})
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	client := NewMTCPClient(address, bpv7.MustNewEndpointID("dtn://server/"))
+	if err := client.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var bundlesSize uint64
+	for _, size := range []int{0, 100, 100000} {
+		bndl, err := bpv7.Builder().
+			Source("dtn://client/").
+			Destination("dtn://server/").
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock(make([]byte, size)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buff := new(bytes.Buffer)
+		if err := cboring.Marshal(&bndl, buff); err != nil {
+			t.Fatal(err)
+		}
+		bundlesSize += uint64(buff.Len())
+
+		if err := client.Send(bndl); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("Bundle was not received")
+		}
+	}
+
+	// Each bundle is framed by a byte string header of up to nine bytes and followed by a one byte probe
+	sent := client.Traffic().Sent
+	if sent < bundlesSize || sent > bundlesSize+3*10 {
+		t.Fatalf("Client sent %d bytes for bundles of %d bytes", sent, bundlesSize)
+	}
+
+	for deadline := time.Now().Add(time.Second); serv.Traffic().Received != sent; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server received %d bytes, client sent %d", serv.Traffic().Received, sent)
+		}
+	}
+}
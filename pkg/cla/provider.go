@@ -0,0 +1,82 @@
+package cla
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// ConvergenceProvider creates new ConvergenceListeners and Convergence objects for a single CLAType.
+//
+// CLA packages register their provider through RegisterProvider, usually from within an init function.
+// Thus, a new CLA can be used by importing its package, without changing the code which creates the CLAs.
+type ConvergenceProvider interface {
+	// Type returns the CLAType this provider is responsible for.
+	Type() CLAType
+
+	// NewListener creates a ConvergenceListener for the given ListenerConfig, applying the options it supports.
+	NewListener(config ListenerConfig, receiveCallback func(*bpv7.Bundle)) (ConvergenceListener, error)
+
+	// NewPeer creates a Convergence which connects to a peer at the given address once it is activated.
+	// The peerID might be unknown, in which case dtn:none should be passed.
+	NewPeer(address string, nodeID bpv7.EndpointID, peerID bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) (Convergence, error)
+}
+
+var (
+	providers      = make(map[CLAType]ConvergenceProvider)
+	providersMutex sync.RWMutex
+)
+
+// RegisterProvider registers a ConvergenceProvider for its CLAType.
+// An error will be returned if there already is a provider for this CLAType.
+func RegisterProvider(provider ConvergenceProvider) error {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	if _, exists := providers[provider.Type()]; exists {
+		return fmt.Errorf("there is already a ConvergenceProvider registered for %v", provider.Type())
+	}
+
+	providers[provider.Type()] = provider
+	return nil
+}
+
+// UnregisterProvider removes the ConvergenceProvider for some CLAType.
+func UnregisterProvider(claType CLAType) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	delete(providers, claType)
+}
+
+// GetProvider returns the ConvergenceProvider registered for a CLAType.
+// If there is none, an UnsupportedCLATypeError will be returned.
+func GetProvider(claType CLAType) (ConvergenceProvider, error) {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	provider, exists := providers[claType]
+	if !exists {
+		return nil, NewUnsupportedCLATypeError(claType)
+	}
+	return provider, nil
+}
+
+// NewListener creates a ConvergenceListener for the given ListenerConfig through the registered ConvergenceProvider.
+func NewListener(config ListenerConfig, receiveCallback func(*bpv7.Bundle)) (ConvergenceListener, error) {
+	provider, err := GetProvider(config.Type)
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewListener(config, receiveCallback)
+}
+
+// NewPeer creates a Convergence to connect to some peer through the registered ConvergenceProvider.
+func NewPeer(claType CLAType, address string, nodeID bpv7.EndpointID, peerID bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) (Convergence, error) {
+	provider, err := GetProvider(claType)
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewPeer(address, nodeID, peerID, receiveCallback)
+}
@@ -0,0 +1,70 @@
+package cla
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
+)
+
+// dummyProvider creates DummyListeners and connected DummyCLAs.
+type dummyProvider struct{}
+
+func (dummyProvider) Type() CLAType {
+	return Dummy
+}
+
+func (dummyProvider) NewListener(config ListenerConfig, _ func(*bpv7.Bundle)) (ConvergenceListener, error) {
+	return dummy_cla.NewDummyListener(config.Address), nil
+}
+
+func (dummyProvider) NewPeer(_ string, nodeID bpv7.EndpointID, peerID bpv7.EndpointID, _ func(*bpv7.Bundle)) (Convergence, error) {
+	noop := func(bundle bpv7.Bundle) (interface{}, error) {
+		return nil, nil
+	}
+	peer, _ := dummy_cla.NewDummyCLAPair(nodeID, peerID, noop)
+	return peer, nil
+}
+
+func TestProviderRegistry(t *testing.T) {
+	if _, err := GetProvider(Dummy); err == nil {
+		t.Fatal("Provider for unregistered type was returned")
+	} else {
+		var typeErr *UnsupportedCLATypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("Unexpected error type %T: %v", err, err)
+		}
+	}
+
+	if err := RegisterProvider(dummyProvider{}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterProvider(Dummy)
+
+	if err := RegisterProvider(dummyProvider{}); err == nil {
+		t.Fatal("Registering a second provider for the same type did not fail")
+	}
+
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	listener, err := NewListener(ListenerConfig{Type: Dummy, Address: "dummy:1", EndpointId: nodeID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listener.Address() != "dummy:1" {
+		t.Fatalf("Listener has wrong address %v", listener.Address())
+	}
+
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+	conv, err := NewPeer(Dummy, "dummy:2", nodeID, peerID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, ok := conv.(ConvergenceSender)
+	if !ok {
+		t.Fatalf("Constructed CLA %v is no ConvergenceSender", conv)
+	}
+	if sender.GetPeerEndpointID() != peerID {
+		t.Fatalf("Constructed CLA has wrong peer %v", sender.GetPeerEndpointID())
+	}
+}
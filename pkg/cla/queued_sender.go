@@ -0,0 +1,260 @@
+package cla
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// PriorityFunc ranks a bundle for sending. Bundles of a higher priority are sent first.
+type PriorityFunc func(bundle bpv7.Bundle) int
+
+// AdministrativeRecordsFirst is a PriorityFunc ranking administrative records, e.g., status reports, above all other
+// bundles.
+func AdministrativeRecordsFirst(bundle bpv7.Bundle) int {
+	if bundle.IsAdministrativeRecord() {
+		return 1
+	}
+	return 0
+}
+
+// QueueFull is returned by QueuedSender.Send if its queue has reached its capacity.
+type QueueFull string
+
+func (err *QueueFull) Error() string {
+	return fmt.Sprintf("send queue of %s is full", string(*err))
+}
+
+func NewQueueFullError(address string) *QueueFull {
+	err := QueueFull(address)
+	return &err
+}
+
+// QueuedSender wraps a ConvergenceSender, whose Send calls are buffered and passed on by descending priority.
+//
+// Bundles of the same priority are sent in the order of their Send calls. Send blocks until the wrapped sender
+// returned, reporting its result; Enqueue returns as soon as a bundle is queued and delivers the result later.
+// If the wrapped sender is a BatchSender, all bundles queued at once are passed on as one batch, ordered by priority.
+//
+// The Manager wraps each registered sender if a send queue was configured by Manager.SetSendQueue.
+type QueuedSender struct {
+	ConvergenceSender
+
+	priority PriorityFunc
+	capacity int
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queue   queuedBundles
+	seq     uint64
+	started bool
+	closed  bool
+}
+
+// NewQueuedSender for the given sender, queueing at most capacity bundles which are ranked by the PriorityFunc.
+func NewQueuedSender(sender ConvergenceSender, priority PriorityFunc, capacity int) *QueuedSender {
+	qs := &QueuedSender{
+		ConvergenceSender: sender,
+		priority:          priority,
+		capacity:          capacity,
+	}
+	qs.cond = sync.NewCond(&qs.mutex)
+	return qs
+}
+
+// Activate the wrapped sender and start passing queued bundles to it.
+func (qs *QueuedSender) Activate() error {
+	if err := qs.ConvergenceSender.Activate(); err != nil {
+		return err
+	}
+
+	qs.start()
+	return nil
+}
+
+// start passing queued bundles to the wrapped sender, which is already activated. Further calls are no-ops.
+func (qs *QueuedSender) start() {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	if !qs.started && !qs.closed {
+		qs.started = true
+		go qs.handleQueue()
+	}
+}
+
+// Close the wrapped sender, dropping all bundles still queued.
+func (qs *QueuedSender) Close() error {
+	qs.drop()
+	return qs.ConvergenceSender.Close()
+}
+
+// drop all queued bundles and refuse new ones, without closing the wrapped sender, e.g., as it was already closed.
+func (qs *QueuedSender) drop() {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	if !qs.closed {
+		qs.closed = true
+		if len(qs.queue) > 0 {
+			log.WithFields(log.Fields{
+				"cla":     qs.Address(),
+				"bundles": len(qs.queue),
+			}).Warn("Closing queued sender drops unsent bundles")
+		}
+		for _, dropped := range qs.queue {
+			dropped.result <- fmt.Errorf("queued sender %s was closed before sending", qs.Address())
+		}
+		qs.queue = nil
+		qs.cond.Broadcast()
+	}
+}
+
+// Send queues a bundle to be sent by the wrapped sender and waits until it was sent, returning the sender's error.
+// If the QueuedSender is closed before the bundle was sent, an error is returned as well.
+func (qs *QueuedSender) Send(bundle bpv7.Bundle) error {
+	result, err := qs.Enqueue(bundle)
+	if err != nil {
+		return err
+	}
+	return <-result
+}
+
+// Enqueue a bundle to be sent by the wrapped sender without waiting for it.
+//
+// The returned channel receives exactly one value, the result of sending this bundle, as returned by Send. An error
+// is returned directly if the bundle could not be queued, e.g., a QueueFull error.
+func (qs *QueuedSender) Enqueue(bundle bpv7.Bundle) (<-chan error, error) {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	if qs.closed {
+		return nil, fmt.Errorf("queued sender %s is closed", qs.Address())
+	} else if len(qs.queue) >= qs.capacity {
+		return nil, NewQueueFullError(qs.Address())
+	}
+
+	result := make(chan error, 1)
+	heap.Push(&qs.queue, &queuedBundle{bundle: bundle, priority: qs.priority(bundle), seq: qs.seq, result: result})
+	qs.seq++
+	qs.cond.Signal()
+	return result, nil
+}
+
+// SendMany queues all bundles and waits until they were sent, implementing BatchSender. Bundles which could not be
+// queued or sent are reported by a SendManyError.
+func (qs *QueuedSender) SendMany(bundles []bpv7.Bundle) error {
+	sendErr := NewSendManyError()
+	results := make([]<-chan error, len(bundles))
+	for i, bundle := range bundles {
+		if result, err := qs.Enqueue(bundle); err != nil {
+			sendErr.Add(i, err)
+		} else {
+			results[i] = result
+		}
+	}
+
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		if err := <-result; err != nil {
+			sendErr.Add(i, err)
+		}
+	}
+	return sendErr.ErrorOrNil()
+}
+
+// Traffic of the wrapped sender, if it is counting its traffic, implementing TrafficCounting.
+func (qs *QueuedSender) Traffic() Traffic {
+	if counting, ok := qs.ConvergenceSender.(TrafficCounting); ok {
+		return counting.Traffic()
+	}
+	return Traffic{}
+}
+
+// Len returns the number of bundles waiting to be sent.
+func (qs *QueuedSender) Len() int {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	return len(qs.queue)
+}
+
+// handleQueue passes the queued bundles to the wrapped sender until the QueuedSender is closed. A BatchSender receives
+// all queued bundles at once, any other sender the bundle of the highest priority.
+func (qs *QueuedSender) handleQueue() {
+	_, batching := qs.ConvergenceSender.(BatchSender)
+
+	for {
+		qs.mutex.Lock()
+		for len(qs.queue) == 0 && !qs.closed {
+			qs.cond.Wait()
+		}
+		if qs.closed {
+			qs.mutex.Unlock()
+			return
+		}
+		var next []*queuedBundle
+		for len(qs.queue) > 0 && (batching || len(next) == 0) {
+			next = append(next, heap.Pop(&qs.queue).(*queuedBundle))
+		}
+		qs.mutex.Unlock()
+
+		bundles := make([]bpv7.Bundle, len(next))
+		for i, queued := range next {
+			bundles[i] = queued.bundle
+		}
+		err := SendMany(qs.ConvergenceSender, bundles)
+
+		for i, queued := range next {
+			bundleErr := BundleError(err, i)
+			if bundleErr != nil {
+				log.WithFields(log.Fields{
+					"cla":    qs.Address(),
+					"bundle": queued.bundle.ID().String(),
+					"error":  bundleErr,
+				}).Debug("Sending queued bundle failed")
+			}
+			queued.result <- bundleErr
+		}
+	}
+}
+
+// queuedBundle is a bundle waiting in a QueuedSender, seq being the order of its Send call. The result of sending it
+// is passed to the buffered result channel.
+type queuedBundle struct {
+	bundle   bpv7.Bundle
+	priority int
+	seq      uint64
+	result   chan error
+}
+
+// queuedBundles implements heap.Interface, popping the bundle of the highest priority which was queued first.
+type queuedBundles []*queuedBundle
+
+func (qb queuedBundles) Len() int { return len(qb) }
+
+func (qb queuedBundles) Less(i, j int) bool {
+	if qb[i].priority != qb[j].priority {
+		return qb[i].priority > qb[j].priority
+	}
+	return qb[i].seq < qb[j].seq
+}
+
+func (qb queuedBundles) Swap(i, j int) { qb[i], qb[j] = qb[j], qb[i] }
+
+func (qb *queuedBundles) Push(x any) { *qb = append(*qb, x.(*queuedBundle)) }
+
+func (qb *queuedBundles) Pop() any {
+	old := *qb
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*qb = old[:n-1]
+	return item
+}
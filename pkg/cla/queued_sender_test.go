@@ -0,0 +1,263 @@
+package cla
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// throttledSender records the bundles it sends, each send waiting for a signal on its gate. Bundles named "fail"
+// are not sent, but result in an error.
+type throttledSender struct {
+	gate chan struct{}
+
+	mutex sync.Mutex
+	sent  []string
+}
+
+func (ts *throttledSender) Close() error                       { return nil }
+func (ts *throttledSender) Activate() error                    { return nil }
+func (ts *throttledSender) Active() bool                       { return true }
+func (ts *throttledSender) Address() string                    { return "throttled" }
+func (ts *throttledSender) GetPeerEndpointID() bpv7.EndpointID { return bpv7.DtnNone() }
+
+func (ts *throttledSender) Send(bundle bpv7.Bundle) error {
+	<-ts.gate
+
+	payload, err := bundle.PayloadBlock()
+	if err != nil {
+		return err
+	} else if string(payload.Value.(*bpv7.PayloadBlock).Data()) == "fail" {
+		return errors.New("failing bundle")
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.sent = append(ts.sent, string(payload.Value.(*bpv7.PayloadBlock).Data()))
+	return nil
+}
+
+// newNamedBundle creates the i-th bundle of a test, carrying its name as payload.
+func newNamedBundle(t *testing.T, i int, name string) bpv7.Bundle {
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+		Lifetime("10m").
+		PayloadBlock([]byte(name)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestQueuedSenderPriority(t *testing.T) {
+	// Bundles are named by their payload and ranked by its first letter, e.g., "c1" being sent before "b1"
+	priority := func(bundle bpv7.Bundle) int {
+		payload, _ := bundle.PayloadBlock()
+		return int(payload.Value.(*bpv7.PayloadBlock).Data()[0])
+	}
+
+	sender := &throttledSender{gate: make(chan struct{})}
+	queued := NewQueuedSender(sender, priority, 5)
+	if err := queued.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	defer queued.Close()
+
+	// The first bundle occupies the throttled link, while the others wait in the queue
+	var results []<-chan error
+	if result, err := queued.Enqueue(newNamedBundle(t, 0, "a0")); err != nil {
+		t.Fatal(err)
+	} else {
+		results = append(results, result)
+	}
+	for deadline := time.Now().Add(time.Second); queued.Len() > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("First bundle was not passed to the sender")
+		}
+	}
+
+	names := []string{"a1", "c1", "b1", "c2", "a2"}
+	for i, name := range names {
+		if result, err := queued.Enqueue(newNamedBundle(t, i+1, name)); err != nil {
+			t.Fatal(err)
+		} else {
+			results = append(results, result)
+		}
+	}
+
+	var queueFull *QueueFull
+	if _, err := queued.Enqueue(newNamedBundle(t, len(names)+1, "c3")); !errors.As(err, &queueFull) {
+		t.Fatalf("Expected QueueFull error, got %v", err)
+	}
+
+	for range append(names, "a0") {
+		sender.gate <- struct{}{}
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		sender.mutex.Lock()
+		n := len(sender.sent)
+		sender.mutex.Unlock()
+
+		if n == len(names)+1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Only %d bundles were sent", n)
+		}
+	}
+
+	expected := []string{"a0", "c1", "c2", "b1", "a1", "a2"}
+	if !reflect.DeepEqual(sender.sent, expected) {
+		t.Fatalf("Expected send order %v, got %v", expected, sender.sent)
+	}
+	for i, result := range results {
+		if err := <-result; err != nil {
+			t.Fatalf("Bundle %d was sent, but its result is %v", i, err)
+		}
+	}
+
+	if err := queued.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := queued.Send(newNamedBundle(t, 0, "a3")); err == nil {
+		t.Fatal("Sending on a closed queue succeeded")
+	}
+}
+
+func TestQueuedSenderResult(t *testing.T) {
+	sender := &throttledSender{gate: make(chan struct{}, 1)}
+	queued := NewQueuedSender(sender, func(bpv7.Bundle) int { return 0 }, 5)
+	if err := queued.Activate(); err != nil {
+		t.Fatal(err)
+	}
+
+	sender.gate <- struct{}{}
+	if err := queued.Send(newNamedBundle(t, 0, "fail")); err == nil {
+		t.Fatal("Failed send was reported as successful")
+	}
+
+	sender.gate <- struct{}{}
+	if err := queued.Send(newNamedBundle(t, 1, "ok")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next bundle blocks the sender, the last one is dropped on closing
+	blocked, err := queued.Enqueue(newNamedBundle(t, 2, "blocked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); queued.Len() > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Blocking bundle was not passed to the sender")
+		}
+	}
+	dropped, err := queued.Enqueue(newNamedBundle(t, 3, "dropped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := queued.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-dropped; err == nil {
+		t.Fatal("Bundle dropped by closing was reported as sent")
+	}
+
+	sender.gate <- struct{}{}
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueuedSenderBatch(t *testing.T) {
+	priority := func(bundle bpv7.Bundle) int {
+		if bundle.PrimaryBlock.SourceNode == bpv7.MustNewEndpointID("dtn://c/") {
+			return 1
+		}
+		return 0
+	}
+
+	batch := &testBatchSender{}
+	queued := NewQueuedSender(batch, priority, 5)
+
+	// Bundles queued before starting are passed on as one batch, ordered by priority
+	bndls := createSendBundles(t, "dtn://a/", "dtn://fail/", "dtn://c/")
+	results := make([]<-chan error, len(bndls))
+	for i, bndl := range bndls {
+		if result, err := queued.Enqueue(bndl); err != nil {
+			t.Fatal(err)
+		} else {
+			results[i] = result
+		}
+	}
+	if err := queued.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	defer queued.Close()
+
+	for i, result := range results {
+		if err := <-result; (err != nil) != (i == 1) {
+			t.Fatalf("Unexpected result of bundle %d: %v", i, err)
+		}
+	}
+	if batch.batches != 1 {
+		t.Fatalf("BatchSender was called %d times instead of once", batch.batches)
+	}
+	if expected := []bpv7.BundleID{bndls[2].ID(), bndls[0].ID()}; !reflect.DeepEqual(batch.sent, expected) {
+		t.Fatalf("Expected send order %v, got %v", expected, batch.sent)
+	}
+
+	// SendMany reports the failed bundles by their index
+	err := SendMany(queued, createSendBundles(t, "dtn://fail/", "dtn://b/"))
+	if BundleError(err, 0) == nil || BundleError(err, 1) != nil {
+		t.Fatalf("Unexpected SendMany error %v", err)
+	}
+}
+
+func TestManagerSendQueue(t *testing.T) {
+	receive := func(bundle *bpv7.Bundle) {}
+	connect := func(eid bpv7.EndpointID) {}
+	disconnect := func(eid bpv7.EndpointID) {}
+	if err := InitialiseCLAManager(receive, connect, disconnect); err != nil {
+		t.Fatal(err)
+	}
+	defer GetManagerSingleton().Shutdown()
+	GetManagerSingleton().SetSendQueue(AdministrativeRecordsFirst, 5)
+
+	sender := &testSender{}
+	if err := GetManagerSingleton().RegisterSync(sender); err != nil {
+		t.Fatal(err)
+	}
+
+	senders := GetManagerSingleton().GetSenders()
+	if len(senders) != 1 {
+		t.Fatalf("Expected one sender, got %v", senders)
+	}
+	queued, ok := senders[0].(*QueuedSender)
+	if !ok {
+		t.Fatalf("Sender %v is not queued", senders[0])
+	}
+
+	bndls := createSendBundles(t, "dtn://a/", "dtn://b/")
+	if err := SendMany(queued, bndls); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []bpv7.BundleID{bndls[0].ID(), bndls[1].ID()}; !reflect.DeepEqual(sender.sent, expected) {
+		t.Fatalf("Expected sent bundles %v, got %v", expected, sender.sent)
+	}
+
+	// A disappearing CLA drops its queue
+	GetManagerSingleton().NotifyDisconnect(sender)
+	if senders := GetManagerSingleton().GetSenders(); len(senders) != 0 {
+		t.Fatalf("Disconnected sender is still registered: %v", senders)
+	}
+	if err := queued.Send(bndls[0]); err == nil {
+		t.Fatal("Sending on the queue of a disconnected CLA succeeded")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/cla"
 	"github.com/dtn7/dtn7-go/pkg/cla/quicl/internal"
+	"github.com/dtn7/dtn7-go/pkg/util"
 	"github.com/quic-go/quic-go"
 	log "github.com/sirupsen/logrus"
 )
@@ -29,6 +31,35 @@ import (
 // TODO: is this a reasonable value? I don't know...
 const handshakeTimeout = 500 * time.Millisecond
 
+// maxEndpointIDLength limits the size of a peer's serialised EndpointID during the handshake.
+const maxEndpointIDLength = 64 * 1024
+
+// DefaultReceiveStreamLimit is the default number of concurrently handled incoming streams per connection.
+const DefaultReceiveStreamLimit = 16
+
+// receiveStreamLimit is used for all subsequently created Endpoints, see SetReceiveStreamLimit.
+var receiveStreamLimit atomic.Int64
+
+func init() {
+	receiveStreamLimit.Store(DefaultReceiveStreamLimit)
+}
+
+// SetReceiveStreamLimit sets the number of concurrently handled incoming streams per connection for all Endpoints
+// created afterwards. As each stream carries one bundle, this limits the bundles received in parallel from one peer.
+// Further streams are only accepted once a handler has finished, applying backpressure to the peer.
+//
+// As no stream could be handled otherwise, a limit below one restores the DefaultReceiveStreamLimit.
+func SetReceiveStreamLimit(limit int64) {
+	if limit < 1 {
+		log.WithField("limit", limit).Warn("Invalid QUICL receive stream limit, using the default")
+		limit = DefaultReceiveStreamLimit
+	}
+	receiveStreamLimit.Store(limit)
+}
+
+// defaultSendHandshakeWait is how long Send waits for a pending handshake before giving up.
+const defaultSendHandshakeWait = 2 * handshakeTimeout
+
 type Endpoint struct {
 	// id is the bundle protocol endpoint id which this CLA is exposing
 	id bpv7.EndpointID
@@ -41,38 +72,56 @@ type Endpoint struct {
 	connection quic.Connection
 	// Gets called when a bundle is received
 	receiveCallback func(*bpv7.Bundle)
+	// Gets called instead of receiveCallback if set, see cla.PeerAwareReceiver
+	receiveFromCallback func(*bpv7.Bundle, bpv7.EndpointID)
 
 	rateLimiter *semaphore.Weighted
+	// streamLimiter bounds the number of concurrently handled incoming streams
+	streamLimiter *semaphore.Weighted
 
 	dialer bool
 	active bool
 
 	// Whether the protocol handshake has been completed
 	handshake *uint32
+	// handshakeDone is closed when the handshake has finished, successfully or not
+	handshakeDone     chan struct{}
+	handshakeDoneOnce sync.Once
+	// How long Send waits for a pending handshake; fail immediately if zero
+	sendHandshakeWait time.Duration
+
+	// traffic of all streams, including the handshake but excluding QUIC's own framing
+	traffic cla.TrafficCounter
 }
 
 func NewListenerEndpoint(id bpv7.EndpointID, session quic.Connection, receiveCallback func(*bpv7.Bundle)) *Endpoint {
 	return &Endpoint{
-		id:              id,
-		peerAddress:     session.RemoteAddr().String(),
-		connection:      session,
-		dialer:          false,
-		active:          false,
-		handshake:       new(uint32),
-		receiveCallback: receiveCallback,
-		rateLimiter:     semaphore.NewWeighted(5),
+		id:                id,
+		peerAddress:       session.RemoteAddr().String(),
+		connection:        session,
+		dialer:            false,
+		active:            false,
+		handshake:         new(uint32),
+		handshakeDone:     make(chan struct{}),
+		sendHandshakeWait: defaultSendHandshakeWait,
+		receiveCallback:   receiveCallback,
+		rateLimiter:       semaphore.NewWeighted(5),
+		streamLimiter:     semaphore.NewWeighted(receiveStreamLimit.Load()),
 	}
 }
 
 func NewDialerEndpoint(peerAddress string, id bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) *Endpoint {
 	return &Endpoint{
-		id:              id,
-		peerAddress:     peerAddress,
-		dialer:          true,
-		active:          false,
-		handshake:       new(uint32),
-		receiveCallback: receiveCallback,
-		rateLimiter:     semaphore.NewWeighted(5),
+		id:                id,
+		peerAddress:       peerAddress,
+		dialer:            true,
+		active:            false,
+		handshake:         new(uint32),
+		handshakeDone:     make(chan struct{}),
+		sendHandshakeWait: defaultSendHandshakeWait,
+		receiveCallback:   receiveCallback,
+		rateLimiter:       semaphore.NewWeighted(5),
+		streamLimiter:     semaphore.NewWeighted(receiveStreamLimit.Load()),
 	}
 }
 
@@ -95,6 +144,8 @@ Methods for Convergence interface
 */
 
 func (endpoint *Endpoint) Activate() error {
+	defer endpoint.finishHandshake()
+
 	log.WithFields(log.Fields{
 		"cla":  endpoint.id,
 		"peer": endpoint.peerAddress,
@@ -147,6 +198,16 @@ func (endpoint *Endpoint) Active() bool {
 	return endpoint.active
 }
 
+// Type of this CLA, cla.QUICL.
+func (_ *Endpoint) Type() cla.CLAType {
+	return cla.QUICL
+}
+
+// Traffic returns the bytes sent and received over this connection's streams, including the handshake.
+func (endpoint *Endpoint) Traffic() cla.Traffic {
+	return endpoint.traffic.Traffic()
+}
+
 func (endpoint *Endpoint) Address() string {
 	return endpoint.peerAddress
 }
@@ -168,39 +229,28 @@ func (endpoint *Endpoint) GetPeerEndpointID() bpv7.EndpointID {
 }
 
 func (endpoint *Endpoint) Send(bndl bpv7.Bundle) error {
-	log.WithFields(log.Fields{
-		"peer":   endpoint.peerId,
-		"bundle": bndl.ID(),
-	}).Debug("Sending bundle")
+	logger := log.WithFields(log.Fields{
+		"peer":                endpoint.peerId,
+		util.CorrelationField: bndl.ID().String(),
+	})
+	logger.WithFields(bndl.PayloadLogFields()).Debug("Sending bundle")
 
-	handshake := atomic.LoadUint32(endpoint.handshake)
-	if handshake == 0 {
+	if !endpoint.awaitHandshake() {
 		return internal.NewInitialisationError("Handshake not yet completed")
 	}
 
 	buff := new(bytes.Buffer)
 	if err := cboring.Marshal(&bndl, buff); err != nil {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-			"error":  err,
-		}).Debug("Error marshaling data")
+		logger.WithError(err).Debug("Error marshaling data")
 		return err
 	} else {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-		}).Debug("Marshaled data")
+		logger.Debug("Marshaled data")
 	}
 
-	ctx := context.Background()
-	err := endpoint.rateLimiter.Acquire(ctx, 1)
+	// Waiting for another transmission to finish is bounded by its idle timeout, closing the connection if it stalls
+	err := endpoint.rateLimiter.Acquire(endpoint.connection.Context(), 1)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-			"error":  err,
-		}).Debug("Error acquiring lock stream")
+		logger.WithError(err).Debug("Error acquiring lock stream")
 		return err
 	}
 	defer endpoint.rateLimiter.Release(1)
@@ -208,11 +258,7 @@ func (endpoint *Endpoint) Send(bndl bpv7.Bundle) error {
 	stream, err := endpoint.connection.OpenStream()
 	if err != nil {
 		// TODO: understand possible error cases
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-			"error":  err,
-		}).Debug("Error opening stream")
+		logger.WithError(err).Debug("Error opening stream")
 
 		var netErr net.Error
 		if errors.As(err, &netErr) {
@@ -223,89 +269,60 @@ func (endpoint *Endpoint) Send(bndl bpv7.Bundle) error {
 
 		return err
 	} else {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-		}).Debug("Opened stream")
+		logger.Debug("Opened stream")
 	}
 
+	// A peer not accepting any data within the idle timeout is considered gone
+	defer func() { _ = stream.SetWriteDeadline(time.Time{}) }()
+
 	// TODO: Do we actually need the bufio-wrapper?
-	writer := bufio.NewWriter(stream)
+	writer := bufio.NewWriter(endpoint.traffic.Writer(cla.IdleDeadlineWriter(stream, stream)))
 	if _, err = buff.WriteTo(writer); err != nil {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-			"error":  err,
-		}).Debug("Error writing to stream")
+		logger.WithError(err).Debug("Error writing to stream")
 
 		stream.CancelWrite(internal.StreamTransmissionError)
 		sErr := stream.Close()
 		if sErr != nil {
-			log.WithFields(log.Fields{
-				"peer":   endpoint.peerId,
-				"bundle": bndl.ID(),
-				"error":  sErr,
-			}).Debug("Error closing stream (buffer-write error)")
+			logger.WithError(sErr).Debug("Error closing stream (buffer-write error)")
 		}
 
 		var netErr net.Error
 		if errors.As(err, &netErr) {
 			if netErr.Timeout() {
-				cla.GetManagerSingleton().NotifyDisconnect(endpoint)
+				endpoint.sendDeadlineExceeded()
 			}
 		}
 		return err
 	} else {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-		}).Debug("Wrote bundle to stream")
+		logger.Debug("Wrote bundle to stream")
 	}
 
 	if err = writer.Flush(); err != nil {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-			"error":  err,
-		}).Debug("Error flushing buffer")
+		logger.WithError(err).Debug("Error flushing buffer")
 
 		stream.CancelWrite(internal.StreamTransmissionError)
 		sErr := stream.Close()
 		if sErr != nil {
-			log.WithFields(log.Fields{
-				"peer":   endpoint.peerId,
-				"bundle": bndl.ID(),
-				"error":  sErr,
-			}).Debug("Error closing stream (flush error)")
+			logger.WithError(sErr).Debug("Error closing stream (flush error)")
 		}
 
 		var netErr net.Error
 		if errors.As(err, &netErr) {
 			if netErr.Timeout() {
-				cla.GetManagerSingleton().NotifyDisconnect(endpoint)
+				endpoint.sendDeadlineExceeded()
 			}
 		}
 		return err
 	} else {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-		}).Debug("Flushed buffer")
+		logger.Debug("Flushed buffer")
 	}
 
 	sErr := stream.Close()
 	if sErr != nil {
-		log.WithFields(log.Fields{
-			"peer":   endpoint.peerId,
-			"bundle": bndl.ID(),
-			"error":  sErr,
-		}).Debug("Error closing stream (send successful)")
+		logger.WithError(sErr).Debug("Error closing stream (send successful)")
 	}
 
-	log.WithFields(log.Fields{
-		"peer":   endpoint.peerId,
-		"bundle": bndl.ID(),
-	}).Debug("Bundle sent")
+	logger.Debug("Bundle sent")
 
 	return nil
 }
@@ -322,24 +339,26 @@ func (endpoint *Endpoint) handleConnection() {
 	log.WithFields(log.Fields{"endpoint": endpoint.GetEndpointID(), "peer": endpoint.GetPeerEndpointID()}).Debug("CLA Started")
 
 	for {
+		// Only accept a new stream if there is capacity to handle it
+		_ = endpoint.streamLimiter.Acquire(context.Background(), 1)
+
 		stream, err := endpoint.connection.AcceptStream(context.Background())
 		log.WithField("CLA", endpoint).Debug("New incoming stream")
 		if err != nil {
+			endpoint.streamLimiter.Release(1)
+
 			var netErr net.Error
 			var appErr *quic.ApplicationError
 
+			// AcceptStream only fails for a closed connection, so this loop must end for each error.
 			switch {
-			case errors.As(err, &netErr):
-				if netErr.Timeout() {
-					log.WithFields(log.Fields{
-						"CLA":   endpoint,
-						"error": netErr,
-					}).Debug("Peer timed out.")
-
-					cla.GetManagerSingleton().NotifyDisconnect(endpoint)
+			case errors.As(err, &netErr) && netErr.Timeout():
+				log.WithFields(log.Fields{
+					"CLA":   endpoint,
+					"error": netErr,
+				}).Debug("Peer timed out.")
 
-					return
-				}
+				cla.GetManagerSingleton().NotifyDisconnect(endpoint)
 
 			case errors.As(err, &appErr):
 				log.WithFields(log.Fields{
@@ -351,16 +370,21 @@ func (endpoint *Endpoint) handleConnection() {
 				if appErr.Remote {
 					cla.GetManagerSingleton().NotifyDisconnect(endpoint)
 				}
-				return
 
 			default:
 				log.WithFields(log.Fields{
 					"CLA":   endpoint,
 					"error": err,
 				}).Error("Unexpected error while waiting for stream")
+
+				cla.GetManagerSingleton().NotifyDisconnect(endpoint)
 			}
+			return
 		} else {
-			go endpoint.handleStream(stream)
+			go func() {
+				defer endpoint.streamLimiter.Release(1)
+				endpoint.handleStream(stream)
+			}()
 		}
 	}
 }
@@ -371,7 +395,7 @@ func (endpoint *Endpoint) handleStream(stream quic.Stream) {
 	log.WithField("cla", endpoint).Debug("Receiving bundle via quicl")
 
 	// TODO: Do we actually need the bufio-wrapper?
-	reader := bufio.NewReader(stream)
+	reader := bufio.NewReader(endpoint.traffic.Reader(stream))
 
 	bundle := new(bpv7.Bundle)
 	if err := cboring.Unmarshal(bundle, reader); err != nil {
@@ -393,7 +417,11 @@ func (endpoint *Endpoint) handleStream(stream quic.Stream) {
 			"cla": endpoint,
 		}).Debug("quicl received a bundle")
 
-		endpoint.receiveCallback(bundle)
+		if endpoint.receiveFromCallback != nil {
+			endpoint.receiveFromCallback(bundle, endpoint.peerId)
+		} else {
+			endpoint.receiveCallback(bundle)
+		}
 	}
 	log.WithFields(log.Fields{
 		"cla":    endpoint,
@@ -401,6 +429,49 @@ func (endpoint *Endpoint) handleStream(stream quic.Stream) {
 	}).Debug("Finished handling stream")
 }
 
+// sendDeadlineExceeded treats a Send which stalled as a disconnect, closing the presumably half-open connection.
+func (endpoint *Endpoint) sendDeadlineExceeded() {
+	log.WithFields(log.Fields{
+		"peer":         endpoint.peerId,
+		"idle timeout": cla.SendIdleTimeout(),
+	}).Info("Sending bundle stalled, considering peer disconnected")
+
+	cla.GetManagerSingleton().NotifyDisconnect(endpoint)
+	_ = endpoint.connection.CloseWithError(internal.ConnectionError, "Send timed out")
+}
+
+// SetReceiveFromCallback passes received bundles together with the peer's endpoint ID to the given callback.
+func (endpoint *Endpoint) SetReceiveFromCallback(receiveFromCallback func(*bpv7.Bundle, bpv7.EndpointID)) {
+	endpoint.receiveFromCallback = receiveFromCallback
+}
+
+// SetSendHandshakeWait sets how long Send waits for a pending handshake to complete, e.g., for bundles offered right
+// after dialing. With a zero duration, Send fails immediately if the handshake has not been completed yet.
+func (endpoint *Endpoint) SetSendHandshakeWait(wait time.Duration) {
+	endpoint.sendHandshakeWait = wait
+}
+
+// finishHandshake wakes up all Send calls waiting for the handshake.
+func (endpoint *Endpoint) finishHandshake() {
+	endpoint.handshakeDoneOnce.Do(func() { close(endpoint.handshakeDone) })
+}
+
+// awaitHandshake waits up to sendHandshakeWait for a pending handshake and reports whether it was completed.
+func (endpoint *Endpoint) awaitHandshake() bool {
+	if atomic.LoadUint32(endpoint.handshake) == 1 {
+		return true
+	}
+
+	if endpoint.sendHandshakeWait > 0 {
+		select {
+		case <-endpoint.handshakeDone:
+		case <-time.After(endpoint.sendHandshakeWait):
+		}
+	}
+
+	return atomic.LoadUint32(endpoint.handshake) == 1
+}
+
 // handshakeListener performs the dialer-portion of the protocol handshake
 // Since communication is initiated by the dialer, we listen on the connection for a new stream
 // We then receive the dialer's EndpointID and finish by sending them ours
@@ -423,7 +494,6 @@ func (endpoint *Endpoint) handshakeListener() error {
 
 	// The listener first receives the dialer's ID
 	if err = endpoint.receiveEndpointID(stream); err != nil {
-		// TODO: close connection with error
 		return err
 	}
 
@@ -460,19 +530,21 @@ func (endpoint *Endpoint) handshakeDialer() error {
 	}
 
 	// wait for the listener's ID
-	err = endpoint.receiveEndpointID(stream)
-	// TODO: if error, close stream
+	if err = endpoint.receiveEndpointID(stream); err != nil {
+		return err
+	}
 
 	atomic.StoreUint32(endpoint.handshake, 1)
 
-	return err
+	return nil
 }
 
 // sendEndpointID sends this CLA's EndpointID (the one which is stored in the id-field) over a given QUIC stream.
 // The EndpointID is first marshalled into a buffer using its builtin cboring marshaller.
 // We then send the length of the buffer (using cboring ByteStringLen) followed by the ID itself.
-func (endpoint *Endpoint) sendEndpointID(stream quic.Stream) error {
+func (endpoint *Endpoint) sendEndpointID(stream quic.Stream) (err error) {
 	log.WithField("cla", endpoint).Debug("Sending own endpoint id")
+	defer func() { abortHandshakeStream(stream, err) }()
 
 	buff := new(bytes.Buffer)
 	if err := cboring.Marshal(&endpoint.id, buff); err != nil {
@@ -480,7 +552,7 @@ func (endpoint *Endpoint) sendEndpointID(stream quic.Stream) error {
 	}
 
 	// TODO: Do we actually need the bufio-wrapper?
-	writer := bufio.NewWriter(stream)
+	writer := bufio.NewWriter(endpoint.traffic.Writer(stream))
 	if err := cboring.WriteByteStringLen(uint64(buff.Len()), writer); err != nil {
 		return internal.NewHandshakeError("error sending id length", internal.ConnectionError, err)
 	}
@@ -499,23 +571,33 @@ func (endpoint *Endpoint) sendEndpointID(stream quic.Stream) error {
 // receiveEndpointID receives a remote CLA's EndpointID over a given QUIC stream
 // The serialised form consists of the cbor representation of the EndpointID,
 // wrapped in a cbor byte-string
-func (endpoint *Endpoint) receiveEndpointID(stream quic.Stream) error {
+//
+// Failing to read the data is a ConnectionError, while malformed data is a PeerError.
+func (endpoint *Endpoint) receiveEndpointID(stream quic.Stream) (err error) {
 	log.WithField("cla", endpoint).Debug("Receiving peer's endpoint id")
-	reader := bufio.NewReader(stream)
+	defer func() { abortHandshakeStream(stream, err) }()
+
+	reader := bufio.NewReader(endpoint.traffic.Reader(stream))
 
 	length, err := cboring.ReadByteStringLen(reader)
-	if err != nil && !errors.Is(err, io.EOF) {
+	if errors.Is(err, io.EOF) {
+		return internal.NewHandshakeError("stream closed before id length", internal.ConnectionError, io.ErrUnexpectedEOF)
+	} else if err != nil {
 		return internal.NewHandshakeError("error reading id length", internal.ConnectionError, err)
-	} else if length == 0 {
-		return internal.NewHandshakeError("error reading id length", internal.ConnectionError, fmt.Errorf("length is 0"))
+	} else if length == 0 || length > maxEndpointIDLength {
+		return internal.NewHandshakeError("invalid id length", internal.PeerError, fmt.Errorf("length is %d", length))
 	}
 
-	id := new(bpv7.EndpointID)
-	if err = cboring.Unmarshal(id, reader); err != nil {
-		// TODO: distinguish cbor and transmission errors
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
 		return internal.NewHandshakeError("error reading id", internal.ConnectionError, err)
 	}
 
+	id := new(bpv7.EndpointID)
+	if err := cboring.Unmarshal(id, bytes.NewReader(data)); err != nil {
+		return internal.NewHandshakeError("malformed id", internal.PeerError, err)
+	}
+
 	log.WithFields(log.Fields{
 		"cla":     endpoint,
 		"peer id": id,
@@ -525,3 +607,20 @@ func (endpoint *Endpoint) receiveEndpointID(stream quic.Stream) error {
 
 	return nil
 }
+
+// abortHandshakeStream cancels both directions of the handshake's stream if the handshake failed.
+// The StreamErrorCode corresponds to the HandshakeError's code.
+func abortHandshakeStream(stream quic.Stream, err error) {
+	if err == nil {
+		return
+	}
+
+	code := internal.StreamTransmissionError
+	var herr *internal.HandshakeError
+	if errors.As(err, &herr) {
+		code = herr.StreamCode()
+	}
+
+	stream.CancelRead(code)
+	stream.CancelWrite(code)
+}
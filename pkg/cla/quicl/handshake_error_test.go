@@ -0,0 +1,101 @@
+package quicl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/dtn7/cboring"
+	"github.com/quic-go/quic-go"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla/quicl/internal"
+)
+
+// handshakeStream is a quic.Stream reading from a buffer and recording its cancellation.
+type handshakeStream struct {
+	quic.Stream
+
+	reader io.Reader
+
+	readCancelled  *quic.StreamErrorCode
+	writeCancelled *quic.StreamErrorCode
+}
+
+func (hs *handshakeStream) Read(p []byte) (int, error) { return hs.reader.Read(p) }
+func (hs *handshakeStream) CancelRead(code quic.StreamErrorCode) {
+	hs.readCancelled = &code
+}
+func (hs *handshakeStream) CancelWrite(code quic.StreamErrorCode) {
+	hs.writeCancelled = &code
+}
+
+// serialisedID wraps the data into a CBOR byte string, as sendEndpointID does.
+func serialisedID(t *testing.T, data []byte) []byte {
+	buff := new(bytes.Buffer)
+	if err := cboring.WriteByteStringLen(uint64(len(data)), buff); err != nil {
+		t.Fatal(err)
+	}
+	buff.Write(data)
+	return buff.Bytes()
+}
+
+func TestReceiveEndpointID(t *testing.T) {
+	eid := bpv7.MustNewEndpointID("dtn://peer/")
+	eidBuff := new(bytes.Buffer)
+	if err := cboring.Marshal(&eid, eidBuff); err != nil {
+		t.Fatal(err)
+	}
+	valid := serialisedID(t, eidBuff.Bytes())
+
+	tests := []struct {
+		name       string
+		data       []byte
+		code       quic.ApplicationErrorCode
+		streamCode quic.StreamErrorCode
+	}{
+		{"valid", valid, 0, 0},
+		{"malformed", serialisedID(t, []byte{0xff, 0x00, 0x13, 0x37}), internal.PeerError, internal.DataUnmarshalError},
+		{"zero length", serialisedID(t, nil), internal.PeerError, internal.DataUnmarshalError},
+		{"premature EOF", valid[:len(valid)-2], internal.ConnectionError, internal.StreamTransmissionError},
+		{"empty stream", nil, internal.ConnectionError, internal.StreamTransmissionError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := &handshakeStream{reader: bytes.NewReader(test.data)}
+			endpoint := NewDialerEndpoint("127.0.0.1:1", bpv7.MustNewEndpointID("dtn://own/"), func(*bpv7.Bundle) {})
+
+			err := endpoint.receiveEndpointID(stream)
+
+			if test.code == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if endpoint.peerId != eid {
+					t.Fatalf("Received peer id %v instead of %v", endpoint.peerId, eid)
+				}
+				if stream.readCancelled != nil || stream.writeCancelled != nil {
+					t.Fatal("Stream of a successful handshake was cancelled")
+				}
+				return
+			}
+
+			var herr *internal.HandshakeError
+			if !errors.As(err, &herr) {
+				t.Fatalf("Expected a HandshakeError, got %v", err)
+			}
+			if herr.Code != test.code {
+				t.Fatalf("Expected error code %d, got %d: %v", test.code, herr.Code, herr)
+			}
+
+			if stream.readCancelled == nil || *stream.readCancelled != test.streamCode {
+				t.Fatalf("Stream's read side was not cancelled with code %d", test.streamCode)
+			}
+			if stream.writeCancelled == nil || *stream.writeCancelled != test.streamCode {
+				t.Fatalf("Stream's write side was not cancelled with code %d", test.streamCode)
+			}
+		})
+	}
+}
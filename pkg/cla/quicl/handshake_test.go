@@ -0,0 +1,119 @@
+package quicl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/quicl/internal"
+)
+
+// freeUDPPort returns a currently unused local UDP port.
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// awaitDeregistration waits until the listener's side of a closed connection was removed from the manager.
+func awaitDeregistration(t *testing.T) {
+	for deadline := time.Now().Add(time.Second); len(cla.GetManagerSingleton().GetSenders()) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Listener's endpoint was not deregistered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendDuringHandshake(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	port := freeUDPPort(t)
+
+	received := make(chan *bpv7.Bundle, 1)
+	serv := NewQUICListener(fmt.Sprintf("127.0.0.1:%d", port), bpv7.MustNewEndpointID("dtn://quicl/"),
+		func(bundle *bpv7.Bundle) { received <- bundle })
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://client/").
+		Destination("dtn://quicl/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello during handshake")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewDialerEndpoint(fmt.Sprintf("127.0.0.1:%d", port), bpv7.MustNewEndpointID("dtn://client/"), func(*bpv7.Bundle) {})
+
+	// Offer the bundle while the endpoint is still dialing and performing its handshake
+	activated := make(chan error, 1)
+	go func() { activated <- client.Activate() }()
+
+	if err := client.Send(bundle); err != nil {
+		t.Fatalf("Sending during the handshake failed: %v", err)
+	}
+	if err := <-activated; err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case bndl := <-received:
+		if bndl.ID() != bundle.ID() {
+			t.Fatalf("Received bundle %v instead of %v", bndl.ID(), bundle.ID())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Bundle was not received")
+	}
+
+	// Wait for the listener's side to notice the closed connection before shutting down the manager
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	awaitDeregistration(t)
+}
+
+func TestSendWithoutHandshake(t *testing.T) {
+	bundle, err := bpv7.Builder().
+		Source("dtn://client/").
+		Destination("dtn://quicl/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello nobody")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, wait := range []time.Duration{0, 50 * time.Millisecond} {
+		client := NewDialerEndpoint("127.0.0.1:1", bpv7.MustNewEndpointID("dtn://client/"), func(*bpv7.Bundle) {})
+		client.SetSendHandshakeWait(wait)
+
+		start := time.Now()
+		err := client.Send(bundle)
+
+		var initErr *internal.InitialisationError
+		if !errors.As(err, &initErr) {
+			t.Fatalf("Expected an InitialisationError without a handshake, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < wait || elapsed > wait+time.Second {
+			t.Fatalf("Send gave up after %v, expected about %v", elapsed, wait)
+		}
+	}
+}
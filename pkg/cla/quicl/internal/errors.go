@@ -23,6 +23,8 @@ const (
 
 	DataMarshalError        quic.StreamErrorCode = 1
 	StreamTransmissionError quic.StreamErrorCode = 2
+	// DataUnmarshalError designates received data which could not be unmarshalled, i.e., was malformed by the peer
+	DataUnmarshalError quic.StreamErrorCode = 3
 )
 
 // HandshakeError is thrown by either the listener or dialer if there is any problem during the protocol handshake
@@ -49,6 +51,18 @@ func (err *HandshakeError) Unwrap() error {
 	return err.Cause
 }
 
+// StreamCode returns the StreamErrorCode for aborting the handshake's stream, corresponding to the error's Code.
+func (err *HandshakeError) StreamCode() quic.StreamErrorCode {
+	switch err.Code {
+	case LocalError:
+		return DataMarshalError
+	case PeerError:
+		return DataUnmarshalError
+	default:
+		return StreamTransmissionError
+	}
+}
+
 type InitialisationError struct {
 	Msg string
 }
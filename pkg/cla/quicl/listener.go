@@ -75,7 +75,7 @@ func (listener *Listener) handle() {
 		session, err := listener.quicListener.Accept(context.Background())
 		if err != nil {
 			if !(errors.Is(err, context.DeadlineExceeded)) {
-				if err.Error() == "quic: Server closed" {
+				if errors.Is(err, quic.ErrServerClosed) {
 					log.WithField("address", listener.listenAddress).Info("Shutting this place down")
 					return
 				}
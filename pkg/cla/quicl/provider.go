@@ -0,0 +1,25 @@
+package quicl
+
+import (
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// provider is QUICL's cla.ConvergenceProvider, registered on package initialisation.
+type provider struct{}
+
+func init() {
+	_ = cla.RegisterProvider(provider{})
+}
+
+func (provider) Type() cla.CLAType {
+	return cla.QUICL
+}
+
+func (provider) NewListener(config cla.ListenerConfig, receiveCallback func(*bpv7.Bundle)) (cla.ConvergenceListener, error) {
+	return NewQUICListener(config.Address, config.EndpointId, receiveCallback), nil
+}
+
+func (provider) NewPeer(address string, nodeID bpv7.EndpointID, _ bpv7.EndpointID, receiveCallback func(*bpv7.Bundle)) (cla.Convergence, error) {
+	return NewDialerEndpoint(address, nodeID, receiveCallback), nil
+}
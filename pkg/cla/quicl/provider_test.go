@@ -0,0 +1,90 @@
+package quicl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// TestProvider exchanges bundles in both directions between a listener and a peer created by QUICL's provider.
+func TestProvider(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	serverID := bpv7.MustNewEndpointID("dtn://quicl/")
+	clientID := bpv7.MustNewEndpointID("dtn://client/")
+	address := fmt.Sprintf("127.0.0.1:%d", freeUDPPort(t))
+
+	receivedServer := make(chan *bpv7.Bundle, 1)
+	listener, err := cla.NewListener(cla.ListenerConfig{Type: cla.QUICL, Address: address, EndpointId: serverID},
+		func(bundle *bpv7.Bundle) { receivedServer <- bundle })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := listener.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	receivedClient := make(chan *bpv7.Bundle, 1)
+	peer, err := cla.NewPeer(cla.QUICL, address, clientID, serverID, func(bundle *bpv7.Bundle) { receivedClient <- bundle })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.Activate(); err != nil {
+		t.Fatal(err)
+	}
+
+	exchange := func(sender cla.ConvergenceSender, received chan *bpv7.Bundle, source, destination bpv7.EndpointID) {
+		bundle, err := bpv7.Builder().
+			Source(source).
+			Destination(destination).
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock([]byte("hello " + destination.String())).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sender.Send(bundle); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case bndl := <-received:
+			if bndl.ID() != bundle.ID() {
+				t.Fatalf("Received bundle %v instead of %v", bndl.ID(), bundle.ID())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Bundle for %v was not received", destination)
+		}
+	}
+
+	exchange(peer.(cla.ConvergenceSender), receivedServer, clientID, serverID)
+
+	// The listener registered its side of the connection with the manager, knowing the client from the handshake
+	var back cla.ConvergenceSender
+	for deadline := time.Now().Add(time.Second); back == nil; time.Sleep(10 * time.Millisecond) {
+		for _, sender := range cla.GetManagerSingleton().GetSenders() {
+			if sender.GetPeerEndpointID() == clientID {
+				back = sender
+			}
+		}
+		if back == nil && time.Now().After(deadline) {
+			t.Fatal("Listener's endpoint to the client was not registered")
+		}
+	}
+	exchange(back, receivedClient, serverID, clientID)
+
+	// Wait for the listener's side to notice the closed connection before shutting down the manager
+	if err := peer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	awaitDeregistration(t)
+}
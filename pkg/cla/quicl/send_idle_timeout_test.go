@@ -0,0 +1,129 @@
+package quicl
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// blackHoleRelay forwards UDP datagrams between a single client and a server until it is told to drop all of them.
+type blackHoleRelay struct {
+	conn   *net.UDPConn
+	server *net.UDPAddr
+	drop   atomic.Bool
+
+	mutex  sync.Mutex
+	client *net.UDPAddr
+}
+
+func newBlackHoleRelay(t *testing.T, server string) *blackHoleRelay {
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := &blackHoleRelay{conn: conn, server: serverAddr}
+	go relay.handle()
+	return relay
+}
+
+func (relay *blackHoleRelay) handle() {
+	buff := make([]byte, 64*1024)
+	for {
+		n, from, err := relay.conn.ReadFromUDP(buff)
+		if err != nil {
+			return
+		} else if relay.drop.Load() {
+			continue
+		}
+
+		relay.mutex.Lock()
+		to := relay.server
+		if from.String() == relay.server.String() {
+			to = relay.client
+		} else {
+			relay.client = from
+		}
+		relay.mutex.Unlock()
+
+		if to != nil {
+			_, _ = relay.conn.WriteToUDP(buff[:n], to)
+		}
+	}
+}
+
+func TestSendIdleTimeout(t *testing.T) {
+	const deadline = 200 * time.Millisecond
+	defer cla.SetSendIdleTimeout(cla.DefaultSendIdleTimeout)
+	cla.SetSendIdleTimeout(deadline)
+
+	disconnected := make(chan bpv7.EndpointID, 4)
+	err := cla.InitialiseCLAManager(
+		func(*bpv7.Bundle) {},
+		func(bpv7.EndpointID) {},
+		func(eid bpv7.EndpointID) { disconnected <- eid })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	serverID := bpv7.MustNewEndpointID("dtn://black-hole/")
+	address := fmt.Sprintf("127.0.0.1:%d", freeUDPPort(t))
+	serv := NewQUICListener(address, serverID, func(*bpv7.Bundle) {})
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	relay := newBlackHoleRelay(t, address)
+	defer relay.conn.Close()
+
+	endpoint := NewDialerEndpoint(relay.conn.LocalAddr().String(), bpv7.MustNewEndpointID("dtn://client/"),
+		func(*bpv7.Bundle) {})
+	if err := endpoint.Activate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the handshake, the peer vanishes without closing the connection
+	relay.drop.Store(true)
+
+	bndl, err := bpv7.Builder().
+		Source("dtn://client/").
+		Destination(serverID).
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock(make([]byte, 4*1024*1024)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := endpoint.Send(bndl); err == nil {
+		t.Fatal("Sending to a black hole succeeded")
+	} else if duration := time.Since(start); duration > deadline+time.Second {
+		t.Fatalf("Send returned after %v, exceeding the deadline of %v", duration, deadline)
+	}
+
+	// The listener's side of the connection might report the client's disconnect as well
+	for timeout := time.After(time.Second); ; {
+		select {
+		case eid := <-disconnected:
+			if eid == serverID {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Stalled transmission was not reported as a disconnect")
+		}
+	}
+}
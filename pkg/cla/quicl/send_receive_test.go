@@ -6,6 +6,7 @@ import (
 	"sync"
 	"testing"
 
+	"go.uber.org/goleak"
 	"pgregory.net/rapid"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
@@ -49,6 +50,8 @@ func teardown() {
 }
 
 func TestSendReceive(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
 	rapid.Check(t, func(t *rapid.T) {
 		setup(t)
 		defer teardown()
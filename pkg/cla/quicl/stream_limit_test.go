@@ -0,0 +1,113 @@
+package quicl
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+func TestReceiveStreamLimit(t *testing.T) {
+	const (
+		limit           = 2
+		numberOfBundles = 12
+	)
+
+	SetReceiveStreamLimit(limit)
+	defer SetReceiveStreamLimit(DefaultReceiveStreamLimit)
+
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	// Slow receivers keep their streams busy, so that further streams pile up
+	var active, maxActive atomic.Int32
+	var wgReceive sync.WaitGroup
+	wgReceive.Add(numberOfBundles)
+	receive := func(*bpv7.Bundle) {
+		n := active.Add(1)
+		for {
+			if m := maxActive.Load(); n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		active.Add(-1)
+		wgReceive.Done()
+	}
+
+	port := freeUDPPort(t)
+	serv := NewQUICListener(fmt.Sprintf("127.0.0.1:%d", port), bpv7.MustNewEndpointID("dtn://quicl/"), receive)
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	client := NewDialerEndpoint(fmt.Sprintf("127.0.0.1:%d", port), bpv7.MustNewEndpointID("dtn://client/"), func(*bpv7.Bundle) {})
+	if err := client.Activate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wgSend sync.WaitGroup
+	wgSend.Add(numberOfBundles)
+	for i := 0; i < numberOfBundles; i++ {
+		go func(i int) {
+			defer wgSend.Done()
+
+			bundle, err := bpv7.Builder().
+				Source("dtn://client/").
+				Destination("dtn://quicl/").
+				CreationTimestampNow().
+				Lifetime("10m").
+				PayloadBlock([]byte(fmt.Sprintf("bundle %d", i))).
+				Build()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := client.Send(bundle); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wgSend.Wait()
+
+	received := make(chan struct{})
+	go func() {
+		wgReceive.Wait()
+		close(received)
+	}()
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Not all bundles were received")
+	}
+
+	if m := maxActive.Load(); m > limit {
+		t.Fatalf("%d streams were handled concurrently, limit is %d", m, limit)
+	} else if m < limit {
+		t.Logf("Only %d streams were handled concurrently", m)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	awaitDeregistration(t)
+}
+
+func TestReceiveStreamLimitInvalid(t *testing.T) {
+	for _, limit := range []int64{0, -1} {
+		SetReceiveStreamLimit(limit)
+		endpoint := NewDialerEndpoint("localhost:35037", bpv7.MustNewEndpointID("dtn://node/"), nil)
+		if !endpoint.streamLimiter.TryAcquire(DefaultReceiveStreamLimit) {
+			t.Fatalf("Limit %d was not replaced by the default", limit)
+		}
+	}
+	SetReceiveStreamLimit(DefaultReceiveStreamLimit)
+}
@@ -0,0 +1,88 @@
+package quicl
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+func TestTraffic(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	received := make(chan struct{}, 8)
+	port := freeUDPPort(t)
+	serv := NewQUICListener(fmt.Sprintf("127.0.0.1:%d", port), bpv7.MustNewEndpointID("dtn://quicl/"),
+		func(*bpv7.Bundle) { received <- struct{}{} })
+	if err := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	client := NewDialerEndpoint(fmt.Sprintf("127.0.0.1:%d", port), bpv7.MustNewEndpointID("dtn://client/"), func(*bpv7.Bundle) {})
+	if err := client.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	handshake := client.Traffic().Sent
+
+	var bundlesSize uint64
+	for _, size := range []int{0, 100, 100000} {
+		bndl, err := bpv7.Builder().
+			Source("dtn://client/").
+			Destination("dtn://quicl/").
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock(make([]byte, size)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buff := new(bytes.Buffer)
+		if err := cboring.Marshal(&bndl, buff); err != nil {
+			t.Fatal(err)
+		}
+		bundlesSize += uint64(buff.Len())
+
+		if err := client.Send(bndl); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("Bundle was not received")
+		}
+	}
+
+	// Each bundle has its own stream without any further framing
+	sent := client.Traffic().Sent
+	if sent-handshake != bundlesSize {
+		t.Fatalf("Client sent %d bytes for bundles of %d bytes", sent-handshake, bundlesSize)
+	}
+
+	// The listener's side of the connection is registered at the manager
+	var senders []cla.ConvergenceSender
+	for deadline := time.Now().Add(time.Second); len(senders) != 1; time.Sleep(10 * time.Millisecond) {
+		if senders = cla.GetManagerSingleton().GetSenders(); time.Now().After(deadline) {
+			t.Fatalf("Expected the listener's endpoint to be registered, got %v", senders)
+		}
+	}
+	traffic, ok := cla.GetManagerSingleton().Traffic()[senders[0].Address()]
+	if !ok || traffic.Received != sent {
+		t.Fatalf("Listener's endpoint received %+v, client sent %d bytes", traffic, sent)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	awaitDeregistration(t)
+}
@@ -0,0 +1,76 @@
+package cla
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSendIdleTimeout is the default duration a transmission may stall, see SetSendIdleTimeout.
+const DefaultSendIdleTimeout = time.Minute
+
+// sendIdleTimeout bounds the stalls of the transmissions of the CLAs supporting it; unlimited if zero
+var sendIdleTimeout atomic.Int64
+
+func init() {
+	sendIdleTimeout.Store(int64(DefaultSendIdleTimeout))
+}
+
+// SetSendIdleTimeout sets the duration a Send call may make no progress before it is aborted. As a peer not accepting
+// any data within this duration is most likely gone, e.g., behind a half-open connection, the aborting CLA reports a
+// disconnect to the Manager. A transmission progressing steadily is never aborted, regardless of the bundles' sizes or
+// the link's speed. A zero duration disables the timeout.
+//
+// This is supported by the mtcp and quicl CLAs.
+func SetSendIdleTimeout(timeout time.Duration) {
+	sendIdleTimeout.Store(int64(timeout))
+}
+
+// SendIdleTimeout returns the duration a Send call may make no progress; unlimited if zero.
+func SendIdleTimeout() time.Duration {
+	return time.Duration(sendIdleTimeout.Load())
+}
+
+// WriteDeadliner is a connection or stream whose writes can be bounded by a deadline, e.g., a net.Conn.
+type WriteDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// idleWriteChunkSize is the maximum number of bytes written under one renewed deadline. Thus, a transmission must
+// progress by this many bytes per SendIdleTimeout.
+const idleWriteChunkSize = 16 * 1024
+
+// IdleDeadlineWriter wraps w, writing to the connection conn, and renews conn's write deadline to the SendIdleTimeout
+// before writing each chunk. If the SendIdleTimeout is disabled, w is returned as it is. The caller must reset conn's
+// write deadline after the transmission.
+func IdleDeadlineWriter(w io.Writer, conn WriteDeadliner) io.Writer {
+	timeout := SendIdleTimeout()
+	if timeout <= 0 {
+		return w
+	}
+	return &idleDeadlineWriter{w: w, conn: conn, timeout: timeout}
+}
+
+type idleDeadlineWriter struct {
+	w       io.Writer
+	conn    WriteDeadliner
+	timeout time.Duration
+}
+
+func (iw *idleDeadlineWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p[:min(len(p), idleWriteChunkSize)]
+		if err = iw.conn.SetWriteDeadline(time.Now().Add(iw.timeout)); err != nil {
+			return
+		}
+
+		var written int
+		written, err = iw.w.Write(chunk)
+		n += written
+		if err != nil {
+			return
+		}
+		p = p[written:]
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package cla
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIdleDeadlineWriter(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	defer SetSendIdleTimeout(DefaultSendIdleTimeout)
+	SetSendIdleTimeout(timeout)
+
+	local, remote := net.Pipe()
+	defer func() { _ = local.Close() }()
+	defer func() { _ = remote.Close() }()
+
+	// The slow reader lets the whole transmission take longer than the timeout, but progresses steadily
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buff := make([]byte, idleWriteChunkSize)
+		for i := 0; i < 16; i++ {
+			time.Sleep(timeout / 2)
+			if _, err := io.ReadFull(remote, buff); err != nil {
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	if _, err := IdleDeadlineWriter(local, local).Write(make([]byte, 16*idleWriteChunkSize)); err != nil {
+		t.Fatalf("Steadily progressing write failed after %v: %v", time.Since(start), err)
+	}
+	<-done
+
+	// Without a reader, the write stalls and fails after the timeout
+	start = time.Now()
+	_, err := IdleDeadlineWriter(local, local).Write(make([]byte, idleWriteChunkSize))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Stalled write resulted in %v", err)
+	} else if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Fatalf("Stalled write returned after %v", elapsed)
+	}
+}
+
+func TestIdleDeadlineWriterDisabled(t *testing.T) {
+	defer SetSendIdleTimeout(DefaultSendIdleTimeout)
+	SetSendIdleTimeout(0)
+
+	local, remote := net.Pipe()
+	defer func() { _ = local.Close() }()
+	defer func() { _ = remote.Close() }()
+
+	if w := IdleDeadlineWriter(local, local); w != io.Writer(local) {
+		t.Fatal("Disabled idle timeout wrapped the writer")
+	}
+}
@@ -0,0 +1,129 @@
+package cla
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Traffic is the number of bytes a CLA has sent and received, including its protocol's framing.
+type Traffic struct {
+	Sent     uint64 `json:"sent"`
+	Received uint64 `json:"received"`
+	// LastActivity is the time of the most recent transfer; zero if nothing was transferred yet
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// TrafficCounter accumulates a CLA's Traffic atomically. All counted bytes are added to the aggregate of all CLAs as
+// well, see TotalTraffic. The zero value is ready to use.
+type TrafficCounter struct {
+	sent     atomic.Uint64
+	received atomic.Uint64
+	// lastActivity in nanoseconds since the Unix epoch
+	lastActivity atomic.Int64
+}
+
+// totalTraffic aggregates the Traffic of all TrafficCounters
+var totalTraffic TrafficCounter
+
+// TotalTraffic returns the Traffic of all CLAs since this process was started.
+func TotalTraffic() Traffic {
+	return totalTraffic.Traffic()
+}
+
+// AddSent counts n sent bytes.
+func (counter *TrafficCounter) AddSent(n int) {
+	if n > 0 {
+		counter.sent.Add(uint64(n))
+		totalTraffic.sent.Add(uint64(n))
+		counter.touch()
+	}
+}
+
+// AddReceived counts n received bytes.
+func (counter *TrafficCounter) AddReceived(n int) {
+	if n > 0 {
+		counter.received.Add(uint64(n))
+		totalTraffic.received.Add(uint64(n))
+		counter.touch()
+	}
+}
+
+// touch records a transfer at the current time as the last activity.
+func (counter *TrafficCounter) touch() {
+	now := time.Now().UnixNano()
+	counter.lastActivity.Store(now)
+	totalTraffic.lastActivity.Store(now)
+}
+
+// Traffic returns the bytes counted so far.
+func (counter *TrafficCounter) Traffic() Traffic {
+	traffic := Traffic{Sent: counter.sent.Load(), Received: counter.received.Load()}
+	if lastActivity := counter.lastActivity.Load(); lastActivity != 0 {
+		traffic.LastActivity = time.Unix(0, lastActivity)
+	}
+	return traffic
+}
+
+// Writer wraps w, counting all bytes written to it as sent.
+func (counter *TrafficCounter) Writer(w io.Writer) io.Writer {
+	return &countingWriter{w: w, counter: counter}
+}
+
+// Reader wraps r, counting all bytes read from it as received.
+func (counter *TrafficCounter) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, counter: counter}
+}
+
+type countingWriter struct {
+	w       io.Writer
+	counter *TrafficCounter
+}
+
+func (cw *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.counter.AddSent(n)
+	return
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter *TrafficCounter
+}
+
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.counter.AddReceived(n)
+	return
+}
+
+// TrafficCounting is implemented by CLAs, i.e., Convergences or ConvergenceListeners, counting their Traffic.
+type TrafficCounting interface {
+	// Traffic returns the bytes sent and received by this CLA.
+	Traffic() Traffic
+}
+
+// Traffic returns the Traffic of all registered CLAs counting it, identified by their addresses.
+// This method is thread-safe.
+func (manager *Manager) Traffic() map[string]Traffic {
+	manager.stateMutex.RLock()
+	defer manager.stateMutex.RUnlock()
+
+	traffic := make(map[string]Traffic)
+	add := func(c any, address string) {
+		if counting, ok := c.(TrafficCounting); ok {
+			traffic[address] = counting.Traffic()
+		}
+	}
+
+	for _, receiver := range manager.receivers {
+		add(receiver, receiver.Address())
+	}
+	for _, sender := range manager.senders {
+		add(sender, sender.Address())
+	}
+	for _, listener := range manager.listeners {
+		add(listener, listener.Address())
+	}
+	return traffic
+}
@@ -0,0 +1,39 @@
+package cla
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTrafficCounter(t *testing.T) {
+	before := TotalTraffic()
+
+	var counter TrafficCounter
+	if !counter.Traffic().LastActivity.IsZero() {
+		t.Fatal("Unused counter has a last activity")
+	}
+	start := time.Now()
+
+	var buff bytes.Buffer
+	if _, err := counter.Writer(&buff).Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(counter.Reader(&buff)); err != nil {
+		t.Fatal(err)
+	}
+	counter.AddSent(5)
+
+	traffic := counter.Traffic()
+	if traffic.Sent != 16 || traffic.Received != 11 {
+		t.Fatalf("Counted %+v", traffic)
+	} else if traffic.LastActivity.Before(start) {
+		t.Fatalf("Last activity %v is before the transfers", traffic.LastActivity)
+	}
+
+	// Other tests' CLAs might count their traffic concurrently
+	if total := TotalTraffic(); total.Sent < before.Sent+16 || total.Received < before.Received+11 {
+		t.Fatalf("Total traffic grew from %+v to %+v only", before, total)
+	}
+}
@@ -21,6 +21,9 @@ type Announcement struct {
 	Type     cla.CLAType
 	Endpoint bpv7.EndpointID
 	Port     uint
+	// Address is the host the CLA is bound to, e.g., one of multiple interfaces' addresses. If empty, the CLA is
+	// reachable at the announcement's origin.
+	Address string
 }
 
 // UnmarshalAnnouncements creates a new array of Announcement based on a CBOR byte string.
@@ -67,8 +70,15 @@ func MarshalAnnouncements(announcements []Announcement) (data []byte, err error)
 }
 
 // MarshalCbor creates a CBOR representation for an Announcement.
+//
+// The Address is only appended as a fourth element if present, keeping other Announcements compatible with nodes not
+// knowing this element.
 func (announcement *Announcement) MarshalCbor(w io.Writer) error {
-	if err := cboring.WriteArrayLength(3, w); err != nil {
+	var l uint64 = 3
+	if announcement.Address != "" {
+		l = 4
+	}
+	if err := cboring.WriteArrayLength(l, w); err != nil {
 		return err
 	}
 
@@ -81,16 +91,22 @@ func (announcement *Announcement) MarshalCbor(w io.Writer) error {
 	if err := cboring.WriteUInt(uint64(announcement.Port), w); err != nil {
 		return err
 	}
+	if l == 4 {
+		if err := cboring.WriteTextString(announcement.Address, w); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 // UnmarshalCbor creates an Announcement from its CBOR representation.
 func (announcement *Announcement) UnmarshalCbor(r io.Reader) error {
-	if l, err := cboring.ReadArrayLength(r); err != nil {
+	l, err := cboring.ReadArrayLength(r)
+	if err != nil {
 		return err
-	} else if l != 3 {
-		return fmt.Errorf("wrong array length: %d instead of 3", l)
+	} else if l != 3 && l != 4 {
+		return fmt.Errorf("wrong array length: %d instead of 3 or 4", l)
 	}
 
 	if n, err := cboring.ReadUInt(r); err != nil {
@@ -108,10 +124,24 @@ func (announcement *Announcement) UnmarshalCbor(r io.Reader) error {
 	} else {
 		announcement.Port = uint(n)
 	}
+	if l == 4 {
+		if announcement.Address, err = cboring.ReadTextString(r); err != nil {
+			return fmt.Errorf("unmarshalling address failed: %v", err)
+		}
+	}
 
 	return nil
 }
 
+// reachableFrom checks if the announced CLA is reachable at the announcement's origin.
+func (announcement Announcement) reachableFrom(origin string) bool {
+	return announcement.Address == "" || announcement.Address == origin
+}
+
 func (announcement Announcement) String() string {
+	if announcement.Address != "" {
+		return fmt.Sprintf("Announcement(%v,%v,%s,%d)",
+			announcement.Type, announcement.Endpoint, announcement.Address, announcement.Port)
+	}
 	return fmt.Sprintf("Announcement(%v,%v,%d)", announcement.Type, announcement.Endpoint, announcement.Port)
 }
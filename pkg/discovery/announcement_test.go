@@ -35,6 +35,12 @@ func TestDiscoveryMessageCbor(t *testing.T) {
 			Endpoint: bpv7.MustNewEndpointID("ipn:1337.23"),
 			Port:     12345,
 		},
+		{
+			Type:     cla.MTCP,
+			Endpoint: bpv7.MustNewEndpointID("dtn://foobar/"),
+			Port:     8000,
+			Address:  "fe80::1%eth0",
+		},
 	}
 
 	for _, dmIn := range tests {
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMinAnnouncementInterval is used as AnnouncementTiming.MinInterval, if unset.
+const DefaultMinAnnouncementInterval = time.Second
+
+// AnnouncementTiming configures the delays between a Manager's Announcements.
+type AnnouncementTiming struct {
+	// Interval between two Announcements on average
+	Interval time.Duration
+	// MinInterval is the lower bound of the delay between two Announcements; DefaultMinAnnouncementInterval if zero
+	MinInterval time.Duration
+	// Jitter shifts each delay by a random duration of up to Jitter in both directions, desynchronising nodes
+	Jitter time.Duration
+}
+
+// normalise replaces unset and out of range values, logging any adjustment of configured values.
+func (timing AnnouncementTiming) normalise() AnnouncementTiming {
+	if timing.MinInterval <= 0 {
+		timing.MinInterval = DefaultMinAnnouncementInterval
+	}
+	if timing.Interval < timing.MinInterval {
+		log.WithFields(log.Fields{
+			"interval":     timing.Interval,
+			"min interval": timing.MinInterval,
+		}).Warn("Announcement interval is below its minimum, using the minimum")
+		timing.Interval = timing.MinInterval
+	}
+	if timing.Jitter < 0 {
+		timing.Jitter = -timing.Jitter
+	}
+	return timing
+}
+
+// delay until the next Announcement: the Interval shifted by the Jitter, but at least the MinInterval.
+func (timing AnnouncementTiming) delay(random *rand.Rand) time.Duration {
+	delay := timing.Interval
+	if timing.Jitter > 0 {
+		delay += time.Duration(random.Int63n(2*int64(timing.Jitter)+1)) - timing.Jitter
+	}
+	return max(delay, timing.MinInterval)
+}
+
+// announcementScheduler paces the Announcements of a peerdiscovery loop.
+//
+// peerdiscovery broadcasts on a fixed ticker, calling its PayloadFunc right before each broadcast. With the ticker's
+// period set to the MinInterval, the scheduler's payload method controls the actual delays by blocking until the next
+// Announcement is due.
+type announcementScheduler struct {
+	timing AnnouncementTiming
+	random *rand.Rand
+	msg    []byte
+
+	next time.Time
+	stop <-chan struct{}
+}
+
+func newAnnouncementScheduler(timing AnnouncementTiming, msg []byte, stop <-chan struct{}) *announcementScheduler {
+	return &announcementScheduler{
+		timing: timing,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		msg:    msg,
+		stop:   stop,
+	}
+}
+
+// payload waits until the next Announcement is due, or the scheduler is stopped, and returns its payload.
+func (scheduler *announcementScheduler) payload() []byte {
+	if wait := time.Until(scheduler.next); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-scheduler.stop:
+			timer.Stop()
+		}
+	}
+
+	scheduler.next = time.Now().Add(scheduler.timing.delay(scheduler.random))
+	return scheduler.msg
+}
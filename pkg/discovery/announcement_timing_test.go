@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestAnnouncementTimingDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		timing   AnnouncementTiming
+		min, max time.Duration
+	}{
+		{"jitter", AnnouncementTiming{Interval: 10 * time.Second, Jitter: 2 * time.Second},
+			8 * time.Second, 12 * time.Second},
+		{"jitter below minimum", AnnouncementTiming{Interval: 2 * time.Second, MinInterval: 1500 * time.Millisecond, Jitter: time.Second},
+			1500 * time.Millisecond, 3 * time.Second},
+		{"interval below minimum", AnnouncementTiming{Interval: 100 * time.Millisecond, Jitter: 500 * time.Millisecond},
+			DefaultMinAnnouncementInterval, DefaultMinAnnouncementInterval + 500*time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timing := test.timing.normalise()
+			random := rand.New(rand.NewSource(23))
+
+			delays := make(map[time.Duration]bool)
+			for i := 0; i < 1000; i++ {
+				delay := timing.delay(random)
+				if delay < test.min || delay > test.max {
+					t.Fatalf("Delay %v is outside of [%v, %v]", delay, test.min, test.max)
+				}
+				delays[delay] = true
+			}
+			if len(delays) < 100 {
+				t.Fatalf("Only %d distinct delays out of 1000", len(delays))
+			}
+		})
+	}
+}
+
+func TestAnnouncementTimingWithoutJitter(t *testing.T) {
+	timing := AnnouncementTiming{Interval: 2 * time.Second}.normalise()
+	if timing.MinInterval != DefaultMinAnnouncementInterval {
+		t.Fatalf("Expected default minimum interval, got %v", timing.MinInterval)
+	}
+
+	random := rand.New(rand.NewSource(23))
+	for i := 0; i < 10; i++ {
+		if delay := timing.delay(random); delay != timing.Interval {
+			t.Fatalf("Delay without jitter is %v instead of %v", delay, timing.Interval)
+		}
+	}
+}
+
+func TestAnnouncementSchedulerStop(t *testing.T) {
+	stop := make(chan struct{})
+	scheduler := newAnnouncementScheduler(AnnouncementTiming{Interval: time.Hour}.normalise(), []byte("hello"), stop)
+
+	// The first Announcement is due immediately, the second one only in an hour unless the scheduler is stopped
+	if msg := scheduler.payload(); string(msg) != "hello" {
+		t.Fatalf("Unexpected payload %q", msg)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.payload()
+		close(done)
+	}()
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stopped scheduler is still waiting")
+	}
+}
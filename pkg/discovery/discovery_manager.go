@@ -7,6 +7,10 @@ package discovery
 
 import (
 	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/schollz/peerdiscovery"
@@ -14,36 +18,121 @@ import (
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/cla"
-	"github.com/dtn7/dtn7-go/pkg/cla/mtcp"
-	"github.com/dtn7/dtn7-go/pkg/cla/quicl"
 	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
+// redialInterval is the time span in which repeated announcements of the same peer do not result in another dial.
+const redialInterval = 30 * time.Second
+
+// DefaultHandlerWorkers is the number of Announcements handled concurrently if no positive number is configured.
+// Received Announcements are handed to these workers. If all are busy, receiving further Announcements is delayed,
+// bounding the goroutines spawned on a busy multicast group.
+const DefaultHandlerWorkers = 4
+
+// discoveryJob is a received Announcement to be handled by a handler worker.
+type discoveryJob struct {
+	announcement Announcement
+	addr         string
+}
+
 // Manager publishes and receives Announcements.
 type Manager struct {
 	NodeId          bpv7.EndpointID
 	receiveCallback func(*bpv7.Bundle)
 
+	// mode restricts this Manager to announcing or dialing; both by default.
+	mode Mode
+
+	// dialTypes restricts the CLA types of discovered peers to be dialed; all types are allowed if empty.
+	dialTypes map[cla.CLAType]bool
+	// dialPreference ranks the CLA types of a peer announcing multiple ones, most preferred first.
+	dialPreference      []cla.CLAType
+	dialPreferenceMutex sync.RWMutex
+
+	// dialAttempts maps recently dialed peers to the time of their last dial attempt.
+	dialAttempts      map[string]time.Time
+	dialAttemptsMutex sync.Mutex
+	redialInterval    time.Duration
+
+	// peers maps announced peers to their state; they are reaped after being silent for peerTimeout.
+	peers       map[string]*discoveredPeer
+	peersMutex  sync.Mutex
+	peerTimeout time.Duration
+	stopReaper  chan struct{}
+
+	// discoveries hands received Announcements to the handler workers, which are stopped by closing stopWorkers.
+	discoveries chan discoveryJob
+	stopWorkers chan struct{}
+
 	stopChan4 chan struct{}
 	stopChan6 chan struct{}
+	// stopAnnouncing releases announcementSchedulers waiting for their next Announcement on Close
+	stopAnnouncing chan struct{}
+}
+
+// discoveredPeer is a peer known from its Announcements.
+type discoveredPeer struct {
+	// conv is the Convergence dialed for this peer; nil if no dial has succeeded.
+	conv     cla.Convergence
+	lastSeen time.Time
 }
 
 var managerSingleton *Manager
 
+// newManager creates a Manager without starting any multicast discovery.
+//
+// A positive peerTimeout starts reaping peers whose Announcements were not received for this duration.
+// The Announcements are handled by this number of workers, DefaultHandlerWorkers if not positive.
+func newManager(nodeId bpv7.EndpointID, dialTypes []cla.CLAType, peerTimeout time.Duration, workers int,
+	receiveCallback func(*bpv7.Bundle)) *Manager {
+	var manager = &Manager{
+		NodeId:          nodeId,
+		receiveCallback: receiveCallback,
+		dialTypes:       make(map[cla.CLAType]bool, len(dialTypes)),
+		dialAttempts:    make(map[string]time.Time),
+		redialInterval:  redialInterval,
+		peers:           make(map[string]*discoveredPeer),
+		peerTimeout:     peerTimeout,
+		stopAnnouncing:  make(chan struct{}),
+		discoveries:     make(chan discoveryJob),
+		stopWorkers:     make(chan struct{}),
+	}
+	for _, claType := range dialTypes {
+		manager.dialTypes[claType] = true
+	}
+
+	if workers < 1 {
+		workers = DefaultHandlerWorkers
+	}
+	for i := 0; i < workers; i++ {
+		go manager.handlerWorker()
+	}
+
+	if peerTimeout > 0 {
+		manager.stopReaper = make(chan struct{})
+		go manager.reaper()
+	}
+
+	return manager
+}
+
+// InitialiseManager initialises the discovery Manager singleton, see GetManagerSingleton.
+//
+// The mode selects whether this node's announcements are sent, discovered peers are dialed, or both. Received
+// announcements are handled by the number of workers, DefaultHandlerWorkers if not positive.
 func InitialiseManager(
 	nodeId bpv7.EndpointID,
-	announcements []Announcement, announcementInterval time.Duration,
-	ipv4, ipv6 bool,
+	announcements []Announcement, timing AnnouncementTiming,
+	ipv4, ipv6 bool, mode Mode,
+	dialTypes []cla.CLAType, peerTimeout time.Duration, workers int,
 	receiveCallback func(*bpv7.Bundle)) error {
 
 	if managerSingleton != nil {
 		return util.NewAlreadyInitialisedError("Discovery Manager")
 	}
 
-	var manager = &Manager{
-		NodeId:          nodeId,
-		receiveCallback: receiveCallback,
-	}
+	var manager = newManager(nodeId, dialTypes, peerTimeout, workers, receiveCallback)
+	manager.mode = mode
 	if ipv4 {
 		manager.stopChan4 = make(chan struct{})
 	}
@@ -51,11 +140,18 @@ func InitialiseManager(
 		manager.stopChan6 = make(chan struct{})
 	}
 
+	timing = timing.normalise()
 	log.WithFields(log.Fields{
-		"interval":      announcementInterval,
+		"interval":      timing.Interval,
+		"min interval":  timing.MinInterval,
+		"jitter":        timing.Jitter,
 		"IPv4":          ipv4,
 		"IPv6":          ipv6,
+		"mode":          mode,
 		"announcements": announcements,
+		"dial types":    dialTypes,
+		"peer timeout":  peerTimeout,
+		"workers":       workers,
 	}).Info("Starting discovery manager")
 
 	msg, err := MarshalAnnouncements(announcements)
@@ -68,10 +164,9 @@ func InitialiseManager(
 		multicastAddress string
 		stopChan         chan struct{}
 		ipVersion        peerdiscovery.IPVersion
-		notify           func(discovered peerdiscovery.Discovered)
 	}{
-		{ipv4, address4, manager.stopChan4, peerdiscovery.IPv4, manager.notify},
-		{ipv6, address6, manager.stopChan6, peerdiscovery.IPv6, manager.notify6},
+		{ipv4, address4, manager.stopChan4, peerdiscovery.IPv4},
+		{ipv6, address6, manager.stopChan6, peerdiscovery.IPv6},
 	}
 
 	for _, set := range sets {
@@ -83,13 +178,14 @@ func InitialiseManager(
 			Limit:            -1,
 			Port:             fmt.Sprintf("%d", port),
 			MulticastAddress: set.multicastAddress,
-			Payload:          msg,
-			Delay:            announcementInterval,
+			PayloadFunc:      newAnnouncementScheduler(timing, msg, manager.stopAnnouncing).payload,
+			Delay:            timing.MinInterval,
 			TimeLimit:        -1,
 			StopChan:         set.stopChan,
+			DisableBroadcast: !mode.announces(),
 			AllowSelf:        true,
 			IPVersion:        set.ipVersion,
-			Notify:           set.notify,
+			Notify:           manager.notify,
 		}
 
 		discoverErrChan := make(chan error)
@@ -113,19 +209,23 @@ func InitialiseManager(
 	return nil
 }
 
-// GetManagerSingleton returns the manager singleton-instance.
-// Attempting to call this function before manager initialisation will cause the program to panic.
-func GetManagerSingleton() *Manager {
+// LookupManagerSingleton returns the manager singleton-instance or a util.NotInitialised-error.
+func LookupManagerSingleton() (*Manager, error) {
 	if managerSingleton == nil {
-		log.Fatalf("Attempting to access an uninitialised discovery manager. This must never happen!")
+		return nil, util.NewNotInitialisedError("discovery Manager")
 	}
-	return managerSingleton
+	return managerSingleton, nil
 }
 
-func (manager *Manager) notify6(discovered peerdiscovery.Discovered) {
-	discovered.Address = fmt.Sprintf("[%s]", discovered.Address)
-
-	manager.notify(discovered)
+// GetManagerSingleton returns the manager singleton-instance.
+// Attempting to call this function before manager initialisation will cause the program to panic; see
+// LookupManagerSingleton.
+func GetManagerSingleton() *Manager {
+	manager, err := LookupManagerSingleton()
+	if err != nil {
+		log.Fatalf("Attempting to access an uninitialised discovery manager. This must never happen!")
+	}
+	return manager
 }
 
 func (manager *Manager) notify(discovered peerdiscovery.Discovered) {
@@ -139,9 +239,74 @@ func (manager *Manager) notify(discovered peerdiscovery.Discovered) {
 		return
 	}
 
+	for _, announcement := range manager.selectAnnouncements(announcements, discovered.Address) {
+		select {
+		case manager.discoveries <- discoveryJob{announcement: announcement, addr: discovered.Address}:
+		case <-manager.stopWorkers:
+			return
+		}
+	}
+}
+
+// handlerWorker handles received Announcements until the Manager is closed.
+func (manager *Manager) handlerWorker() {
+	for {
+		select {
+		case <-manager.stopWorkers:
+			return
+
+		case job := <-manager.discoveries:
+			manager.handleDiscovery(job.announcement, job.addr)
+		}
+	}
+}
+
+// SetDialPreference ranks CLA types for peers announcing multiple ones, most preferred first. Such peers are only
+// dialed using their most preferred type. Types without a rank are least preferred, in the peer's announced order.
+func (manager *Manager) SetDialPreference(claTypes []cla.CLAType) {
+	manager.dialPreferenceMutex.Lock()
+	defer manager.dialPreferenceMutex.Unlock()
+
+	manager.dialPreference = claTypes
+}
+
+// rank of a CLA type in the dial preference, lower being preferred; unranked types share the lowest preference.
+func (manager *Manager) rank(claType cla.CLAType) int {
+	manager.dialPreferenceMutex.RLock()
+	defer manager.dialPreferenceMutex.RUnlock()
+
+	for i, preferred := range manager.dialPreference {
+		if preferred == claType {
+			return i
+		}
+	}
+	return len(manager.dialPreference)
+}
+
+// selectAnnouncements picks the Announcement of the most preferred, dialable CLA type for each announced node. Of
+// multiple Announcements of this type, e.g., for each of the node's interfaces, one reachable at the origin is
+// preferred.
+func (manager *Manager) selectAnnouncements(announcements []Announcement, origin string) []Announcement {
+	selected := make([]Announcement, 0, len(announcements))
+
 	for _, announcement := range announcements {
-		go manager.handleDiscovery(announcement, discovered.Address)
+		if !manager.mayDial(announcement.Type) {
+			continue
+		}
+
+		i := slices.IndexFunc(selected, func(other Announcement) bool {
+			return other.Endpoint.SameNode(announcement.Endpoint)
+		})
+		if i < 0 {
+			selected = append(selected, announcement)
+		} else if rank, selectedRank := manager.rank(announcement.Type), manager.rank(selected[i].Type); rank < selectedRank {
+			selected[i] = announcement
+		} else if rank == selectedRank && announcement.reachableFrom(origin) && !selected[i].reachableFrom(origin) {
+			selected[i] = announcement
+		}
 	}
+
+	return selected
 }
 
 func (manager *Manager) handleDiscovery(announcement Announcement, addr string) {
@@ -154,26 +319,183 @@ func (manager *Manager) handleDiscovery(announcement Announcement, addr string)
 		"message": announcement,
 	}).Debug("Peer discovery received a message")
 
-	var conv cla.Convergence
-	switch announcement.Type {
-	case cla.MTCP:
-		conv = mtcp.NewMTCPClient(fmt.Sprintf("%s:%d", addr, announcement.Port), announcement.Endpoint)
-	case cla.QUICL:
-		conv = quicl.NewDialerEndpoint(fmt.Sprintf("%s:%d", addr, announcement.Port), manager.NodeId, manager.receiveCallback)
-	default:
-		log.WithField("cType", announcement.Type).Error("Invalid cType")
+	if !manager.mode.dials() {
+		log.WithFields(log.Fields{
+			"peer":    addr,
+			"message": announcement,
+		}).Debug("Peer discovery ignores announcement in announce-only mode")
+		return
+	}
+
+	if !manager.mayDial(announcement.Type) {
+		log.WithFields(log.Fields{
+			"peer":    addr,
+			"message": announcement,
+		}).Debug("Peer discovery ignores announcement of a disallowed CLA type")
+		return
+	}
+
+	host := addr
+	if announcement.Address != "" {
+		host = announcement.Address
+	}
+	address := peerAddress(host, announcement.Port)
+	key := fmt.Sprintf("%v://%s", announcement.Type, address)
+	manager.peerSeen(key)
+
+	if !manager.attemptDial(key) {
+		log.WithFields(log.Fields{
+			"peer":    addr,
+			"message": announcement,
+		}).Debug("Peer discovery ignores announcement of a recently dialed peer")
+		return
+	}
+
+	conv, err := cla.NewPeer(announcement.Type, address,
+		manager.NodeId, announcement.Endpoint, manager.receiveCallback)
+	if err != nil {
+		log.WithError(err).WithField("cType", announcement.Type).Error("Invalid cType")
 		return
 	}
+	manager.peerDialed(key, conv)
 	cla.GetManagerSingleton().Register(conv)
 }
 
+// mayDial checks if discovered peers of this CLA type might be dialed.
+func (manager *Manager) mayDial(claType cla.CLAType) bool {
+	return len(manager.dialTypes) == 0 || manager.dialTypes[claType]
+}
+
+// attemptDial checks if a peer was not dialed within the redial interval and marks it as dialed now.
+func (manager *Manager) attemptDial(key string) bool {
+	manager.dialAttemptsMutex.Lock()
+	defer manager.dialAttemptsMutex.Unlock()
+
+	now := time.Now()
+	for attemptKey, lastAttempt := range manager.dialAttempts {
+		if now.Sub(lastAttempt) >= manager.redialInterval {
+			delete(manager.dialAttempts, attemptKey)
+		}
+	}
+
+	if _, ok := manager.dialAttempts[key]; ok {
+		return false
+	}
+	manager.dialAttempts[key] = now
+	return true
+}
+
+// peerSeen updates a peer's last seen time, adding the peer if unknown.
+func (manager *Manager) peerSeen(key string) {
+	manager.peersMutex.Lock()
+	defer manager.peersMutex.Unlock()
+
+	if peer, ok := manager.peers[key]; ok {
+		peer.lastSeen = time.Now()
+	} else {
+		manager.peers[key] = &discoveredPeer{lastSeen: time.Now()}
+	}
+}
+
+// peerDialed stores the Convergence dialed for a peer.
+func (manager *Manager) peerDialed(key string, conv cla.Convergence) {
+	manager.peersMutex.Lock()
+	defer manager.peersMutex.Unlock()
+
+	if peer, ok := manager.peers[key]; ok {
+		peer.conv = conv
+	}
+}
+
+// reaper periodically reaps silent peers until the Manager is closed.
+func (manager *Manager) reaper() {
+	ticker := time.NewTicker(manager.peerTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-manager.stopReaper:
+			return
+
+		case <-ticker.C:
+			manager.reapPeers()
+		}
+	}
+}
+
+// reapPeers removes all peers being silent for longer than the peer timeout and closes the Convergence dialed for them.
+//
+// Other CLAs to the same address, e.g., of static peers or peers added by the REST API, are kept. The CLA manager
+// ignores the registration of a dialed Convergence while another CLA of its address is registered.
+func (manager *Manager) reapPeers() {
+	var disappeared = make(map[string]*discoveredPeer)
+
+	manager.peersMutex.Lock()
+	for key, peer := range manager.peers {
+		if time.Since(peer.lastSeen) > manager.peerTimeout {
+			disappeared[key] = peer
+			delete(manager.peers, key)
+		}
+	}
+	manager.peersMutex.Unlock()
+
+	for key, peer := range disappeared {
+		log.WithFields(log.Fields{
+			"peer":      key,
+			"last seen": peer.lastSeen,
+		}).Info("Peer discovery lost a peer")
+
+		// Allow redialing as soon as the peer reappears
+		manager.dialAttemptsMutex.Lock()
+		delete(manager.dialAttempts, key)
+		manager.dialAttemptsMutex.Unlock()
+
+		if peer.conv == nil {
+			continue
+		}
+		for _, sender := range cla.GetManagerSingleton().GetSenders() {
+			if !isDialed(sender, peer.conv) {
+				continue
+			}
+
+			cla.GetManagerSingleton().NotifyDisconnect(sender)
+			if err := sender.Close(); err != nil {
+				log.WithError(err).WithField("cla", sender.Address()).Warn("Closing CLA of a lost peer errored")
+			}
+		}
+	}
+}
+
+// isDialed checks if a registered sender is the Convergence dialed by the discovery, which might be wrapped in a
+// cla.QueuedSender.
+func isDialed(sender cla.ConvergenceSender, conv cla.Convergence) bool {
+	if queued, ok := sender.(*cla.QueuedSender); ok {
+		return queued.ConvergenceSender == conv
+	}
+	return sender == conv
+}
+
+// peerAddress creates a dialable address for a discovered peer's host and the announced port.
+//
+// IPv6 hosts are enclosed in brackets. A zone, e.g., "fe80::1%eth0", is kept because link-local addresses cannot
+// be dialed without it.
+func peerAddress(host string, port uint) string {
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+}
+
 // Close this Manager.
 func (manager *Manager) Close() {
+	close(manager.stopAnnouncing)
 	for _, c := range []chan struct{}{manager.stopChan4, manager.stopChan6} {
 		if c != nil {
 			c <- struct{}{}
 		}
 	}
+
+	if manager.stopReaper != nil {
+		close(manager.stopReaper)
+	}
+	close(manager.stopWorkers)
 }
 
 func (manager *Manager) String() string {
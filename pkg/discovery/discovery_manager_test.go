@@ -0,0 +1,490 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/schollz/peerdiscovery"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+func TestPeerAddress(t *testing.T) {
+	tests := []struct {
+		host    string
+		port    uint
+		address string
+	}{
+		{"192.168.1.23", 35037, "192.168.1.23:35037"},
+		{"2001:db8::23", 35037, "[2001:db8::23]:35037"},
+		{"fe80::1", 4556, "[fe80::1]:4556"},
+		{"fe80::1%eth0", 4556, "[fe80::1%eth0]:4556"},
+		{"fe80::aede:48ff:fe00:1122%wlp3s0", 35037, "[fe80::aede:48ff:fe00:1122%wlp3s0]:35037"},
+	}
+
+	for _, test := range tests {
+		if address := peerAddress(test.host, test.port); address != test.address {
+			t.Fatalf("Address for %s and %d is %s, expected %s", test.host, test.port, address, test.address)
+		}
+	}
+}
+
+// errNotSupported is returned by test providers refusing to create peers.
+var errNotSupported = errors.New("not supported")
+
+// testProvider is a cla.ConvergenceProvider for any CLAType, creating dummy CLAs.
+type testProvider struct {
+	claType cla.CLAType
+	// dialed receives the address of each peer to be created, if set
+	dialed chan<- string
+	// peerErr is returned by NewPeer instead of a dummy CLA, if set
+	peerErr error
+}
+
+func (provider testProvider) Type() cla.CLAType {
+	return provider.claType
+}
+
+func (provider testProvider) NewListener(config cla.ListenerConfig, _ func(*bpv7.Bundle)) (cla.ConvergenceListener, error) {
+	return dummy_cla.NewDummyListener(config.Address), nil
+}
+
+func (provider testProvider) NewPeer(address string, nodeID bpv7.EndpointID, peerID bpv7.EndpointID, _ func(*bpv7.Bundle)) (cla.Convergence, error) {
+	if provider.dialed != nil {
+		provider.dialed <- address
+	}
+	if provider.peerErr != nil {
+		return nil, provider.peerErr
+	}
+
+	noop := func(bundle bpv7.Bundle) (interface{}, error) {
+		return nil, nil
+	}
+	peer, _ := dummy_cla.NewDummyCLAPair(nodeID, peerID, noop)
+	return peer, nil
+}
+
+func TestHandleDiscoveryDialTypes(t *testing.T) {
+	dialed := make(chan string, 2)
+	for _, claType := range []cla.CLAType{cla.MTCP, cla.QUICL} {
+		provider := testProvider{claType: claType, dialed: dialed, peerErr: errNotSupported}
+		if err := cla.RegisterProvider(provider); err != nil {
+			t.Fatal(err)
+		}
+		defer cla.UnregisterProvider(claType)
+	}
+
+	manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), []cla.CLAType{cla.QUICL}, 0, 0, nil)
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+
+	manager.handleDiscovery(Announcement{Type: cla.MTCP, Endpoint: peerID, Port: 35038}, "192.168.1.23")
+	manager.handleDiscovery(Announcement{Type: cla.QUICL, Endpoint: peerID, Port: 35037}, "192.168.1.23")
+
+	if l := len(dialed); l != 1 {
+		t.Fatalf("Expected one dialed peer, got %d", l)
+	}
+	if address := <-dialed; address != "192.168.1.23:35037" {
+		t.Fatalf("Dialed %s instead of the QUICL peer", address)
+	}
+
+	if !newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, 0, 0, nil).mayDial(cla.MTCP) {
+		t.Fatal("Manager without dial types does not allow MTCP")
+	}
+}
+
+func TestNotifyDialPreference(t *testing.T) {
+	dialed := make(chan string, 4)
+	for _, claType := range []cla.CLAType{cla.MTCP, cla.QUICL} {
+		provider := testProvider{claType: claType, dialed: dialed, peerErr: errNotSupported}
+		if err := cla.RegisterProvider(provider); err != nil {
+			t.Fatal(err)
+		}
+		defer cla.UnregisterProvider(claType)
+	}
+
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+	payload, err := MarshalAnnouncements([]Announcement{
+		{Type: cla.MTCP, Endpoint: peerID, Port: 35038},
+		{Type: cla.QUICL, Endpoint: peerID, Port: 35037},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		preference []cla.CLAType
+		address    string
+	}{
+		{"preferred QUICL", []cla.CLAType{cla.QUICL, cla.MTCP}, "192.168.1.23:35037"},
+		{"preferred MTCP", []cla.CLAType{cla.MTCP}, "192.168.1.23:35038"},
+		{"announced order", nil, "192.168.1.23:35038"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, 0, 0, nil)
+			manager.SetDialPreference(test.preference)
+			manager.notify(peerdiscovery.Discovered{Address: "192.168.1.23", Payload: payload})
+
+			select {
+			case address := <-dialed:
+				if address != test.address {
+					t.Fatalf("Dialed %s instead of %s", address, test.address)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Peer was not dialed")
+			}
+
+			select {
+			case address := <-dialed:
+				t.Fatalf("Peer was dialed again via %s", address)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestNotifyAnnouncedAddresses(t *testing.T) {
+	dialed := make(chan string, 4)
+	provider := testProvider{claType: cla.MTCP, dialed: dialed, peerErr: errNotSupported}
+	if err := cla.RegisterProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	defer cla.UnregisterProvider(cla.MTCP)
+
+	// The peer's listeners are bound to two interfaces' addresses
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+	payload, err := MarshalAnnouncements([]Announcement{
+		{Type: cla.MTCP, Endpoint: peerID, Port: 35038, Address: "10.0.0.1"},
+		{Type: cla.MTCP, Endpoint: peerID, Port: 35038, Address: "192.168.1.23"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		address string
+	}{
+		{"reachable at origin", "192.168.1.23", "192.168.1.23:35038"},
+		{"unknown origin", "172.16.0.5", "10.0.0.1:35038"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, 0, 0, nil)
+			manager.notify(peerdiscovery.Discovered{Address: test.origin, Payload: payload})
+
+			select {
+			case address := <-dialed:
+				if address != test.address {
+					t.Fatalf("Dialed %s instead of %s", address, test.address)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Peer was not dialed")
+			}
+
+			select {
+			case address := <-dialed:
+				t.Fatalf("Peer was dialed again via %s", address)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestHandleDiscoveryRedial(t *testing.T) {
+	dialed := make(chan string, 100)
+	provider := testProvider{claType: cla.QUICL, dialed: dialed, peerErr: errNotSupported}
+	if err := cla.RegisterProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	defer cla.UnregisterProvider(cla.QUICL)
+
+	manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, 0, 0, nil)
+	manager.redialInterval = 250 * time.Millisecond
+	announcement := Announcement{Type: cla.QUICL, Endpoint: bpv7.MustNewEndpointID("dtn://peer/"), Port: 35037}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			manager.handleDiscovery(announcement, "192.168.1.23")
+		}()
+	}
+	wg.Wait()
+
+	if l := len(dialed); l != 1 {
+		t.Fatalf("Expected one dial attempt within the interval, got %d", l)
+	}
+
+	// Another peer must not be suppressed
+	manager.handleDiscovery(announcement, "192.168.1.42")
+	if l := len(dialed); l != 2 {
+		t.Fatalf("Expected a dial attempt for another peer, got %d in total", l)
+	}
+
+	time.Sleep(manager.redialInterval)
+	manager.handleDiscovery(announcement, "192.168.1.23")
+	if l := len(dialed); l != 3 {
+		t.Fatalf("Expected another dial attempt after the interval, got %d in total", l)
+	}
+}
+
+func TestReapSilentPeer(t *testing.T) {
+	disconnected := make(chan bpv7.EndpointID, 1)
+	err := cla.InitialiseCLAManager(
+		func(*bpv7.Bundle) {},
+		func(bpv7.EndpointID) {},
+		func(eid bpv7.EndpointID) { disconnected <- eid })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	if err := cla.RegisterProvider(testProvider{claType: cla.Dummy}); err != nil {
+		t.Fatal(err)
+	}
+	defer cla.UnregisterProvider(cla.Dummy)
+
+	peerTimeout := 250 * time.Millisecond
+	manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, peerTimeout, 0, nil)
+	defer manager.Close()
+
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+	announcement := Announcement{Type: cla.Dummy, Endpoint: peerID, Port: 35037}
+
+	// Keep announcing the peer for longer than the timeout
+	for start := time.Now(); time.Since(start) < 2*peerTimeout; time.Sleep(peerTimeout / 5) {
+		manager.handleDiscovery(announcement, "192.168.1.23")
+	}
+
+	if l := len(cla.GetManagerSingleton().GetSenders()); l != 1 {
+		t.Fatalf("Expected the announced peer to be registered, got %d senders", l)
+	}
+	select {
+	case eid := <-disconnected:
+		t.Fatalf("Announced peer %v was reaped", eid)
+	default:
+	}
+
+	// Announcements stop
+	select {
+	case eid := <-disconnected:
+		if eid != peerID {
+			t.Fatalf("Disconnected peer %v instead of %v", eid, peerID)
+		}
+	case <-time.After(4 * peerTimeout):
+		t.Fatal("Silent peer was not reaped")
+	}
+
+	if l := len(cla.GetManagerSingleton().GetSenders()); l != 0 {
+		t.Fatalf("Expected no senders after reaping, got %d", l)
+	}
+}
+
+func TestReapKeepsOtherCLAs(t *testing.T) {
+	disconnected := make(chan bpv7.EndpointID, 1)
+	err := cla.InitialiseCLAManager(
+		func(*bpv7.Bundle) {},
+		func(bpv7.EndpointID) {},
+		func(eid bpv7.EndpointID) { disconnected <- eid })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	if err := cla.RegisterProvider(testProvider{claType: cla.Dummy}); err != nil {
+		t.Fatal(err)
+	}
+	defer cla.UnregisterProvider(cla.Dummy)
+
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+
+	// A static peer's CLA has the same address as the CLA the discovery dials
+	static, _ := dummy_cla.NewDummyCLAPair(nodeID, peerID, func(bpv7.Bundle) (interface{}, error) { return nil, nil })
+	if err := cla.GetManagerSingleton().RegisterSync(static); err != nil {
+		t.Fatal(err)
+	}
+
+	peerTimeout := 100 * time.Millisecond
+	manager := newManager(nodeID, nil, peerTimeout, 0, nil)
+	defer manager.Close()
+
+	manager.handleDiscovery(Announcement{Type: cla.Dummy, Endpoint: peerID, Port: 35037}, "192.168.1.23")
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		manager.peersMutex.Lock()
+		reaped := len(manager.peers) == 0
+		manager.peersMutex.Unlock()
+
+		if reaped {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("Silent peer was not reaped")
+		}
+	}
+
+	select {
+	case eid := <-disconnected:
+		t.Fatalf("Reaping disconnected the static peer %v", eid)
+	default:
+	}
+	if senders := cla.GetManagerSingleton().GetSenders(); len(senders) != 1 || senders[0] != static {
+		t.Fatalf("Expected only the static peer's CLA, got %v", senders)
+	}
+	if !static.Active() {
+		t.Fatal("Static peer's CLA was closed")
+	}
+}
+
+func TestInitialiseManagerTwice(t *testing.T) {
+	defer func(manager *Manager) { managerSingleton = manager }(managerSingleton)
+	managerSingleton = nil
+
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	if err := InitialiseManager(nodeID, nil, AnnouncementTiming{Interval: time.Second}, false, false, ModeBoth, nil, 0, 0, func(*bpv7.Bundle) {}); err != nil {
+		t.Fatal(err)
+	}
+	manager := GetManagerSingleton()
+	defer manager.Close()
+
+	var alreadyInitialised *util.AlreadyInitialised
+	err := InitialiseManager(nodeID, nil, AnnouncementTiming{Interval: time.Second}, false, false, ModeBoth, nil, 0, 0, func(*bpv7.Bundle) {})
+	if !errors.As(err, &alreadyInitialised) {
+		t.Fatalf("Expected AlreadyInitialised error, got %v", err)
+	}
+	if GetManagerSingleton() != manager {
+		t.Fatal("Manager was replaced by the second initialisation")
+	}
+}
+
+// concurrencyProvider is a cla.ConvergenceProvider recording the dialed peers and the maximum of concurrent dials.
+type concurrencyProvider struct {
+	dialed chan string
+
+	mutex   sync.Mutex
+	current int
+	maximum int
+}
+
+func (provider *concurrencyProvider) Type() cla.CLAType {
+	return cla.QUICL
+}
+
+func (provider *concurrencyProvider) NewListener(_ cla.ListenerConfig, _ func(*bpv7.Bundle)) (cla.ConvergenceListener, error) {
+	return nil, errNotSupported
+}
+
+func (provider *concurrencyProvider) NewPeer(address string, _, _ bpv7.EndpointID, _ func(*bpv7.Bundle)) (cla.Convergence, error) {
+	provider.mutex.Lock()
+	provider.current++
+	provider.maximum = max(provider.maximum, provider.current)
+	provider.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	provider.mutex.Lock()
+	provider.current--
+	provider.mutex.Unlock()
+
+	provider.dialed <- address
+	return nil, errNotSupported
+}
+
+func TestNotifyBoundedWorkers(t *testing.T) {
+	const peers = 50
+	const workers = 3
+
+	provider := &concurrencyProvider{dialed: make(chan string, 2*peers)}
+	if err := cla.RegisterProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	defer cla.UnregisterProvider(cla.QUICL)
+
+	manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, 0, workers, nil)
+	defer manager.Close()
+
+	// Each peer is announced twice, from multiple goroutines as by the IPv4 and IPv6 discovery
+	var wg sync.WaitGroup
+	for i := 0; i < 2*peers; i++ {
+		peer := i % peers
+		payload, err := MarshalAnnouncements([]Announcement{
+			{Type: cla.QUICL, Endpoint: bpv7.MustNewEndpointID(fmt.Sprintf("dtn://peer-%d/", peer)), Port: 35037},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			manager.notify(peerdiscovery.Discovered{Address: fmt.Sprintf("192.168.1.%d", peer), Payload: payload})
+		}()
+	}
+	wg.Wait()
+
+	addresses := make(map[string]bool)
+	for len(addresses) < peers {
+		select {
+		case address := <-provider.dialed:
+			if addresses[address] {
+				t.Fatalf("Peer %s was dialed twice", address)
+			}
+			addresses[address] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Only %d of %d peers were dialed", len(addresses), peers)
+		}
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.maximum > workers {
+		t.Fatalf("%d announcements were handled concurrently, exceeding %d workers", provider.maximum, workers)
+	}
+}
+
+func TestHandleDiscoveryAnnounceOnly(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	if err := cla.RegisterProvider(testProvider{claType: cla.Dummy}); err != nil {
+		t.Fatal(err)
+	}
+	defer cla.UnregisterProvider(cla.Dummy)
+
+	manager := newManager(bpv7.MustNewEndpointID("dtn://node/"), nil, 0, 0, nil)
+	defer manager.Close()
+	manager.mode = ModeAnnounce
+
+	announcement := Announcement{Type: cla.Dummy, Endpoint: bpv7.MustNewEndpointID("dtn://peer/"), Port: 35037}
+	for i := 0; i < 10; i++ {
+		manager.handleDiscovery(announcement, "192.168.1.23")
+	}
+	// Registering a dialed peer would happen in the background
+	time.Sleep(100 * time.Millisecond)
+	if l := len(cla.GetManagerSingleton().GetSenders()); l != 0 {
+		t.Fatalf("Announce-only manager registered %d senders", l)
+	}
+
+	// The same announcement is dialed if dialing is allowed
+	manager.mode = ModeListen
+	manager.handleDiscovery(announcement, "192.168.1.23")
+	for deadline := time.Now().Add(time.Second); len(cla.GetManagerSingleton().GetSenders()) != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Announced peer was not registered after allowing to dial")
+		}
+	}
+}
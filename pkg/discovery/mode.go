@@ -0,0 +1,56 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode selects whether a Manager announces this node, dials discovered peers, or both.
+type Mode int
+
+const (
+	// ModeBoth announces this node and dials discovered peers.
+	ModeBoth Mode = iota
+	// ModeAnnounce only announces this node for others to connect to, e.g., behind a firewall allowing only inbound
+	// connections. Discovered peers are never dialed.
+	ModeAnnounce
+	// ModeListen only dials discovered peers without announcing this node.
+	ModeListen
+)
+
+// ModeFromString parses a Mode's name, i.e., "both", "announce", or "listen".
+func ModeFromString(name string) (Mode, error) {
+	switch name = strings.ToLower(name); name {
+	case "both":
+		return ModeBoth, nil
+	case "announce":
+		return ModeAnnounce, nil
+	case "listen":
+		return ModeListen, nil
+	default:
+		return 0, fmt.Errorf("%s is not a valid discovery mode", name)
+	}
+}
+
+// announces checks if this node's Announcements are sent in this Mode.
+func (mode Mode) announces() bool {
+	return mode != ModeListen
+}
+
+// dials checks if discovered peers are dialed in this Mode.
+func (mode Mode) dials() bool {
+	return mode != ModeAnnounce
+}
+
+func (mode Mode) String() string {
+	switch mode {
+	case ModeBoth:
+		return "both"
+	case ModeAnnounce:
+		return "announce"
+	case ModeListen:
+		return "listen"
+	default:
+		return "unknown"
+	}
+}
@@ -7,6 +7,7 @@ package id_keeper
 
 import (
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -29,6 +30,14 @@ func newIdTuple(bndl *bpv7.Bundle) idTuple {
 	}
 }
 
+// staleThreshold is the age after which an IdKeeper's state for some source and creation time is considered stale
+// and will be removed by Clean. Sequence numbers only need to be unique for the same creation time, so states older
+// than any creation time still in use can be forgotten safely.
+const staleThreshold = time.Hour
+
+// now returns the current time for Clean. Tests may replace it to control the clock deterministically.
+var now = time.Now
+
 var idKeeperSingleton *IdKeeper
 
 // IdKeeper keeps track of the creation timestamp's sequence number for
@@ -43,18 +52,33 @@ func InitializeIdKeeper() error {
 		return util.NewAlreadyInitialisedError("IdKeeper")
 	}
 
-	idKeeperSingleton = &IdKeeper{
+	idKeeperSingleton = newIdKeeper()
+
+	return nil
+}
+
+func newIdKeeper() *IdKeeper {
+	return &IdKeeper{
 		data: make(map[idTuple]uint64),
 	}
+}
 
-	return nil
+// LookupIdKeeperSingleton returns the IdKeeper singleton-instance or a util.NotInitialised-error.
+func LookupIdKeeperSingleton() (*IdKeeper, error) {
+	if idKeeperSingleton == nil {
+		return nil, util.NewNotInitialisedError("IdKeeper")
+	}
+	return idKeeperSingleton, nil
 }
 
+// GetIdKeeperSingleton returns the IdKeeper singleton-instance.
+// Attempting to call this function before initialisation will cause the program to panic; see LookupIdKeeperSingleton.
 func GetIdKeeperSingleton() *IdKeeper {
-	if idKeeperSingleton == nil {
+	idKeeper, err := LookupIdKeeperSingleton()
+	if err != nil {
 		log.Fatalf("Attempting to access an uninitialised IdKeeper. This must never happen!")
 	}
-	return idKeeperSingleton
+	return idKeeper
 }
 
 // Update updates the IdKeeper's state regarding this bundle and sets this
@@ -73,15 +97,18 @@ func (idk *IdKeeper) Update(bndl *bpv7.Bundle) {
 	bndl.PrimaryBlock.CreationTimestamp[1] = idk.data[tpl]
 }
 
-// Clean removes states which are older an hour and aren't the epoch time.
+// Clean removes states which are older than the staleThreshold and aren't the epoch time.
+//
+// States for the epoch time are kept, because bundles created without an accurate clock all share this creation
+// time and can only be distinguished by their sequence number.
 func (idk *IdKeeper) Clean() {
 	idk.mutex.Lock()
 	defer idk.mutex.Unlock()
 
-	var threshold = bpv7.DtnTimeNow() - 60
+	var threshold = bpv7.DtnTimeFromTime(now().Add(-staleThreshold))
 
 	for tpl := range idk.data {
-		if tpl.time < threshold {
+		if tpl.time != bpv7.DtnTimeEpoch && tpl.time < threshold {
 			delete(idk.data, tpl)
 		}
 	}
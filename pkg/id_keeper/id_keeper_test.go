@@ -6,9 +6,12 @@
 package id_keeper
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
 func TestIdKeeper(t *testing.T) {
@@ -53,3 +56,111 @@ func TestIdKeeper(t *testing.T) {
 		t.Errorf("Second bundle's sequence number is %d", seq)
 	}
 }
+
+func TestIdKeeperClean(t *testing.T) {
+	buildBundle := func(creation time.Time) bpv7.Bundle {
+		bldr := bpv7.Builder().
+			Source("dtn://src/").
+			Destination("dtn://dest/").
+			Lifetime("24h").
+			BundleAgeBlock(0).
+			PayloadBlock([]byte("hello world!"))
+		if creation.IsZero() {
+			bldr.CreationTimestampEpoch()
+		} else {
+			bldr.CreationTimestampTime(creation)
+		}
+
+		bndl, err := bldr.Build()
+		if err != nil {
+			t.Fatalf("Creating bundle failed: %v", err)
+		}
+		return bndl
+	}
+
+	tests := []struct {
+		name     string
+		creation time.Time
+		evicted  bool
+	}{
+		{"epoch", time.Time{}, false},
+		{"recent", time.Now().Add(-time.Minute), false},
+		{"stale", time.Now().Add(-2 * staleThreshold), true},
+	}
+
+	keeper := newIdKeeper()
+
+	for _, test := range tests {
+		for i := uint64(0); i < 3; i++ {
+			bndl := buildBundle(test.creation)
+			keeper.Update(&bndl)
+			if seq := bndl.PrimaryBlock.CreationTimestamp.SequenceNumber(); seq != i {
+				t.Fatalf("%s bundle %d got sequence number %d", test.name, i, seq)
+			}
+		}
+	}
+
+	keeper.Clean()
+
+	for _, test := range tests {
+		bndl := buildBundle(test.creation)
+		keeper.Update(&bndl)
+
+		var expected uint64 = 3
+		if test.evicted {
+			expected = 0
+		}
+		if seq := bndl.PrimaryBlock.CreationTimestamp.SequenceNumber(); seq != expected {
+			t.Fatalf("%s bundle got sequence number %d after Clean, expected %d", test.name, seq, expected)
+		}
+	}
+}
+
+func TestInitializeIdKeeperTwice(t *testing.T) {
+	defer func(keeper *IdKeeper) { idKeeperSingleton = keeper }(idKeeperSingleton)
+	idKeeperSingleton = nil
+
+	if err := InitializeIdKeeper(); err != nil {
+		t.Fatal(err)
+	}
+	keeper := GetIdKeeperSingleton()
+
+	var alreadyInitialised *util.AlreadyInitialised
+	if err := InitializeIdKeeper(); !errors.As(err, &alreadyInitialised) {
+		t.Fatalf("Expected AlreadyInitialised error, got %v", err)
+	}
+	if GetIdKeeperSingleton() != keeper {
+		t.Fatal("IdKeeper was replaced by the second initialisation")
+	}
+}
+
+func TestIdKeeperCleanFakeClock(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	creation := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	bndl, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dest/").
+		CreationTimestampTime(creation).
+		Lifetime("24h").
+		PayloadBlock([]byte("hello world!")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keeper := newIdKeeper()
+	keeper.Update(&bndl)
+
+	now = func() time.Time { return creation.Add(staleThreshold) }
+	keeper.Clean()
+	if len(keeper.data) != 1 {
+		t.Fatal("State was removed before becoming stale")
+	}
+
+	now = func() time.Time { return creation.Add(staleThreshold + time.Second) }
+	keeper.Clean()
+	if len(keeper.data) != 0 {
+		t.Fatal("Stale state was not removed")
+	}
+}
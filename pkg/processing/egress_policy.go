@@ -0,0 +1,102 @@
+package processing
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// EgressPredicate decides if a bundle may be sent over a CLA.
+type EgressPredicate func(bundle *bpv7.Bundle) bool
+
+// byteCounter is an io.Writer only counting the written bytes.
+type byteCounter int
+
+func (bc *byteCounter) Write(p []byte) (int, error) {
+	*bc += byteCounter(len(p))
+	return len(p), nil
+}
+
+// MaxBundleSize creates an EgressPredicate allowing only bundles of up to maxSize bytes in their serialised form.
+func MaxBundleSize(maxSize int) EgressPredicate {
+	return func(bundle *bpv7.Bundle) bool {
+		var size byteCounter
+		if err := bundle.MarshalCbor(&size); err != nil {
+			return false
+		}
+		return int(size) <= maxSize
+	}
+}
+
+// peerEgress restricts the bundles sent to a peer node, see SetPeerEgressPolicy.
+type peerEgress struct {
+	node       bpv7.EndpointID
+	predicates []EgressPredicate
+}
+
+var (
+	// egressPolicy restricts the bundles sent over CLAs of a type to those satisfying all its predicates
+	egressPolicy = make(map[cla.CLAType][]EgressPredicate)
+	// peerEgressPolicy restricts the bundles sent to peer nodes, independent of the CLA type
+	peerEgressPolicy  []peerEgress
+	egressPolicyMutex sync.RWMutex
+)
+
+// SetEgressPolicy restricts the bundles sent over CLAs of the given type to those satisfying all predicates, e.g.,
+// to keep large bundles off a slow link. Disallowed bundles are skipped for peers of this CLA type, while they are
+// still sent to other peers. Without predicates, the CLA type is unrestricted again.
+//
+// CLAs without a known type, i.e., not implementing cla.TypedConvergence, are never restricted by their type. To
+// restrict a single link instead of all links of a CLA type, see SetPeerEgressPolicy.
+func SetEgressPolicy(claType cla.CLAType, predicates ...EgressPredicate) {
+	egressPolicyMutex.Lock()
+	defer egressPolicyMutex.Unlock()
+
+	if len(predicates) == 0 {
+		delete(egressPolicy, claType)
+	} else {
+		egressPolicy[claType] = predicates
+	}
+}
+
+// SetPeerEgressPolicy restricts the bundles sent to the given peer node to those satisfying all predicates, regardless
+// of the CLA connecting to it. A bundle must also satisfy the policy of the peer's CLA type, if there is one. Without
+// predicates, the peer node is unrestricted again.
+func SetPeerEgressPolicy(node bpv7.EndpointID, predicates ...EgressPredicate) {
+	egressPolicyMutex.Lock()
+	defer egressPolicyMutex.Unlock()
+
+	peerEgressPolicy = slices.DeleteFunc(peerEgressPolicy, func(policy peerEgress) bool {
+		return policy.node.SameNode(node)
+	})
+	if len(predicates) > 0 {
+		peerEgressPolicy = append(peerEgressPolicy, peerEgress{node: node, predicates: predicates})
+	}
+}
+
+// egressAllowed checks if the bundle may be sent to the peer according to the egress policies of its CLA type and of
+// its node.
+func egressAllowed(peer cla.ConvergenceSender, bundle *bpv7.Bundle) bool {
+	egressPolicyMutex.RLock()
+	var predicates []EgressPredicate
+	if claType, ok := cla.TypeOf(peer); ok {
+		predicates = append(predicates, egressPolicy[claType]...)
+	}
+	if peerID := peer.GetPeerEndpointID(); peerID != bpv7.DtnNone() {
+		for _, policy := range peerEgressPolicy {
+			if policy.node.SameNode(peerID) {
+				predicates = append(predicates, policy.predicates...)
+			}
+		}
+	}
+	egressPolicyMutex.RUnlock()
+
+	for _, predicate := range predicates {
+		if !predicate(bundle) {
+			return false
+		}
+	}
+	return true
+}
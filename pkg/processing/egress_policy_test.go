@@ -0,0 +1,96 @@
+package processing
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+// egressTestJobs stores a small and a large bundle, returning their payloads and forwarding jobs.
+func egressTestJobs(t *testing.T) (map[string][]byte, []*forwardingJob) {
+	payloads := map[string][]byte{
+		"small": []byte("hello world"),
+		"large": bytes.Repeat([]byte("x"), 4096),
+	}
+
+	var jobs []*forwardingJob
+	for _, name := range []string{"small", "large"} {
+		bundle := testBundle(t, "dtn://src/", "dtn://dst/", payloads[name])
+		bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, &forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: bundle})
+	}
+	return payloads, jobs
+}
+
+func TestEgressPolicy(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	SetEgressPolicy(cla.MTCP, MaxBundleSize(1024))
+	defer SetEgressPolicy(cla.MTCP)
+
+	payloads, jobs := egressTestJobs(t)
+
+	restricted := newTestSender("dtn://satellite/")
+	restricted.claType = cla.MTCP
+	unrestricted := newTestSender("dtn://ground/")
+	unrestricted.claType = cla.QUICL
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go forwardBundlesToPeer(restricted, jobs, &wg)
+	go forwardBundlesToPeer(unrestricted, jobs, &wg)
+	wg.Wait()
+
+	if expected := []string{string(payloads["small"])}; !slices.Equal(restricted.sentPayloads(), expected) {
+		t.Fatalf("Restricted CLA sent %d bundles instead of only the small one", len(restricted.sentPayloads()))
+	}
+	if len(unrestricted.sentPayloads()) != 2 {
+		t.Fatalf("Unrestricted CLA sent %d bundles instead of both", len(unrestricted.sentPayloads()))
+	}
+
+	sentTo := jobs[1].descriptor.GetAlreadySent()
+	if slices.Contains(sentTo, restricted.peer) || !slices.Contains(sentTo, unrestricted.peer) {
+		t.Fatalf("Large bundle was recorded as sent to %v", sentTo)
+	}
+}
+
+func TestPeerEgressPolicy(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	SetPeerEgressPolicy(bpv7.MustNewEndpointID("dtn://satellite/"), MaxBundleSize(1024))
+	defer SetPeerEgressPolicy(bpv7.MustNewEndpointID("dtn://satellite/"))
+
+	payloads, jobs := egressTestJobs(t)
+
+	// Both links are of the same CLA type, but only the satellite link is restricted
+	restricted := newTestSender("dtn://satellite/dtn7")
+	restricted.claType = cla.MTCP
+	unrestricted := newTestSender("dtn://ground/")
+	unrestricted.claType = cla.MTCP
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go forwardBundlesToPeer(restricted, jobs, &wg)
+	go forwardBundlesToPeer(unrestricted, jobs, &wg)
+	wg.Wait()
+
+	if expected := []string{string(payloads["small"])}; !slices.Equal(restricted.sentPayloads(), expected) {
+		t.Fatalf("Restricted peer was sent %d bundles instead of only the small one", len(restricted.sentPayloads()))
+	}
+	if len(unrestricted.sentPayloads()) != 2 {
+		t.Fatalf("Unrestricted peer was sent %d bundles instead of both", len(unrestricted.sentPayloads()))
+	}
+
+	SetPeerEgressPolicy(bpv7.MustNewEndpointID("dtn://satellite/"))
+	if !egressAllowed(restricted, &jobs[1].bundle) {
+		t.Fatal("Removed peer egress policy still restricts the peer")
+	}
+}
@@ -0,0 +1,63 @@
+package processing
+
+import (
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// DropReason names why a received bundle was dropped, e.g., as a label for metrics.
+type DropReason string
+
+const (
+	// DropLifetimeExceeded bundles were received after their lifetime was exceeded.
+	DropLifetimeExceeded DropReason = "lifetime exceeded"
+	// DropCreatedInFuture bundles were created further in the future than the tolerated clock skew.
+	DropCreatedInFuture DropReason = "created in future"
+	// DropIngressDenied bundles were rejected by the IngressPolicy.
+	DropIngressDenied DropReason = "ingress denied"
+	// DropStoreFailed bundles could not be stored, e.g., because there was no store.
+	DropStoreFailed DropReason = "store failed"
+	// DropBlockUnsupported bundles contained an unknown block requesting the bundle's deletion in this case.
+	DropBlockUnsupported DropReason = "block unsupported"
+)
+
+// EventObserver is notified about the events of a bundle's lifecycle, allowing embedders to plug in their telemetry.
+//
+// The methods are called synchronously from the processing goroutines and must not block. Embedding NopObserver
+// allows implementing only the events of interest.
+type EventObserver interface {
+	// OnReceived is called for each bundle received by a CLA or an application agent. The peer it was received from
+	// is only known for some CLAs, otherwise it is zero-valued.
+	OnReceived(id bpv7.BundleID, from bpv7.EndpointID)
+	// OnStored is called after a received bundle was stored.
+	OnStored(id bpv7.BundleID)
+	// OnForwarded is called for each peer a bundle was successfully sent to.
+	OnForwarded(id bpv7.BundleID, peer bpv7.EndpointID)
+	// OnDelivered is called after a bundle was delivered to at least one local application agent.
+	OnDelivered(id bpv7.BundleID)
+	// OnExpired is called for each bundle deleted by the garbage collection after its lifetime was exceeded.
+	OnExpired(id bpv7.BundleID)
+	// OnDropped is called for each received bundle which was discarded instead of being stored.
+	OnDropped(id bpv7.BundleID, reason DropReason)
+}
+
+// NopObserver is an EventObserver ignoring all events, used unless another observer is set.
+type NopObserver struct{}
+
+func (NopObserver) OnReceived(bpv7.BundleID, bpv7.EndpointID)  {}
+func (NopObserver) OnStored(bpv7.BundleID)                     {}
+func (NopObserver) OnForwarded(bpv7.BundleID, bpv7.EndpointID) {}
+func (NopObserver) OnDelivered(bpv7.BundleID)                  {}
+func (NopObserver) OnExpired(bpv7.BundleID)                    {}
+func (NopObserver) OnDropped(bpv7.BundleID, DropReason)        {}
+
+// observer is notified about all bundles' lifecycle events
+var observer EventObserver = NopObserver{}
+
+// SetEventObserver sets the EventObserver to be notified about all bundles' lifecycle events. A nil observer resets
+// it to the NopObserver.
+func SetEventObserver(eventObserver EventObserver) {
+	if eventObserver == nil {
+		eventObserver = NopObserver{}
+	}
+	observer = eventObserver
+}
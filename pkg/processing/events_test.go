@@ -0,0 +1,104 @@
+package processing
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+)
+
+// recordingObserver records all events as strings of the event's name and the bundle's ID.
+type recordingObserver struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (ro *recordingObserver) record(event string, id bpv7.BundleID) {
+	ro.mutex.Lock()
+	defer ro.mutex.Unlock()
+
+	ro.events = append(ro.events, fmt.Sprintf("%s %v", event, id))
+}
+
+func (ro *recordingObserver) OnReceived(id bpv7.BundleID, _ bpv7.EndpointID) {
+	ro.record("received", id)
+}
+func (ro *recordingObserver) OnStored(id bpv7.BundleID) { ro.record("stored", id) }
+func (ro *recordingObserver) OnForwarded(id bpv7.BundleID, peer bpv7.EndpointID) {
+	ro.record("forwarded to "+peer.String(), id)
+}
+func (ro *recordingObserver) OnDelivered(id bpv7.BundleID) { ro.record("delivered", id) }
+func (ro *recordingObserver) OnExpired(id bpv7.BundleID)   { ro.record("expired", id) }
+func (ro *recordingObserver) OnDropped(id bpv7.BundleID, reason DropReason) {
+	ro.record(string(reason), id)
+}
+
+// awaitEvents waits until the expected events were recorded, in this order.
+func (ro *recordingObserver) awaitEvents(t *testing.T, expected []string) {
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		ro.mutex.Lock()
+		events := slices.Clone(ro.events)
+		ro.mutex.Unlock()
+
+		if slices.Equal(events, expected) {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Expected events %v, got %v", expected, events)
+		}
+	}
+}
+
+func TestEventObserver(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	sender := newTestSender("dtn://peer/")
+	if err := cla.GetManagerSingleton().RegisterSync(sender); err != nil {
+		t.Fatal(err)
+	}
+
+	observer := &recordingObserver{}
+	SetEventObserver(observer)
+	defer SetEventObserver(nil)
+
+	forwarded, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1s").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := IngestBundle(&forwarded); err != nil {
+		t.Fatal(err)
+	}
+	observer.awaitEvents(t, []string{
+		fmt.Sprintf("received %v", forwarded.ID()),
+		fmt.Sprintf("stored %v", forwarded.ID()),
+		fmt.Sprintf("forwarded to %v %v", sender.GetPeerEndpointID(), forwarded.ID()),
+	})
+
+	// The creation timestamp has a resolution of seconds; wait until the bundle has surely expired.
+	time.Sleep(2 * time.Second)
+	garbageCollect(func(*bpv7.Bundle) {})
+
+	// Received bundles are only dropped after their lifetime and the tolerated clock skew have passed
+	expired := forwarded
+	expired.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(bpv7.DtnTimeFromTime(time.Now().Add(-time.Hour)), 0)
+	if _, err := IngestBundle(&expired); err != nil {
+		t.Fatal(err)
+	}
+	observer.awaitEvents(t, []string{
+		fmt.Sprintf("received %v", forwarded.ID()),
+		fmt.Sprintf("stored %v", forwarded.ID()),
+		fmt.Sprintf("forwarded to %v %v", sender.GetPeerEndpointID(), forwarded.ID()),
+		fmt.Sprintf("expired %v", forwarded.ID()),
+		fmt.Sprintf("received %v", expired.ID()),
+		fmt.Sprintf("%s %v", DropLifetimeExceeded, expired.ID()),
+	})
+}
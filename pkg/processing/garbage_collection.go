@@ -0,0 +1,105 @@
+package processing
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/application_agent"
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+// deadLetters receives expired bundles instead of discarding them, disabled if nil.
+var deadLetters *application_agent.DeadLetterMailbox
+
+// SetDeadLetterMailbox enables moving expired bundles of this or some collected node to the given mailbox, if they
+// were neither delivered locally nor forwarded to any peer.
+func SetDeadLetterMailbox(mailbox *application_agent.DeadLetterMailbox) {
+	deadLetters = mailbox
+}
+
+// GarbageCollect deletes all expired bundles from the store and emits deletion status reports where requested.
+// If a dead-letter mailbox is set, accepted bundles which never left this node are moved there.
+func GarbageCollect() {
+	garbageCollect(ReceiveBundle)
+}
+
+// garbageCollect deletes expired bundles and hands their deletion status reports over to send.
+func garbageCollect(send func(*bpv7.Bundle)) {
+	log.Debug("Collecting expired bundles")
+
+	deleted, err := store.GetStoreSingleton().GarbageCollect()
+	if err != nil {
+		log.WithError(err).Error("Error during garbage collection")
+	}
+
+	for _, bd := range deleted {
+		observer.OnExpired(bd.ID)
+
+		if bd.Bundle == nil {
+			continue
+		}
+
+		if deadLetters != nil && undelivered(bd) && deadLetters.Add(*bd.Bundle) {
+			util.LogEntry(bundleContext(bd.ID.String())).Info("Moved expired bundle to the dead-letter mailbox")
+		}
+
+		report, ok := deletionReport(*bd.Bundle, bpv7.LifetimeExpired)
+		if !ok {
+			continue
+		}
+		send(report)
+	}
+}
+
+// undelivered checks if a bundle was neither delivered to a local application agent nor forwarded to any peer.
+//
+// A bundle's AlreadySentTo always includes this node and, for received bundles, the nodes it was received from; only
+// other nodes were reached by forwarding.
+func undelivered(bd *store.BundleDescriptor) bool {
+	if bd.Delivered || len(bd.PendingHandoffs) > 0 {
+		return false
+	}
+
+	for _, peer := range bd.AlreadySentTo {
+		if peer.SameNode(ownNodeID) {
+			continue
+		}
+		if bd.PreviousNode.EndpointType != nil && peer.SameNode(bd.PreviousNode) {
+			continue
+		}
+		if bd.ReceivedFrom.EndpointType != nil && peer.SameNode(bd.ReceivedFrom) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// deletionReport builds a deletion status report for the given bundle if it was requested by the bundle's creator.
+func deletionReport(bundle bpv7.Bundle, reason bpv7.StatusReportReason) (*bpv7.Bundle, bool) {
+	logger := util.LogEntry(bundleContext(bundle.ID().String()))
+
+	primary := bundle.PrimaryBlock
+	if !primary.BundleControlFlags.Has(bpv7.StatusRequestDeletion) || primary.ReportTo == bpv7.DtnNone() {
+		return nil, false
+	}
+
+	report, err := bpv7.Builder().
+		Source(ownNodeID).
+		Destination(primary.ReportTo).
+		CreationTimestampNow().
+		Lifetime("24h").
+		StatusReport(bundle, bpv7.DeletedBundle, reason).
+		Build()
+	if err != nil {
+		logger.WithError(err).Error("Error creating deletion status report")
+		return nil, false
+	}
+
+	logger.WithFields(log.Fields{
+		"report_to": primary.ReportTo,
+		"reason":    reason,
+	}).Debug("Created deletion status report")
+	return &report, true
+}
@@ -0,0 +1,135 @@
+package processing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/application_agent"
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+func createShortLivedBundle(t *testing.T, flags bpv7.BundleControlFlags) bpv7.Bundle {
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		ReportTo("dtn://report/").
+		BundleCtrlFlags(flags).
+		CreationTimestampNow().
+		Lifetime("1s").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestGarbageCollectDeletionReport(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	reporting := createShortLivedBundle(t, bpv7.StatusRequestDeletion)
+	silent := createShortLivedBundle(t, 0)
+	for _, bundle := range []bpv7.Bundle{reporting, silent} {
+		if _, err := store.GetStoreSingleton().InsertBundle(&bundle); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The creation timestamp has a resolution of seconds; wait until both bundles have surely expired.
+	time.Sleep(2 * time.Second)
+
+	var reports []*bpv7.Bundle
+	garbageCollect(func(report *bpv7.Bundle) {
+		reports = append(reports, report)
+	})
+
+	if len(reports) != 1 {
+		t.Fatalf("expected exactly one deletion report, got %d", len(reports))
+	}
+
+	report := reports[0]
+	if report.PrimaryBlock.Destination != reporting.PrimaryBlock.ReportTo {
+		t.Fatalf("report is addressed to %v instead of %v", report.PrimaryBlock.Destination, reporting.PrimaryBlock.ReportTo)
+	}
+
+	ar, err := report.AdministrativeRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusReport, ok := ar.(*bpv7.StatusReport)
+	if !ok {
+		t.Fatalf("administrative record is %T, not a StatusReport", ar)
+	}
+	if statusReport.ReportReason != bpv7.LifetimeExpired {
+		t.Fatalf("report reason is %v instead of %v", statusReport.ReportReason, bpv7.LifetimeExpired)
+	}
+	if statusReport.RefBundle != reporting.ID() {
+		t.Fatalf("report references %v instead of %v", statusReport.RefBundle, reporting.ID())
+	}
+	if sips := statusReport.StatusInformations(); len(sips) != 1 || sips[0] != bpv7.DeletedBundle {
+		t.Fatalf("report asserts %v instead of only %v", sips, bpv7.DeletedBundle)
+	}
+
+	for _, bundle := range []bpv7.Bundle{reporting, silent} {
+		if _, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID()); err == nil {
+			t.Fatalf("bundle %v was not deleted", bundle.ID())
+		}
+	}
+}
+
+func TestGarbageCollectDeadLetter(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://src/")
+	setupTestNode(t, nodeID)
+
+	mailbox := application_agent.NewDeadLetterMailbox(nodeID, nil, 10)
+	SetDeadLetterMailbox(mailbox)
+	defer SetDeadLetterMailbox(nil)
+
+	local := createShortLivedBundle(t, 0)
+	foreign, err := bpv7.Builder().
+		Source("dtn://other/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1s").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bundle := range []bpv7.Bundle{local, foreign} {
+		if _, err := store.GetStoreSingleton().InsertBundle(&bundle); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Bundles which left this node did not fail
+	forwarded := createShortLivedBundle(t, 0)
+	forwarded.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(local.PrimaryBlock.CreationTimestamp.DtnTime(), 1)
+	if bd, err := store.GetStoreSingleton().InsertBundle(&forwarded); err != nil {
+		t.Fatal(err)
+	} else {
+		bd.AddAlreadySent(bpv7.MustNewEndpointID("dtn://peer/"))
+	}
+	delivered := createShortLivedBundle(t, 0)
+	delivered.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(local.PrimaryBlock.CreationTimestamp.DtnTime(), 2)
+	if bd, err := store.GetStoreSingleton().InsertBundle(&delivered); err != nil {
+		t.Fatal(err)
+	} else if err := bd.SetDelivered(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Second)
+	garbageCollect(func(*bpv7.Bundle) {})
+
+	if _, err := store.GetStoreSingleton().LoadBundleDescriptor(local.ID()); err == nil {
+		t.Fatalf("bundle %v was not removed from the store", local.ID())
+	}
+
+	if dead := mailbox.Fetch(local.PrimaryBlock.SourceNode, 0, false); len(dead) != 1 || dead[0].ID() != local.ID() {
+		t.Fatalf("dead-letter mailbox holds %v instead of the local bundle", dead)
+	}
+	if dead := mailbox.Fetch(foreign.PrimaryBlock.SourceNode, 0, false); len(dead) != 0 {
+		t.Fatalf("dead-letter mailbox holds the foreign bundles %v", dead)
+	}
+}
@@ -0,0 +1,193 @@
+package processing
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+// handoffPeers are the node IDs of the peers cooperating in acknowledged handoffs; disabled if empty
+var handoffPeers map[bpv7.EndpointID]bool
+
+// SetHandoffPeers enables acknowledged handoffs with the given cooperating peers, which must list this node as well.
+//
+// A bundle forwarded to such a peer is not considered sent until the peer acknowledges it with a bpv7.HandoffAck. Until
+// then, the bundle is retained and forwarded again in the next dispatch cycles. After the acknowledgement, this node
+// deletes its copy. In turn, each bundle received from such a peer is acknowledged once it is stored.
+func SetHandoffPeers(peers ...bpv7.EndpointID) {
+	handoffPeers = make(map[bpv7.EndpointID]bool, len(peers))
+	for _, peer := range peers {
+		handoffPeers[peer] = true
+	}
+}
+
+// forwardingJobs are the bundles currently being forwarded by their IDString. Handoff acknowledgements for these
+// bundles are recorded by their jobs, whose descriptors would otherwise overwrite the acknowledgement.
+var (
+	forwardingJobs      = make(map[string]*forwardingJob)
+	forwardingJobsMutex sync.Mutex
+)
+
+// registerForwardingJob makes the job receive the acknowledgements of its bundle until it is unregistered.
+func registerForwardingJob(job *forwardingJob) {
+	forwardingJobsMutex.Lock()
+	defer forwardingJobsMutex.Unlock()
+
+	forwardingJobs[job.descriptor.IDString] = job
+}
+
+// unregisterForwardingJob removes the job, unless another job of its bundle has been registered in the meantime.
+func unregisterForwardingJob(job *forwardingJob) {
+	forwardingJobsMutex.Lock()
+	defer forwardingJobsMutex.Unlock()
+
+	if forwardingJobs[job.descriptor.IDString] == job {
+		delete(forwardingJobs, job.descriptor.IDString)
+	}
+}
+
+// lookupForwardingJob returns the job currently forwarding the bundle, or nil.
+func lookupForwardingJob(bundleID string) *forwardingJob {
+	forwardingJobsMutex.Lock()
+	defer forwardingJobsMutex.Unlock()
+
+	return forwardingJobs[bundleID]
+}
+
+// handoffAck builds the acknowledgement of a bundle received from a cooperating peer.
+func handoffAck(bundle bpv7.Bundle, peer bpv7.EndpointID) (*bpv7.Bundle, error) {
+	ack, err := bpv7.Builder().
+		Source(ownNodeID).
+		Destination(peer).
+		CreationTimestampNow().
+		Lifetime("24h").
+		AdministrativeRecord(bpv7.NewHandoffAck(bundle)).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	return &ack, nil
+}
+
+// acknowledgeHandoff sends a HandoffAck for a stored bundle if it was received from a cooperating peer. The peer is
+// the one reported by the CLA, or the bundle's previous node otherwise.
+func acknowledgeHandoff(ctx context.Context, bundle *bpv7.Bundle, from bpv7.EndpointID) {
+	if len(handoffPeers) == 0 || bundle.IsAdministrativeRecord() {
+		return
+	}
+
+	peer := from
+	if peer.EndpointType == nil {
+		if previousNodeBlock, err := bundle.ExtensionBlock(bpv7.ExtBlockTypePreviousNodeBlock); err == nil {
+			peer = previousNodeBlock.Value.(*bpv7.PreviousNodeBlock).Endpoint()
+		}
+	}
+	if !handoffPeers[peer] {
+		return
+	}
+
+	logger := util.LogEntry(ctx).WithField("peer", peer)
+	ack, err := handoffAck(*bundle, peer)
+	if err != nil {
+		logger.WithError(err).Error("Error creating handoff acknowledgement")
+		return
+	}
+
+	logger.Debug("Acknowledging handoff of bundle")
+	ReceiveBundle(ack)
+}
+
+// consumeHandoffAck processes a HandoffAck addressed to this node, returning false for all other bundles.
+//
+// The acknowledged bundle is deleted, unless it is currently being forwarded. Then, the acknowledgement is recorded by
+// the forwarding job, and the bundle is deleted by finishForwarding.
+func consumeHandoffAck(ctx context.Context, bundle *bpv7.Bundle) bool {
+	if bundle.PrimaryBlock.Destination != ownNodeID || !bundle.IsAdministrativeRecord() {
+		return false
+	}
+	ar, err := bundle.AdministrativeRecord()
+	if err != nil {
+		return false
+	}
+	ack, ok := ar.(*bpv7.HandoffAck)
+	if !ok {
+		return false
+	}
+
+	peer := bundle.PrimaryBlock.SourceNode
+	logger := util.LogEntry(ctx).WithFields(log.Fields{
+		"peer":         peer,
+		"acknowledged": ack.RefBundle,
+	})
+
+	if job := lookupForwardingJob(ack.RefBundle.String()); job != nil && job.acknowledgeHandoff(logger, peer) {
+		return true
+	}
+
+	bst, err := store.LookupStoreSingleton()
+	if err != nil {
+		logger.WithError(err).Error("Cannot process handoff acknowledgement without a store")
+		return true
+	}
+	bd, err := bst.LoadBundleDescriptor(ack.RefBundle)
+	if err != nil {
+		logger.Debug("Received handoff acknowledgement for an unknown bundle")
+		return true
+	}
+
+	if acknowledged, err := bd.AcknowledgeHandoff(peer); err != nil {
+		logger.WithError(err).Error("Error recording handoff acknowledgement")
+		return true
+	} else if !acknowledged {
+		logger.Warn("Received handoff acknowledgement without a pending handoff")
+		return true
+	}
+
+	if bd.Retain {
+		logger.Info("Peer acknowledged handoff of bundle, which is still being processed")
+		return true
+	}
+	deleteAcknowledged(logger, bd)
+	return true
+}
+
+// acknowledgeHandoff records the peer's acknowledgement in this job's descriptor. The acknowledgement may arrive
+// before the peer's pending handoff was recorded, as the peer might answer before the CLA's Send returns. Then, the
+// peer is recorded as having the bundle, and no handoff will be pending. False is returned if the job has finished.
+func (job *forwardingJob) acknowledgeHandoff(logger *log.Entry, peer bpv7.EndpointID) bool {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	if job.finished {
+		return false
+	}
+
+	if acknowledged, err := job.descriptor.AcknowledgeHandoff(peer); err != nil {
+		logger.WithError(err).Error("Error recording handoff acknowledgement")
+		return true
+	} else if !acknowledged {
+		if !handoffPeers[peer] {
+			logger.Warn("Received handoff acknowledgement without a pending handoff")
+			return true
+		}
+		job.descriptor.AddAlreadySent(peer)
+	}
+
+	job.acknowledged = true
+	logger.Info("Peer acknowledged handoff of bundle, which is still being forwarded")
+	return true
+}
+
+// deleteAcknowledged deletes a bundle after its handoff was acknowledged.
+func deleteAcknowledged(logger *log.Entry, bd *store.BundleDescriptor) {
+	if err := store.GetStoreSingleton().DeleteBundle(bd); err != nil {
+		logger.WithError(err).Error("Error deleting bundle after its acknowledged handoff")
+		return
+	}
+	logger.Info("Deleted bundle after its handoff was acknowledged")
+}
@@ -0,0 +1,205 @@
+package processing
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+func TestAcknowledgedHandoff(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	setupTestNode(t, nodeID)
+
+	// The sender's peer cooperates with this node
+	sender := newTestSender("dtn://peer/")
+	SetHandoffPeers(sender.GetPeerEndpointID())
+	defer SetHandoffPeers()
+
+	SetMaxForwardingAttempts(1, false)
+	defer SetMaxForwardingAttempts(0, false)
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mimic prepareForwarding
+	if err := bd.AddConstraint(store.ForwardPending); err != nil {
+		t.Fatal(err)
+	}
+	if err := bd.RemoveConstraint(store.DispatchPending); err != nil {
+		t.Fatal(err)
+	}
+	job := &forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: bundle}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	forwardBundlesToPeer(sender, []*forwardingJob{job}, &wg)
+	finishForwarding(job, func(*bpv7.Bundle) { t.Fatal("Retained bundle was reported as deleted") })
+
+	// The bundle is retained until the acknowledgement, despite its exhausted forwarding attempts
+	stored, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+	if err != nil {
+		t.Fatalf("Bundle awaiting its acknowledgement was deleted: %v", err)
+	}
+	if !slices.Equal(stored.PendingHandoffs, []bpv7.EndpointID{sender.GetPeerEndpointID()}) {
+		t.Fatalf("Pending handoffs are %v", stored.PendingHandoffs)
+	}
+	if slices.Contains(stored.AlreadySentTo, sender.GetPeerEndpointID()) {
+		t.Fatal("Unacknowledged bundle was recorded as sent")
+	}
+
+	// The acknowledgements are created by the receiving nodes
+	ackFrom := func(peer bpv7.EndpointID) *bpv7.Bundle {
+		SetOwnNodeID(peer)
+		defer SetOwnNodeID(nodeID)
+
+		ack, err := handoffAck(bundle, nodeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ack
+	}
+
+	// An acknowledgement of another peer is ignored
+	outcome, err := IngestBundle(ackFrom(bpv7.MustNewEndpointID("dtn://other/")))
+	if err != nil || outcome != OutcomeConsumed {
+		t.Fatalf("Acknowledgement resulted in %v: %v", outcome, err)
+	}
+	if _, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID()); err != nil {
+		t.Fatalf("Bundle was deleted after another peer's acknowledgement: %v", err)
+	}
+
+	outcome, err = IngestBundle(ackFrom(sender.GetPeerEndpointID()))
+	if err != nil || outcome != OutcomeConsumed {
+		t.Fatalf("Acknowledgement resulted in %v: %v", outcome, err)
+	}
+	if _, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID()); err == nil {
+		t.Fatal("Bundle was not deleted after its acknowledged handoff")
+	}
+}
+
+func TestAcknowledgedHandoffDuringForwarding(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	setupTestNode(t, nodeID)
+
+	peer := bpv7.MustNewEndpointID("dtn://peer/")
+	SetHandoffPeers(peer)
+	defer SetHandoffPeers()
+
+	// prepare stores a bundle like prepareForwarding and creates its acknowledgement by the peer
+	prepare := func(payload string) (*forwardingJob, *bpv7.Bundle) {
+		bundle, err := bpv7.Builder().
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock([]byte(payload)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bd.AddConstraint(store.ForwardPending); err != nil {
+			t.Fatal(err)
+		}
+		if err := bd.RemoveConstraint(store.DispatchPending); err != nil {
+			t.Fatal(err)
+		}
+
+		SetOwnNodeID(peer)
+		ack, err := handoffAck(bundle, nodeID)
+		SetOwnNodeID(nodeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return newForwardingJob(bundleContext(bd.IDString), bd, bundle), ack
+	}
+
+	forward := func(sender cla.ConvergenceSender, job *forwardingJob) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		forwardBundlesToPeer(sender, []*forwardingJob{job}, &wg)
+	}
+
+	isStored := func(job *forwardingJob) bool {
+		_, err := store.GetStoreSingleton().LoadBundleDescriptor(job.bundle.ID())
+		return err == nil
+	}
+
+	t.Run("before pending handoff", func(t *testing.T) {
+		job, ack := prepare("early")
+		// The peer acknowledges the handoff before the transmission returns
+		acking := newTestSender(peer.String())
+		acking.transmit = func(bpv7.Bundle) error {
+			_, err := IngestBundle(ack)
+			return err
+		}
+		forward(acking, job)
+		if len(job.descriptor.PendingHandoffs) != 0 {
+			t.Fatalf("Acknowledged handoff is still pending: %v", job.descriptor.PendingHandoffs)
+		}
+
+		finishForwarding(job, func(*bpv7.Bundle) { t.Fatal("Acknowledged bundle was reported as deleted") })
+		if isStored(job) {
+			t.Fatal("Bundle was not deleted after its acknowledged handoff")
+		}
+	})
+
+	t.Run("before finishing", func(t *testing.T) {
+		job, ack := prepare("late")
+		forward(newTestSender(peer.String()), job)
+
+		if outcome, err := IngestBundle(ack); err != nil || outcome != OutcomeConsumed {
+			t.Fatalf("Acknowledgement resulted in %v: %v", outcome, err)
+		}
+		if !isStored(job) {
+			t.Fatal("Bundle was deleted while being forwarded")
+		}
+
+		finishForwarding(job, func(*bpv7.Bundle) { t.Fatal("Acknowledged bundle was reported as deleted") })
+		if isStored(job) {
+			t.Fatal("Bundle was not deleted after its acknowledged handoff")
+		}
+	})
+
+	// Acknowledgements of finished jobs are processed based on the store
+	t.Run("after finishing", func(t *testing.T) {
+		job, ack := prepare("after")
+		forward(newTestSender(peer.String()), job)
+		finishForwarding(job, func(*bpv7.Bundle) { t.Fatal("Retained bundle was reported as deleted") })
+
+		stored, err := store.GetStoreSingleton().LoadBundleDescriptor(job.bundle.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(stored.PendingHandoffs, []bpv7.EndpointID{peer}) {
+			t.Fatalf("Pending handoffs are %v", stored.PendingHandoffs)
+		}
+
+		if outcome, err := IngestBundle(ack); err != nil || outcome != OutcomeConsumed {
+			t.Fatalf("Acknowledgement resulted in %v: %v", outcome, err)
+		}
+		if isStored(job) {
+			t.Fatal("Bundle was not deleted after its acknowledged handoff")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package processing
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// IngressPolicy decides which received bundles are accepted, before they reach the store.
+//
+// Sources and destinations are checked against regular expressions, each matching the whole endpoint ID. If source
+// patterns exist, a bundle's source must match at least one of them; the same applies to the destination. Bundles
+// created by this node, e.g., its status reports or its agents' bundles, are always accepted.
+type IngressPolicy struct {
+	sources      []*regexp.Regexp
+	destinations []*regexp.Regexp
+
+	// reportDeletion of rejected bundles, if requested by their creators
+	reportDeletion bool
+
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewIngressPolicy compiles the allowed source and destination patterns into an IngressPolicy. If reportDeletion is
+// set, a deletion status report is sent for each rejected bundle requesting one.
+func NewIngressPolicy(sources, destinations []string, reportDeletion bool) (*IngressPolicy, error) {
+	policy := &IngressPolicy{reportDeletion: reportDeletion}
+
+	var err error
+	if policy.sources, err = compileEndpointPatterns(sources); err != nil {
+		return nil, fmt.Errorf("invalid source pattern: %w", err)
+	}
+	if policy.destinations, err = compileEndpointPatterns(destinations); err != nil {
+		return nil, fmt.Errorf("invalid destination pattern: %w", err)
+	}
+
+	return policy, nil
+}
+
+// compileEndpointPatterns compiles regular expressions to match whole endpoint IDs.
+func compileEndpointPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchesAny checks if the endpoint matches one of the patterns, or if there are none.
+func matchesAny(patterns []*regexp.Regexp, eid bpv7.EndpointID) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	eidStr := eid.String()
+	for _, re := range patterns {
+		if re.MatchString(eidStr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Accepts checks if the bundle may be received and counts the decision. Bundles created by this node, i.e., from one of
+// its endpoints, are always accepted without being counted.
+func (policy *IngressPolicy) Accepts(bundle *bpv7.Bundle) bool {
+	primary := bundle.PrimaryBlock
+	if ownNodeID.SameNode(primary.SourceNode) {
+		return true
+	}
+
+	if !matchesAny(policy.sources, primary.SourceNode) || !matchesAny(policy.destinations, primary.Destination) {
+		policy.rejected.Add(1)
+		return false
+	}
+
+	policy.accepted.Add(1)
+	return true
+}
+
+// Accepted returns the number of bundles accepted by this policy.
+func (policy *IngressPolicy) Accepted() uint64 {
+	return policy.accepted.Load()
+}
+
+// Rejected returns the number of bundles rejected by this policy.
+func (policy *IngressPolicy) Rejected() uint64 {
+	return policy.rejected.Load()
+}
+
+// ingressPolicy restricts the received bundles; all are accepted if nil.
+var ingressPolicy *IngressPolicy
+
+// SetIngressPolicy restricts the received bundles to those accepted by the policy. A nil policy accepts all bundles.
+func SetIngressPolicy(policy *IngressPolicy) {
+	ingressPolicy = policy
+}
@@ -0,0 +1,151 @@
+package processing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+func TestIngressPolicy(t *testing.T) {
+	SetOwnNodeID(bpv7.MustNewEndpointID("dtn://node/"))
+
+	tests := []struct {
+		name         string
+		sources      []string
+		destinations []string
+		source       string
+		destination  string
+		accepted     bool
+	}{
+		{"unrestricted", nil, nil, "dtn://src/", "dtn://dst/", true},
+		{"source allowed", []string{"dtn://src/.*", "dtn://other/"}, nil, "dtn://src/", "dtn://dst/", true},
+		{"source denied", []string{"dtn://other/"}, nil, "dtn://src/", "dtn://dst/", false},
+		{"source partial match", []string{"dtn://src"}, nil, "dtn://src/", "dtn://dst/", false},
+		{"destination allowed", nil, []string{"dtn://dst/.*"}, "dtn://src/", "dtn://dst/inbox", true},
+		{"destination denied", nil, []string{"dtn://dst/.*"}, "dtn://src/", "dtn://other/inbox", false},
+		{"both allowed", []string{"dtn://src/"}, []string{"dtn://dst/"}, "dtn://src/", "dtn://dst/", true},
+		{"only destination allowed", []string{"dtn://other/"}, []string{"dtn://dst/"}, "dtn://src/", "dtn://dst/", false},
+		{"own node", []string{"dtn://other/"}, []string{"dtn://other/"}, "dtn://node/", "dtn://dst/", true},
+		{"own agent", []string{"dtn://other/"}, []string{"dtn://other/"}, "dtn://node/app", "dtn://dst/", true},
+		{"other node", []string{"dtn://other/"}, nil, "dtn://node-2/app", "dtn://dst/", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := NewIngressPolicy(test.sources, test.destinations, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bundle, err := bpv7.Builder().
+				Source(test.source).
+				Destination(test.destination).
+				CreationTimestampNow().
+				Lifetime("10m").
+				PayloadBlock([]byte("hello world")).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if accepted := policy.Accepts(&bundle); accepted != test.accepted {
+				t.Fatalf("Expected accepted to be %t", test.accepted)
+			}
+
+			var expectedAccepted, expectedRejected uint64
+			switch {
+			case strings.HasPrefix(test.source, "dtn://node/"):
+				// Bundles of the own node are not counted
+			case test.accepted:
+				expectedAccepted = 1
+			default:
+				expectedRejected = 1
+			}
+			if policy.Accepted() != expectedAccepted || policy.Rejected() != expectedRejected {
+				t.Fatalf("Expected %d accepted and %d rejected, got %d and %d",
+					expectedAccepted, expectedRejected, policy.Accepted(), policy.Rejected())
+			}
+		})
+	}
+}
+
+func TestNewIngressPolicyInvalid(t *testing.T) {
+	if _, err := NewIngressPolicy([]string{"dtn://src/("}, nil, false); err == nil {
+		t.Fatal("Invalid source pattern was accepted")
+	}
+	if _, err := NewIngressPolicy(nil, []string{"dtn://dst/("}, false); err == nil {
+		t.Fatal("Invalid destination pattern was accepted")
+	}
+}
+
+func TestIngestBundleIngressPolicy(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	policy, err := NewIngressPolicy([]string{"dtn://allowed/"}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetIngressPolicy(policy)
+	defer SetIngressPolicy(nil)
+
+	ingest := func(source string) (bpv7.Bundle, Outcome) {
+		bundle, err := bpv7.Builder().
+			Source(source).
+			Destination("dtn://dst/").
+			ReportTo("dtn://report/").
+			BundleCtrlFlags(bpv7.StatusRequestDeletion).
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock([]byte(source)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		outcome, err := IngestBundle(&bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bundle, outcome
+	}
+
+	if _, outcome := ingest("dtn://allowed/"); !outcome.Has(OutcomeStored) {
+		t.Fatalf("Allowed bundle was %v", outcome)
+	}
+
+	denied, outcome := ingest("dtn://denied/")
+	if outcome != OutcomeDropped {
+		t.Fatalf("Denied bundle was %v", outcome)
+	}
+	if _, err := store.GetStoreSingleton().LoadBundleDescriptor(denied.ID()); err == nil {
+		t.Fatal("Denied bundle was stored")
+	}
+
+	if policy.Accepted() != 1 || policy.Rejected() != 1 {
+		t.Fatalf("Expected one accepted and one rejected bundle, got %d and %d", policy.Accepted(), policy.Rejected())
+	}
+
+	// The deletion report is received in the background, bypassing the policy as it was created by this node
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		bds, err := store.GetStoreSingleton().GetDispatchable()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reported := false
+		for _, bd := range bds {
+			reported = reported || bd.Destination == bpv7.MustNewEndpointID("dtn://report/")
+		}
+		if reported {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("No deletion report was stored for the denied bundle")
+		}
+	}
+
+	// Let the forwarding of the stored bundles finish before tearing down the store
+	time.Sleep(100 * time.Millisecond)
+}
@@ -1,7 +1,11 @@
 package processing
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -9,6 +13,7 @@ import (
 	"github.com/dtn7/dtn7-go/pkg/cla"
 	"github.com/dtn7/dtn7-go/pkg/routing"
 	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
 var ownNodeID bpv7.EndpointID
@@ -17,45 +22,141 @@ func SetOwnNodeID(nid bpv7.EndpointID) {
 	ownNodeID = nid
 }
 
+// DefaultSendTimeout is the default duration after which a peer's pending transmission is abandoned.
+const DefaultSendTimeout = time.Minute
+
+// sendTimeout after which a peer's pending transmission is abandoned; disabled if zero
+var sendTimeout = DefaultSendTimeout
+
+// SetSendTimeout sets the duration after which a peer's pending transmission is considered failed. Other peers are
+// not delayed by such a stuck peer. For CLAs counting their traffic, see cla.TrafficCounting, only a transmission
+// making no progress for this duration is abandoned, allowing large bundles on slow links. A zero duration waits
+// indefinitely.
+//
+// The CLAs' own cla.SetSendIdleTimeout should be set to the same duration.
+func SetSendTimeout(timeout time.Duration) {
+	sendTimeout = timeout
+}
+
+// maxForwardingAttempts after which an undelivered bundle is dropped; unlimited if zero
+var maxForwardingAttempts int
+
+// reportGiveUp of bundles dropped after their last forwarding attempt, if requested by their creators
+var reportGiveUp bool
+
+// SetMaxForwardingAttempts drops bundles after forwarding them was attempted in the given number of dispatch cycles,
+// even before their lifetime is exceeded. If reportDeletion is set, a deletion status report is sent for each dropped
+// bundle requesting one. Zero attempts retry forwarding until the bundle expires.
+func SetMaxForwardingAttempts(attempts int, reportDeletion bool) {
+	maxForwardingAttempts = attempts
+	reportGiveUp = reportDeletion
+}
+
+// dispatchBackoff after a bundle's first forwarding attempt until it is dispatched again; disabled if zero
+var dispatchBackoff time.Duration
+
+// maxDispatchBackoff limits the dispatchBackoff, which is doubled for each further forwarding attempt
+var maxDispatchBackoff time.Duration
+
+// SetDispatchBackoff defers the next dispatch of a bundle after each forwarding attempt, instead of retrying it in
+// every dispatch cycle. The backoff starts with initial and doubles for each further attempt, up to maximum. Deferred
+// bundles are not forwarded before their next dispatch is due, even to newly appeared peers. A zero initial backoff
+// retries bundles in every dispatch cycle.
+func SetDispatchBackoff(initial, maximum time.Duration) {
+	dispatchBackoff = initial
+	maxDispatchBackoff = max(maximum, initial)
+}
+
+// minimumCRCType of all blocks of forwarded bundles; CRCs are left as received if bpv7.CRCNo
+var minimumCRCType = bpv7.CRCNo
+
+// SetMinimumCRCType upgrades the blocks of forwarded bundles with a weaker CRC to the given CRC type, e.g., bundles
+// without any CRC to CRC32 for noisy links. The CRCs are recalculated, while signed blocks are kept unaltered, see
+// bpv7.Bundle.UpgradeCRCType. With bpv7.CRCNo, bundles are forwarded with their CRCs as received.
+func SetMinimumCRCType(crcType bpv7.CRCType) {
+	minimumCRCType = crcType
+}
+
+// forwardingJob is a bundle prepared for its transmission to the selected peers.
+type forwardingJob struct {
+	ctx        context.Context
+	descriptor *store.BundleDescriptor
+	bundle     bpv7.Bundle
+
+	// mutex guards the descriptor and the following fields, updated concurrently for each peer and by handoff
+	// acknowledgements received in the meantime
+	mutex sync.Mutex
+	// acknowledged is set if a peer acknowledged its handoff of the bundle during the forwarding
+	acknowledged bool
+	// finished is set by finishForwarding, after which the descriptor must no longer be updated by this job
+	finished bool
+}
+
+// newForwardingJob creates a forwardingJob and registers it for the bundle's handoff acknowledgements.
+func newForwardingJob(ctx context.Context, descriptor *store.BundleDescriptor, bundle bpv7.Bundle) *forwardingJob {
+	job := &forwardingJob{ctx: ctx, descriptor: descriptor, bundle: bundle}
+	registerForwardingJob(job)
+	return job
+}
+
 // forwardingAsync implements the bundle forwarding procedure described in RFC9171 section 5.4
-func forwardingAsync(bundleDescriptor *store.BundleDescriptor) {
-	log.WithField("bundle", bundleDescriptor.ID.String()).Debug("Processing bundle")
+func forwardingAsync(ctx context.Context, bundleDescriptor *store.BundleDescriptor) {
+	job, forwardToPeers, ok := prepareForwarding(ctx, bundleDescriptor)
+	if !ok {
+		return
+	}
+
+	// Step 4.4: call CLAs for transmission
+	var wg sync.WaitGroup
+	wg.Add(len(forwardToPeers))
+	for _, peer := range forwardToPeers {
+		go forwardBundlesToPeer(peer, []*forwardingJob{job}, &wg)
+	}
+	wg.Wait()
+
+	finishForwarding(job, ReceiveBundle)
+}
+
+// prepareForwarding performs the forwarding procedure's steps up to the transmission.
+// If the bundle cannot be forwarded, e.g., because there are no peers for it, false is returned.
+func prepareForwarding(ctx context.Context, bundleDescriptor *store.BundleDescriptor) (job *forwardingJob, forwardToPeers []cla.ConvergenceSender, ok bool) {
+	logger := util.LogEntry(ctx)
+	logger.Debug("Processing bundle")
+
+	algorithm, err := routing.LookupAlgorithmSingleton()
+	if err != nil {
+		logger.WithError(err).Error("Cannot forward bundle without a routing algorithm")
+		return
+	}
 
 	// Step 1: add "Forward Pending, remove "Dispatch Pending"
-	err := bundleDescriptor.AddConstraint(store.ForwardPending)
+	err = bundleDescriptor.AddConstraint(store.ForwardPending)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundleDescriptor.ID,
-			"error":  err,
-		}).Error("Error adding constraint to bundle")
+		logger.WithError(err).Error("Error adding constraint to bundle")
 		return
 	}
 	err = bundleDescriptor.RemoveConstraint(store.DispatchPending)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundleDescriptor.ID,
-			"error":  err,
-		}).Error("Error removing constraint from bundle")
+		logger.WithError(err).Error("Error removing constraint from bundle")
 		return
 	}
 
 	// Step 2: determine if contraindicated - whatever that means
 	// Step 2.1: Call routing algorithm(?)
-	forwardToPeers := routing.GetAlgorithmSingleton().SelectPeersForForwarding(bundleDescriptor)
+	forwardToPeers = algorithm.SelectPeersForForwarding(bundleDescriptor)
+	// Step 2.2: never send a bundle back to the node we just received it from
+	forwardToPeers = routing.FilterPreviousNode(bundleDescriptor, forwardToPeers)
 
 	// Step 3: if contraindicated, call `contraindicateBundle`, and return
 	if len(forwardToPeers) == 0 {
-		bundleContraindicated(bundleDescriptor)
+		bundleContraindicated(ctx, bundleDescriptor)
 		return
 	}
 
 	// Step 4:
 	bundle, err := bundleDescriptor.Load()
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundleDescriptor.ID,
-			"error":  err,
-		}).Error("Error loading bundle from disk")
+		logger.WithError(err).Error("Error loading bundle from disk")
 		return
 	}
 	// Step 4.1: remove previous node block
@@ -66,83 +167,288 @@ func forwardingAsync(bundleDescriptor *store.BundleDescriptor) {
 	prevNodeBlock := bpv7.NewCanonicalBlock(0, 0, bpv7.NewPreviousNodeBlock(ownNodeID))
 	err = bundle.AddExtensionBlock(prevNodeBlock)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundleDescriptor.ID,
-			"error":  err,
-		}).Error("Error adding PreviousNodeBlock to bundle")
+		logger.WithError(err).Error("Error adding PreviousNodeBlock to bundle")
 	}
-	// TODO: Step 4.3: update bundle age block
-	// Step 4.4: call CLAs for transmission
-	var mutex sync.Mutex
-	var wg sync.WaitGroup
-	wg.Add(len(forwardToPeers))
-	for _, peer := range forwardToPeers {
-		go forwardBundleToPeer(&mutex, bundleDescriptor, bundle, peer, &wg)
+	// Additionally, record this node in the route record block, if the bundle carries one
+	recordRoute(ctx, &bundle)
+	// Step 4.3: update bundle age block
+	if bundle.HasExtensionBlock(bpv7.ExtBlockTypeBundleAgeBlock) {
+		residence := uint64(time.Since(bundleDescriptor.ReceivedAt).Milliseconds())
+		if age, err := bundle.IncrementBundleAge(residence); err != nil {
+			logger.WithError(err).Error("Error updating BundleAgeBlock")
+		} else {
+			logger.WithField("age", age).Debug("Updated BundleAgeBlock")
+		}
+	}
+	// Additionally, protect the bundle by the configured CRC type
+	if minimumCRCType != bpv7.CRCNo {
+		if upgraded := bundle.UpgradeCRCType(minimumCRCType); upgraded > 0 {
+			logger.WithFields(log.Fields{
+				"blocks":   upgraded,
+				"crc type": minimumCRCType,
+			}).Debug("Upgraded CRC type of blocks")
+		}
+	}
+
+	job = newForwardingJob(ctx, bundleDescriptor, bundle)
+	return job, forwardToPeers, true
+}
+
+// recordRoute appends this node to the bundle's RouteRecordBlock, if present.
+func recordRoute(ctx context.Context, bundle *bpv7.Bundle) {
+	routeRecordBlock, err := bundle.ExtensionBlock(bpv7.ExtBlockTypeRouteRecordBlock)
+	if err != nil {
+		return
+	}
+
+	routeRecord := routeRecordBlock.Value.(*bpv7.RouteRecordBlock)
+	if routeRecord.Record(ownNodeID) {
+		util.LogEntry(ctx).WithField("hops", len(routeRecord.Route)).Debug("Recorded node in RouteRecordBlock")
+	} else if routeRecord.IsFull() {
+		util.LogEntry(ctx).Debug("RouteRecordBlock is full, not recording node")
+	}
+}
+
+// finishForwarding concludes the forwarding procedure after the bundle's transmission. A bundle whose handoff was
+// acknowledged during the forwarding is deleted. A bundle whose last forwarding attempt has passed is dropped, handing
+// its deletion status report over to send, unless it awaits the acknowledgement of a handoff. The next dispatch of
+// all other bundles is deferred, see SetDispatchBackoff.
+func finishForwarding(job *forwardingJob, send func(*bpv7.Bundle)) {
+	logger := util.LogEntry(job.ctx)
+
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	job.finished = true
+	unregisterForwardingJob(job)
+
+	if err := job.descriptor.AddForwardingAttempt(); err != nil {
+		logger.WithError(err).Error("Error counting forwarding attempt of bundle")
 	}
-	wg.Wait()
 
 	// Step 6: remove "Forward Pending"
-	err = bundleDescriptor.RemoveConstraint(store.ForwardPending)
+	err := job.descriptor.RemoveConstraint(store.ForwardPending)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundleDescriptor.ID,
-			"error":  err,
-		}).Error("Error removing constraint from bundle")
+		logger.WithError(err).Error("Error removing constraint from bundle")
+	}
+
+	if job.descriptor.Retain || len(job.descriptor.PendingHandoffs) > 0 {
+		deferDispatch(job)
+		return
+	}
+	if job.acknowledged {
+		deleteAcknowledged(logger, job.descriptor)
+	} else if maxForwardingAttempts > 0 && job.descriptor.ForwardingAttempts >= maxForwardingAttempts {
+		giveUpForwarding(job, send)
+	} else {
+		deferDispatch(job)
+	}
+}
+
+// deferDispatch postpones the next dispatch of a bundle to be retried by the backoff of its forwarding attempts,
+// see SetDispatchBackoff.
+func deferDispatch(job *forwardingJob) {
+	if dispatchBackoff <= 0 {
+		return
+	}
+
+	backoff := dispatchBackoff
+	for attempt := 1; attempt < job.descriptor.ForwardingAttempts && backoff < maxDispatchBackoff; attempt++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxDispatchBackoff)
+
+	logger := util.LogEntry(job.ctx).WithField("backoff", backoff)
+	if err := job.descriptor.SetNextDispatch(time.Now().Add(backoff)); err != nil {
+		logger.WithError(err).Error("Error deferring next dispatch of bundle")
+	} else {
+		logger.Debug("Deferred next dispatch of bundle")
+	}
+}
+
+// giveUpForwarding drops a bundle after its last forwarding attempt and hands its deletion status report over to send.
+func giveUpForwarding(job *forwardingJob, send func(*bpv7.Bundle)) {
+	logger := util.LogEntry(job.ctx).WithField("attempts", job.descriptor.ForwardingAttempts)
+
+	if err := store.GetStoreSingleton().DeleteBundle(job.descriptor); err != nil {
+		logger.WithError(err).Error("Error deleting bundle after its last forwarding attempt")
 		return
 	}
+	logger.Info("Dropped bundle after its last forwarding attempt")
+
+	if reportGiveUp {
+		if report, ok := deletionReport(job.bundle, bpv7.NoNextNodeContact); ok {
+			send(report)
+		}
+	}
 }
 
 func BundleForwarding(bundleDescriptor *store.BundleDescriptor) {
-	go forwardingAsync(bundleDescriptor)
+	go forwardingAsync(bundleContext(bundleDescriptor.IDString), bundleDescriptor)
+}
+
+// bundleContext creates a new processing context, correlating all log entries of the bundle with its ID.
+func bundleContext(bundleID string) context.Context {
+	return util.WithCorrelationID(context.Background(), bundleID)
 }
 
-func bundleContraindicated(bundleDescriptor *store.BundleDescriptor) {
+func bundleContraindicated(ctx context.Context, bundleDescriptor *store.BundleDescriptor) {
 	// TODO: is there anything else to do here?
 	err := bundleDescriptor.ResetConstraints()
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundleDescriptor.ID,
-			"error":  err,
-		}).Error("Error resetting bundle constraints")
+		util.LogEntry(ctx).WithError(err).Error("Error resetting bundle constraints")
 	}
 }
 
-func forwardBundleToPeer(mutex *sync.Mutex, bundleDescriptor *store.BundleDescriptor, bundle bpv7.Bundle, peer cla.ConvergenceSender, wg *sync.WaitGroup) {
-	log.WithFields(log.Fields{
-		"bundle": bundle.ID(),
-		"cla":    peer,
-	}).Info("Sending bundle to a CLA (ConvergenceSender)")
+// forwardBundlesToPeer sends all bundles as one batch to the peer, recording each successful transmission. Bundles
+// denied by the peer's egress policy are skipped.
+func forwardBundlesToPeer(peer cla.ConvergenceSender, jobs []*forwardingJob, wg *sync.WaitGroup) {
+	defer wg.Done()
 
-	if err := peer.Send(bundle); err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundle.ID(),
-			"cla":    peer,
-			"error":  err,
-		}).Warn("Sending bundle failed")
-	} else {
-		log.WithFields(log.Fields{
-			"bundle": bundle.ID(),
-			"cla":    peer,
-		}).Debug("Sending bundle succeeded")
-		mutex.Lock()
-		bundleDescriptor.AddAlreadySent(peer.GetPeerEndpointID())
-		mutex.Unlock()
+	allowed := make([]*forwardingJob, 0, len(jobs))
+	for _, job := range jobs {
+		if !egressAllowed(peer, &job.bundle) {
+			util.LogEntry(job.ctx).WithField("cla", peer).Info("Egress policy denies sending bundle to the CLA")
+			continue
+		}
+		allowed = append(allowed, job)
+	}
+	jobs = allowed
+	if len(jobs) == 0 {
+		return
+	}
+
+	bundles := make([]bpv7.Bundle, len(jobs))
+	for i, job := range jobs {
+		bundles[i] = job.bundle
+		util.LogEntry(job.ctx).WithField("cla", peer).Info("Sending bundle to a CLA (ConvergenceSender)")
+	}
+
+	err := sendWithTimeout(peer, bundles)
+
+	for i, job := range jobs {
+		logger := util.LogEntry(job.ctx).WithField("cla", peer)
+		if bundleErr := cla.BundleError(err, i); bundleErr != nil {
+			logger.WithError(bundleErr).Warn("Sending bundle failed")
+			continue
+		}
+
+		logger.Debug("Sending bundle succeeded")
+		peerID := peer.GetPeerEndpointID()
+		job.mutex.Lock()
+		// A cooperating peer might have acknowledged the handoff already, recording it as having the bundle
+		if handoffPeers[peerID] && !slices.Contains(job.descriptor.AlreadySentTo, peerID) {
+			if err := job.descriptor.AddPendingHandoff(peerID); err != nil {
+				logger.WithError(err).Error("Error recording pending handoff of bundle")
+			}
+		} else {
+			job.descriptor.AddAlreadySent(peerID)
+		}
+		job.mutex.Unlock()
+		observer.OnForwarded(job.descriptor.ID, peer.GetPeerEndpointID())
+	}
+}
+
+// sendWithTimeout sends the bundles to the peer, but gives up after the sendTimeout. If the peer counts its traffic,
+// the timeout is renewed as long as the peer's sent bytes increase.
+//
+// A CLA's Send cannot be interrupted. Thus, an abandoned transmission keeps running in the background, but its
+// result is ignored.
+func sendWithTimeout(peer cla.ConvergenceSender, bundles []bpv7.Bundle) error {
+	if sendTimeout <= 0 {
+		return cla.SendMany(peer, bundles)
+	}
+
+	counting, _ := peer.(cla.TrafficCounting)
+	var sent uint64
+	if counting != nil {
+		sent = counting.Traffic().Sent
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- cla.SendMany(peer, bundles)
+	}()
+
+	timer := time.NewTimer(sendTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case err := <-result:
+			return err
+		case <-timer.C:
+			if counting != nil {
+				if nowSent := counting.Traffic().Sent; nowSent != sent {
+					sent = nowSent
+					timer.Reset(sendTimeout)
+					continue
+				}
+			}
+
+			log.WithFields(log.Fields{
+				"cla":     peer,
+				"timeout": sendTimeout,
+			}).Warn("Abandoning stuck transmission to CLA")
+			return fmt.Errorf("sending to %v was abandoned: %w", peer.GetPeerEndpointID(), context.DeadlineExceeded)
+		}
 	}
-	wg.Done()
 }
 
+// DispatchPending forwards all bundles marked for dispatching whose next dispatch is due.
+//
+// Bundles going to the same peer are sent together as one batch.
 func DispatchPending() {
 	log.Debug("Dispatching bundles")
 
-	bndls, err := store.GetStoreSingleton().GetDispatchable()
+	bndls, err := store.GetStoreSingleton().GetDueForDispatch(time.Now())
 	if err != nil {
 		log.WithError(err).Error("Error dispatching pending bundles")
 		return
 	}
-	log.WithField("bundles", bndls).Debug("Bundles to dispatch")
+	if log.IsLevelEnabled(log.DebugLevel) {
+		ids := make([]string, len(bndls))
+		for i, bndl := range bndls {
+			ids[i] = bndl.IDString
+		}
+		log.WithField("bundles", ids).Debug("Bundles to dispatch")
+	}
 
+	jobs := make([]*forwardingJob, 0, len(bndls))
+	batches := make(map[cla.ConvergenceSender][]*forwardingJob)
 	for _, bndl := range bndls {
-		BundleForwarding(bndl)
+		job, forwardToPeers, ok := prepareForwarding(bundleContext(bndl.IDString), bndl)
+		if !ok {
+			continue
+		}
+
+		jobs = append(jobs, job)
+		for _, peer := range forwardToPeers {
+			batches[peer] = append(batches[peer], job)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(batches))
+	for peer, batch := range batches {
+		go forwardBundlesToPeer(peer, batch, &wg)
+	}
+	wg.Wait()
+
+	for _, job := range jobs {
+		finishForwarding(job, ReceiveBundle)
+	}
+}
+
+// Scrub verifies the integrity of all stored bundles, quarantining corrupt ones.
+func Scrub() {
+	log.Debug("Scrubbing bundle store")
+
+	quarantined, err := store.GetStoreSingleton().Scrub()
+	if err != nil {
+		log.WithError(err).Error("Error scrubbing bundle store")
+	}
+	if len(quarantined) > 0 {
+		log.WithField("bundles", quarantined).Warn("Quarantined corrupt bundles")
 	}
 }
 
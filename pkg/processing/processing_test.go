@@ -0,0 +1,447 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/application_agent"
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/routing"
+	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+// allowInitialised ignores errors of singletons without a reset, initialised by an earlier test run.
+func allowInitialised(t *testing.T, err error) {
+	var initErr *util.AlreadyInitialised
+	if err != nil && !errors.As(err, &initErr) {
+		t.Fatal(err)
+	}
+}
+
+// setupTestNode initialises the store, routing algorithm, application agent manager and CLA manager of a node,
+// tearing them down at the end of the test.
+func setupTestNode(t *testing.T, nodeID bpv7.EndpointID) {
+	storePath, err := os.MkdirTemp("", "dtn7-processing-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(storePath) })
+
+	SetOwnNodeID(nodeID)
+	if err := store.InitialiseStore(nodeID, storePath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.GetStoreSingleton().Close() })
+
+	allowInitialised(t, routing.InitialiseAlgorithm(routing.Epidemic, nil))
+	if err := application_agent.InitialiseApplicationAgentManager(ReceiveBundle); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { application_agent.GetManagerSingleton().Shutdown() })
+	if err := cla.InitialiseCLAManager(ReceiveBundle, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cla.GetManagerSingleton().Shutdown() })
+}
+
+// testSender is a ConvergenceSender recording the bundles accepted by its peer.
+//
+// Each bundle is passed to transmit, if set, and is rejected by the peer if transmit returns an error.
+type testSender struct {
+	peer     bpv7.EndpointID
+	claType  cla.CLAType
+	transmit func(bpv7.Bundle) error
+	traffic  cla.TrafficCounter
+
+	mutex   sync.Mutex
+	batches int
+	sent    []bpv7.Bundle
+}
+
+func newTestSender(peer string) *testSender {
+	return &testSender{peer: bpv7.MustNewEndpointID(peer), claType: cla.Dummy}
+}
+
+func (ts *testSender) Close() error                       { return nil }
+func (ts *testSender) Activate() error                    { return nil }
+func (ts *testSender) Active() bool                       { return true }
+func (ts *testSender) Address() string                    { return ts.peer.String() }
+func (ts *testSender) GetPeerEndpointID() bpv7.EndpointID { return ts.peer }
+func (ts *testSender) Type() cla.CLAType                  { return ts.claType }
+func (ts *testSender) Traffic() cla.Traffic               { return ts.traffic.Traffic() }
+
+func (ts *testSender) Send(bndl bpv7.Bundle) error {
+	return cla.BundleError(ts.SendMany([]bpv7.Bundle{bndl}), 0)
+}
+
+func (ts *testSender) SendMany(bndls []bpv7.Bundle) error {
+	ts.mutex.Lock()
+	ts.batches++
+	ts.mutex.Unlock()
+
+	sendErr := cla.NewSendManyError()
+	for i, bndl := range bndls {
+		if ts.transmit != nil {
+			if err := ts.transmit(bndl); err != nil {
+				sendErr.Add(i, err)
+				continue
+			}
+		}
+
+		ts.mutex.Lock()
+		ts.sent = append(ts.sent, bndl)
+		ts.mutex.Unlock()
+	}
+	return sendErr.ErrorOrNil()
+}
+
+// sentBundles returns the bundles accepted by the peer.
+func (ts *testSender) sentBundles() []bpv7.Bundle {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return slices.Clone(ts.sent)
+}
+
+// sentPayloads returns the payloads of the bundles accepted by the peer.
+func (ts *testSender) sentPayloads() []string {
+	var payloads []string
+	for _, bndl := range ts.sentBundles() {
+		if payload, err := bndl.PayloadBlock(); err == nil {
+			payloads = append(payloads, string(payload.Value.(*bpv7.PayloadBlock).Data()))
+		}
+	}
+	return payloads
+}
+
+// testBundle builds a bundle from the source to the destination.
+func testBundle(t *testing.T, source, destination string, payload []byte) bpv7.Bundle {
+	bundle, err := bpv7.Builder().
+		Source(source).
+		Destination(destination).
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestForwardBundlesToPeer(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	var jobs []*forwardingJob
+	for _, source := range []string{"dtn://ok/", "dtn://fail/", "dtn://also-ok/"} {
+		bundle := testBundle(t, source, "dtn://dst/", []byte("hello world"))
+		bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, &forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: bundle})
+	}
+
+	// The peer rejects bundles from dtn://fail/
+	sender := newTestSender("dtn://peer/")
+	sender.transmit = func(bndl bpv7.Bundle) error {
+		if bndl.PrimaryBlock.SourceNode == bpv7.MustNewEndpointID("dtn://fail/") {
+			return errors.New("rejected")
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	forwardBundlesToPeer(sender, jobs, &wg)
+	wg.Wait()
+
+	if sender.batches != 1 {
+		t.Fatalf("Bundles were sent in %d batches instead of one", sender.batches)
+	}
+
+	for i, job := range jobs {
+		sent := slices.Contains(job.descriptor.GetAlreadySent(), sender.GetPeerEndpointID())
+		if expected := i != 1; sent != expected {
+			t.Fatalf("Bundle %v recorded as sent: %t, expected %t", job.descriptor.ID, sent, expected)
+		}
+	}
+}
+
+func TestForwardBundlesToStuckPeer(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	const timeout = 200 * time.Millisecond
+	SetSendTimeout(timeout)
+	defer SetSendTimeout(DefaultSendTimeout)
+
+	bundle := testBundle(t, "dtn://src/", "dtn://dst/", []byte("hello world"))
+	bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: bundle}
+
+	// The stuck peer blocks in Send until it is released
+	release := make(chan struct{})
+	defer close(release)
+	working := newTestSender("dtn://peer/")
+	stuck := newTestSender("dtn://stuck/")
+	stuck.transmit = func(bpv7.Bundle) error {
+		<-release
+		return nil
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go forwardBundlesToPeer(working, []*forwardingJob{job}, &wg)
+	go forwardBundlesToPeer(stuck, []*forwardingJob{job}, &wg)
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed > 2*timeout {
+		t.Fatalf("Forwarding took %v despite a timeout of %v", elapsed, timeout)
+	}
+
+	sentTo := job.descriptor.GetAlreadySent()
+	if !slices.Contains(sentTo, working.GetPeerEndpointID()) {
+		t.Fatalf("Bundle was not recorded as sent to the working peer: %v", sentTo)
+	}
+	if slices.Contains(sentTo, stuck.GetPeerEndpointID()) {
+		t.Fatalf("Bundle was recorded as sent to the stuck peer: %v", sentTo)
+	}
+}
+
+func TestSendWithTimeoutProgress(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	SetSendTimeout(timeout)
+	defer SetSendTimeout(DefaultSendTimeout)
+
+	bundle := testBundle(t, "dtn://src/", "dtn://dst/", []byte("hello world"))
+
+	// slowSender takes several steps to send a bundle, counting its progress as traffic
+	slowSender := func(steps int, step time.Duration) *testSender {
+		slow := newTestSender("dtn://slow/")
+		slow.transmit = func(bpv7.Bundle) error {
+			for i := 0; i < steps; i++ {
+				time.Sleep(step)
+				slow.traffic.AddSent(1024)
+			}
+			return nil
+		}
+		return slow
+	}
+
+	// The whole transmission takes longer than the timeout, but progresses steadily
+	if err := sendWithTimeout(slowSender(8, timeout/4), []bpv7.Bundle{bundle}); err != nil {
+		t.Fatalf("Progressing transmission was abandoned: %v", err)
+	}
+
+	stuck := slowSender(1, time.Hour)
+	if err := sendWithTimeout(stuck, []bpv7.Bundle{bundle}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stalled transmission resulted in %v", err)
+	}
+}
+
+func TestPrepareForwardingWithoutAlgorithm(t *testing.T) {
+	if _, err := routing.LookupAlgorithmSingleton(); err == nil {
+		t.Skip("Routing algorithm is already initialised")
+	}
+
+	descriptor := &store.BundleDescriptor{RetentionConstraints: []store.Constraint{store.DispatchPending}}
+	if _, _, ok := prepareForwarding(bundleContext("test"), descriptor); ok {
+		t.Fatal("Bundle was prepared for forwarding without a routing algorithm")
+	}
+	if !slices.Equal(descriptor.RetentionConstraints, []store.Constraint{store.DispatchPending}) {
+		t.Fatalf("Constraints were changed: %v", descriptor.RetentionConstraints)
+	}
+}
+
+func TestFinishForwardingMaxAttempts(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	const maxAttempts = 3
+	SetMaxForwardingAttempts(maxAttempts, true)
+	defer SetMaxForwardingAttempts(0, false)
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		ReportTo("dtn://report/").
+		BundleCtrlFlags(bpv7.StatusRequestDeletion).
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []*bpv7.Bundle
+	send := func(report *bpv7.Bundle) {
+		reports = append(reports, report)
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		// Mimic prepareForwarding
+		if err := bd.AddConstraint(store.ForwardPending); err != nil {
+			t.Fatal(err)
+		}
+		if err := bd.RemoveConstraint(store.DispatchPending); err != nil {
+			t.Fatal(err)
+		}
+		finishForwarding(&forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: bundle}, send)
+
+		stored, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+		if attempt == maxAttempts {
+			if err == nil {
+				t.Fatalf("Bundle was not dropped after %d attempts", attempt)
+			}
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("Bundle was dropped after %d attempts: %v", attempt, err)
+		}
+		if stored.ForwardingAttempts != attempt {
+			t.Fatalf("Bundle has %d forwarding attempts instead of %d", stored.ForwardingAttempts, attempt)
+		}
+		if len(reports) != 0 {
+			t.Fatalf("Deletion report was sent after %d attempts", attempt)
+		}
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("Expected exactly one deletion report, got %d", len(reports))
+	}
+	ar, err := reports[0].AdministrativeRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusReport := ar.(*bpv7.StatusReport); statusReport.ReportReason != bpv7.NoNextNodeContact ||
+		statusReport.RefBundle != bundle.ID() {
+		t.Fatalf("Unexpected deletion report %v", statusReport)
+	}
+}
+
+func TestFinishForwardingBackoff(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	SetDispatchBackoff(time.Minute, 3*time.Minute)
+	defer SetDispatchBackoff(0, 0)
+
+	bundle := testBundle(t, "dtn://src/", "dtn://dst/", []byte("hello world"))
+	bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backoff doubles for each attempt, up to the maximum
+	for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		// Mimic prepareForwarding
+		if err := bd.AddConstraint(store.ForwardPending); err != nil {
+			t.Fatal(err)
+		}
+		if err := bd.RemoveConstraint(store.DispatchPending); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		finishForwarding(&forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: bundle},
+			func(*bpv7.Bundle) {})
+
+		if next := bd.NextDispatch; next.Before(start.Add(backoff)) || next.After(time.Now().Add(backoff)) {
+			t.Fatalf("Attempt %d deferred the next dispatch to %v instead of by %v", attempt+1, next, backoff)
+		}
+
+		due, err := store.GetStoreSingleton().GetDueForDispatch(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != 0 {
+			t.Fatalf("Deferred bundle is due for dispatch after attempt %d", attempt+1)
+		}
+		if due, err = store.GetStoreSingleton().GetDueForDispatch(time.Now().Add(backoff)); err != nil {
+			t.Fatal(err)
+		} else if len(due) != 1 || due[0].ID != bundle.ID() {
+			t.Fatalf("Bundle is not due for dispatch after its backoff of %v", backoff)
+		}
+	}
+}
+
+func TestForwardingMinimumCRCType(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	sender := newTestSender("dtn://next-hop/")
+	if err := cla.GetManagerSingleton().RegisterSync(sender); err != nil {
+		t.Fatal(err)
+	}
+
+	SetMinimumCRCType(bpv7.CRC32)
+	defer SetMinimumCRCType(bpv7.CRCNo)
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A bundle received without any CRC
+	bundle.SetCRCType(bpv7.CRCNo)
+	bundle.PrimaryBlock.CRCType = bpv7.CRCNo
+	bundle.PrimaryBlock.CRC = nil
+
+	bd, err := store.GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, peers, ok := prepareForwarding(bundleContext(bd.IDString), bd)
+	if !ok || !slices.Contains(peers, cla.ConvergenceSender(sender)) {
+		t.Fatalf("Bundle is not forwarded to the sender, but to %v", peers)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	forwardBundlesToPeer(sender, []*forwardingJob{job}, &wg)
+	wg.Wait()
+
+	sent := sender.sentBundles()
+	if len(sent) != 1 {
+		t.Fatalf("%d bundles were sent", len(sent))
+	}
+	relayed := sent[0]
+
+	// Parsing the relayed bundle checks its CRC values
+	buff := new(bytes.Buffer)
+	if err := relayed.MarshalCbor(buff); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := bpv7.ParseBundle(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.PrimaryBlock.CRCType != bpv7.CRC32 || len(parsed.PrimaryBlock.CRC) == 0 {
+		t.Fatalf("Primary block has CRC type %v and CRC %x", parsed.PrimaryBlock.CRCType, parsed.PrimaryBlock.CRC)
+	}
+	for _, cb := range parsed.CanonicalBlocks {
+		if cb.CRCType != bpv7.CRC32 || len(cb.CRC) == 0 {
+			t.Fatalf("Block of type %d has CRC type %v and CRC %x", cb.TypeCode(), cb.CRCType, cb.CRC)
+		}
+	}
+}
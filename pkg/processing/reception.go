@@ -1,36 +1,152 @@
 package processing
 
 import (
-	log "github.com/sirupsen/logrus"
+	"context"
+	"strings"
 
 	"github.com/dtn7/dtn7-go/pkg/application_agent"
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
 	"github.com/dtn7/dtn7-go/pkg/routing"
 	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
-func receiveAsync(bundle *bpv7.Bundle) {
-	bundleDescriptor, err := store.GetStoreSingleton().InsertBundle(bundle)
+// Outcome of a bundle's ingestion, combining the flags below. A zero Outcome means the bundle was dropped.
+type Outcome uint
+
+const (
+	// OutcomeDropped bundles were discarded, e.g., because their lifetime was exceeded.
+	OutcomeDropped Outcome = 0
+
+	// OutcomeStored bundles were accepted and kept in the store.
+	OutcomeStored Outcome = 1 << (iota - 1)
+
+	// OutcomeDelivered bundles were delivered to at least one local application agent.
+	OutcomeDelivered
+
+	// OutcomeForwarding bundles are dispatched for forwarding, which continues asynchronously.
+	OutcomeForwarding
+
+	// OutcomeConsumed bundles were administrative records processed by this node itself, e.g., handoff
+	// acknowledgements, which are not stored.
+	OutcomeConsumed
+)
+
+// Has checks if all of the given flags are part of this Outcome.
+func (o Outcome) Has(flags Outcome) bool {
+	return o&flags == flags
+}
+
+func (o Outcome) String() string {
+	if o == OutcomeDropped {
+		return "dropped"
+	}
+
+	var parts []string
+	for _, flag := range []struct {
+		outcome Outcome
+		name    string
+	}{
+		{OutcomeStored, "stored"},
+		{OutcomeDelivered, "delivered"},
+		{OutcomeForwarding, "forwarding"},
+		{OutcomeConsumed, "consumed"},
+	} {
+		if o.Has(flag.outcome) {
+			parts = append(parts, flag.name)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func ingestBundle(ctx context.Context, bundle *bpv7.Bundle, from bpv7.EndpointID) (outcome Outcome, err error) {
+	logger := util.LogEntry(ctx)
+	logger.Debug("Processing received bundle")
+
+	observer.OnReceived(bundle.ID(), from)
+
+	if bundle.IsLifetimeExceeded() {
+		logger.Info("Dropping received bundle with an exceeded lifetime")
+		observer.OnDropped(bundle.ID(), DropLifetimeExceeded)
+		return
+	} else if bundle.IsCreatedInFuture() {
+		logger.WithField("tolerance", bpv7.ClockSkewTolerance()).Info(
+			"Dropping received bundle created in the future, exceeding the tolerated clock skew")
+		observer.OnDropped(bundle.ID(), DropCreatedInFuture)
+		return
+	} else if ingressPolicy != nil && !ingressPolicy.Accepts(bundle) {
+		logger.Info("Dropping received bundle denied by the ingress policy")
+		observer.OnDropped(bundle.ID(), DropIngressDenied)
+		if ingressPolicy.reportDeletion {
+			if report, ok := deletionReport(*bundle, bpv7.TrafficPared); ok {
+				ReceiveBundle(report)
+			}
+		}
+		return
+	} else if !processUnknownBlocks(ctx, bundle, ReceiveBundle) {
+		observer.OnDropped(bundle.ID(), DropBlockUnsupported)
+		return
+	}
+
+	if consumeHandoffAck(ctx, bundle) {
+		outcome |= OutcomeConsumed
+		return
+	}
+
+	bst, err := store.LookupStoreSingleton()
 	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundle.ID(),
-			"error":  err,
-		}).Error("Error storing new bundle")
+		logger.WithError(err).Error("Cannot receive bundle without a store")
+		observer.OnDropped(bundle.ID(), DropStoreFailed)
 		return
 	}
 
-	application_agent.GetManagerSingleton().Delivery(bundleDescriptor)
+	bundleDescriptor, err := bst.InsertReceivedBundle(bundle, from)
+	if err != nil {
+		logger.WithError(err).Error("Error storing new bundle")
+		observer.OnDropped(bundle.ID(), DropStoreFailed)
+		return
+	}
+	outcome |= OutcomeStored
+	observer.OnStored(bundle.ID())
+	acknowledgeHandoff(ctx, bundle, from)
+
+	if application_agent.GetManagerSingleton().Delivery(bundleDescriptor) {
+		outcome |= OutcomeDelivered
+		observer.OnDelivered(bundle.ID())
+		if err := bundleDescriptor.SetDelivered(); err != nil {
+			logger.WithError(err).Error("Error recording delivery of bundle")
+		}
+	}
 
 	routing.GetAlgorithmSingleton().NotifyNewBundle(bundleDescriptor)
 
 	for _, constraint := range bundleDescriptor.RetentionConstraints {
 		if constraint == store.DispatchPending {
-			log.WithField("bundle", bundleDescriptor.IDString).Debug("Forwarding received bundle")
-			BundleForwarding(bundleDescriptor)
+			logger.Debug("Forwarding received bundle")
+			outcome |= OutcomeForwarding
+			go forwardingAsync(ctx, bundleDescriptor)
 		}
 	}
+
+	logger.WithField("outcome", outcome).Debug("Ingested received bundle")
+	return
 }
 
+// IngestBundle processes a received bundle synchronously and returns its Outcome.
+//
+// Storage and local delivery are finished when this function returns, while forwarding continues in the background.
+// An error is only returned if the bundle could not be processed, e.g., because storing it failed.
+func IngestBundle(bundle *bpv7.Bundle) (Outcome, error) {
+	return ingestBundle(bundleContext(bundle.ID().String()), bundle, bpv7.EndpointID{})
+}
+
+// ReceiveBundle processes a received bundle in the background, see IngestBundle.
 func ReceiveBundle(bundle *bpv7.Bundle) {
-	go receiveAsync(bundle)
+	go func() { _, _ = IngestBundle(bundle) }()
+}
+
+// ReceiveBundleFrom processes a bundle received from a known peer in the background, like ReceiveBundle.
+// The peer is recorded as the bundle's store.BundleDescriptor.ReceivedFrom.
+func ReceiveBundleFrom(bundle *bpv7.Bundle, from bpv7.EndpointID) {
+	go func() { _, _ = ingestBundle(bundleContext(bundle.ID().String()), bundle, from) }()
 }
@@ -0,0 +1,251 @@
+package processing
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/dtn7/dtn7-go/pkg/application_agent"
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
+	_ "github.com/dtn7/dtn7-go/pkg/cla/loopback"
+	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+func TestCorrelationID(t *testing.T) {
+	hook := test.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.DebugLevel)
+
+	nodeA := bpv7.MustNewEndpointID("dtn://node-a/")
+	nodeB := bpv7.MustNewEndpointID("dtn://node-b/")
+
+	// This node is B, receiving a bundle from A and forwarding it back to A as its only peer
+	setupTestNode(t, nodeB)
+
+	listener, err := cla.NewListener(
+		cla.ListenerConfig{Type: cla.Loopback, Address: "correlation-b", EndpointId: nodeB},
+		cla.GetManagerSingleton().NotifyReceive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cla.GetManagerSingleton().RegisterListener(listener); err != nil {
+		t.Fatal(err)
+	}
+
+	receivedA := make(chan *bpv7.Bundle, 1)
+	dialer, err := cla.NewPeer(cla.Loopback, "correlation-b", nodeA, bpv7.DtnNone(),
+		func(bundle *bpv7.Bundle) { receivedA <- bundle })
+	if err != nil {
+		t.Fatal(err)
+	}
+	cla.GetManagerSingleton().Register(dialer)
+
+	// Both the dialer and the Listener's side of the connection are registered as senders
+	for deadline := time.Now().Add(time.Second); len(cla.GetManagerSingleton().GetSenders()) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("Loopback endpoints did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bundle, err := bpv7.Builder().
+		Source(nodeA).
+		Destination("dtn://node-c/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello C")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dialer.(cla.ConvergenceSender).Send(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-receivedA:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Bundle was not forwarded back to node A")
+	}
+
+	// Each stage of the pipeline must have logged under the bundle's correlation ID
+	stages := []string{
+		"Received bundle",
+		"Processing received bundle",
+		"Processing bundle",
+		"Sending bundle to a CLA (ConvergenceSender)",
+		"Sending bundle via loopback",
+		"Sending bundle succeeded",
+	}
+	correlationID := bundle.ID().String()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		logged := make(map[string]bool)
+		for _, entry := range hook.AllEntries() {
+			if entry.Data[util.CorrelationField] == correlationID {
+				logged[entry.Message] = true
+			}
+		}
+
+		var missing []string
+		for _, stage := range stages {
+			if !logged[stage] {
+				missing = append(missing, stage)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("No log entries with correlation ID %s for %v", correlationID, missing)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Let the forwarding finish before tearing down the store and the CLAs
+	for deadline = time.Now().Add(time.Second); ; {
+		bd, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bd.Retain {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Bundle is still retained by %v", bd.RetentionConstraints)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := dialer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// inboxAgent is an ApplicationAgent accepting bundles for its endpoint.
+type inboxAgent struct {
+	endpoint bpv7.EndpointID
+}
+
+func (ia *inboxAgent) Endpoints() []bpv7.EndpointID            { return []bpv7.EndpointID{ia.endpoint} }
+func (ia *inboxAgent) Deliver(_ *store.BundleDescriptor) error { return nil }
+func (ia *inboxAgent) Shutdown()                               {}
+
+func TestIngestBundle(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	inbox := bpv7.MustNewEndpointID("dtn://node/inbox")
+	if err := application_agent.GetManagerSingleton().RegisterAgent(&inboxAgent{endpoint: inbox}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		destination bpv7.EndpointID
+		expired     bool
+		future      bool
+		outcome     Outcome
+	}{
+		{"deliverable", inbox, false, false, OutcomeStored | OutcomeDelivered | OutcomeForwarding},
+		{"forwardable", bpv7.MustNewEndpointID("dtn://other/"), false, false, OutcomeStored | OutcomeForwarding},
+		{"expired", inbox, true, false, OutcomeDropped},
+		{"future", inbox, false, true, OutcomeDropped},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bundle, err := bpv7.Builder().
+				Source("dtn://src/").
+				Destination(test.destination).
+				CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+				Lifetime("10m").
+				PayloadBlock([]byte(test.name)).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expired {
+				// The builder refuses to create expired bundles
+				bundle.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(
+					bpv7.DtnTimeFromTime(time.Now().Add(-time.Hour)), 0)
+			} else if test.future {
+				bundle.PrimaryBlock.CreationTimestamp = bpv7.NewCreationTimestamp(
+					bpv7.DtnTimeFromTime(time.Now().Add(time.Hour)), 0)
+			}
+
+			outcome, err := IngestBundle(&bundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome != test.outcome {
+				t.Fatalf("Expected outcome %v, got %v", test.outcome, outcome)
+			}
+
+			_, err = store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+			if stored := err == nil; stored != outcome.Has(OutcomeStored) {
+				t.Fatalf("Bundle stored is %t for outcome %v", stored, outcome)
+			}
+		})
+	}
+
+	// Let the forwarding of the stored bundles finish before tearing down the store
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestReceiveBundleFrom(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	peerID := bpv7.MustNewEndpointID("dtn://peer/")
+	setupTestNode(t, nodeID)
+
+	cla.GetManagerSingleton().SetReceiveFromCallback(ReceiveBundleFrom)
+
+	discard := func(bpv7.Bundle) (interface{}, error) { return nil, nil }
+	local, remote := dummy_cla.NewDummyCLAPair(nodeID, peerID, discard)
+	if err := cla.GetManagerSingleton().RegisterSync(local); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Activate(); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := bpv7.Builder().
+		Source(peerID).
+		Destination("dtn://node/inbox").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello node")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Send(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []*store.BundleDescriptor
+	for deadline := time.Now().Add(time.Second); len(received) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Bundle was not recorded as received from the peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if received, err = store.GetStoreSingleton().GetReceivedFrom(peerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(received) != 1 || received[0].ID != bundle.ID() {
+		t.Fatalf("Expected only bundle %v to be received from %v, got %v", bundle.ID(), peerID, received)
+	}
+	if received[0].ReceivedFrom != peerID {
+		t.Fatalf("Expected bundle to be received from %v, got %v", peerID, received[0].ReceivedFrom)
+	}
+
+	// Let the forwarding of the bundle finish before tearing down the store
+	time.Sleep(100 * time.Millisecond)
+}
@@ -0,0 +1,54 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestRecordRoute(t *testing.T) {
+	defer SetOwnNodeID(ownNodeID)
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://a/app").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		RouteRecordBlock(8).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route := []bpv7.EndpointID{
+		bpv7.MustNewEndpointID("dtn://a/"),
+		bpv7.MustNewEndpointID("dtn://b/"),
+		bpv7.MustNewEndpointID("dtn://c/"),
+	}
+
+	// Each node records itself before forwarding the bundle, which is serialised for its transmission
+	for _, node := range route {
+		SetOwnNodeID(node)
+		recordRoute(context.Background(), &bundle)
+
+		var buff bytes.Buffer
+		if err := bundle.MarshalCbor(&buff); err != nil {
+			t.Fatal(err)
+		}
+		if bundle, err = bpv7.ParseBundle(&buff); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	routeRecordBlock, err := bundle.ExtensionBlock(bpv7.ExtBlockTypeRouteRecordBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded := routeRecordBlock.Value.(*bpv7.RouteRecordBlock).Route; !reflect.DeepEqual(recorded, route) {
+		t.Fatalf("Recorded route %v, expected %v", recorded, route)
+	}
+}
@@ -0,0 +1,77 @@
+package processing
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+// processUnknownBlocks handles the extension blocks this node cannot process, as described in RFC 9171 section 5.6,
+// step 4. It returns false if the bundle must be deleted.
+//
+// Depending on each block's processing control flags, a reception status report is sent, the bundle is deleted, or
+// the block is removed. Otherwise, the block is kept unaltered and forwarded with the bundle. Deleting the bundle
+// takes precedence over removing the block. Status reports are passed to send, at most one of each kind per bundle.
+func processUnknownBlocks(ctx context.Context, bundle *bpv7.Bundle, send func(*bpv7.Bundle)) bool {
+	var (
+		removeBlocks []uint64
+		reported     bool
+	)
+	for _, cb := range bundle.UnknownBlocks() {
+		logger := util.LogEntry(ctx).WithFields(log.Fields{
+			"block number": cb.BlockNumber,
+			"block type":   cb.TypeCode(),
+			"flags":        cb.BlockControlFlags.Strings(),
+		})
+
+		if cb.BlockControlFlags.Has(bpv7.StatusReportBlock) && !reported {
+			if report, ok := blockUnsupportedReport(*bundle); ok {
+				send(report)
+			}
+			reported = true
+		}
+
+		if cb.BlockControlFlags.Has(bpv7.DeleteBundle) {
+			logger.Info("Dropping received bundle with an unsupported block requesting its deletion")
+			if report, ok := deletionReport(*bundle, bpv7.BlockUnsupported); ok {
+				send(report)
+			}
+			return false
+		} else if cb.BlockControlFlags.Has(bpv7.RemoveBlock) {
+			logger.Debug("Removing unsupported block from received bundle")
+			removeBlocks = append(removeBlocks, cb.BlockNumber)
+		} else {
+			logger.Debug("Keeping unsupported block of received bundle")
+		}
+	}
+
+	for _, blockNumber := range removeBlocks {
+		bundle.RemoveExtensionBlockByBlockNumber(blockNumber)
+	}
+	return true
+}
+
+// blockUnsupportedReport builds a reception status report for a bundle with an unsupported block requesting it.
+func blockUnsupportedReport(bundle bpv7.Bundle) (*bpv7.Bundle, bool) {
+	primary := bundle.PrimaryBlock
+	if primary.BundleControlFlags.Has(bpv7.AdministrativeRecordPayload) || primary.ReportTo == bpv7.DtnNone() {
+		return nil, false
+	}
+
+	report, err := bpv7.Builder().
+		Source(ownNodeID).
+		Destination(primary.ReportTo).
+		CreationTimestampNow().
+		Lifetime("24h").
+		StatusReport(bundle, bpv7.ReceivedBundle, bpv7.BlockUnsupported).
+		Build()
+	if err != nil {
+		util.LogEntry(bundleContext(bundle.ID().String())).WithError(err).Error(
+			"Error creating status report for an unsupported block")
+		return nil, false
+	}
+	return &report, true
+}
@@ -0,0 +1,239 @@
+package processing
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+func TestUnknownBlocks(t *testing.T) {
+	setupTestNode(t, bpv7.MustNewEndpointID("dtn://node/"))
+
+	const unknownType = 250
+	if bpv7.GetExtensionBlockManager().IsKnown(unknownType) {
+		t.Fatalf("Block type code %d is known", unknownType)
+	}
+
+	// received parses a bundle with an unknown block from its wire format, as a CLA would do
+	received := func(t *testing.T, source string, flags bpv7.BlockControlFlags) (bpv7.Bundle, []byte) {
+		bundle, err := bpv7.Builder().
+			Source(source).
+			Destination("dtn://dst/").
+			ReportTo("dtn://report/").
+			BundleCtrlFlags(bpv7.StatusRequestDeletion).
+			CreationTimestampNow().
+			Lifetime("10m").
+			Canonical(bpv7.NewGenericExtensionBlock([]byte{0x23, 0x42}, unknownType), flags).
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buff := new(bytes.Buffer)
+		if err := bundle.MarshalCbor(buff); err != nil {
+			t.Fatal(err)
+		}
+		data := buff.Bytes()
+
+		parsed, err := bpv7.ParseBundle(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed, data
+	}
+
+	t.Run("relayed", func(t *testing.T) {
+		bundle, data := received(t, "dtn://relayed/", bpv7.ReplicateBlock)
+		if outcome, err := IngestBundle(&bundle); err != nil || !outcome.Has(OutcomeStored) {
+			t.Fatalf("Bundle was %v: %v", outcome, err)
+		}
+
+		bd, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := bd.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sender := newTestSender("dtn://next-hop/")
+		job := &forwardingJob{ctx: bundleContext(bd.IDString), descriptor: bd, bundle: stored}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		forwardBundlesToPeer(sender, []*forwardingJob{job}, &wg)
+		wg.Wait()
+
+		sent := sender.sentBundles()
+		if len(sent) != 1 {
+			t.Fatalf("%d bundles were sent", len(sent))
+		}
+		buff := new(bytes.Buffer)
+		if err := sent[0].MarshalCbor(buff); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buff.Bytes(), data) {
+			t.Fatalf("Relayed bundle differs: %x != %x", buff.Bytes(), data)
+		}
+	})
+
+	t.Run("removed block", func(t *testing.T) {
+		bundle, _ := received(t, "dtn://removed/", bpv7.RemoveBlock)
+		if outcome, err := IngestBundle(&bundle); err != nil || !outcome.Has(OutcomeStored) {
+			t.Fatalf("Bundle was %v: %v", outcome, err)
+		}
+
+		bd, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := bd.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.HasExtensionBlock(unknownType) {
+			t.Fatal("Unknown block flagged for removal was stored")
+		}
+	})
+
+	t.Run("deleted bundle", func(t *testing.T) {
+		bundle, _ := received(t, "dtn://deleted/", bpv7.DeleteBundle|bpv7.RemoveBlock)
+		if outcome, err := IngestBundle(&bundle); err != nil || outcome != OutcomeDropped {
+			t.Fatalf("Bundle was %v: %v", outcome, err)
+		}
+		if _, err := store.GetStoreSingleton().LoadBundleDescriptor(bundle.ID()); err == nil {
+			t.Fatal("Bundle flagged for deletion was stored")
+		}
+
+		// The deletion report is received in the background
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+			bds, err := store.GetStoreSingleton().GetDispatchable()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reported := false
+			for _, bd := range bds {
+				reported = reported || bd.Destination == bpv7.MustNewEndpointID("dtn://report/")
+			}
+			if reported {
+				break
+			} else if time.Now().After(deadline) {
+				t.Fatal("No deletion report was sent")
+			}
+		}
+	})
+
+	// Let the forwarding of the stored bundles finish before tearing down the store
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestProcessUnknownBlocksFlags(t *testing.T) {
+	SetOwnNodeID(bpv7.MustNewEndpointID("dtn://node/"))
+
+	const unknownType = 250
+	for _, reportFlag := range []bool{false, true} {
+		for _, deleteFlag := range []bool{false, true} {
+			for _, removeFlag := range []bool{false, true} {
+				var flags bpv7.BlockControlFlags
+				if reportFlag {
+					flags |= bpv7.StatusReportBlock
+				}
+				if deleteFlag {
+					flags |= bpv7.DeleteBundle
+				}
+				if removeFlag {
+					flags |= bpv7.RemoveBlock
+				}
+
+				t.Run(fmt.Sprintf("%v", flags.Strings()), func(t *testing.T) {
+					bundle, err := bpv7.Builder().
+						Source("dtn://src/").
+						Destination("dtn://dst/").
+						ReportTo("dtn://report/").
+						BundleCtrlFlags(bpv7.StatusRequestDeletion).
+						CreationTimestampNow().
+						Lifetime("10m").
+						HopCountBlock(64).
+						Canonical(bpv7.NewGenericExtensionBlock([]byte{0x23}, unknownType), flags).
+						PayloadBlock([]byte("hello world")).
+						Build()
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					var reports []bpv7.StatusInformationPos
+					send := func(report *bpv7.Bundle) {
+						ar, err := report.AdministrativeRecord()
+						if err != nil {
+							t.Fatal(err)
+						}
+						statusReport := ar.(*bpv7.StatusReport)
+						if statusReport.ReportReason != bpv7.BlockUnsupported {
+							t.Fatalf("Report reason is %v", statusReport.ReportReason)
+						}
+						reports = append(reports, statusReport.StatusInformations()...)
+					}
+					keep := processUnknownBlocks(bundleContext(bundle.ID().String()), &bundle, send)
+
+					if keep != !deleteFlag {
+						t.Fatalf("Bundle kept: %t", keep)
+					}
+					if keep && bundle.HasExtensionBlock(unknownType) == removeFlag {
+						t.Fatalf("Unknown block kept: %t", bundle.HasExtensionBlock(unknownType))
+					}
+					if !bundle.HasExtensionBlock(bpv7.ExtBlockTypeHopCountBlock) {
+						t.Fatal("Known block was removed")
+					}
+
+					var expected []bpv7.StatusInformationPos
+					if reportFlag {
+						expected = append(expected, bpv7.ReceivedBundle)
+					}
+					if deleteFlag {
+						expected = append(expected, bpv7.DeletedBundle)
+					}
+					if !slices.Equal(reports, expected) {
+						t.Fatalf("Reports %v, expected %v", reports, expected)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestProcessUnknownBlocksSingleReport(t *testing.T) {
+	SetOwnNodeID(bpv7.MustNewEndpointID("dtn://node/"))
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		ReportTo("dtn://report/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		Canonical(bpv7.NewGenericExtensionBlock([]byte{0x23}, 250), bpv7.StatusReportBlock|bpv7.RemoveBlock).
+		Canonical(bpv7.NewGenericExtensionBlock([]byte{0x42}, 251), bpv7.StatusReportBlock).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports := 0
+	if !processUnknownBlocks(bundleContext(bundle.ID().String()), &bundle, func(*bpv7.Bundle) { reports++ }) {
+		t.Fatal("Bundle was not kept")
+	}
+	if reports != 1 {
+		t.Fatalf("%d reports were sent", reports)
+	}
+	if bundle.HasExtensionBlock(250) || !bundle.HasExtensionBlock(251) {
+		t.Fatal("Wrong unknown blocks were removed")
+	}
+}
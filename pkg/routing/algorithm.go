@@ -16,12 +16,15 @@ type AlgorithmEnum int
 
 const (
 	Epidemic AlgorithmEnum = iota
+	RecentlyActive
 )
 
 func AlgorithmEnumFromString(name string) (AlgorithmEnum, error) {
 	switch name = strings.ToLower(name); name {
 	case "epidemic":
 		return Epidemic, nil
+	case "recently_active":
+		return RecentlyActive, nil
 	default:
 		return 0, fmt.Errorf("%s is not a valid algorithm name", name)
 	}
@@ -46,6 +49,11 @@ type Algorithm interface {
 	NotifyPeerDisappeared(peer bpv7.EndpointID)
 }
 
+// shutdowner is implemented by Algorithms holding state which must be flushed before the algorithm is replaced.
+type shutdowner interface {
+	Shutdown()
+}
+
 var algorithmSingleton Algorithm
 
 type NoSuchAlgorithmError AlgorithmEnum
@@ -59,26 +67,63 @@ func NewNoSuchAlgorithmError(algorithm AlgorithmEnum) *NoSuchAlgorithmError {
 	return &err
 }
 
-func InitialiseAlgorithm(algorithm AlgorithmEnum) error {
+// InitialiseAlgorithm initialises the routing algorithm singleton.
+// If filter is not nil, it removes denied peers from the algorithm's selection.
+func InitialiseAlgorithm(algorithm AlgorithmEnum, filter *ForwardingFilter) error {
 	if algorithmSingleton != nil {
 		return util.NewAlreadyInitialisedError("Routing Algorithm")
 	}
 
-	if algorithm == Epidemic {
-		algorithmSingleton = NewEpidemicRouting()
-		return nil
+	var algo Algorithm
+	switch algorithm {
+	case Epidemic:
+		algo = NewEpidemicRouting()
+	case RecentlyActive:
+		algo = NewRecentlyActiveRouting()
+	default:
+		return NewNoSuchAlgorithmError(algorithm)
 	}
 
-	return NewNoSuchAlgorithmError(algorithm)
+	if filter != nil {
+		algo = &filteredAlgorithm{Algorithm: algo, filter: filter}
+	}
+	algorithmSingleton = algo
+	return nil
+}
+
+// ShutdownAlgorithm shuts the routing algorithm singleton down, allowing another one to be initialised.
+// Algorithms having a Shutdown method get the chance to flush their state before being dropped.
+func ShutdownAlgorithm() error {
+	if algorithmSingleton == nil {
+		return util.NewNotInitialisedError("routing algorithm")
+	}
+
+	if algo, ok := algorithmSingleton.(shutdowner); ok {
+		algo.Shutdown()
+	}
+
+	log.WithField("algorithm", algorithmSingleton).Info("Shut down routing algorithm")
+	algorithmSingleton = nil
+	return nil
+}
+
+// LookupAlgorithmSingleton returns the routing algorithm singleton-instance or a util.NotInitialised-error.
+func LookupAlgorithmSingleton() (Algorithm, error) {
+	if algorithmSingleton == nil {
+		return nil, util.NewNotInitialisedError("routing algorithm")
+	}
+	return algorithmSingleton, nil
 }
 
 // GetAlgorithmSingleton returns the routing algorithm singleton-instance.
-// Attempting to call this function before algorithm initialisation will cause the program to panic.
+// Attempting to call this function before algorithm initialisation will cause the program to panic; see
+// LookupAlgorithmSingleton.
 func GetAlgorithmSingleton() Algorithm {
-	if algorithmSingleton == nil {
+	algorithm, err := LookupAlgorithmSingleton()
+	if err != nil {
 		log.Fatalf("Attempting to access an uninitialised manager. This must never happen!")
 	}
-	return algorithmSingleton
+	return algorithm
 }
 
 // filterCLAs filters the nodes which already received a Bundle.
@@ -92,7 +137,7 @@ func filterCLAs(bundleDescriptor *store.BundleDescriptor, clas []cla.Convergence
 		skip := false
 
 		for _, eid := range sentEids {
-			if cs.GetPeerEndpointID() == eid {
+			if cs.GetPeerEndpointID().SameNode(eid) {
 				skip = true
 				break
 			}
@@ -105,3 +150,27 @@ func filterCLAs(bundleDescriptor *store.BundleDescriptor, clas []cla.Convergence
 
 	return
 }
+
+// FilterPreviousNode removes all ConvergenceSenders leading back to the node from which the Bundle was received.
+//
+// Nodes are matched based on their node ID, i.e., a sender for "dtn://a/foo" is dropped for a previous node "dtn://a/".
+func FilterPreviousNode(bundleDescriptor *store.BundleDescriptor, clas []cla.ConvergenceSender) (filtered []cla.ConvergenceSender) {
+	previousNode := bundleDescriptor.PreviousNode
+	if previousNode.EndpointType == nil || previousNode == bpv7.DtnNone() {
+		return clas
+	}
+
+	filtered = make([]cla.ConvergenceSender, 0, len(clas))
+	for _, cs := range clas {
+		if cs.GetPeerEndpointID().SameNode(previousNode) {
+			log.WithFields(log.Fields{
+				"bundle": bundleDescriptor.ID,
+				"peer":   cs.GetPeerEndpointID(),
+			}).Debug("Refusing to forward bundle back to its previous node")
+			continue
+		}
+		filtered = append(filtered, cs)
+	}
+
+	return
+}
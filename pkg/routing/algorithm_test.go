@@ -0,0 +1,113 @@
+package routing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+	"github.com/dtn7/dtn7-go/pkg/util"
+)
+
+func TestFilterPreviousNode(t *testing.T) {
+	// Node B received a bundle from node A, whose PreviousNodeBlock names A's node ID,
+	// while B's CLA to A reports an endpoint with a demux part.
+	nodeA := bpv7.MustNewEndpointID("dtn://a/")
+	descriptor := &store.BundleDescriptor{
+		AlreadySentTo: []bpv7.EndpointID{bpv7.MustNewEndpointID("dtn://b/"), nodeA},
+		PreviousNode:  nodeA,
+	}
+
+	senders := []cla.ConvergenceSender{
+		&testSender{address: "a", peer: bpv7.MustNewEndpointID("dtn://a/incoming")},
+		&testSender{address: "c", peer: bpv7.MustNewEndpointID("dtn://c/")},
+	}
+
+	tests := []struct {
+		name   string
+		filter func(*store.BundleDescriptor, []cla.ConvergenceSender) []cla.ConvergenceSender
+	}{
+		{"FilterPreviousNode", FilterPreviousNode},
+		{"filterCLAs", filterCLAs},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if addrs := addresses(test.filter(descriptor, senders)); !reflect.DeepEqual(addrs, []string{"c"}) {
+				t.Fatalf("expected only the sender to c, got %v", addrs)
+			}
+		})
+	}
+}
+
+func TestFilterPreviousNodeLocalBundle(t *testing.T) {
+	senders := []cla.ConvergenceSender{
+		&testSender{address: "a", peer: bpv7.MustNewEndpointID("dtn://a/")},
+	}
+
+	if addrs := addresses(FilterPreviousNode(&store.BundleDescriptor{}, senders)); !reflect.DeepEqual(addrs, []string{"a"}) {
+		t.Fatalf("locally created bundle must not be filtered, got %v", addrs)
+	}
+}
+
+func TestLookupAlgorithmSingleton(t *testing.T) {
+	defer func(algorithm Algorithm) { algorithmSingleton = algorithm }(algorithmSingleton)
+	algorithmSingleton = nil
+
+	algorithm, err := LookupAlgorithmSingleton()
+	if algorithm != nil {
+		t.Fatalf("Expected no algorithm, got %v", algorithm)
+	}
+	var notInitialised *util.NotInitialised
+	if !errors.As(err, &notInitialised) {
+		t.Fatalf("Expected NotInitialised error, got %v", err)
+	}
+}
+
+// shutdownRecorder is an Algorithm recording its shutdown.
+type shutdownRecorder struct {
+	*EpidemicRouting
+	shutdown bool
+}
+
+func (sr *shutdownRecorder) Shutdown() {
+	sr.shutdown = true
+}
+
+func TestShutdownAlgorithm(t *testing.T) {
+	defer func(algorithm Algorithm) { algorithmSingleton = algorithm }(algorithmSingleton)
+	algorithmSingleton = nil
+
+	if err := InitialiseAlgorithm(Epidemic, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ShutdownAlgorithm(); err != nil {
+		t.Fatal(err)
+	}
+	var notInitialised *util.NotInitialised
+	if err := ShutdownAlgorithm(); !errors.As(err, &notInitialised) {
+		t.Fatalf("Expected NotInitialised error, got %v", err)
+	}
+
+	filter, err := NewForwardingFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InitialiseAlgorithm(Epidemic, filter); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetAlgorithmSingleton().(*filteredAlgorithm); !ok {
+		t.Fatalf("Re-initialised algorithm is not filtered: %v", GetAlgorithmSingleton())
+	}
+
+	// A wrapped algorithm's state must be flushed, too.
+	recorder := &shutdownRecorder{EpidemicRouting: NewEpidemicRouting()}
+	algorithmSingleton = &filteredAlgorithm{Algorithm: recorder, filter: filter}
+	if err := ShutdownAlgorithm(); err != nil {
+		t.Fatal(err)
+	}
+	if !recorder.shutdown {
+		t.Fatal("Algorithm was not shut down")
+	}
+}
@@ -0,0 +1,65 @@
+package routing
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/cla/dummy_cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+func TestEpidemicSelectPeers(t *testing.T) {
+	discard := func(bpv7.Bundle) (interface{}, error) { return nil, nil }
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	// This node has one dummy CLA to each of A and B and two to C, which are registered as senders
+	peers := map[string]string{
+		"dtn://node-to-a/":  "dtn://a/",
+		"dtn://node-to-b/":  "dtn://b/",
+		"dtn://node-to-c/":  "dtn://c/",
+		"dtn://node-to-c2/": "dtn://c/",
+	}
+	for own, peer := range peers {
+		local, remote := dummy_cla.NewDummyCLAPair(bpv7.MustNewEndpointID(own), bpv7.MustNewEndpointID(peer), discard)
+		if err := cla.GetManagerSingleton().RegisterSync(local); err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Activate(); err != nil {
+			t.Fatal(err)
+		}
+		defer remote.Close()
+	}
+
+	tests := []struct {
+		name          string
+		alreadySentTo []bpv7.EndpointID
+		peers         []string
+	}{
+		{"new bundle", nil, []string{"dtn://a/", "dtn://b/", "dtn://c/"}},
+		{"received from A", []bpv7.EndpointID{bpv7.MustNewEndpointID("dtn://a/")}, []string{"dtn://b/", "dtn://c/"}},
+		{"sent to B and C", []bpv7.EndpointID{bpv7.MustNewEndpointID("dtn://b/"), bpv7.MustNewEndpointID("dtn://c/app")},
+			[]string{"dtn://a/"}},
+	}
+
+	algorithm := NewEpidemicRouting()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var selected []string
+			for _, sender := range algorithm.SelectPeersForForwarding(&store.BundleDescriptor{AlreadySentTo: test.alreadySentTo}) {
+				selected = append(selected, sender.GetPeerEndpointID().String())
+			}
+			sort.Strings(selected)
+
+			if !reflect.DeepEqual(selected, test.peers) {
+				t.Fatalf("Expected peers %v, got %v", test.peers, selected)
+			}
+		})
+	}
+}
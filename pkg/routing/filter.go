@@ -0,0 +1,142 @@
+package routing
+
+import (
+	"fmt"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+// ForwardingRule denies forwarding bundles over some CLAs. The rule applies if all of its set fields match.
+type ForwardingRule struct {
+	// Source is a regular expression, which must match the whole source node ID.
+	Source string
+	// Destination is a regular expression, which must match the whole destination endpoint ID.
+	Destination string
+	// CLATypes restricts this rule to CLAs of these types. CLAs of an unknown type are never matched.
+	CLATypes []cla.CLAType
+}
+
+// forwardingRule is the compiled version of a ForwardingRule.
+type forwardingRule struct {
+	source      *regexp.Regexp
+	destination *regexp.Regexp
+	claTypes    map[cla.CLAType]bool
+}
+
+// ForwardingFilter removes ConvergenceSenders from a routing Algorithm's selection based on ForwardingRules.
+type ForwardingFilter struct {
+	rules []forwardingRule
+}
+
+// NewForwardingFilter compiles the ForwardingRules into a ForwardingFilter.
+func NewForwardingFilter(rules []ForwardingRule) (*ForwardingFilter, error) {
+	filter := &ForwardingFilter{rules: make([]forwardingRule, 0, len(rules))}
+
+	for i, rule := range rules {
+		if rule.Source == "" && rule.Destination == "" && len(rule.CLATypes) == 0 {
+			return nil, fmt.Errorf("forwarding rule %d has no condition and would deny everything", i)
+		}
+
+		compiled := forwardingRule{}
+
+		var err error
+		if compiled.source, err = compileEndpointPattern(rule.Source); err != nil {
+			return nil, fmt.Errorf("forwarding rule %d has an invalid source: %w", i, err)
+		}
+		if compiled.destination, err = compileEndpointPattern(rule.Destination); err != nil {
+			return nil, fmt.Errorf("forwarding rule %d has an invalid destination: %w", i, err)
+		}
+
+		if len(rule.CLATypes) > 0 {
+			compiled.claTypes = make(map[cla.CLAType]bool, len(rule.CLATypes))
+			for _, claType := range rule.CLATypes {
+				if err := claType.CheckValid(); err != nil {
+					return nil, fmt.Errorf("forwarding rule %d has an invalid CLA type %d: %w", i, claType, err)
+				}
+				compiled.claTypes[claType] = true
+			}
+		}
+
+		filter.rules = append(filter.rules, compiled)
+	}
+
+	return filter, nil
+}
+
+// compileEndpointPattern compiles a regular expression to match a whole endpoint ID; nil for an empty pattern.
+func compileEndpointPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// matches checks if this rule denies sending the bundle over the ConvergenceSender.
+func (rule forwardingRule) matches(source, destination bpv7.EndpointID, sender cla.ConvergenceSender) bool {
+	if rule.source != nil && !rule.source.MatchString(source.String()) {
+		return false
+	}
+	if rule.destination != nil && !rule.destination.MatchString(destination.String()) {
+		return false
+	}
+	if rule.claTypes != nil {
+		claType, ok := cla.TypeOf(sender)
+		if !ok || !rule.claTypes[claType] {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter removes all ConvergenceSenders which must not be used to forward the bundle.
+func (filter *ForwardingFilter) Filter(descriptor *store.BundleDescriptor, senders []cla.ConvergenceSender) []cla.ConvergenceSender {
+	if filter == nil || len(filter.rules) == 0 {
+		return senders
+	}
+
+	allowed := make([]cla.ConvergenceSender, 0, len(senders))
+	for _, sender := range senders {
+		denied := false
+		for _, rule := range filter.rules {
+			if rule.matches(descriptor.Source, descriptor.Destination, sender) {
+				denied = true
+				break
+			}
+		}
+
+		if denied {
+			log.WithFields(log.Fields{
+				"bundle": descriptor.ID,
+				"peer":   sender.Address(),
+			}).Debug("Forwarding rule denies sending bundle to peer")
+		} else {
+			allowed = append(allowed, sender)
+		}
+	}
+	return allowed
+}
+
+// filteredAlgorithm applies a ForwardingFilter to another Algorithm's selected peers.
+type filteredAlgorithm struct {
+	Algorithm
+	filter *ForwardingFilter
+}
+
+func (fa *filteredAlgorithm) SelectPeersForForwarding(descriptor *store.BundleDescriptor) []cla.ConvergenceSender {
+	return fa.filter.Filter(descriptor, fa.Algorithm.SelectPeersForForwarding(descriptor))
+}
+
+func (fa *filteredAlgorithm) Shutdown() {
+	if algo, ok := fa.Algorithm.(shutdowner); ok {
+		algo.Shutdown()
+	}
+}
+
+func (fa *filteredAlgorithm) String() string {
+	return fmt.Sprintf("%v", fa.Algorithm)
+}
@@ -0,0 +1,112 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+// testSender is a typed ConvergenceSender reporting a fixed last activity, which cannot send anything.
+type testSender struct {
+	address      string
+	claType      cla.CLAType
+	peer         bpv7.EndpointID
+	lastActivity time.Time
+}
+
+func (ts *testSender) Close() error                       { return nil }
+func (ts *testSender) Activate() error                    { return nil }
+func (ts *testSender) Active() bool                       { return true }
+func (ts *testSender) Address() string                    { return ts.address }
+func (ts *testSender) Type() cla.CLAType                  { return ts.claType }
+func (ts *testSender) Send(bpv7.Bundle) error             { return nil }
+func (ts *testSender) GetPeerEndpointID() bpv7.EndpointID { return ts.peer }
+func (ts *testSender) String() string                     { return ts.address }
+func (ts *testSender) Traffic() cla.Traffic               { return cla.Traffic{LastActivity: ts.lastActivity} }
+
+// testAlgorithm selects all of its senders.
+type testAlgorithm struct {
+	senders []cla.ConvergenceSender
+}
+
+func (ta *testAlgorithm) NotifyNewBundle(*store.BundleDescriptor) {}
+func (ta *testAlgorithm) SelectPeersForForwarding(*store.BundleDescriptor) []cla.ConvergenceSender {
+	return ta.senders
+}
+func (ta *testAlgorithm) NotifyPeerAppeared(bpv7.EndpointID)    {}
+func (ta *testAlgorithm) NotifyPeerDisappeared(bpv7.EndpointID) {}
+
+func addresses(senders []cla.ConvergenceSender) (addrs []string) {
+	for _, sender := range senders {
+		addrs = append(addrs, sender.Address())
+	}
+	return
+}
+
+func TestForwardingFilter(t *testing.T) {
+	filter, err := NewForwardingFilter([]ForwardingRule{
+		{Destination: `ipn:9\..*`, CLATypes: []cla.CLAType{cla.QUICL}},
+		{Source: "dtn://spammer/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	senders := []cla.ConvergenceSender{
+		&testSender{address: "satellite", claType: cla.QUICL, peer: bpv7.MustNewEndpointID("dtn://sat/")},
+		&testSender{address: "cable", claType: cla.MTCP, peer: bpv7.MustNewEndpointID("dtn://cable/")},
+	}
+	algorithm := &filteredAlgorithm{Algorithm: &testAlgorithm{senders: senders}, filter: filter}
+
+	tests := []struct {
+		source      string
+		destination string
+		addresses   []string
+	}{
+		{"dtn://src/", "ipn:9.1", []string{"cable"}},
+		{"dtn://src/", "ipn:9.23", []string{"cable"}},
+		{"dtn://src/", "ipn:90.1", []string{"satellite", "cable"}},
+		{"dtn://src/", "dtn://dst/", []string{"satellite", "cable"}},
+		{"dtn://spammer/", "dtn://dst/", nil},
+	}
+
+	for _, test := range tests {
+		descriptor := &store.BundleDescriptor{
+			Source:      bpv7.MustNewEndpointID(test.source),
+			Destination: bpv7.MustNewEndpointID(test.destination),
+		}
+
+		addrs := addresses(algorithm.SelectPeersForForwarding(descriptor))
+		if len(addrs) != len(test.addresses) {
+			t.Fatalf("Bundle from %s to %s selected %v, expected %v", test.source, test.destination, addrs, test.addresses)
+		}
+		for i := range addrs {
+			if addrs[i] != test.addresses[i] {
+				t.Fatalf("Bundle from %s to %s selected %v, expected %v", test.source, test.destination, addrs, test.addresses)
+			}
+		}
+	}
+}
+
+func TestForwardingFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule ForwardingRule
+	}{
+		{"no condition", ForwardingRule{}},
+		{"invalid source", ForwardingRule{Source: "dtn://(foo/"}},
+		{"invalid destination", ForwardingRule{Destination: "ipn:9.[*"}},
+		{"invalid CLA type", ForwardingRule{CLATypes: []cla.CLAType{cla.CLAType(23)}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewForwardingFilter([]ForwardingRule{test.rule}); err == nil {
+				t.Fatal("Invalid rule was accepted")
+			}
+		})
+	}
+}
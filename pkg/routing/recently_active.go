@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+// maxCopies is used by all subsequently created RecentlyActiveRouting algorithms, see SetMaxCopies.
+var maxCopies atomic.Int64
+
+// SetMaxCopies limits the number of peers a bundle is forwarded to per dispatch by all RecentlyActiveRouting
+// algorithms created afterwards. A zero limit forwards to all peers.
+func SetMaxCopies(copies int) {
+	maxCopies.Store(int64(copies))
+}
+
+// RecentlyActiveRouting is an Algorithm flooding bundles like EpidemicRouting, but preferring the peers whose CLAs
+// were active most recently. By limiting the copies per dispatch, fewer bundles are wasted on flaky links, which are
+// left for later dispatches.
+//
+// A CLA's activity is known if it implements cla.TrafficCounting. Peers without any known activity are tried last.
+type RecentlyActiveRouting struct {
+	maxCopies int
+}
+
+// NewRecentlyActiveRouting creates a new RecentlyActiveRouting Algorithm, limited by the current SetMaxCopies.
+func NewRecentlyActiveRouting() *RecentlyActiveRouting {
+	copies := int(maxCopies.Load())
+	log.WithField("max copies", copies).Debug("Initialised recently active routing")
+
+	return &RecentlyActiveRouting{maxCopies: copies}
+}
+
+// lastActivity of a CLA, or the zero time if unknown.
+func lastActivity(sender cla.ConvergenceSender) time.Time {
+	if counting, ok := sender.(cla.TrafficCounting); ok {
+		return counting.Traffic().LastActivity
+	}
+	return time.Time{}
+}
+
+func (rar *RecentlyActiveRouting) NotifyNewBundle(_ *store.BundleDescriptor) {}
+
+func (rar *RecentlyActiveRouting) SelectPeersForForwarding(bp *store.BundleDescriptor) (css []cla.ConvergenceSender) {
+	css = filterCLAs(bp, cla.GetManagerSingleton().GetSenders())
+
+	activity := make(map[cla.ConvergenceSender]time.Time, len(css))
+	for _, sender := range css {
+		activity[sender] = lastActivity(sender)
+	}
+	sort.SliceStable(css, func(i, j int) bool { return activity[css[i]].After(activity[css[j]]) })
+
+	// Keep the most recently active CLA of each peer
+	endpoints := make(map[bpv7.EndpointID]bool)
+	unique := make([]cla.ConvergenceSender, 0, len(css))
+	for _, sender := range css {
+		if !endpoints[sender.GetPeerEndpointID()] {
+			endpoints[sender.GetPeerEndpointID()] = true
+			unique = append(unique, sender)
+		}
+	}
+
+	css = unique
+	if rar.maxCopies > 0 && len(css) > rar.maxCopies {
+		css = css[:rar.maxCopies]
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":        bp.ID,
+		"new receivers": css,
+	}).Debug("RecentlyActiveRouting selected Convergence Senders for an outgoing bundle")
+
+	return
+}
+
+func (_ *RecentlyActiveRouting) NotifyPeerAppeared(_ bpv7.EndpointID) {}
+
+func (_ *RecentlyActiveRouting) NotifyPeerDisappeared(_ bpv7.EndpointID) {}
+
+func (_ *RecentlyActiveRouting) String() string {
+	return "recently active"
+}
@@ -0,0 +1,63 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/cla"
+	"github.com/dtn7/dtn7-go/pkg/store"
+)
+
+func TestRecentlyActiveSelectPeers(t *testing.T) {
+	err := cla.InitialiseCLAManager(func(*bpv7.Bundle) {}, func(bpv7.EndpointID) {}, func(bpv7.EndpointID) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cla.GetManagerSingleton().Shutdown()
+
+	now := time.Now()
+	senders := []*testSender{
+		{address: "stale", peer: bpv7.MustNewEndpointID("dtn://stale/"), lastActivity: now.Add(-time.Hour)},
+		{address: "unknown", peer: bpv7.MustNewEndpointID("dtn://unknown/")},
+		{address: "active", peer: bpv7.MustNewEndpointID("dtn://active/"), lastActivity: now},
+		{address: "recent", peer: bpv7.MustNewEndpointID("dtn://recent/"), lastActivity: now.Add(-time.Minute)},
+		// A second, less active CLA to the active peer must not be selected in addition
+		{address: "active-2", peer: bpv7.MustNewEndpointID("dtn://active/"), lastActivity: now.Add(-2 * time.Hour)},
+	}
+	for _, sender := range senders {
+		if err := cla.GetManagerSingleton().RegisterSync(sender); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		maxCopies     int
+		alreadySentTo []bpv7.EndpointID
+		addresses     []string
+	}{
+		{"unlimited", 0, nil, []string{"active", "recent", "stale", "unknown"}},
+		{"limited", 2, nil, []string{"active", "recent"}},
+		{"limited, sent to active", 2, []bpv7.EndpointID{bpv7.MustNewEndpointID("dtn://active/")},
+			[]string{"recent", "stale"}},
+	}
+
+	defer SetMaxCopies(0)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetMaxCopies(test.maxCopies)
+			algorithm := NewRecentlyActiveRouting()
+
+			var selected []string
+			for _, sender := range algorithm.SelectPeersForForwarding(&store.BundleDescriptor{AlreadySentTo: test.alreadySentTo}) {
+				selected = append(selected, sender.Address())
+			}
+
+			if !reflect.DeepEqual(selected, test.addresses) {
+				t.Fatalf("Expected CLAs %v, got %v", test.addresses, selected)
+			}
+		})
+	}
+}
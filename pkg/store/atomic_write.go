@@ -0,0 +1,102 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tempFilePattern matches the temporary files of serialised bundles, see writeTemp.
+const tempFilePattern = "*.tmp"
+
+// SetTempDirectory sets the directory serialised bundles are written to before being moved into the store. It must be
+// on the same file system as the store, which is checked by moving a probe file. By default, the store's "tmp"
+// subdirectory is used.
+//
+// Leftovers of interrupted writes in this directory are removed.
+func (bst *BundleStore) SetTempDirectory(path string) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(path, "probe-"+tempFilePattern)
+	if err != nil {
+		return err
+	}
+	_ = probe.Close()
+
+	probeTarget := filepath.Join(bst.bundleDirectory, filepath.Base(probe.Name()))
+	if err := os.Rename(probe.Name(), probeTarget); err != nil {
+		_ = os.Remove(probe.Name())
+		return fmt.Errorf("temp directory %s is not on the store's file system: %w", path, err)
+	}
+	_ = os.Remove(probeTarget)
+
+	bst.tempDirectory = path
+	cleanTempDirectory(path)
+	return nil
+}
+
+// cleanTempDirectory removes the temporary files of writes interrupted, e.g., by a crash.
+func cleanTempDirectory(path string) {
+	leftovers, _ := filepath.Glob(filepath.Join(path, tempFilePattern))
+	for _, leftover := range leftovers {
+		if err := os.Remove(leftover); err != nil {
+			log.WithFields(log.Fields{
+				"file":  leftover,
+				"error": err,
+			}).Warn("Failed to remove temporary file of an interrupted write")
+		}
+	}
+}
+
+// writeSerialised atomically writes a serialised bundle as the bundle directory's file of the given name.
+//
+// The data is written and synced to a temporary file first, which is only renamed into place when complete. Thus,
+// a crash never leaves a partially written bundle behind.
+func (bst *BundleStore) writeSerialised(name string, data []byte) error {
+	tempPath, err := bst.writeTemp(name, data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, filepath.Join(bst.bundleDirectory, name)); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	syncDirectory(bst.bundleDirectory)
+	return nil
+}
+
+// writeTemp writes and syncs the data to a new temporary file for the named bundle file and returns its path.
+func (bst *BundleStore) writeTemp(name string, data []byte) (string, error) {
+	f, err := os.CreateTemp(bst.tempDirectory, name+"-"+tempFilePattern)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// syncDirectory persists a directory's entries, e.g., a renamed file. Errors are ignored, as some platforms do not
+// support syncing directories.
+func syncDirectory(path string) {
+	if dir, err := os.Open(path); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+}
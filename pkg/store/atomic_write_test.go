@@ -0,0 +1,84 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestAtomicWrite(t *testing.T) {
+	path := t.TempDir()
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+
+	bst, err := openStore(nodeID, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock(bytes.Repeat([]byte("hello world"), 100)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buff := new(bytes.Buffer)
+	if err := cboring.Marshal(&bundle, buff); err != nil {
+		t.Fatal(err)
+	}
+	serialisedFileName := fmt.Sprintf("%x", sha256.Sum256([]byte(bundle.ID().String())))
+
+	// A crash in the middle of writing leaves a partial temporary file, which was never renamed into place
+	if _, err := bst.writeTemp(serialisedFileName, buff.Bytes()[:buff.Len()/2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(bst.bundleDirectory, serialisedFileName)); !os.IsNotExist(err) {
+		t.Fatalf("Partially written bundle is visible in the bundle directory: %v", err)
+	}
+	if _, err := bst.LoadBundleDescriptor(bundle.ID()); !errors.Is(err, ErrBundleNotFound) {
+		t.Fatalf("Partially written bundle is known to the store: %v", err)
+	}
+	if err := bst.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening the store after the crash removes the leftover
+	if bst, err = openStore(nodeID, path); err != nil {
+		t.Fatal(err)
+	}
+	defer bst.Close()
+	if leftovers, _ := filepath.Glob(filepath.Join(bst.tempDirectory, tempFilePattern)); len(leftovers) > 0 {
+		t.Fatalf("Temporary files of the interrupted write were not removed: %v", leftovers)
+	}
+
+	// A configured temp directory on the same file system is used instead
+	if err := bst.SetTempDirectory(filepath.Join(path, "other-tmp")); err != nil {
+		t.Fatal(err)
+	}
+
+	bd, err := bst.InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(bst.bundleDirectory, bd.SerialisedFileName))
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, buff.Bytes()) {
+		t.Fatal("Stored bundle differs from the serialised bundle")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(bst.tempDirectory, tempFilePattern)); len(leftovers) > 0 {
+		t.Fatalf("Temporary files were left behind: %v", leftovers)
+	}
+}
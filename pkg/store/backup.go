@@ -0,0 +1,197 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// Suffixes of the archive's entries. Each bundle is archived as a metadata entry directly followed by its bundle entry.
+const (
+	archiveMetadataSuffix = ".json"
+	archiveBundleSuffix   = ".cbor"
+)
+
+// archiveMetadata is the engine-independent part of a BundleDescriptor, stored next to its bundle in an archive.
+// All other fields are derived from the bundle when importing it.
+type archiveMetadata struct {
+	AlreadySentTo        []string     `json:"already_sent_to"`
+	PreviousNode         string       `json:"previous_node,omitempty"`
+	ReceivedFrom         string       `json:"received_from,omitempty"`
+	RetentionConstraints []Constraint `json:"retention_constraints"`
+	Retain               bool         `json:"retain"`
+	Dispatch             bool         `json:"dispatch"`
+	NextDispatch         time.Time    `json:"next_dispatch"`
+	Expires              time.Time    `json:"expires"`
+	ReceivedAt           time.Time    `json:"received_at"`
+	Delivered            bool         `json:"delivered,omitempty"`
+}
+
+// Export writes all stored bundles and their metadata as a tar archive, to be restored by Import.
+//
+// The bundles are written uncompressed, independent of the store's compression setting.
+func (bst *BundleStore) Export(w io.Writer) (exported int, err error) {
+	archive := tar.NewWriter(w)
+
+	err = bst.metadataStore.ForEach(nil, func(bd *BundleDescriptor) error {
+		bundle, err := bst.loadEntireBundle(bd.SerialisedFileName, bd.Compressed)
+		if err != nil {
+			return fmt.Errorf("loading bundle %s failed: %w", bd.IDString, err)
+		}
+
+		bundleBuff := new(bytes.Buffer)
+		if err := cboring.Marshal(bundle, bundleBuff); err != nil {
+			return fmt.Errorf("serialising bundle %s failed: %w", bd.IDString, err)
+		}
+
+		metadata := archiveMetadata{
+			AlreadySentTo:        make([]string, 0, len(bd.AlreadySentTo)),
+			RetentionConstraints: bd.RetentionConstraints,
+			Retain:               bd.Retain,
+			Dispatch:             bd.Dispatch,
+			NextDispatch:         bd.NextDispatch,
+			Expires:              bd.Expires,
+			ReceivedAt:           bd.ReceivedAt,
+			Delivered:            bd.Delivered,
+		}
+		for _, peer := range bd.AlreadySentTo {
+			metadata.AlreadySentTo = append(metadata.AlreadySentTo, peer.String())
+		}
+		if bd.PreviousNode.EndpointType != nil {
+			metadata.PreviousNode = bd.PreviousNode.String()
+		}
+		if bd.ReceivedFrom.EndpointType != nil {
+			metadata.ReceivedFrom = bd.ReceivedFrom.String()
+		}
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("serialising metadata of bundle %s failed: %w", bd.IDString, err)
+		}
+
+		for _, entry := range []struct {
+			suffix string
+			data   []byte
+		}{
+			{archiveMetadataSuffix, metadataBytes},
+			{archiveBundleSuffix, bundleBuff.Bytes()},
+		} {
+			header := &tar.Header{
+				Name:    bd.SerialisedFileName + entry.suffix,
+				Mode:    0600,
+				Size:    int64(len(entry.data)),
+				ModTime: bd.ReceivedAt,
+			}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := archive.Write(entry.data); err != nil {
+				return err
+			}
+		}
+
+		exported++
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = archive.Close()
+	log.WithField("bundles", exported).Info("Exported store")
+	return
+}
+
+// Import restores the bundles of an archive written by Export, keeping their constraints and expiry.
+//
+// Bundles already present in this store are updated with the archived metadata.
+func (bst *BundleStore) Import(r io.Reader) (imported int, err error) {
+	archive := tar.NewReader(r)
+
+	for {
+		metadataHeader, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return imported, err
+		} else if !strings.HasSuffix(metadataHeader.Name, archiveMetadataSuffix) {
+			return imported, fmt.Errorf("archive entry %s is no bundle metadata", metadataHeader.Name)
+		}
+
+		var metadata archiveMetadata
+		if err := json.NewDecoder(archive).Decode(&metadata); err != nil {
+			return imported, fmt.Errorf("parsing archive entry %s failed: %w", metadataHeader.Name, err)
+		}
+
+		name := strings.TrimSuffix(metadataHeader.Name, archiveMetadataSuffix)
+		if bundleHeader, err := archive.Next(); err != nil {
+			return imported, fmt.Errorf("archive lacks the bundle for %s: %w", metadataHeader.Name, err)
+		} else if bundleHeader.Name != name+archiveBundleSuffix {
+			return imported, fmt.Errorf("archive entry %s does not belong to %s", bundleHeader.Name, metadataHeader.Name)
+		}
+
+		bundle, err := bpv7.ParseBundle(archive)
+		if err != nil {
+			return imported, fmt.Errorf("parsing archived bundle %s failed: %w", name, err)
+		}
+
+		if err := bst.importBundle(&bundle, metadata); err != nil {
+			return imported, fmt.Errorf("importing bundle %v failed: %w", bundle.ID(), err)
+		}
+		imported++
+	}
+
+	log.WithField("bundles", imported).Info("Imported store")
+	return imported, nil
+}
+
+// importBundle stores a bundle and replaces its metadata by the archived one.
+func (bst *BundleStore) importBundle(bundle *bpv7.Bundle, metadata archiveMetadata) error {
+	bd, err := bst.InsertBundle(bundle)
+	if err != nil {
+		return err
+	}
+
+	bd.AlreadySentTo = make([]bpv7.EndpointID, 0, len(metadata.AlreadySentTo))
+	for _, peer := range metadata.AlreadySentTo {
+		eid, err := bpv7.NewEndpointID(peer)
+		if err != nil {
+			return err
+		}
+		bd.AlreadySentTo = append(bd.AlreadySentTo, eid)
+	}
+	for _, node := range []struct {
+		field *bpv7.EndpointID
+		value string
+	}{
+		{&bd.PreviousNode, metadata.PreviousNode},
+		{&bd.ReceivedFrom, metadata.ReceivedFrom},
+	} {
+		*node.field = bpv7.EndpointID{}
+		if node.value != "" {
+			if *node.field, err = bpv7.NewEndpointID(node.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	bd.RetentionConstraints = metadata.RetentionConstraints
+	bd.Retain = metadata.Retain
+	bd.Dispatch = metadata.Dispatch
+	bd.NextDispatch = metadata.NextDispatch
+	bd.Expires = metadata.Expires
+	bd.ReceivedAt = metadata.ReceivedAt
+	bd.Delivered = metadata.Delivered
+
+	return bst.updateBundleMetadata(bd)
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestExportImport(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	if err := InitialiseStore(nodeID, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	GetStoreSingleton().SetCompression(true)
+
+	exported := make(map[bpv7.BundleID]*BundleDescriptor)
+	for i := 0; i < 5; i++ {
+		bundle, err := bpv7.Builder().
+			Source("dtn://src/").
+			Destination(fmt.Sprintf("dtn://dst-%d/", i)).
+			CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+			Lifetime("10m").
+			PayloadBlock([]byte(fmt.Sprintf("bundle %d", i))).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 1 {
+			// Received bundles carry the node they were received from
+			err = bundle.AddExtensionBlock(bpv7.NewCanonicalBlock(0, 0, bpv7.NewPreviousNodeBlock(bpv7.MustNewEndpointID("dtn://prev/"))))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		bd, err := GetStoreSingleton().InsertBundle(&bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			if err := bd.AddConstraint(ForwardPending); err != nil {
+				t.Fatal(err)
+			}
+			bd.AddAlreadySent(bpv7.MustNewEndpointID("dtn://peer/"))
+			if err := bd.SetNextDispatch(time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if bd, err = GetStoreSingleton().LoadBundleDescriptor(bundle.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bd.Load(); err != nil {
+			t.Fatal(err)
+		}
+		exported[bd.ID] = bd
+	}
+
+	var archive bytes.Buffer
+	if n, err := GetStoreSingleton().Export(&archive); err != nil {
+		t.Fatal(err)
+	} else if n != len(exported) {
+		t.Fatalf("Exported %d bundles, expected %d", n, len(exported))
+	}
+	if err := GetStoreSingleton().Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitialiseStore(nodeID, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	if n, err := GetStoreSingleton().Import(&archive); err != nil {
+		t.Fatal(err)
+	} else if n != len(exported) {
+		t.Fatalf("Imported %d bundles, expected %d", n, len(exported))
+	}
+
+	for id, expected := range exported {
+		bd, err := GetStoreSingleton().LoadBundleDescriptor(id)
+		if err != nil {
+			t.Fatalf("Bundle %v was not imported: %v", id, err)
+		}
+
+		if bd.Source != expected.Source || bd.Destination != expected.Destination || bd.ReportTo != expected.ReportTo ||
+			bd.PreviousNode != expected.PreviousNode || bd.Retain != expected.Retain || bd.Dispatch != expected.Dispatch {
+			t.Fatalf("Imported descriptor %v differs from %v", bd, expected)
+		}
+		if !slices.Equal(bd.AlreadySentTo, expected.AlreadySentTo) {
+			t.Fatalf("Bundle %v was already sent to %v, expected %v", id, bd.AlreadySentTo, expected.AlreadySentTo)
+		}
+		if !slices.Equal(bd.RetentionConstraints, expected.RetentionConstraints) {
+			t.Fatalf("Bundle %v has constraints %v, expected %v", id, bd.RetentionConstraints, expected.RetentionConstraints)
+		}
+		for _, times := range [][2]time.Time{
+			{bd.NextDispatch, expected.NextDispatch},
+			{bd.Expires, expected.Expires},
+			{bd.ReceivedAt, expected.ReceivedAt},
+		} {
+			if !times[0].Equal(times[1]) {
+				t.Fatalf("Bundle %v has time %v, expected %v", id, times[0], times[1])
+			}
+		}
+
+		bundle, err := bd.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(bundle, *expected.Bundle) {
+			t.Fatalf("Imported bundle %v differs from the exported one", id)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"slices"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -14,10 +15,17 @@ type BundleDescriptor struct {
 	Destination bpv7.EndpointID
 	ReportTo    bpv7.EndpointID
 
-	Bundle *bpv7.Bundle
+	// Bundle is the loaded bundle, never marshalled to JSON to keep its payload out of, e.g., logs
+	Bundle *bpv7.Bundle `json:"-"`
 
 	// node IDs of peers which already have this bundle
 	AlreadySentTo []bpv7.EndpointID
+	// PendingHandoffs are the node IDs of peers which received this bundle, but have not acknowledged it yet
+	PendingHandoffs []bpv7.EndpointID
+	// node ID from the PreviousNodeBlock of the most recent reception, zero-valued for locally created bundles
+	PreviousNode bpv7.EndpointID
+	// ReceivedFrom is the peer reported by the CLA of the most recent reception, zero-valued if unknown
+	ReceivedFrom bpv7.EndpointID
 
 	// RetentionConstraints as defined by RFC9171 Section 5, see constraints.go for possible types
 	RetentionConstraints []Constraint
@@ -28,17 +36,31 @@ type BundleDescriptor struct {
 	Retain bool
 	// should this bundle be dispatched?
 	Dispatch bool
+	// NextDispatch is the earliest time to dispatch this bundle, indexed to efficiently find the due bundles
+	NextDispatch time.Time `badgerhold:"index"`
 	// TTL after which the bundle will be deleted - assuming Retain == false
 	Expires time.Time
+	// time at which this node received or created the bundle, used to calculate its residence time
+	ReceivedAt time.Time
 	// filename of the serialised bundle on-disk
 	SerialisedFileName string
+	// SHA-256 hash of the bundle's file, empty for bundles stored before it was introduced
+	ContentHash []byte
+	// Size of the bundle's file in bytes, zero for bundles stored before it was introduced
+	Size int64
+	// Compressed indicates that the bundle's file is zstd compressed
+	Compressed bool
+	// ForwardingAttempts is the number of dispatch cycles in which forwarding this bundle was attempted
+	ForwardingAttempts int
+	// Delivered indicates that the bundle was delivered to at least one local application agent
+	Delivered bool
 }
 
 func (bd *BundleDescriptor) Load() (bpv7.Bundle, error) {
 	if bd.Bundle != nil {
 		return *bd.Bundle, nil
 	}
-	bndle, err := GetStoreSingleton().loadEntireBundle(bd.SerialisedFileName)
+	bndle, err := GetStoreSingleton().loadEntireBundle(bd.SerialisedFileName, bd.Compressed)
 	if err != nil {
 		return bpv7.Bundle{}, err
 	}
@@ -51,8 +73,14 @@ func (bd *BundleDescriptor) GetAlreadySent() []bpv7.EndpointID {
 	return bd.AlreadySentTo
 }
 
+// AddAlreadySent records that the peers have this bundle. Each peer is recorded only once.
 func (bd *BundleDescriptor) AddAlreadySent(peers ...bpv7.EndpointID) {
-	bd.AlreadySentTo = append(bd.AlreadySentTo, peers...)
+	sentTo := appendPeers(bd.AlreadySentTo, peers...)
+	if len(sentTo) == len(bd.AlreadySentTo) {
+		return
+	}
+
+	bd.AlreadySentTo = sentTo
 	err := GetStoreSingleton().updateBundleMetadata(bd)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -67,6 +95,30 @@ func (bd *BundleDescriptor) AddAlreadySent(peers ...bpv7.EndpointID) {
 	}
 }
 
+// AddPendingHandoff records that the peer received this bundle, awaiting its acknowledgement.
+func (bd *BundleDescriptor) AddPendingHandoff(peer bpv7.EndpointID) error {
+	pending := appendPeers(bd.PendingHandoffs, peer)
+	if len(pending) == len(bd.PendingHandoffs) {
+		return nil
+	}
+
+	bd.PendingHandoffs = pending
+	return GetStoreSingleton().updateBundleMetadata(bd)
+}
+
+// AcknowledgeHandoff records the acknowledgement of a pending handoff, adding the peer to those which already have
+// this bundle. False is returned if no handoff to this peer was pending.
+func (bd *BundleDescriptor) AcknowledgeHandoff(peer bpv7.EndpointID) (bool, error) {
+	index := slices.Index(bd.PendingHandoffs, peer)
+	if index < 0 {
+		return false, nil
+	}
+
+	bd.PendingHandoffs = slices.Delete(bd.PendingHandoffs, index, index+1)
+	bd.AlreadySentTo = appendPeers(bd.AlreadySentTo, peer)
+	return true, GetStoreSingleton().updateBundleMetadata(bd)
+}
+
 func (bd *BundleDescriptor) AddConstraint(constraint Constraint) error {
 	// check if value is valid constraint
 	if constraint < DispatchPending || constraint > ReassemblyPending {
@@ -92,6 +144,24 @@ func (bd *BundleDescriptor) RemoveConstraint(constraint Constraint) error {
 	return GetStoreSingleton().updateBundleMetadata(bd)
 }
 
+// AddForwardingAttempt counts a dispatch cycle in which forwarding this bundle was attempted.
+func (bd *BundleDescriptor) AddForwardingAttempt() error {
+	bd.ForwardingAttempts++
+	return GetStoreSingleton().updateBundleMetadata(bd)
+}
+
+// SetDelivered records that the bundle was delivered to a local application agent.
+func (bd *BundleDescriptor) SetDelivered() error {
+	bd.Delivered = true
+	return GetStoreSingleton().updateBundleMetadata(bd)
+}
+
+// SetNextDispatch defers the bundle's next dispatch until the given time, e.g., to retry a failed transmission later.
+func (bd *BundleDescriptor) SetNextDispatch(next time.Time) error {
+	bd.NextDispatch = next
+	return GetStoreSingleton().updateBundleMetadata(bd)
+}
+
 func (bd *BundleDescriptor) ResetConstraints() error {
 	bd.RetentionConstraints = make([]Constraint, 0)
 	bd.Retain = false
@@ -102,3 +172,13 @@ func (bd *BundleDescriptor) ResetConstraints() error {
 func (bd *BundleDescriptor) String() string {
 	return bd.ID.String()
 }
+
+// appendPeers appends those peers which are not yet part of sentTo.
+func appendPeers(sentTo []bpv7.EndpointID, peers ...bpv7.EndpointID) []bpv7.EndpointID {
+	for _, peer := range peers {
+		if !slices.Contains(sentTo, peer) {
+			sentTo = append(sentTo, peer)
+		}
+	}
+	return sentTo
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdEncoder and zstdDecoder are shared, as their EncodeAll and DecodeAll methods are safe for concurrent use.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// SetCompression enables or disables the zstd compression of newly stored bundles.
+//
+// Each BundleDescriptor records if its bundle was compressed, so bundles stored before changing this setting remain
+// readable.
+func (bst *BundleStore) SetCompression(compress bool) {
+	bst.compress = compress
+}
+
+// compressBundle compresses a serialised bundle.
+func compressBundle(data []byte) []byte {
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)))
+}
+
+// decompressBundle restores a serialised bundle compressed by compressBundle.
+func decompressBundle(data []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(data, nil)
+}
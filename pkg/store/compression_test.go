@@ -0,0 +1,93 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestCompression(t *testing.T) {
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	newBundle := func(source string) bpv7.Bundle {
+		bundle, err := bpv7.Builder().
+			Source(source).
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("10m").
+			PayloadBlock([]byte(strings.Repeat("hello world ", 1000))).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bundle
+	}
+
+	fileSize := func(bd *BundleDescriptor) int64 {
+		info, err := os.Stat(filepath.Join(GetStoreSingleton().bundleDirectory, bd.SerialisedFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	plain := newBundle("dtn://plain/")
+	plainBd, err := GetStoreSingleton().InsertBundle(&plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	GetStoreSingleton().SetCompression(true)
+	compressed := newBundle("dtn://compressed/")
+	compressedBd, err := GetStoreSingleton().InsertBundle(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plainBd.Compressed || !compressedBd.Compressed {
+		t.Fatalf("Compression flags are %t and %t", plainBd.Compressed, compressedBd.Compressed)
+	}
+	if plainSize, compressedSize := fileSize(plainBd), fileSize(compressedBd); compressedSize >= plainSize {
+		t.Fatalf("Compressed file has %d bytes, uncompressed %d", compressedSize, plainSize)
+	}
+
+	// Both bundles are readable with either setting
+	for _, compress := range []bool{true, false} {
+		GetStoreSingleton().SetCompression(compress)
+
+		for _, bundle := range []bpv7.Bundle{plain, compressed} {
+			bd, err := GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := bd.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expected, actual bytes.Buffer
+			if err := cboring.Marshal(&bundle, &expected); err != nil {
+				t.Fatal(err)
+			}
+			if err := cboring.Marshal(&loaded, &actual); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+				t.Fatalf("Bundle %v differs after round-trip", bundle.ID())
+			}
+
+			if err := GetStoreSingleton().VerifyBundle(bundle.ID()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
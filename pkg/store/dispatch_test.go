@@ -0,0 +1,104 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func insertDispatchTestBundles(tb testing.TB, n int) []*BundleDescriptor {
+	bds := make([]*BundleDescriptor, n)
+	for i := range bds {
+		bundle, err := bpv7.Builder().
+			Source(fmt.Sprintf("dtn://src-%d/", i)).
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("24h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		if bds[i], err = GetStoreSingleton().InsertBundle(&bundle); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return bds
+}
+
+func TestGetDueForDispatch(t *testing.T) {
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	bds := insertDispatchTestBundles(t, 6)
+
+	// Bundles 0 and 1 are deferred, bundle 2 is no longer marked for dispatching
+	later := time.Now().Add(time.Hour)
+	for _, bd := range bds[:2] {
+		if err := bd.SetNextDispatch(later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bds[2].AddConstraint(ForwardPending); err != nil {
+		t.Fatal(err)
+	}
+
+	due := func(now time.Time) map[string]bool {
+		bundles, err := GetStoreSingleton().GetDueForDispatch(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make(map[string]bool, len(bundles))
+		for _, bd := range bundles {
+			ids[bd.IDString] = true
+		}
+		return ids
+	}
+
+	if ids := due(time.Now()); len(ids) != 3 || !ids[bds[3].IDString] || !ids[bds[4].IDString] || !ids[bds[5].IDString] {
+		t.Fatalf("Unexpected due bundles %v", ids)
+	}
+	if ids := due(later); len(ids) != 5 || ids[bds[2].IDString] {
+		t.Fatalf("Unexpected due bundles %v", ids)
+	}
+}
+
+func BenchmarkGetDueForDispatch(b *testing.B) {
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), b.TempDir()); err != nil {
+		b.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	// Only every tenth bundle is due
+	bds := insertDispatchTestBundles(b, 1000)
+	later := time.Now().Add(time.Hour)
+	for i, bd := range bds {
+		if i%10 != 0 {
+			if err := bd.SetNextDispatch(later); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("GetDueForDispatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := GetStoreSingleton().GetDueForDispatch(time.Now()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("GetDispatchable", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := GetStoreSingleton().GetDispatchable(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
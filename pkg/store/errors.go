@@ -0,0 +1,39 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/timshannon/badgerhold/v4"
+)
+
+// Errors of store operations, to be checked by errors.Is. The underlying error remains accessible by errors.As.
+var (
+	// ErrBundleNotFound is matched by errors for bundles, or their serialised files, which are not in the store.
+	ErrBundleNotFound = errors.New("bundle not found")
+	// ErrStoreIO is matched by errors of the store's metadata database or file system, which might be transient.
+	ErrStoreIO = errors.New("store I/O error")
+	// ErrStoreInUse is matched by errors of opening a store which is already opened by another dtnd or dtn-tool.
+	ErrStoreInUse = errors.New("store already in use")
+)
+
+// storeError wraps an error of the underlying storage for the given bundle as ErrBundleNotFound or ErrStoreIO.
+func storeError(bundle string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, badgerhold.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("bundle %s: %w: %w", bundle, ErrBundleNotFound, err)
+	default:
+		return fmt.Errorf("bundle %s: %w: %w", bundle, ErrStoreIO, err)
+	}
+}
+
+// queryError wraps an error of a query over multiple bundles as ErrStoreIO.
+func queryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("querying bundles failed: %w: %w", ErrStoreIO, err)
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+// CorruptBundleError is returned if a serialised bundle does not match its BundleDescriptor.
+type CorruptBundleError struct {
+	bundle string
+	reason string
+}
+
+func NewCorruptBundleError(bundle string, reason string) *CorruptBundleError {
+	return &CorruptBundleError{bundle: bundle, reason: reason}
+}
+
+func (err *CorruptBundleError) Error() string {
+	return fmt.Sprintf("bundle %s is corrupt: %s", err.bundle, err.reason)
+}
+
+// VerifyBundle re-reads the serialised bundle and checks it against its stored content hash.
+//
+// If verification fails, a CorruptBundleError is returned. Other errors indicate that the bundle could not be checked
+// at all. Bundles stored without a content hash can only be checked for their file's existence.
+func (bst *BundleStore) VerifyBundle(bundleId bpv7.BundleID) error {
+	bd, err := bst.LoadBundleDescriptor(bundleId)
+	if err != nil {
+		return err
+	}
+	return bst.verifyBundle(bd)
+}
+
+func (bst *BundleStore) verifyBundle(bd *BundleDescriptor) error {
+	data, err := os.ReadFile(filepath.Join(bst.bundleDirectory, bd.SerialisedFileName))
+	if os.IsNotExist(err) {
+		return NewCorruptBundleError(bd.IDString, "serialised bundle is missing")
+	} else if err != nil {
+		return storeError(bd.IDString, err)
+	}
+
+	if contentHash := sha256.Sum256(data); len(bd.ContentHash) > 0 && !bytes.Equal(contentHash[:], bd.ContentHash) {
+		return NewCorruptBundleError(bd.IDString, "content hash mismatch")
+	}
+
+	return nil
+}
+
+// Scrub verifies all stored bundles and quarantines the corrupt ones.
+//
+// A quarantined bundle's serialised file is moved into the store's quarantine directory for later inspection and its
+// metadata is deleted. The quarantined bundles are returned.
+func (bst *BundleStore) Scrub() (quarantined []*BundleDescriptor, err error) {
+	bundles := make([]BundleDescriptor, 0)
+	if err = bst.metadataStore.Find(&bundles, nil); err != nil {
+		return nil, queryError(err)
+	}
+
+	for i := range bundles {
+		bd := &bundles[i]
+
+		verifyErr := bst.verifyBundle(bd)
+		if verifyErr == nil {
+			continue
+		} else if _, ok := verifyErr.(*CorruptBundleError); !ok {
+			log.WithFields(log.Fields{
+				"bundle": bd.IDString,
+				"error":  verifyErr,
+			}).Warn("Error verifying bundle")
+			err = multierror.Append(err, verifyErr)
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"bundle": bd.IDString,
+			"error":  verifyErr,
+		}).Error("Corrupt bundle detected, moving it to quarantine")
+
+		if qErr := bst.quarantineBundle(bd); qErr != nil {
+			log.WithFields(log.Fields{
+				"bundle": bd.IDString,
+				"error":  qErr,
+			}).Error("Error quarantining corrupt bundle")
+			err = multierror.Append(err, qErr)
+			continue
+		}
+		quarantined = append(quarantined, bd)
+	}
+
+	return
+}
+
+// quarantineBundle moves the serialised bundle into the quarantine directory and deletes its metadata.
+func (bst *BundleStore) quarantineBundle(bd *BundleDescriptor) error {
+	serialisedPath := filepath.Join(bst.bundleDirectory, bd.SerialisedFileName)
+	quarantinePath := filepath.Join(bst.quarantineDirectory, bd.SerialisedFileName)
+	if err := os.Rename(serialisedPath, quarantinePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return bst.DeleteBundle(bd)
+}
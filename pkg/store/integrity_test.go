@@ -0,0 +1,66 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestVerifyBundle(t *testing.T) {
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bd, err := GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GetStoreSingleton().VerifyBundle(bundle.ID()); err != nil {
+		t.Fatalf("Intact bundle failed verification: %v", err)
+	}
+
+	// Flip a byte of the payload, which has no CRC and is only detected by the content hash
+	serialisedPath := filepath.Join(GetStoreSingleton().bundleDirectory, bd.SerialisedFileName)
+	data, err := os.ReadFile(serialisedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-3] ^= 0xff
+	if err := os.WriteFile(serialisedPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = GetStoreSingleton().VerifyBundle(bundle.ID())
+	if _, ok := err.(*CorruptBundleError); !ok {
+		t.Fatalf("Corrupt bundle was not detected, got error %v", err)
+	}
+
+	quarantined, err := GetStoreSingleton().Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarantined) != 1 || quarantined[0].ID != bundle.ID() {
+		t.Fatalf("Expected the corrupt bundle to be quarantined, got %v", quarantined)
+	}
+
+	if _, err := os.Stat(filepath.Join(GetStoreSingleton().quarantineDirectory, bd.SerialisedFileName)); err != nil {
+		t.Fatalf("Corrupt bundle was not moved to quarantine: %v", err)
+	}
+	if _, err := GetStoreSingleton().LoadBundleDescriptor(bundle.ID()); err == nil {
+		t.Fatal("Metadata of the corrupt bundle was not deleted")
+	}
+}
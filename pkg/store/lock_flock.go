@@ -0,0 +1,37 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// lockStore takes an exclusive lock on the store's directory, which is held until the returned file is closed.
+// The lock file records the locking process' ID for a descriptive ErrStoreInUse.
+func lockStore(path string) (*os.File, error) {
+	lockFile, err := os.OpenFile(filepath.Join(path, lockFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: opening lock file: %w", ErrStoreIO, err)
+	}
+
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		pid, _ := os.ReadFile(lockFile.Name())
+		_ = lockFile.Close()
+
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s is locked by process %s", ErrStoreInUse, path, strings.TrimSpace(string(pid)))
+		}
+		return nil, fmt.Errorf("%w: locking %s: %w", ErrStoreIO, path, err)
+	}
+
+	if err := lockFile.Truncate(0); err == nil {
+		_, _ = lockFile.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return lockFile, nil
+}
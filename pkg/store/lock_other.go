@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package store
+
+import "os"
+
+// lockStore is not supported on this operating system, leaving the detection of a concurrent usage to badger.
+func lockStore(string) (*os.File, error) {
+	return nil, nil
+}
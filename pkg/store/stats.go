@@ -0,0 +1,55 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stats aggregates the bundles held by the BundleStore, e.g., for capacity planning.
+type Stats struct {
+	// Bundles is the total number of stored bundles
+	Bundles int `json:"bundles"`
+	// Bytes is the total size of all serialised bundles
+	Bytes int64 `json:"bytes"`
+	// ByDestination counts the bundles per destination endpoint
+	ByDestination map[string]int `json:"by_destination"`
+	// BySource counts the bundles per source endpoint
+	BySource map[string]int `json:"by_source"`
+}
+
+// Stats aggregates all stored bundles in a single pass over their metadata.
+//
+// The size of bundles stored before it was recorded in their BundleDescriptor is taken from their file.
+func (bst *BundleStore) Stats() (Stats, error) {
+	stats := Stats{
+		ByDestination: make(map[string]int),
+		BySource:      make(map[string]int),
+	}
+
+	err := bst.metadataStore.ForEach(nil, func(bd *BundleDescriptor) error {
+		size := bd.Size
+		if size == 0 {
+			if info, err := os.Stat(filepath.Join(bst.bundleDirectory, bd.SerialisedFileName)); err == nil {
+				size = info.Size()
+			} else {
+				log.WithFields(log.Fields{
+					"bundle": bd.IDString,
+					"error":  err,
+				}).Debug("Cannot determine size of stored bundle")
+			}
+		}
+
+		stats.Bundles++
+		stats.Bytes += size
+		stats.ByDestination[bd.Destination.String()]++
+		stats.BySource[bd.Source.String()]++
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
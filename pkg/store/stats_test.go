@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/pkg/bpv7"
+)
+
+func TestStats(t *testing.T) {
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	if stats, err := GetStoreSingleton().Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.Bundles != 0 || stats.Bytes != 0 || len(stats.ByDestination) != 0 || len(stats.BySource) != 0 {
+		t.Fatalf("Empty store has stats %v", stats)
+	}
+
+	destinations := []string{"dtn://a/", "dtn://b/", "dtn://b/", "dtn://c/inbox", "dtn://c/inbox", "dtn://c/inbox"}
+	sources := []string{"dtn://x/", "dtn://y/"}
+
+	var bytes int64
+	for i, destination := range destinations {
+		bundle, err := bpv7.Builder().
+			Source(sources[i%len(sources)]).
+			Destination(destination).
+			CreationTimestampTime(time.Now().Add(time.Duration(i) * time.Second)).
+			Lifetime("10m").
+			PayloadBlock([]byte(fmt.Sprintf("bundle %d", i))).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bd, err := GetStoreSingleton().InsertBundle(&bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(filepath.Join(GetStoreSingleton().bundleDirectory, bd.SerialisedFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bd.Size != info.Size() {
+			t.Fatalf("Recorded size %d differs from file size %d", bd.Size, info.Size())
+		}
+		bytes += info.Size()
+	}
+
+	stats, err := GetStoreSingleton().Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Stats{
+		Bundles:       len(destinations),
+		Bytes:         bytes,
+		ByDestination: map[string]int{"dtn://a/": 1, "dtn://b/": 2, "dtn://c/inbox": 3},
+		BySource:      map[string]int{"dtn://x/": 3, "dtn://y/": 3},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("Expected stats %v, got %v", expected, stats)
+	}
+}
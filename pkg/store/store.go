@@ -1,8 +1,9 @@
 package store
 
 import (
-	"bufio"
+	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,68 +23,232 @@ type BundleStore struct {
 	nodeID          bpv7.EndpointID
 	metadataStore   *badgerhold.Store
 	bundleDirectory string
+	// quarantineDirectory holds serialised bundles which failed their integrity verification
+	quarantineDirectory string
+	// tempDirectory holds serialised bundles while they are written, see SetTempDirectory
+	tempDirectory string
+	// compress newly stored bundles, see SetCompression
+	compress bool
+	// gracePeriod after their expiry until bundles are deleted, see SetGracePeriod
+	gracePeriod time.Duration
+	// lockFile holds the lock on the store's directory until the store is closed; nil if locking is unsupported
+	lockFile *os.File
 }
 
+// lockFileName is the name of the file within a store's directory, which is locked while the store is in use.
+const lockFileName = "dtnd.lock"
+
+// now returns the current time for the bundles' reception and expiry. Tests may replace it to control the clock
+// deterministically.
+var now = time.Now
+
 var storeSingleton *BundleStore
 
 // InitialiseStore initialises the store singleton
 // To access Singleton-instance, use GetStoreSingleton
 // Further calls to this function after initialisation will return a util.AlreadyInitialised-error
+//
+// A store path can only be used by one process at a time. An error matching ErrStoreInUse is returned if another
+// process, e.g., a second dtnd, has already opened it.
 func InitialiseStore(nodeID bpv7.EndpointID, path string) error {
 	if storeSingleton != nil {
 		return util.NewAlreadyInitialisedError("BundleStore")
 	}
 
-	opts := badgerhold.DefaultOptions
-	opts.Dir = path
-	opts.ValueDir = path
+	bst, err := openStore(nodeID, path)
+	if err != nil {
+		return err
+	}
+
+	storeSingleton = bst
+	return nil
+}
 
+// openStore locks and opens the store at the path, creating it if necessary.
+func openStore(nodeID bpv7.EndpointID, path string) (bst *BundleStore, err error) {
 	if err := os.MkdirAll(path, 0700); err != nil {
-		return err
+		return nil, err
+	}
+
+	lockFile, err := lockStore(path)
+	if err != nil {
+		return nil, err
 	}
+	defer func() {
+		if err != nil && lockFile != nil {
+			_ = lockFile.Close()
+		}
+	}()
+
+	opts := badgerhold.DefaultOptions
+	opts.Dir = path
+	opts.ValueDir = path
 
 	badgerStore, err := badgerhold.Open(opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	bundleDirectory := filepath.Join(path, "bundles")
 	if err := os.MkdirAll(bundleDirectory, 0700); err != nil {
-		return err
+		_ = badgerStore.Close()
+		return nil, err
 	}
 
-	storeSingleton = &BundleStore{nodeID: nodeID, metadataStore: badgerStore, bundleDirectory: bundleDirectory}
+	quarantineDirectory := filepath.Join(path, "quarantine")
+	if err := os.MkdirAll(quarantineDirectory, 0700); err != nil {
+		_ = badgerStore.Close()
+		return nil, err
+	}
 
+	tempDirectory := filepath.Join(path, "tmp")
+	if err := os.MkdirAll(tempDirectory, 0700); err != nil {
+		_ = badgerStore.Close()
+		return nil, err
+	}
+	cleanTempDirectory(tempDirectory)
+
+	bst = &BundleStore{
+		nodeID:              nodeID,
+		metadataStore:       badgerStore,
+		bundleDirectory:     bundleDirectory,
+		quarantineDirectory: quarantineDirectory,
+		tempDirectory:       tempDirectory,
+		lockFile:            lockFile,
+	}
+	if err := bst.rekeyBundleIDs(); err != nil {
+		_ = badgerStore.Close()
+		return nil, err
+	}
+	if err := bst.indexNextDispatch(); err != nil {
+		_ = badgerStore.Close()
+		return nil, err
+	}
+	if err := bst.recoverForwardPending(); err != nil {
+		_ = badgerStore.Close()
+		return nil, err
+	}
+
+	return bst, nil
+}
+
+// rekeyBundleIDs stores bundles under their bpv7.BundleID's current textual representation, which is the store's key.
+// Fragments were previously stored with dash-separated instead of colon-separated offset and length.
+func (bst *BundleStore) rekeyBundleIDs() error {
+	var bds []BundleDescriptor
+	if err := bst.metadataStore.Find(&bds, nil); err != nil {
+		return queryError(err)
+	}
+
+	for _, bd := range bds {
+		idString := bd.ID.String()
+		if bd.IDString == idString {
+			continue
+		}
+
+		if err := bst.metadataStore.Delete(bd.IDString, BundleDescriptor{}); err != nil {
+			return storeError(bd.IDString, err)
+		}
+		bd.IDString = idString
+		if err := bst.metadataStore.Insert(bd.IDString, bd); err != nil {
+			return storeError(bd.IDString, err)
+		}
+		log.WithField("bundle", bd.IDString).Debug("Rekeyed bundle to its current ID representation")
+	}
+	return nil
+}
+
+// indexNextDispatch sets the NextDispatch field of bundles stored before it was introduced, adding them to its index.
+func (bst *BundleStore) indexNextDispatch() error {
+	query := badgerhold.Where("NextDispatch").Eq(time.Time{})
+	return bst.metadataStore.UpdateMatching(&BundleDescriptor{}, query, func(record interface{}) error {
+		bd := record.(*BundleDescriptor)
+		bd.NextDispatch = bd.ReceivedAt
+		if bd.NextDispatch.IsZero() {
+			bd.NextDispatch = now().UTC().Round(0)
+		}
+		return nil
+	})
+}
+
+// recoverForwardPending resets bundles left with a ForwardPending constraint, e.g., by a crash while forwarding, to
+// DispatchPending. Otherwise, they would neither be dispatched nor garbage collected anymore.
+func (bst *BundleStore) recoverForwardPending() error {
+	recovered := 0
+	query := badgerhold.Where("RetentionConstraints").Contains(ForwardPending)
+	err := bst.metadataStore.UpdateMatching(&BundleDescriptor{}, query, func(record interface{}) error {
+		bd := record.(*BundleDescriptor)
+
+		constraints := []Constraint{DispatchPending}
+		for _, constraint := range bd.RetentionConstraints {
+			if constraint != ForwardPending && constraint != DispatchPending {
+				constraints = append(constraints, constraint)
+			}
+		}
+		bd.RetentionConstraints = constraints
+		bd.Retain = true
+		bd.Dispatch = true
+
+		recovered++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if recovered > 0 {
+		log.WithField("bundles", recovered).Info("Recovered bundles with a stale forwarding pending constraint")
+	}
 	return nil
 }
 
+// LookupStoreSingleton returns the store singleton-instance or a util.NotInitialised-error.
+func LookupStoreSingleton() (*BundleStore, error) {
+	if storeSingleton == nil {
+		return nil, util.NewNotInitialisedError("BundleStore")
+	}
+	return storeSingleton, nil
+}
+
 // GetStoreSingleton returns the store singleton-instance.
-// Attempting to call this function before store initialisation will cause the program to panic.
+// Attempting to call this function before store initialisation will cause the program to panic; see
+// LookupStoreSingleton.
 func GetStoreSingleton() *BundleStore {
-	if storeSingleton == nil {
+	bst, err := LookupStoreSingleton()
+	if err != nil {
 		log.Fatal("Attempting to access an uninitialised store. This must never happen!")
 	}
-	return storeSingleton
+	return bst
 }
 
 func (bst *BundleStore) Close() error {
 	err := bst.metadataStore.Close()
-	storeSingleton = nil
+	if bst.lockFile != nil {
+		// Closing the file releases its lock
+		if closeErr := bst.lockFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if storeSingleton == bst {
+		storeSingleton = nil
+	}
 	return err
 }
 
+// LoadBundleDescriptor of a stored bundle. An error matching ErrBundleNotFound is returned for an unknown bundle,
+// while one matching ErrStoreIO indicates a failing metadata database.
 func (bst *BundleStore) LoadBundleDescriptor(bundleId bpv7.BundleID) (*BundleDescriptor, error) {
 	idString := bundleId.String()
 	bd := BundleDescriptor{}
 	err := bst.metadataStore.Get(idString, &bd)
-	return &bd, err
+	return &bd, storeError(idString, err)
 }
 
 func (bst *BundleStore) GetWithConstraint(constraint Constraint) ([]*BundleDescriptor, error) {
 	bundles := make([]BundleDescriptor, 0)
 	err := bst.metadataStore.Find(&bundles, badgerhold.Where("RetentionConstraints").Contains(constraint))
 	if err != nil {
-		return nil, err
+		return nil, queryError(err)
 	}
 
 	ptrs := make([]*BundleDescriptor, len(bundles))
@@ -98,7 +263,29 @@ func (bst *BundleStore) GetDispatchable() ([]*BundleDescriptor, error) {
 	bundles := make([]BundleDescriptor, 0)
 	err := bst.metadataStore.Find(&bundles, badgerhold.Where("Dispatch").Eq(true))
 	if err != nil {
-		return nil, err
+		return nil, queryError(err)
+	}
+
+	ptrs := make([]*BundleDescriptor, len(bundles))
+	for i := range bundles {
+		ptrs[i] = &bundles[i]
+	}
+
+	return ptrs, nil
+}
+
+// GetDueForDispatch returns all bundles marked for dispatching whose NextDispatch time is not after now.
+//
+// Bundles expired for longer than the bpv7.ClockSkewTolerance are left out, as receiving nodes would drop them. Thus,
+// bundles kept for a grace period, see SetGracePeriod, are not forwarded beyond this tolerance.
+//
+// In contrast to GetDispatchable, this query uses the NextDispatch index and does not decode all bundles' metadata.
+func (bst *BundleStore) GetDueForDispatch(now time.Time) ([]*BundleDescriptor, error) {
+	bundles := make([]BundleDescriptor, 0)
+	query := badgerhold.Where("NextDispatch").Le(now).Index("NextDispatch").And("Dispatch").Eq(true).
+		And("Expires").Ge(now.Add(-bpv7.ClockSkewTolerance()))
+	if err := bst.metadataStore.Find(&bundles, query); err != nil {
+		return nil, queryError(err)
 	}
 
 	ptrs := make([]*BundleDescriptor, len(bundles))
@@ -109,23 +296,41 @@ func (bst *BundleStore) GetDispatchable() ([]*BundleDescriptor, error) {
 	return ptrs, nil
 }
 
-func (bst *BundleStore) loadEntireBundle(filename string) (*bpv7.Bundle, error) {
+func (bst *BundleStore) loadEntireBundle(filename string, compressed bool) (*bpv7.Bundle, error) {
 	path := filepath.Join(bst.bundleDirectory, filename)
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, storeError(filename, err)
 	}
-	defer f.Close()
 
-	bundle, err := bpv7.ParseBundle(f)
+	if compressed {
+		if data, err = decompressBundle(data); err != nil {
+			return nil, err
+		}
+	}
+
+	bundle, err := bpv7.ParseBundle(bytes.NewReader(data))
+	if err != nil {
+		return nil, NewCorruptBundleError(filename, fmt.Sprintf("parsing serialised bundle failed: %v", err))
+	}
 
 	return &bundle, nil
 }
 
-func (bst *BundleStore) insertNewBundle(bundle *bpv7.Bundle) (*BundleDescriptor, error) {
-	log.WithField("bundle", bundle.ID().String()).Debug("Inserting new bundle")
+func (bst *BundleStore) insertNewBundle(bundle *bpv7.Bundle, from bpv7.EndpointID) (*BundleDescriptor, error) {
+	log.WithField("bundle", bundle.ID().String()).WithFields(bundle.PayloadLogFields()).Debug("Inserting new bundle")
 	lifetimeDuration := time.Millisecond * time.Duration(bundle.PrimaryBlock.Lifetime)
 	serialisedFileName := fmt.Sprintf("%x", sha256.Sum256([]byte(bundle.ID().String())))
+
+	receivedAt := now().UTC().Round(0)
+	expires := bundle.PrimaryBlock.CreationTimestamp.DtnTime().Time().Add(lifetimeDuration)
+	if bundle.IsAgeOnly() {
+		// Without a creation time, the remaining lifetime is derived from the bundle's current age
+		bab, _ := bundle.ExtensionBlock(bpv7.ExtBlockTypeBundleAgeBlock)
+		age := time.Millisecond * time.Duration(bab.Value.(*bpv7.BundleAgeBlock).Age())
+		expires = receivedAt.Add(lifetimeDuration - age)
+	}
+
 	bd := BundleDescriptor{
 		ID:                   bundle.ID(),
 		IDString:             bundle.ID().String(),
@@ -133,90 +338,198 @@ func (bst *BundleStore) insertNewBundle(bundle *bpv7.Bundle) (*BundleDescriptor,
 		Destination:          bundle.PrimaryBlock.Destination,
 		ReportTo:             bundle.PrimaryBlock.ReportTo,
 		AlreadySentTo:        []bpv7.EndpointID{bst.nodeID},
+		ReceivedFrom:         from,
 		RetentionConstraints: []Constraint{DispatchPending},
 		Retain:               false,
 		Dispatch:             true,
-		Expires:              bundle.PrimaryBlock.CreationTimestamp.DtnTime().Time().Add(lifetimeDuration),
+		NextDispatch:         receivedAt,
+		Expires:              expires,
+		ReceivedAt:           receivedAt,
 		SerialisedFileName:   serialisedFileName,
 		Bundle:               nil,
 	}
 
 	if previousNodeBlock, err := bundle.ExtensionBlock(bpv7.ExtBlockTypePreviousNodeBlock); err == nil {
 		previousNode := previousNodeBlock.Value.(*bpv7.PreviousNodeBlock).Endpoint()
-		bd.AlreadySentTo = append(bd.AlreadySentTo, previousNode)
+		bd.AlreadySentTo = appendPeers(bd.AlreadySentTo, previousNode)
+		bd.PreviousNode = previousNode
 		log.WithFields(log.Fields{
 			"bundle": bd.ID,
 			"sender": previousNode,
 		}).Debug("Added sender to AlreadySentTo")
 	}
 
-	err := storeSingleton.metadataStore.Insert(bd.IDString, bd)
-	if err != nil {
+	buff := new(bytes.Buffer)
+	if err := cboring.Marshal(bundle, buff); err != nil {
 		return nil, err
 	}
+	if bst.compress {
+		buff = bytes.NewBuffer(compressBundle(buff.Bytes()))
+		bd.Compressed = true
+	}
+	contentHash := sha256.Sum256(buff.Bytes())
+	bd.ContentHash = contentHash[:]
+	bd.Size = int64(buff.Len())
 
-	serialisedPath := filepath.Join(bst.bundleDirectory, serialisedFileName)
-	f, err := os.Create(serialisedPath)
-	defer f.Close()
-	if err != nil {
+	// The serialised bundle is written first, so that its metadata never refers to a missing or partial file
+	if err := bst.writeSerialised(serialisedFileName, buff.Bytes()); err != nil {
 		log.WithFields(log.Fields{
 			"bundle": bd.IDString,
 			"error":  err,
-		}).Error("Error opening file to store serialised bundle. Deleting...")
-		delErr := bst.DeleteBundle(&bd)
-		if delErr != nil {
+		}).Error("Error writing serialised bundle")
+		return nil, storeError(bd.IDString, err)
+	}
+
+	if err := bst.metadataStore.Insert(bd.IDString, bd); err != nil {
+		if rmErr := os.Remove(filepath.Join(bst.bundleDirectory, serialisedFileName)); rmErr != nil {
 			log.WithFields(log.Fields{
 				"bundle": bd.IDString,
-				"error":  delErr,
-			}).Error("Error deleting BundleDescriptor. Something is very wrong")
-			err = multierror.Append(err, delErr)
+				"error":  rmErr,
+			}).Error("Error removing serialised bundle without metadata")
+			err = multierror.Append(err, rmErr)
 		}
-		return nil, err
-	}
-
-	w := bufio.NewWriter(f)
-	err = cboring.Marshal(bundle, w)
-	if err != nil {
-		return nil, err
+		return nil, storeError(bd.IDString, err)
 	}
-	err = w.Flush()
 
-	return &bd, err
+	return &bd, nil
 }
 
+// InsertBundle stores a bundle and returns its BundleDescriptor.
+//
+// Bundles are identified by their full BundleID, which includes the fragment offset and total data length of
+// fragments. Thus, different fragments of the same bundle, as well as a bundle and its fragments, are distinct
+// entries. Only a bundle or fragment with an already stored BundleID is deduplicated: it is not stored again, but the
+// existing BundleDescriptor is updated by the new reception, e.g., with its PreviousNodeBlock.
 func (bst *BundleStore) InsertBundle(bundle *bpv7.Bundle) (*BundleDescriptor, error) {
+	return bst.InsertReceivedBundle(bundle, bpv7.EndpointID{})
+}
+
+// InsertReceivedBundle stores a bundle like InsertBundle and records the peer it was received from, if known.
+// A zero-valued peer is used for bundles without a known sender, e.g., those created locally.
+func (bst *BundleStore) InsertReceivedBundle(bundle *bpv7.Bundle, from bpv7.EndpointID) (*BundleDescriptor, error) {
 	bd := BundleDescriptor{}
 	err := bst.metadataStore.Get(bundle.ID().String(), &bd)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"bundle": bundle.ID().String(),
-			"error":  err,
-		}).Debug("Could not get bundle from store (because it may be new)")
-		return bst.insertNewBundle(bundle)
+	if errors.Is(err, badgerhold.ErrNotFound) {
+		log.WithField("bundle", bundle.ID().String()).Debug("Bundle is new to the store")
+		return bst.insertNewBundle(bundle, from)
+	} else if err != nil {
+		return nil, storeError(bundle.ID().String(), err)
 	}
 
 	log.WithField("bundle", bundle.ID().String()).Debug("Bundle already exists, updating metadata")
 
-	var uerr error
+	update := false
 	if previousNodeBlock, err := bundle.ExtensionBlock(bpv7.ExtBlockTypePreviousNodeBlock); err == nil {
 		previousNode := previousNodeBlock.Value.(*bpv7.PreviousNodeBlock).Endpoint()
-		bd.AlreadySentTo = append(bd.AlreadySentTo, previousNode)
-		uerr = bst.updateBundleMetadata(&bd)
+		bd.AlreadySentTo = appendPeers(bd.AlreadySentTo, previousNode)
+		bd.PreviousNode = previousNode
+		update = true
+	}
+	if from.EndpointType != nil {
+		bd.ReceivedFrom = from
+		update = true
 	}
 
+	var uerr error
+	if update {
+		uerr = bst.updateBundleMetadata(&bd)
+	}
 	return &bd, uerr
 }
 
+// GetReceivedFrom returns all bundles most recently received from the given peer's node.
+func (bst *BundleStore) GetReceivedFrom(peer bpv7.EndpointID) ([]*BundleDescriptor, error) {
+	bundles := make([]*BundleDescriptor, 0)
+	err := bst.metadataStore.ForEach(nil, func(bd *BundleDescriptor) error {
+		if bd.ReceivedFrom.EndpointType != nil && bd.ReceivedFrom.SameNode(peer) {
+			bundles = append(bundles, bd)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	return bundles, nil
+}
+
 func (bst *BundleStore) updateBundleMetadata(bundleDescriptor *BundleDescriptor) error {
 	bndl := bundleDescriptor.Bundle
 	bundleDescriptor.Bundle = nil
 	err := bst.metadataStore.Update(bundleDescriptor.IDString, bundleDescriptor)
 	bundleDescriptor.Bundle = bndl
-	return err
+	return storeError(bundleDescriptor.IDString, err)
 }
 
+// DeleteBundle removes a bundle's metadata and serialised file. Deleting an unknown bundle results in an error
+// matching ErrBundleNotFound.
 func (bst *BundleStore) DeleteBundle(bundleDescriptor *BundleDescriptor) error {
-	err := bst.metadataStore.Delete(bundleDescriptor.IDString, bundleDescriptor)
-	err = multierror.Append(os.Remove(bundleDescriptor.SerialisedFileName))
+	var err error
+	if delErr := bst.metadataStore.Delete(bundleDescriptor.IDString, BundleDescriptor{}); delErr != nil {
+		err = multierror.Append(err, storeError(bundleDescriptor.IDString, delErr))
+	}
+	serialisedPath := filepath.Join(bst.bundleDirectory, bundleDescriptor.SerialisedFileName)
+	if rmErr := os.Remove(serialisedPath); rmErr != nil && !os.IsNotExist(rmErr) {
+		err = multierror.Append(err, storeError(bundleDescriptor.IDString, rmErr))
+	}
 	return err
 }
+
+// SetGracePeriod keeps expired bundles for the given duration before GetExpired returns them for deletion, e.g., so
+// that a briefly reconnected peer can still receive a just expired bundle. By default, there is no grace period.
+//
+// Receiving nodes drop bundles expired for longer than the bpv7.ClockSkewTolerance, so GetDueForDispatch stops
+// returning bundles after this tolerance. A longer grace period only defers the deletion, e.g., to inspect the bundles
+// in the store.
+func (bst *BundleStore) SetGracePeriod(grace time.Duration) {
+	bst.gracePeriod = grace
+}
+
+// GetExpired returns all bundles whose lifetime and grace period have expired and which are not retained.
+func (bst *BundleStore) GetExpired() ([]*BundleDescriptor, error) {
+	bundles := make([]BundleDescriptor, 0)
+	query := badgerhold.Where("Expires").Lt(now().Add(-bst.gracePeriod)).And("Retain").Eq(false)
+	if err := bst.metadataStore.Find(&bundles, query); err != nil {
+		return nil, queryError(err)
+	}
+
+	ptrs := make([]*BundleDescriptor, len(bundles))
+	for i := range bundles {
+		ptrs[i] = &bundles[i]
+	}
+
+	return ptrs, nil
+}
+
+// GarbageCollect deletes all expired bundles, as returned by GetExpired.
+//
+// The deleted bundles are returned with their Bundle field loaded, e.g., to create status reports.
+func (bst *BundleStore) GarbageCollect() (deleted []*BundleDescriptor, err error) {
+	expired, err := bst.GetExpired()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bd := range expired {
+		if _, loadErr := bd.Load(); loadErr != nil {
+			log.WithFields(log.Fields{
+				"bundle": bd.IDString,
+				"error":  loadErr,
+			}).Warn("Error loading expired bundle before deletion")
+		}
+
+		if delErr := bst.DeleteBundle(bd); delErr != nil {
+			log.WithFields(log.Fields{
+				"bundle": bd.IDString,
+				"error":  delErr,
+			}).Error("Error deleting expired bundle")
+			err = multierror.Append(err, delErr)
+			continue
+		}
+
+		log.WithField("bundle", bd.IDString).Debug("Deleted expired bundle")
+		deleted = append(deleted, bd)
+	}
+
+	return
+}
@@ -1,14 +1,19 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"pgregory.net/rapid"
 
 	"github.com/dtn7/dtn7-go/pkg/bpv7"
+	"github.com/dtn7/dtn7-go/pkg/util"
 )
 
 func initTest(t *rapid.T) {
@@ -40,7 +45,7 @@ func TestBundleInsertion(t *testing.T) {
 		defer cleanupTest(t)
 
 		bundle := bpv7.GenerateBundle(t, 0)
-		bd, err := GetStoreSingleton().insertNewBundle(&bundle)
+		bd, err := GetStoreSingleton().insertNewBundle(&bundle, bpv7.EndpointID{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -71,7 +76,7 @@ func TestConstraints(t *testing.T) {
 		defer cleanupTest(t)
 
 		bundle := bpv7.GenerateBundle(t, 0)
-		bd, err := GetStoreSingleton().insertNewBundle(&bundle)
+		bd, err := GetStoreSingleton().insertNewBundle(&bundle, bpv7.EndpointID{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -148,3 +153,461 @@ func removeConstraints(t *rapid.T, bd *BundleDescriptor, constraints []Constrain
 		}
 	}
 }
+
+func TestAlreadySent(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		initTest(t)
+		defer cleanupTest(t)
+
+		bundle := bpv7.GenerateBundle(t, 0)
+		bd, err := GetStoreSingleton().insertNewBundle(&bundle, bpv7.EndpointID{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Forwarding attempts to a few peers, repeatedly sending to the same peers
+		peers := rapid.SliceOf(rapid.SampledFrom([]string{"dtn://a/", "dtn://b/", "dtn://c/"})).Draw(t, "peers")
+		for _, peer := range peers {
+			bd.AddAlreadySent(bpv7.MustNewEndpointID(peer))
+		}
+
+		bdLoad, err := GetStoreSingleton().LoadBundleDescriptor(bd.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts := make(map[bpv7.EndpointID]int)
+		for _, eid := range bdLoad.AlreadySentTo {
+			counts[eid]++
+		}
+		for eid, count := range counts {
+			if count > 1 {
+				t.Fatalf("Peer %v is recorded %d times in %v", eid, count, bdLoad.AlreadySentTo)
+			}
+		}
+		for _, peer := range peers {
+			if counts[bpv7.MustNewEndpointID(peer)] != 1 {
+				t.Fatalf("Peer %s is missing in %v", peer, bdLoad.AlreadySentTo)
+			}
+		}
+	})
+}
+
+func TestLookupStoreSingleton(t *testing.T) {
+	defer func(bst *BundleStore) { storeSingleton = bst }(storeSingleton)
+	storeSingleton = nil
+
+	bst, err := LookupStoreSingleton()
+	if bst != nil {
+		t.Fatalf("Expected no store, got %v", bst)
+	}
+	var notInitialised *util.NotInitialised
+	if !errors.As(err, &notInitialised) {
+		t.Fatalf("Expected NotInitialised error, got %v", err)
+	}
+}
+
+func TestFragmentDeduplication(t *testing.T) {
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+	if err := InitialiseStore(nodeID, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock(make([]byte, 1024)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragments, err := bundle.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fragments) < 2 {
+		t.Fatalf("Expected multiple fragments, got %d", len(fragments))
+	}
+
+	countBundles := func() int {
+		stats, err := GetStoreSingleton().Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats.Bundles
+	}
+
+	// Each fragment of the bundle, as well as the entire bundle, is a distinct entry
+	for i := range fragments {
+		if _, err := GetStoreSingleton().InsertBundle(&fragments[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := GetStoreSingleton().InsertBundle(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBundles(); n != len(fragments)+1 {
+		t.Fatalf("Expected %d stored bundles, got %d", len(fragments)+1, n)
+	}
+
+	for _, fragment := range fragments {
+		bd, err := GetStoreSingleton().LoadBundleDescriptor(fragment.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bd.ID != fragment.ID() {
+			t.Fatalf("Fragment %v is stored as %v", fragment.ID(), bd.ID)
+		}
+	}
+
+	// Receiving an identical fragment again only updates its existing entry
+	previousNode := bpv7.MustNewEndpointID("dtn://prev/")
+	resent := fragments[1]
+	err = resent.AddExtensionBlock(bpv7.NewCanonicalBlock(0, 0, bpv7.NewPreviousNodeBlock(previousNode)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd, err := GetStoreSingleton().InsertBundle(&resent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countBundles(); n != len(fragments)+1 {
+		t.Fatalf("Resent fragment was stored again, %d stored bundles", n)
+	}
+	if bd.ID != fragments[1].ID() || bd.PreviousNode != previousNode {
+		t.Fatalf("Resent fragment updated %v with previous node %v", bd.ID, bd.PreviousNode)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	storePath := t.TempDir()
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), storePath); err != nil {
+		t.Fatal(err)
+	}
+	bst := GetStoreSingleton()
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bst.LoadBundleDescriptor(bundle.ID()); !errors.Is(err, ErrBundleNotFound) {
+		t.Fatalf("Loading an unknown bundle resulted in %v", err)
+	} else if errors.Is(err, ErrStoreIO) {
+		t.Fatalf("Unknown bundle is reported as an I/O error: %v", err)
+	}
+
+	bd, err := bst.InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(storePath, "bundles", bd.SerialisedFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bst.loadEntireBundle(bd.SerialisedFileName, bd.Compressed); !errors.Is(err, ErrBundleNotFound) {
+		t.Fatalf("Loading a bundle without its serialised file resulted in %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(storePath, "bundles", bd.SerialisedFileName), []byte{0xff}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	var corrupt *CorruptBundleError
+	if _, err := bst.loadEntireBundle(bd.SerialisedFileName, bd.Compressed); !errors.As(err, &corrupt) {
+		t.Fatalf("Loading an unparsable bundle resulted in %v", err)
+	}
+
+	if err := bst.DeleteBundle(bd); err != nil {
+		t.Fatal(err)
+	}
+	if err := bst.DeleteBundle(bd); !errors.Is(err, ErrBundleNotFound) {
+		t.Fatalf("Deleting a deleted bundle resulted in %v", err)
+	}
+
+	// A closed metadata database stands in for a failing disk
+	if err := bst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bst.LoadBundleDescriptor(bundle.ID()); !errors.Is(err, ErrStoreIO) {
+		t.Fatalf("Loading from a closed store resulted in %v", err)
+	} else if errors.Is(err, ErrBundleNotFound) {
+		t.Fatalf("I/O error is reported as an unknown bundle: %v", err)
+	}
+	if _, err := bst.GetExpired(); !errors.Is(err, ErrStoreIO) {
+		t.Fatalf("Querying a closed store resulted in %v", err)
+	}
+}
+
+func TestStoreInUse(t *testing.T) {
+	path := t.TempDir()
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+
+	first, err := openStore(nodeID, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another dtnd would open the same store in its own process, which is equivalent to a second lock in this one
+	if _, err := openStore(nodeID, path); !errors.Is(err, ErrStoreInUse) {
+		t.Fatalf("Opening a store twice did not fail with ErrStoreInUse, but %v", err)
+	} else if !strings.Contains(err.Error(), fmt.Sprint(os.Getpid())) {
+		t.Fatalf("Error %q does not name the locking process", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := openStore(nodeID, path)
+	if err != nil {
+		t.Fatalf("Opening a closed store failed: %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverForwardPending(t *testing.T) {
+	path := t.TempDir()
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+
+	if err := InitialiseStore(nodeID, path); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bd, err := GetStoreSingleton().InsertBundle(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash while forwarding leaves the bundle in this state
+	if err := bd.AddConstraint(ForwardPending); err != nil {
+		t.Fatal(err)
+	}
+	if err := bd.RemoveConstraint(DispatchPending); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetStoreSingleton().Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitialiseStore(nodeID, path); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	bdLoad, err := GetStoreSingleton().LoadBundleDescriptor(bundle.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bdLoad.Dispatch || !bdLoad.Retain || !reflect.DeepEqual(bdLoad.RetentionConstraints, []Constraint{DispatchPending}) {
+		t.Fatalf("Bundle was not recovered: dispatch %t, retain %t, constraints %v",
+			bdLoad.Dispatch, bdLoad.Retain, bdLoad.RetentionConstraints)
+	}
+
+	dispatchable, err := GetStoreSingleton().GetDispatchable()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(dispatchable) != 1 || dispatchable[0].ID != bundle.ID() {
+		t.Fatalf("Recovered bundle is not dispatchable: %v", dispatchable)
+	}
+}
+
+func TestGarbageCollectFakeClock(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+
+	creation := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return creation }
+
+	bndl, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampTime(creation).
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd, err := GetStoreSingleton().InsertBundle(&bndl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bd.ReceivedAt.Equal(creation) {
+		t.Fatalf("Bundle was received at %v instead of %v", bd.ReceivedAt, creation)
+	}
+	if err := bd.ResetConstraints(); err != nil {
+		t.Fatal(err)
+	}
+
+	now = func() time.Time { return creation.Add(time.Hour - time.Second) }
+	if deleted, err := GetStoreSingleton().GarbageCollect(); err != nil || len(deleted) != 0 {
+		t.Fatalf("Garbage collection before expiry deleted %v: %v", deleted, err)
+	}
+
+	now = func() time.Time { return creation.Add(time.Hour + time.Second) }
+	if deleted, err := GetStoreSingleton().GarbageCollect(); err != nil || len(deleted) != 1 {
+		t.Fatalf("Garbage collection after expiry deleted %v: %v", deleted, err)
+	}
+	if _, err := GetStoreSingleton().LoadBundleDescriptor(bndl.ID()); err == nil {
+		t.Fatal("Expired bundle is still stored")
+	}
+}
+
+func TestGarbageCollectGracePeriod(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	if err := InitialiseStore(bpv7.MustNewEndpointID("dtn://node/"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer GetStoreSingleton().Close()
+	GetStoreSingleton().SetGracePeriod(10 * time.Minute)
+
+	creation := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return creation }
+
+	var bundles []bpv7.Bundle
+	for _, lifetime := range []string{"1h", "2h"} {
+		bndl, err := bpv7.Builder().
+			Source("dtn://src-" + lifetime + "/").
+			Destination("dtn://dst/").
+			CreationTimestampTime(creation).
+			Lifetime(lifetime).
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bd, err := GetStoreSingleton().InsertBundle(&bndl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bd.ResetConstraints(); err != nil {
+			t.Fatal(err)
+		}
+		bundles = append(bundles, bndl)
+	}
+
+	// The first bundle is beyond its grace period, while the second one expired just now
+	now = func() time.Time { return creation.Add(2*time.Hour + time.Second) }
+	deleted, err := GetStoreSingleton().GarbageCollect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != bundles[0].ID() {
+		t.Fatalf("Garbage collection deleted %v instead of only %v", deleted, bundles[0].ID())
+	}
+	if _, err := GetStoreSingleton().LoadBundleDescriptor(bundles[1].ID()); err != nil {
+		t.Fatalf("Bundle within its grace period was deleted: %v", err)
+	}
+
+	// A just expired bundle is still forwarded within the clock skew tolerance, as receivers accept it
+	if due, err := GetStoreSingleton().GetDueForDispatch(now()); err != nil || len(due) != 1 {
+		t.Fatalf("Bundle expired within the clock skew tolerance is not due: %v, %v", due, err)
+	}
+	now = func() time.Time { return creation.Add(2*time.Hour + bpv7.ClockSkewTolerance() + time.Second) }
+	if due, err := GetStoreSingleton().GetDueForDispatch(now()); err != nil || len(due) != 0 {
+		t.Fatalf("Bundle expired beyond the clock skew tolerance is due: %v, %v", due, err)
+	}
+
+	now = func() time.Time { return creation.Add(2*time.Hour + 10*time.Minute + time.Second) }
+	if deleted, err := GetStoreSingleton().GarbageCollect(); err != nil || len(deleted) != 1 {
+		t.Fatalf("Garbage collection after the grace period deleted %v: %v", deleted, err)
+	}
+}
+
+func TestRekeyBundleIDs(t *testing.T) {
+	path := t.TempDir()
+	nodeID := bpv7.MustNewEndpointID("dtn://node/")
+
+	if err := InitialiseStore(nodeID, path); err != nil {
+		t.Fatal(err)
+	}
+	bst := GetStoreSingleton()
+
+	bundle, err := bpv7.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("10m").
+		PayloadBlock(make([]byte, 1024)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragments, err := bundle.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment := fragments[1]
+
+	bd, err := bst.InsertBundle(&fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Previously, a fragment's offset and length were dash-separated in its key
+	bid := fragment.ID()
+	legacyKey := fmt.Sprintf("%v-%d-%d-%d-%d", bid.SourceNode, bid.Timestamp[0], bid.Timestamp[1],
+		bid.FragmentOffset, bid.TotalDataLength)
+	if err := bst.metadataStore.Delete(bd.IDString, BundleDescriptor{}); err != nil {
+		t.Fatal(err)
+	}
+	bd.Bundle = nil
+	bd.IDString = legacyKey
+	if err := bst.metadataStore.Insert(legacyKey, bd); err != nil {
+		t.Fatal(err)
+	}
+	if err := bst.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitialiseStore(nodeID, path); err != nil {
+		t.Fatal(err)
+	}
+	bst = GetStoreSingleton()
+	defer bst.Close()
+
+	bdLoad, err := bst.LoadBundleDescriptor(bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bdLoad.IDString != bid.String() {
+		t.Fatalf("Fragment is stored as %q instead of %q", bdLoad.IDString, bid.String())
+	}
+	if loaded, err := bdLoad.Load(); err != nil {
+		t.Fatal(err)
+	} else if loaded.ID() != bid {
+		t.Fatalf("Loaded fragment %v differs from %v", loaded.ID(), bid)
+	}
+
+	stats, err := bst.Stats()
+	if err != nil {
+		t.Fatal(err)
+	} else if stats.Bundles != 1 {
+		t.Fatalf("Expected one stored bundle, got %d", stats.Bundles)
+	}
+}
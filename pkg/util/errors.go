@@ -12,3 +12,14 @@ func NewAlreadyInitialisedError(name string) *AlreadyInitialised {
 	err := AlreadyInitialised(name)
 	return &err
 }
+
+type NotInitialised string
+
+func (err *NotInitialised) Error() string {
+	return fmt.Sprintf("%s is not initialised", string(*err))
+}
+
+func NewNotInitialisedError(name string) *NotInitialised {
+	err := NotInitialised(name)
+	return &err
+}
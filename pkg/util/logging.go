@@ -0,0 +1,147 @@
+package util
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ModuleField is the logrus field naming the module a log entry originates from, e.g., "cla/quicl".
+//
+// If an entry does not set this field, it is derived from the calling package.
+const ModuleField = "module"
+
+// CorrelationField is the logrus field carrying the ID of the bundle being processed.
+//
+// All log entries along a bundle's path, from its reception over forwarding to its transmission by a CLA, carry this
+// field. It is named like the "bundle" field of all other log entries about a bundle, e.g., by the store. Thus,
+// grepping for one bundle ID shows the bundle's whole path through this node.
+const CorrelationField = "bundle"
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a child context carrying the correlation ID, e.g., a bundle ID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the context's correlation ID, if one was set.
+func CorrelationID(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(correlationKey{}).(string)
+	return
+}
+
+// LogEntry returns a logrus entry for the context, carrying the correlation ID in the CorrelationField, if one was set.
+func LogEntry(ctx context.Context) *log.Entry {
+	entry := log.WithContext(ctx)
+	if id, ok := CorrelationID(ctx); ok {
+		entry = entry.WithField(CorrelationField, id)
+	}
+	return entry
+}
+
+const (
+	logrusPackage = "github.com/sirupsen/logrus"
+	modulePrefix  = "github.com/dtn7/dtn7-go/"
+)
+
+// SetModuleLevels configures the logger to use individual log levels for some modules.
+//
+// An override for a module also applies to its sub-modules, e.g., "cla" for "cla/quicl", while the most specific
+// override wins. Entries of modules without an override are logged based on the global level.
+func SetModuleLevels(logger *log.Logger, global log.Level, modules map[string]log.Level) {
+	if len(modules) == 0 {
+		logger.SetLevel(global)
+		return
+	}
+
+	// The logger's own level must let pass everything any module might log; filtering happens while formatting.
+	maxLevel := global
+	for _, level := range modules {
+		if level > maxLevel {
+			maxLevel = level
+		}
+	}
+	logger.SetLevel(maxLevel)
+
+	logger.AddHook(&moduleHook{})
+	logger.SetFormatter(&moduleFormatter{
+		Formatter: logger.Formatter,
+		global:    global,
+		modules:   modules,
+	})
+}
+
+// moduleHook adds the ModuleField to entries which do not carry one.
+type moduleHook struct{}
+
+func (mh *moduleHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (mh *moduleHook) Fire(entry *log.Entry) error {
+	if _, ok := entry.Data[ModuleField]; !ok {
+		entry.Data[ModuleField] = callerModule()
+	}
+	return nil
+}
+
+// callerModule returns the module of the function which called into logrus.
+func callerModule() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	inLogrus := false
+	for {
+		frame, more := frames.Next()
+		pkg := packageName(frame.Function)
+
+		if strings.HasPrefix(pkg, logrusPackage) {
+			inLogrus = true
+		} else if inLogrus {
+			return strings.TrimPrefix(strings.TrimPrefix(pkg, modulePrefix+"pkg/"), modulePrefix+"cmd/")
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
+
+// packageName extracts the package path from a fully qualified function name.
+func packageName(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[lastSlash+1:], "."); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
+}
+
+// moduleFormatter drops entries which are too verbose for their module before passing them on.
+type moduleFormatter struct {
+	log.Formatter
+
+	global  log.Level
+	modules map[string]log.Level
+}
+
+func (mf *moduleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	module, _ := entry.Data[ModuleField].(string)
+	if entry.Level > mf.levelFor(module) {
+		return nil, nil
+	}
+	return mf.Formatter.Format(entry)
+}
+
+// levelFor returns the level of the most specific override for the module, or the global level.
+func (mf *moduleFormatter) levelFor(module string) log.Level {
+	level, matchLen := mf.global, -1
+	for name, moduleLevel := range mf.modules {
+		if (module == name || strings.HasPrefix(module, name+"/")) && len(name) > matchLen {
+			level, matchLen = moduleLevel, len(name)
+		}
+	}
+	return level
+}
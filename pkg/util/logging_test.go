@@ -0,0 +1,55 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSetModuleLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+
+	SetModuleLevels(logger, log.InfoLevel, map[string]log.Level{
+		"cla":   log.DebugLevel,
+		"store": log.WarnLevel,
+	})
+
+	tests := []struct {
+		module  string
+		level   log.Level
+		message string
+		logged  bool
+	}{
+		{"cla/quicl", log.DebugLevel, "quicl debug", true},
+		{"cla", log.TraceLevel, "cla trace", false},
+		{"store", log.InfoLevel, "store info", false},
+		{"store", log.WarnLevel, "store warning", true},
+		{"routing", log.DebugLevel, "routing debug", false},
+		{"routing", log.InfoLevel, "routing info", true},
+		{"", log.DebugLevel, "caller debug", false},
+		{"", log.InfoLevel, "caller info", true},
+	}
+
+	for _, test := range tests {
+		entry := log.NewEntry(logger)
+		if test.module != "" {
+			entry = entry.WithField(ModuleField, test.module)
+		}
+		entry.Log(test.level, test.message)
+	}
+
+	output := buf.String()
+	for _, test := range tests {
+		if logged := strings.Contains(output, test.message); logged != test.logged {
+			t.Errorf("message %q of module %q logged: %t, expected %t", test.message, test.module, logged, test.logged)
+		}
+	}
+
+	if !strings.Contains(output, ModuleField+"=util") {
+		t.Errorf("module was not derived from the caller:\n%s", output)
+	}
+}